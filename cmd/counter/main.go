@@ -0,0 +1,10 @@
+// counter 命令行工具，抓取一次 hive 表的大小，或者通过子命令查询、导出和运行其他计数器
+package main
+
+import (
+	"github.com/rea1shane/counter/internal/app"
+)
+
+func main() {
+	app.Main()
+}
@@ -0,0 +1,11 @@
+// counterd 常驻服务，与 counter serve 相同，定时抓取并提供 HTTP、gRPC API
+package main
+
+import (
+	"github.com/rea1shane/counter/internal/app"
+	"os"
+)
+
+func main() {
+	app.Serve(os.Args[1:])
+}
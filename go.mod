@@ -3,24 +3,40 @@ module github.com/rea1shane/counter
 go 1.17
 
 require (
+	github.com/apache/thrift v0.14.1
 	github.com/beltran/gohive v1.5.4
 	github.com/colinmarc/hdfs v1.1.3
+	github.com/go-sql-driver/mysql v1.7.0
+	github.com/go-zookeeper/zk v1.0.1
+	github.com/golang/snappy v0.0.4
 	github.com/morikuni/failure v1.1.2
+	github.com/segmentio/kafka-go v0.4.38
+	google.golang.org/grpc v1.47.0
+	google.golang.org/protobuf v1.27.1
 	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/mysql v1.4.7
+	gorm.io/driver/postgres v1.4.8
+	gorm.io/driver/sqlite v1.4.4
 	gorm.io/gorm v1.24.6
 )
 
 require (
-	github.com/apache/thrift v0.14.1 // indirect
 	github.com/beltran/gosasl v0.0.0-20200715011608-d5475aebb293 // indirect
 	github.com/beltran/gssapi v0.0.0-20200324152954-d86554db4bab // indirect
-	github.com/go-sql-driver/mysql v1.7.0 // indirect
-	github.com/go-zookeeper/zk v1.0.1 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/pgx/v5 v5.3.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/mattn/go-sqlite3 v1.14.15 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/stretchr/testify v1.8.2 // indirect
-	google.golang.org/protobuf v1.26.0 // indirect
+	golang.org/x/crypto v0.6.0 // indirect
+	golang.org/x/net v0.6.0 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+	golang.org/x/text v0.7.0 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 )
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/hivemeta"
+	"github.com/rea1shane/counter/pkg/model"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// defaultAcidMaxDeltas 与 Hive 的 hive.compactor.delta.num.threshold 默认值一致
+const defaultAcidMaxDeltas = 10
+
+// ACID 表目录的前缀，delete_delta_ 需要先于 delta_ 判断
+const (
+	acidBase        = "base_"
+	acidDelta       = "delta_"
+	acidDeleteDelta = "delete_delta_"
+)
+
+// isTransactional 表属性 transactional 为 true 的表为 ACID 表
+func isTransactional(t *hivemeta.Table) bool {
+	return strings.EqualFold(t.Properties["transactional"], "true")
+}
+
+// acidDirKind 返回 base_、delta_ 或 delete_delta_，其他目录返回空
+func acidDirKind(name string) string {
+	for _, kind := range []string{acidDeleteDelta, acidBase, acidDelta} {
+		if strings.HasPrefix(name, kind) {
+			return kind
+		}
+	}
+	return ""
+}
+
+// measureAcid 遍历事务表目录，将大小拆分为 base、delta 和 delete_delta 写入 extra，并记录等待 compaction 的 delta 目录数量。
+// 分区表的 delta 目录在每个分区目录下，按所在目录分别计数，单个分区中最多的数量即为 compaction 的积压
+func measureAcid(client *hdfs.Client, entity *model.Hive) error {
+	root, err := hdfsPath(entity.Location)
+	if err != nil {
+		return err
+	}
+	root = strings.TrimSuffix(root, "/")
+	sizes := make(map[string]int64)
+	deltas := make(map[string]int64)
+	err = client.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if kind := acidDirKind(info.Name()); kind == acidDelta || kind == acidDeleteDelta {
+				deltas[path.Dir(name)]++
+			}
+			return nil
+		}
+		// 文件归属于路径中最近的 ACID 目录，不在 ACID 目录下的文件（例如转换为事务表之前的原始文件）不拆分
+		dirs := strings.Split(strings.TrimPrefix(path.Dir(name), root), "/")
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if kind := acidDirKind(dirs[i]); kind != "" {
+				sizes[kind] += info.Size()
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return failure.Wrap(err)
+	}
+
+	var total, max int64
+	for _, n := range deltas {
+		total += n
+		if n > max {
+			max = n
+		}
+	}
+	entity.SetExtra(model.ExtraBaseSize, sizes[acidBase])
+	entity.SetExtra(model.ExtraDeltaSize, sizes[acidDelta])
+	entity.SetExtra(model.ExtraDeleteDeltaSize, sizes[acidDeleteDelta])
+	entity.SetExtra(model.ExtraDeltaDirs, total)
+	entity.SetExtra(model.ExtraMaxDeltaDirs, max)
+	return nil
+}
+
+// compactionBacklog 单个分区中 delta 目录数量超过 acid.max_deltas 的事务表
+type compactionBacklog struct {
+	record      *model.Hive
+	deltaDirs   int64
+	maxDeltas   int64
+	baseSize    int64
+	deltaSize   int64
+	deleteDelta int64
+}
+
+// findCompactionBacklog 按单个分区中最多的 delta 目录数量降序排列
+func findCompactionBacklog(records []*model.Hive) []*compactionBacklog {
+	var backlogs []*compactionBacklog
+	for _, record := range records {
+		maxDeltas, ok := record.Extra.Int64(model.ExtraMaxDeltaDirs)
+		if !ok || maxDeltas < int64(config.Acid.MaxDeltas) {
+			continue
+		}
+		backlog := &compactionBacklog{record: record, maxDeltas: maxDeltas}
+		backlog.deltaDirs, _ = record.Extra.Int64(model.ExtraDeltaDirs)
+		backlog.baseSize, _ = record.Extra.Int64(model.ExtraBaseSize)
+		backlog.deltaSize, _ = record.Extra.Int64(model.ExtraDeltaSize)
+		backlog.deleteDelta, _ = record.Extra.Int64(model.ExtraDeleteDeltaSize)
+		backlogs = append(backlogs, backlog)
+	}
+	sort.SliceStable(backlogs, func(i, j int) bool {
+		return backlogs[i].maxDeltas > backlogs[j].maxDeltas
+	})
+	return backlogs
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/rea1shane/counter/pkg/sink"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// hiveDbUpsert 同一天重复运行时覆盖
+var hiveDbUpsert = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "db"}, {Name: "date"}},
+	DoUpdates: clause.AssignmentColumns([]string{"size", "tables", "failures", "delta"}),
+}
+
+// saveSummaries 写入后在数据库中生成汇总表、增长趋势和生命周期事件
+func saveSummaries(ctx context.Context, date time.Time, entities []*model.Hive) error {
+	store, err := openStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if config.Aggregate.Db {
+		err = saveDbAggregates(ctx, store, date, entities)
+		if err != nil {
+			return err
+		}
+	}
+	if config.Aggregate.Cluster {
+		err = saveClusterAggregate(ctx, store, date, entities)
+		if err != nil {
+			return err
+		}
+	}
+	if config.Trend.Enabled {
+		_, err = updateTrends(ctx, store, date)
+		if err != nil {
+			return err
+		}
+	}
+	if config.Lifecycle.Enabled {
+		err = saveLifecycle(ctx, store, date, entities)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hiveClusterUpsert 同一天重复运行时覆盖
+var hiveClusterUpsert = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "cluster"}, {Name: "date"}},
+	DoUpdates: clause.AssignmentColumns([]string{"size", "raw_size", "dbs", "tables", "failures", "delta"}),
+}
+
+// defaultClusterName 没有配置集群名称时使用
+const defaultClusterName = "default"
+
+// clusterName 多个集群写入同一个数据库时用于区分
+func clusterName() string {
+	if config.Cluster.Name != "" {
+		return config.Cluster.Name
+	}
+	return defaultClusterName
+}
+
+// saveDbAggregates 按库汇总本次运行的结果写入 hive_db 表，并与前一天的汇总比较
+func saveDbAggregates(ctx context.Context, store *sink.Gorm, date time.Time, entities []*model.Hive) error {
+	err := store.Migrate(ctx, &model.HiveDb{})
+	if err != nil {
+		return err
+	}
+
+	var yesterday []*model.HiveDb
+	err = store.DB().WithContext(ctx).Where("date = ?", date.AddDate(0, 0, -1)).Find(&yesterday).Error
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	previous := make(map[string]int64, len(yesterday))
+	for _, db := range yesterday {
+		previous[db.Db] = db.Size
+	}
+
+	aggregates := aggregateDbs(entities, date)
+	if len(aggregates) == 0 {
+		return nil
+	}
+	for _, aggregate := range aggregates {
+		if size, ok := previous[aggregate.Db]; ok {
+			delta := aggregate.Size - size
+			aggregate.Delta = &delta
+		}
+	}
+	return failure.Wrap(store.DB().WithContext(ctx).Clauses(hiveDbUpsert).CreateInBatches(aggregates, 1000).Error)
+}
+
+func aggregateDbs(entities []*model.Hive, date time.Time) []*model.HiveDb {
+	var (
+		aggregates []*model.HiveDb
+		index      = make(map[string]*model.HiveDb)
+	)
+	for _, entity := range entities {
+		aggregate, ok := index[entity.Db]
+		if !ok {
+			aggregate = &model.HiveDb{Db: entity.Db, Date: date}
+			index[entity.Db] = aggregate
+			aggregates = append(aggregates, aggregate)
+		}
+		aggregate.Tables++
+		if entity.Desc != "" {
+			aggregate.Failures++
+		}
+		if entity.Size > 0 {
+			aggregate.Size += entity.Size
+		}
+	}
+	return aggregates
+}
+
+// saveClusterAggregate 汇总整个集群写入 hive_cluster 表，并与前一天的汇总比较
+func saveClusterAggregate(ctx context.Context, store *sink.Gorm, date time.Time, entities []*model.Hive) error {
+	err := store.Migrate(ctx, &model.HiveCluster{})
+	if err != nil {
+		return err
+	}
+
+	aggregate := &model.HiveCluster{Cluster: clusterName(), Date: date}
+	dbs := make(map[string]bool)
+	for _, entity := range entities {
+		dbs[entity.Db] = true
+		aggregate.Tables++
+		if entity.Desc != "" {
+			aggregate.Failures++
+		}
+		if entity.Size > 0 {
+			aggregate.Size += entity.Size
+		}
+		if rawSize, ok := entity.Extra.Int64(model.ExtraRawSize); ok && rawSize > 0 {
+			aggregate.RawSize += rawSize
+		}
+	}
+	aggregate.Dbs = int64(len(dbs))
+
+	var yesterday []*model.HiveCluster
+	err = store.DB().WithContext(ctx).Where("cluster = ? AND date = ?", aggregate.Cluster, date.AddDate(0, 0, -1)).Find(&yesterday).Error
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	if len(yesterday) > 0 {
+		delta := aggregate.Size - yesterday[0].Size
+		aggregate.Delta = &delta
+	}
+	return failure.Wrap(store.DB().WithContext(ctx).Clauses(hiveClusterUpsert).Create(aggregate).Error)
+}
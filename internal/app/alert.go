@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// threshold 大小阈值，配置了 table 时检查匹配的每张表，否则检查匹配的库的总大小
+//
+// db 匹配库名，table 匹配 db.table，均支持 path.Match 的通配符。
+type threshold struct {
+	Db    string   `yaml:"db"`
+	Table string   `yaml:"table"`
+	Size  quantity `yaml:"size"`
+}
+
+// checkThresholds 返回超过阈值的库和表，每个库或表只报告第一个超过的阈值
+func checkThresholds(entities []*model.Hive) []string {
+	var (
+		dbs     []string
+		dbSizes = make(map[string]int64)
+	)
+	for _, entity := range entities {
+		if _, ok := dbSizes[entity.Db]; !ok {
+			dbs = append(dbs, entity.Db)
+		}
+		if entity.Size > 0 {
+			dbSizes[entity.Db] += entity.Size
+		}
+	}
+	sort.Strings(dbs)
+
+	var (
+		alerts   []string
+		reported = make(map[string]bool)
+	)
+	for _, rule := range config.Alert.Thresholds {
+		if rule.Table != "" {
+			for _, entity := range entities {
+				name := entity.Db + "." + entity.Table
+				if reported[name] || entity.Size <= int64(rule.Size) {
+					continue
+				}
+				if ok, _ := path.Match(rule.Table, name); ok {
+					reported[name] = true
+					alerts = append(alerts, fmt.Sprintf("表 %s 大小 %s 超过阈值 %s", name, formatBytes(entity.Size), formatBytes(int64(rule.Size))))
+				}
+			}
+			continue
+		}
+		pattern := rule.Db
+		if pattern == "" {
+			pattern = "*"
+		}
+		for _, db := range dbs {
+			if reported[db] || dbSizes[db] <= int64(rule.Size) {
+				continue
+			}
+			if ok, _ := path.Match(pattern, db); ok {
+				reported[db] = true
+				alerts = append(alerts, fmt.Sprintf("库 %s 大小 %s 超过阈值 %s", db, formatBytes(dbSizes[db]), formatBytes(int64(rule.Size))))
+			}
+		}
+	}
+	return alerts
+}
+
+// checkAnomalies 与数据库中前一天的结果比较，返回大小变化超过阈值的表，新建、删除和获取失败的表不检查
+func checkAnomalies(ctx context.Context, date time.Time, entities []*model.Hive) []string {
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Println("检查异常变化失败: " + err.Error())
+		return nil
+	}
+	defer store.Close()
+	previous, err := loadRecords(ctx, store, date.AddDate(0, 0, -1))
+	if err != nil {
+		log.Println("读取前一天的结果失败: " + err.Error())
+		return nil
+	}
+
+	anomaly := config.Alert.Anomaly
+	var alerts []string
+	for _, d := range diffTables(withoutSkipped(previous), withoutSkipped(entities)) {
+		if d.status != "" {
+			continue
+		}
+		delta := d.delta()
+		if delta < 0 {
+			delta = -delta
+		}
+		exceeded := anomaly.Size > 0 && delta > int64(anomaly.Size)
+		if p := d.percent(); !exceeded && anomaly.Percent > 0 && p != nil && (d.from >= int64(anomaly.MinSize) || d.to >= int64(anomaly.MinSize)) {
+			exceeded = *p > anomaly.Percent || *p < -anomaly.Percent
+		}
+		if exceeded {
+			alerts = append(alerts, fmt.Sprintf("表 %s.%s 从 %s 变为 %s (%s, %s)", d.db, d.table, formatBytes(d.from), formatBytes(d.to), signedBytes(d.delta()), formatValue(d.percent(), true)))
+		}
+	}
+	return alerts
+}
+
+// sendAlert 通过群机器人和邮件发送告警
+func sendAlert(title string, alerts []string) {
+	text := strings.Join(alerts, "\n") + "\n"
+	if len(config.Notify.Webhooks) > 0 {
+		err := notify(title, text)
+		if err != nil {
+			log.Println("发送告警失败: " + err.Error())
+		}
+	}
+	if config.Email.Enabled {
+		subject := title
+		if config.Email.SubjectPrefix != "" {
+			subject = config.Email.SubjectPrefix + " " + subject
+		}
+		message, err := buildEmail(subject, text, nil)
+		if err == nil {
+			err = sendEmail(message)
+		}
+		if err != nil {
+			log.Println("发送告警邮件失败: " + err.Error())
+		}
+	}
+}
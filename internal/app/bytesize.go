@@ -0,0 +1,56 @@
+package app
+
+import (
+	"fmt"
+	"github.com/morikuni/failure"
+	"gopkg.in/yaml.v3"
+	"strconv"
+	"strings"
+)
+
+// byteUnits KB、MB 等为十进制，KiB、MiB 等为二进制
+var byteUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"PB":  1e15,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+	"PIB": 1 << 50,
+}
+
+// quantity 配置文件中的大小，可以是 bytes 数值，也可以带单位，例如 500TB、20TiB
+type quantity int64
+
+func (q *quantity) UnmarshalYAML(node *yaml.Node) error {
+	size, err := parseByteSize(node.Value)
+	if err != nil {
+		return err
+	}
+	*q = quantity(size)
+	return nil
+}
+
+func parseByteSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	i := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(value)
+	}
+	number, err := strconv.ParseFloat(value[:i], 64)
+	if err != nil {
+		return 0, failure.Wrap(fmt.Errorf("invalid size: %s", value))
+	}
+	unit, ok := byteUnits[strings.ToUpper(strings.TrimSpace(value[i:]))]
+	if !ok {
+		return 0, failure.Wrap(fmt.Errorf("invalid size unit: %s", value))
+	}
+	return int64(number * unit), nil
+}
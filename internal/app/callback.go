@@ -0,0 +1,96 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+// callbackWebhook 运行结束后接收结果的地址，用于 Airflow、DolphinScheduler 等调度系统触发下游任务
+type callbackWebhook struct {
+	Url string `yaml:"url"`
+	// Headers 例如鉴权使用的请求头
+	Headers map[string]string `yaml:"headers"`
+	Timeout time.Duration     `yaml:"timeout"`
+}
+
+// callbackPayload 回调的请求体
+type callbackPayload struct {
+	RunID           string          `json:"run_id"`
+	Status          string          `json:"status"`
+	Error           string          `json:"error,omitempty"`
+	Date            string          `json:"date"`
+	Start           time.Time       `json:"start"`
+	End             time.Time       `json:"end"`
+	DurationSeconds float64         `json:"duration_seconds"`
+	Tables          int             `json:"tables"`
+	Size            int64           `json:"size"`
+	Failures        int             `json:"failures"`
+	Filter          json.RawMessage `json:"filter,omitempty"`
+	Shard           string          `json:"shard,omitempty"`
+}
+
+// callback 向所有配置的回调地址发送本次运行的结果，单个地址失败只记录日志
+func callback(record *model.HiveRun) {
+	payload := callbackPayload{
+		RunID:           record.RunID,
+		Status:          record.Status,
+		Error:           record.Error,
+		Date:            record.Date.Format("2006-01-02"),
+		Start:           record.Start,
+		End:             record.End,
+		DurationSeconds: record.DurationSeconds,
+		Tables:          record.Tables,
+		Size:            record.Size,
+		Failures:        record.Failures,
+		Shard:           record.Shard,
+	}
+	if record.Filter != "" {
+		payload.Filter = json.RawMessage(record.Filter)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("编码回调失败: %+v", failure.Wrap(err))
+		return
+	}
+
+	for _, hook := range config.Callback.Webhooks {
+		err = hook.send(body)
+		if err != nil {
+			log.Printf("回调 %s 失败: %+v", hook.Url, err)
+		}
+	}
+}
+
+func (h callbackWebhook) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, h.Url, bytes.NewReader(body))
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range h.Headers {
+		req.Header.Set(key, value)
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return failure.Wrap(fmt.Errorf("callback failed with %s: %s", resp.Status, bytes.TrimSpace(message)))
+	}
+	return nil
+}
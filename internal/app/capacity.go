@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultCapacityTimeout = 30 * time.Second
+
+// fsNamesystemBean NameNode /jmx 中 Hadoop:service=NameNode,name=FSNamesystem 的字段
+type fsNamesystemBean struct {
+	HAState               string `json:"tag.HAState"`
+	CapacityTotal         int64  `json:"CapacityTotal"`
+	CapacityUsed          int64  `json:"CapacityUsed"`
+	CapacityRemaining     int64  `json:"CapacityRemaining"`
+	CapacityUsedNonDFS    int64  `json:"CapacityUsedNonDFS"`
+	BlocksTotal           int64  `json:"BlocksTotal"`
+	FilesTotal            int64  `json:"FilesTotal"`
+	MissingBlocks         int64  `json:"MissingBlocks"`
+	UnderReplicatedBlocks int64  `json:"UnderReplicatedBlocks"`
+	NumLiveDataNodes      int    `json:"NumLiveDataNodes"`
+	NumDeadDataNodes      int    `json:"NumDeadDataNodes"`
+}
+
+// capacityCounter 通过 NameNode JMX 记录整个集群每天的容量
+var capacityCounter = &counter{
+	name:  "capacity",
+	usage: "通过 capacity.namenodes 的 /jmx 接口记录 HDFS 集群的容量、块数量和文件数量并保存到 hdfs_capacity 表",
+	model: &model.HdfsCapacity{},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		record, err := countCapacity(ctx, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if config.Runway.AlertDays > 0 && storeConfigured() {
+			err = checkRunway(ctx, record)
+			if err != nil {
+				log.Printf("计算容量剩余天数失败: %+v", err)
+			}
+		}
+		header := []string{"namenode", "capacity", "used", "used_percent", "remaining", "non_dfs_used", "blocks", "files", "missing_blocks", "under_replicated_blocks", "live_datanodes", "dead_datanodes"}
+		rows := [][]interface{}{{
+			record.Namenode, byteSize(record.Capacity), byteSize(record.Used), sizePercent(record.Used, record.Capacity),
+			byteSize(record.Remaining), byteSize(record.NonDfsUsed), record.Blocks, record.Files,
+			record.MissingBlocks, record.UnderReplicatedBlocks, record.LiveDatanodes, record.DeadDatanodes,
+		}}
+		return []*model.HdfsCapacity{record}, header, rows, nil
+	},
+}
+
+// countCapacity 使用第一个 active NameNode 的数据
+func countCapacity(ctx context.Context, date time.Time) (*model.HdfsCapacity, error) {
+	address, bean, err := activeNamenode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &model.HdfsCapacity{
+		Namenode:              address,
+		Capacity:              bean.CapacityTotal,
+		Used:                  bean.CapacityUsed,
+		Remaining:             bean.CapacityRemaining,
+		NonDfsUsed:            bean.CapacityUsedNonDFS,
+		Blocks:                bean.BlocksTotal,
+		Files:                 bean.FilesTotal,
+		MissingBlocks:         bean.MissingBlocks,
+		UnderReplicatedBlocks: bean.UnderReplicatedBlocks,
+		LiveDatanodes:         bean.NumLiveDataNodes,
+		DeadDatanodes:         bean.NumDeadDataNodes,
+		Date:                  date,
+	}, nil
+}
+
+// activeNamenode 依次访问 capacity.namenodes，返回第一个 active NameNode 的地址和 FSNamesystem，standby 的块和容量信息可能滞后
+func activeNamenode(ctx context.Context) (string, *fsNamesystemBean, error) {
+	if len(config.Capacity.Namenodes) == 0 {
+		return "", nil, failure.Wrap(fmt.Errorf("capacity.namenodes is empty"))
+	}
+	for _, address := range config.Capacity.Namenodes {
+		var bean fsNamesystemBean
+		err := fetchJmxBean(ctx, address, "Hadoop:service=NameNode,name=FSNamesystem", &bean)
+		if err != nil {
+			log.Printf("获取 %s 的 JMX 失败: %+v", address, err)
+			continue
+		}
+		// 没有开启 HA 时 HAState 为 active 或不存在
+		if bean.HAState != "" && bean.HAState != "active" {
+			continue
+		}
+		return address, &bean, nil
+	}
+	return "", nil, failure.Wrap(fmt.Errorf("no active namenode in %s", strings.Join(config.Capacity.Namenodes, ", ")))
+}
+
+// fetchJmxBean 获取 NameNode /jmx 接口中 qry 对应的第一个 bean
+func fetchJmxBean(ctx context.Context, address, qry string, bean interface{}) error {
+	timeout := config.Capacity.Timeout
+	if timeout <= 0 {
+		timeout = defaultCapacityTimeout
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(address, "/")+"/jmx?qry="+qry, nil)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return failure.Wrap(fmt.Errorf("jmx failed with %s", resp.Status))
+	}
+
+	var result struct {
+		Beans []json.RawMessage `json:"beans"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return failure.Wrap(err)
+	}
+	if len(result.Beans) == 0 {
+		return failure.Wrap(fmt.Errorf("bean %s not found", qry))
+	}
+	return failure.Wrap(json.Unmarshal(result.Beans[0], bean))
+}
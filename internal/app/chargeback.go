@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unassignedOwner 没有匹配到归属映射的表
+const unassignedOwner = "unassigned"
+
+// chargebackTier 按路径前缀定价的存储层级，例如归档目录使用更便宜的价格
+type chargebackTier struct {
+	Name            string  `yaml:"name"`
+	Prefix          string  `yaml:"prefix"`
+	PricePerGbMonth float64 `yaml:"price_per_gb_month"`
+}
+
+type chargebackItem struct {
+	owner   string
+	tier    string
+	gbMonth float64
+	cost    float64
+}
+
+// runChargeback 计算某个月每个团队或库的存储费用
+func runChargeback(args []string) {
+	flags := flag.NewFlagSet("chargeback", flag.ExitOnError)
+	monthFlag := flags.String("month", "", "月份，格式为 2006-01，默认为上个月")
+	by := flags.String("by", "team", "统计维度，可选 team、project、cost_center、db")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	var month time.Time
+	if *monthFlag == "" {
+		now := time.Now()
+		month = time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.Local)
+	} else {
+		month, err = time.ParseInLocation("2006-01", *monthFlag, time.Local)
+		if err != nil {
+			log.Fatal("解析月份失败: " + err.Error())
+		}
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	records, err := loadRecordsBetween(ctx, store, month, month.AddDate(0, 1, -1))
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	owner := func(record *model.Hive) string {
+		if *by == "db" {
+			return record.Db
+		}
+		if value, ok := record.Extra[*by].(string); ok && value != "" {
+			return value
+		}
+		return unassignedOwner
+	}
+	items := computeChargeback(records, owner)
+
+	header := []string{"month", *by, "tier", "gb_month", "cost", "currency"}
+	var rows [][]interface{}
+	for _, item := range items {
+		rows = append(rows, []interface{}{month.Format("2006-01"), item.owner, item.tier, roundCost(item.gbMonth), roundCost(item.cost), config.Chargeback.Currency})
+	}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+// computeChargeback 用量为当月每次抓取的大小之和除以抓取的天数，月中新建或删除的表按存在的天数折算，按费用降序排列
+func computeChargeback(records []*model.Hive, owner func(*model.Hive) string) []*chargebackItem {
+	type tableUsage struct {
+		record *model.Hive
+		bytes  float64
+	}
+
+	var (
+		keys   []string
+		tables = make(map[string]*tableUsage)
+		days   = make(map[int32]bool)
+	)
+	for _, record := range records {
+		days[epochDays(record.Date)] = true
+		size := record.Size
+		if config.Chargeback.Raw {
+			rawSize, ok := record.Extra.Int64(model.ExtraRawSize)
+			if !ok {
+				continue
+			}
+			size = rawSize
+		}
+		if size < 0 {
+			continue
+		}
+		key := record.Db + "." + record.Table
+		usage, ok := tables[key]
+		if !ok {
+			usage = &tableUsage{}
+			tables[key] = usage
+			keys = append(keys, key)
+		}
+		// 以最近一天的记录确定归属和存储层级
+		if usage.record == nil || record.Date.After(usage.record.Date) {
+			usage.record = record
+		}
+		usage.bytes += float64(size)
+	}
+
+	var (
+		items []*chargebackItem
+		index = make(map[string]*chargebackItem)
+	)
+	for _, key := range keys {
+		usage := tables[key]
+		tier, price := chargebackPrice(usage.record.Location)
+		name := owner(usage.record)
+		item, ok := index[name+"\x00"+tier]
+		if !ok {
+			item = &chargebackItem{owner: name, tier: tier}
+			index[name+"\x00"+tier] = item
+			items = append(items, item)
+		}
+		gbMonth := usage.bytes / float64(len(days)) / gigabyte
+		item.gbMonth += gbMonth
+		item.cost += gbMonth * price
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].cost != items[j].cost {
+			return items[i].cost > items[j].cost
+		}
+		return items[i].owner < items[j].owner
+	})
+	return items
+}
+
+// chargebackPrice 按配置顺序匹配路径前缀，hdfs 路径不包含集群名称，都不匹配时使用默认价格
+func chargebackPrice(location string) (string, float64) {
+	path := location
+	if strings.HasPrefix(location, hdfsFlag) && strings.Count(location, "/") > 2 {
+		_, path = hdfssize.ParseLocation(location)
+	}
+	for _, tier := range config.Chargeback.Tiers {
+		if strings.HasPrefix(path, tier.Prefix) {
+			return tier.Name, tier.PricePerGbMonth
+		}
+	}
+	return "default", config.Chargeback.PricePerGbMonth
+}
+
+func roundCost(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultClickhouseTable     = "hive"
+	defaultClickhouseBatchSize = 10000
+	defaultClickhouseTimeout   = time.Minute
+)
+
+// clickhouseSink 通过 ClickHouse HTTP 接口以 JSONEachRow 格式写入数据
+type clickhouseSink struct {
+	client *http.Client
+}
+
+func newClickhouseSink() *clickhouseSink {
+	timeout := config.Clickhouse.Timeout
+	if timeout <= 0 {
+		timeout = defaultClickhouseTimeout
+	}
+	return &clickhouseSink{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *clickhouseSink) Open(ctx context.Context) error {
+	return nil
+}
+
+func (s *clickhouseSink) WriteBatch(ctx context.Context, entities []*model.Hive) error {
+	batchSize := config.Clickhouse.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultClickhouseBatchSize
+	}
+
+	for start := 0; start < len(entities); start += batchSize {
+		end := start + batchSize
+		if end > len(entities) {
+			end = len(entities)
+		}
+		if err := s.insert(ctx, entities[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clickhouseRow extra 以 JSON 字符串写入 String 列
+type clickhouseRow struct {
+	jsonRow
+	Extra string `json:"extra"`
+}
+
+func (s *clickhouseSink) insert(ctx context.Context, entities []*model.Hive) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, entity := range entities {
+		extra := []byte("{}")
+		if len(entity.Extra) > 0 {
+			data, err := json.Marshal(entity.Extra)
+			if err != nil {
+				return failure.Wrap(err)
+			}
+			extra = data
+		}
+		err := encoder.Encode(clickhouseRow{jsonRow: newJSONRow(entity), Extra: string(extra)})
+		if err != nil {
+			return failure.Wrap(err)
+		}
+	}
+
+	table := config.Clickhouse.Table
+	if table == "" {
+		table = defaultClickhouseTable
+	}
+	u, err := url.Parse(config.Clickhouse.Url)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	query := u.Query()
+	query.Set("query", fmt.Sprintf("INSERT INTO `%s` FORMAT JSONEachRow", table))
+	// 兼容没有 run_id、counter_version 等新增列的旧表
+	query.Set("input_format_skip_unknown_fields", "1")
+	if config.Clickhouse.Database != "" {
+		query.Set("database", config.Clickhouse.Database)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	if config.Clickhouse.Username != "" {
+		req.Header.Set("X-ClickHouse-User", config.Clickhouse.Username)
+		req.Header.Set("X-ClickHouse-Key", config.Clickhouse.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return failure.Wrap(fmt.Errorf("clickhouse insert failed with %s: %s", resp.Status, bytes.TrimSpace(message)))
+	}
+	return nil
+}
+
+func (s *clickhouseSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS `hive`
+(
+    `db`       LowCardinality(String) COMMENT '库名',
+    `table`    String COMMENT '表名',
+    `location` String COMMENT '路径，为空代表没有路径',
+    `size`     Int64 COMMENT '占用存储空间大小，单位 bytes，-1 表示没有路径或者获取路径错误',
+    `desc`     String COMMENT '备注',
+    `date`     Date COMMENT '抓取数据时间',
+    `extra`    String DEFAULT '{}' COMMENT '扩展属性，JSON 格式',
+    `run_id`   String DEFAULT '' COMMENT '运行 ID',
+    `counter_version` LowCardinality(String) DEFAULT '' COMMENT 'counter 版本',
+    `metadata_ms` Int64 DEFAULT 0 COMMENT '获取元数据耗时，单位毫秒',
+    `size_ms` Int64 DEFAULT 0 COMMENT '获取大小耗时，单位毫秒',
+    `delta_bytes` Nullable(Int64) COMMENT '与上一次抓取相比的变化，单位 bytes',
+    `delta_pct` Nullable(Float64) COMMENT '与上一次抓取相比的变化，单位 %'
+)
+ENGINE = ReplacingMergeTree
+PARTITION BY toYYYYMM(`date`)
+ORDER BY (`db`, `table`, `date`);
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// getLatestAccessTime 遍历路径下的所有文件，返回最近的访问时间，没有文件时为零值
+//
+// NameNode 需要开启 dfs.namenode.accesstime.precision，否则访问时间不会更新。
+func getLatestAccessTime(client *hdfs.Client, location string) (latest time.Time, err error) {
+	path, err := hdfsPath(location)
+	if err != nil {
+		return
+	}
+	err = client.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if accessTime := info.(*hdfs.FileInfo).AccessTime(); accessTime.After(latest) {
+			latest = accessTime
+		}
+		return nil
+	})
+	err = failure.Wrap(err)
+	return
+}
+
+// runCold 输出超过一定天数没有被读取的表和可以释放的空间
+func runCold(args []string) {
+	flags := flag.NewFlagSet("cold", flag.ExitOnError)
+	dateFlag := flags.String("date", "", "日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	days := flags.Int("days", 90, "超过多少天没有被读取视为冷数据")
+	limit := flags.Int("limit", 50, "输出的数量，0 表示全部输出")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	date, err := resolveDate(ctx, store, *dateFlag)
+	if err != nil {
+		log.Fatal("解析日期失败: " + err.Error())
+	}
+	records, err := loadRecords(ctx, store, date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	var (
+		cold        []*model.Hive
+		reclaimable int64
+		tracked     bool
+	)
+	deadline := date.AddDate(0, 0, -*days).Unix()
+	for _, record := range records {
+		accessTime, ok := record.Extra.Int64(model.ExtraAccessTime)
+		if !ok {
+			continue
+		}
+		tracked = true
+		if accessTime < deadline && record.Size > 0 {
+			cold = append(cold, record)
+			reclaimable += coldReclaimable(record)
+		}
+	}
+	if !tracked {
+		log.Fatal("没有访问时间数据，需要开启 hdfs.access_time 并在 NameNode 开启 dfs.namenode.accesstime.precision")
+	}
+	sort.SliceStable(cold, func(i, j int) bool {
+		return cold[i].Size > cold[j].Size
+	})
+	log.Printf("%d 张表超过 %d 天没有被读取，可以释放 %s", len(cold), *days, formatBytes(reclaimable))
+	if *limit > 0 && len(cold) > *limit {
+		cold = cold[:*limit]
+	}
+
+	header := []string{"rank", "db", "table", "location", "last_access", "idle_days", "size", "reclaimable"}
+	var rows [][]interface{}
+	for i, record := range cold {
+		accessTime, _ := record.Extra.Int64(model.ExtraAccessTime)
+		lastAccess := time.Unix(accessTime, 0)
+		rows = append(rows, []interface{}{i + 1, record.Db, record.Table, record.Location, lastAccess.Format("2006-01-02"), int(date.Sub(lastAccess).Hours() / 24), byteSize(record.Size), byteSize(coldReclaimable(record))})
+	}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+// coldReclaimable 删除后释放的空间，有包含副本的大小时使用包含副本的大小
+func coldReclaimable(record *model.Hive) int64 {
+	if rawSize, ok := record.Extra.Int64(model.ExtraRawSize); ok {
+		return rawSize
+	}
+	return record.Size
+}
@@ -0,0 +1,540 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"github.com/rea1shane/counter/internal/configfile"
+	"github.com/rea1shane/counter/internal/retry"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/plugin"
+	"github.com/rea1shane/counter/pkg/sink"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+	"time"
+)
+
+type Config struct {
+	Hive struct {
+		Username  string `yaml:"username"`
+		Password  string `yaml:"password"`
+		Zookeeper struct {
+			Quorum string `yaml:"quorum"`
+		} `yaml:"zookeeper"`
+		Retry retry.Policy `yaml:"retry"`
+		// Dialect HiveServer2 的输出格式，例如 hive2、hive3、spark3，为空时自动识别
+		Dialect string `yaml:"dialect"`
+		// Metastore SHOW CREATE TABLE 和 DESCRIBE FORMATTED 都失败时通过 metastore 的 thrift 接口 get_table 获取
+		Metastore struct {
+			Uris    string        `yaml:"uris"`
+			Timeout time.Duration `yaml:"timeout"`
+		} `yaml:"metastore"`
+		// SkipProperty 值为 true 时跳过该表的表属性
+		SkipProperty string `yaml:"skip_property"`
+		// Temporary 临时表和符合命名规则的中间表，action 为 tag 时正常统计并在 extra 中标记，为 exclude 时不写入结果
+		Temporary struct {
+			Patterns []string `yaml:"patterns"`
+			Action   string   `yaml:"action"`
+		} `yaml:"temporary"`
+	} `yaml:"hive"`
+	Hdfs struct {
+		Username   string          `yaml:"username"`
+		AccessTime bool            `yaml:"access_time"`
+		Router     hdfssize.Router `yaml:"router"`
+	} `yaml:"hdfs"`
+	Hadoop struct {
+		Conf struct {
+			Dir string `yaml:"dir"`
+		} `yaml:"conf"`
+	} `yaml:"hadoop"`
+	Sink struct {
+		// Type 已废弃，加载时合并到 Types
+		Type  string       `yaml:"type,omitempty"`
+		Types []string     `yaml:"types"`
+		Retry retry.Policy `yaml:"retry"`
+		// Options 通过 sink.Register 注册的 sink 的自定义配置，key 为 sink 名称
+		Options map[string]yaml.Node `yaml:"options"`
+	} `yaml:"sink"`
+	Mysql struct {
+		Dsn   string       `yaml:"dsn"`
+		Pool  sink.Pool    `yaml:",inline"`
+		Retry retry.Policy `yaml:"retry"`
+	} `yaml:"mysql"`
+	Postgres struct {
+		Dsn string `yaml:"dsn"`
+	} `yaml:"postgres"`
+	Sqlite struct {
+		Path string `yaml:"path"`
+	} `yaml:"sqlite"`
+	Csv struct {
+		Dir string `yaml:"dir"`
+	} `yaml:"csv"`
+	Parquet struct {
+		Dir string `yaml:"dir"`
+	} `yaml:"parquet"`
+	Kafka struct {
+		Brokers        []string      `yaml:"brokers"`
+		Topic          string        `yaml:"topic"`
+		Format         string        `yaml:"format"`
+		BatchSize      int           `yaml:"batch_size"`
+		Timeout        time.Duration `yaml:"timeout"`
+		SchemaRegistry struct {
+			Url     string `yaml:"url"`
+			Subject string `yaml:"subject"`
+		} `yaml:"schema_registry"`
+	} `yaml:"kafka"`
+	Influxdb struct {
+		Url             string        `yaml:"url"`
+		Measurement     string        `yaml:"measurement"`
+		Database        string        `yaml:"database"`
+		RetentionPolicy string        `yaml:"retention_policy"`
+		Username        string        `yaml:"username"`
+		Password        string        `yaml:"password"`
+		Org             string        `yaml:"org"`
+		Bucket          string        `yaml:"bucket"`
+		Token           string        `yaml:"token"`
+		BatchSize       int           `yaml:"batch_size"`
+		Timeout         time.Duration `yaml:"timeout"`
+	} `yaml:"influxdb"`
+	Elasticsearch struct {
+		Url         string        `yaml:"url"`
+		IndexPrefix string        `yaml:"index_prefix"`
+		Username    string        `yaml:"username"`
+		Password    string        `yaml:"password"`
+		ApiKey      string        `yaml:"api_key"`
+		BatchSize   int           `yaml:"batch_size"`
+		Timeout     time.Duration `yaml:"timeout"`
+	} `yaml:"elasticsearch"`
+	Clickhouse struct {
+		Url       string        `yaml:"url"`
+		Database  string        `yaml:"database"`
+		Table     string        `yaml:"table"`
+		Username  string        `yaml:"username"`
+		Password  string        `yaml:"password"`
+		BatchSize int           `yaml:"batch_size"`
+		Timeout   time.Duration `yaml:"timeout"`
+	} `yaml:"clickhouse"`
+	Pushgateway struct {
+		Url      string `yaml:"url"`
+		Job      string `yaml:"job"`
+		Instance string `yaml:"instance"`
+	} `yaml:"pushgateway"`
+	RemoteWrite struct {
+		Url         string            `yaml:"url"`
+		Username    string            `yaml:"username"`
+		Password    string            `yaml:"password"`
+		BearerToken string            `yaml:"bearer_token"`
+		Labels      map[string]string `yaml:"labels"`
+		Timeout     time.Duration     `yaml:"timeout"`
+	} `yaml:"remote_write"`
+	Otlp struct {
+		Endpoint           string            `yaml:"endpoint"`
+		ServiceName        string            `yaml:"service_name"`
+		ResourceAttributes map[string]string `yaml:"resource_attributes"`
+		Headers            map[string]string `yaml:"headers"`
+		Timeout            time.Duration     `yaml:"timeout"`
+		Traces             bool              `yaml:"traces"`
+	} `yaml:"otlp"`
+	Cluster struct {
+		Name string `yaml:"name"`
+	} `yaml:"cluster"`
+	Aggregate struct {
+		Db      bool `yaml:"db"`
+		Cluster bool `yaml:"cluster"`
+	} `yaml:"aggregate"`
+	Trend struct {
+		Enabled bool  `yaml:"enabled"`
+		Windows []int `yaml:"windows"`
+	} `yaml:"trend"`
+	Lifecycle struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"lifecycle"`
+	Audit struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"audit"`
+	Forecast struct {
+		History  int   `yaml:"history"`
+		Horizons []int `yaml:"horizons"`
+		Capacity int64 `yaml:"capacity"`
+	} `yaml:"forecast"`
+	SmallFiles struct {
+		AvgSize    quantity `yaml:"avg_size"`
+		MinFiles   int64    `yaml:"min_files"`
+		TargetSize quantity `yaml:"target_size"`
+	} `yaml:"small_files"`
+	PartitionSkew struct {
+		Enabled bool     `yaml:"enabled"`
+		Percent float64  `yaml:"percent"`
+		MinSize quantity `yaml:"min_size"`
+	} `yaml:"partition_skew"`
+	LargestFiles struct {
+		Enabled bool     `yaml:"enabled"`
+		Top     int      `yaml:"top"`
+		MinSize quantity `yaml:"min_size"`
+	} `yaml:"largest_files"`
+	Replication struct {
+		Enabled bool `yaml:"enabled"`
+		// Default 集群默认的副本数，为空时读取 hadoop 配置中的 dfs.replication
+		Default int `yaml:"default"`
+	} `yaml:"replication"`
+	Acid struct {
+		Enabled   bool `yaml:"enabled"`
+		MaxDeltas int  `yaml:"max_deltas"`
+	} `yaml:"acid"`
+	Prune struct {
+		Enabled bool            `yaml:"enabled"`
+		Keep    days            `yaml:"keep"`
+		Tables  map[string]days `yaml:"tables"`
+	} `yaml:"prune"`
+	Retention struct {
+		Candidates []int `yaml:"candidates"`
+		MinKeep    int   `yaml:"min_keep"`
+		Lookback   int   `yaml:"lookback"`
+	} `yaml:"retention"`
+	Chargeback struct {
+		PricePerGbMonth float64          `yaml:"price_per_gb_month"`
+		Currency        string           `yaml:"currency"`
+		Raw             bool             `yaml:"raw"`
+		Tiers           []chargebackTier `yaml:"tiers"`
+	} `yaml:"chargeback"`
+	Email struct {
+		Enabled       bool     `yaml:"enabled"`
+		Host          string   `yaml:"host"`
+		Port          int      `yaml:"port"`
+		Tls           bool     `yaml:"tls"`
+		Username      string   `yaml:"username"`
+		Password      string   `yaml:"password"`
+		From          string   `yaml:"from"`
+		To            []string `yaml:"to"`
+		SubjectPrefix string   `yaml:"subject_prefix"`
+		Attach        []string `yaml:"attach"`
+	} `yaml:"email"`
+	Notify struct {
+		Webhooks []webhook `yaml:"webhooks"`
+	} `yaml:"notify"`
+	Callback struct {
+		Webhooks []callbackWebhook `yaml:"webhooks"`
+	} `yaml:"callback"`
+	Alert struct {
+		Thresholds []threshold `yaml:"thresholds"`
+		Anomaly    struct {
+			Percent float64  `yaml:"percent"`
+			Size    quantity `yaml:"size"`
+			MinSize quantity `yaml:"min_size"`
+		} `yaml:"anomaly"`
+	} `yaml:"alert"`
+	Shard struct {
+		Index     int `yaml:"index"`
+		Count     int `yaml:"count"`
+		Zookeeper struct {
+			Enabled bool          `yaml:"enabled"`
+			Path    string        `yaml:"path"`
+			Settle  time.Duration `yaml:"settle"`
+		} `yaml:"zookeeper"`
+	} `yaml:"shard"`
+	Serve struct {
+		Listen          string        `yaml:"listen"`
+		GrpcListen      string        `yaml:"grpc_listen"`
+		Schedule        string        `yaml:"schedule"`
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+		// BearerToken 触发运行、重新抓取和修改名单等接口需要的 token，为空时这些接口不可用
+		BearerToken    string `yaml:"bearer_token"`
+		LeaderElection struct {
+			Enabled bool   `yaml:"enabled"`
+			Path    string `yaml:"path"`
+		} `yaml:"leader_election"`
+	} `yaml:"serve"`
+	Ownership struct {
+		File string `yaml:"file"`
+	} `yaml:"ownership"`
+	Exit struct {
+		PartialFailure int `yaml:"partial_failure"`
+	} `yaml:"exit"`
+	Blacklist struct {
+		Db []string `yaml:"db"`
+	} `yaml:"blacklist"`
+	Whitelist struct {
+		Db []string `yaml:"db"`
+	} `yaml:"whitelist"`
+	Filters struct {
+		Store bool `yaml:"store"`
+		Mysql struct {
+			Dsn   string `yaml:"dsn"`
+			Query string `yaml:"query"`
+		} `yaml:"mysql"`
+		Url     string            `yaml:"url"`
+		Headers map[string]string `yaml:"headers"`
+		Timeout time.Duration     `yaml:"timeout"`
+	} `yaml:"filters"`
+	QuietWindows []quietWindow `yaml:"quiet_windows"`
+	Paths        []string      `yaml:"paths"`
+	Quota        struct {
+		Paths        []string `yaml:"paths"`
+		Databases    bool     `yaml:"databases"`
+		AlertPercent float64  `yaml:"alert_percent"`
+	} `yaml:"quota"`
+	Users struct {
+		Root    string   `yaml:"root"`
+		Exclude []string `yaml:"exclude"`
+	} `yaml:"users"`
+	Hbase struct {
+		Root              string   `yaml:"root"`
+		ExcludeNamespaces []string `yaml:"exclude_namespaces"`
+	} `yaml:"hbase"`
+	Kudu struct {
+		Tservers []string      `yaml:"tservers"`
+		Timeout  time.Duration `yaml:"timeout"`
+	} `yaml:"kudu"`
+	Yarn struct {
+		Resourcemanagers []string      `yaml:"resourcemanagers"`
+		Timeout          time.Duration `yaml:"timeout"`
+	} `yaml:"yarn"`
+	KafkaTopics struct {
+		Brokers []string      `yaml:"brokers"`
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"kafka_topics"`
+	ElasticsearchIndices struct {
+		Url      string        `yaml:"url"`
+		Username string        `yaml:"username"`
+		Password string        `yaml:"password"`
+		ApiKey   string        `yaml:"api_key"`
+		Timeout  time.Duration `yaml:"timeout"`
+	} `yaml:"elasticsearch_indices"`
+	Capacity struct {
+		Namenodes []string      `yaml:"namenodes"`
+		Timeout   time.Duration `yaml:"timeout"`
+	} `yaml:"capacity"`
+	Runway struct {
+		Percent   float64 `yaml:"percent"`
+		AlertDays int     `yaml:"alert_days"`
+	} `yaml:"runway"`
+	Doris struct {
+		Dsn     string   `yaml:"dsn"`
+		Exclude []string `yaml:"exclude"`
+	} `yaml:"doris"`
+	S3 struct {
+		Endpoint  string        `yaml:"endpoint"`
+		Region    string        `yaml:"region"`
+		AccessKey string        `yaml:"access_key"`
+		SecretKey string        `yaml:"secret_key"`
+		PathStyle bool          `yaml:"path_style"`
+		Timeout   time.Duration `yaml:"timeout"`
+		Retry     retry.Policy  `yaml:"retry"`
+		Buckets   []s3Bucket    `yaml:"buckets"`
+	} `yaml:"s3"`
+	Schemas struct {
+		Instances []schemaInstance `yaml:"instances"`
+	} `yaml:"schemas"`
+	Logs struct {
+		SparkEventLogDirs []string `yaml:"spark_event_log_dirs"`
+		YarnLogDirs       []string `yaml:"yarn_log_dirs"`
+		CleanupDays       int      `yaml:"cleanup_days"`
+	} `yaml:"logs"`
+	Ozone struct {
+		Recon   string        `yaml:"recon"`
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"ozone"`
+	Counters []counterSchedule `yaml:"counters"`
+	Plugins  struct {
+		Source plugin.Command            `yaml:"source"`
+		Sizes  plugin.Command            `yaml:"sizes"`
+		Sinks  map[string]plugin.Command `yaml:"sinks"`
+	} `yaml:"plugins"`
+	Migrate struct {
+		Manual bool `yaml:"manual"`
+	} `yaml:"migrate"`
+	Log struct {
+		Level     string        `yaml:"level"`
+		Format    string        `yaml:"format"`
+		SlowQuery time.Duration `yaml:"slow_query"`
+		SlowTable time.Duration `yaml:"slow_table"`
+	} `yaml:"log"`
+}
+
+// configDeprecations 已废弃但仍然兼容的配置项
+var configDeprecations = []configfile.Deprecation{
+	{Key: "sink.type", Replacement: "sink.types"},
+}
+
+// configSecrets config show --redact-secrets 时隐藏的配置项，env 和 headers 隐藏其中的所有值
+var configSecrets = []string{"password", "dsn", "token", "bearer_token", "api_key", "access_key", "secret", "secret_key", "env", "headers"}
+
+// configURLs config show --redact-secrets 时只隐藏查询参数和密码的地址，群机器人的 access_token、key 在查询参数中
+var configURLs = []string{"url"}
+
+// configPath 环境变量 COUNTER_CONFIG 指定的配置文件，默认为当前目录下的 config.yaml
+func configPath() string {
+	path := os.Getenv("COUNTER_CONFIG")
+	if path == "" {
+		path = "config.yaml"
+	}
+	return path
+}
+
+// loadConfig 读取配置文件并填充默认值，文件中的 ${NAME} 替换为环境变量的值，便于通过 Kubernetes 的 Secret 注入密码，
+// 已废弃或者不存在的配置项只打印警告
+func loadConfig() error {
+	path := configPath()
+	file, err := configfile.Load(path, &config, configDeprecations)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		config = &Config{}
+	}
+	config.applyDefaults(file)
+	setupLogger()
+	for _, warning := range file.Warnings {
+		log.Printf("配置文件 %s: %s", path, warning)
+	}
+	return nil
+}
+
+// applyDefaults 为没有配置的项填充各处使用的默认值，config show 输出的即为实际生效的配置。
+// 数值只在配置文件中没有设置时填充，设置为 0 时保留，例如 retry.times: 0 关闭重试
+func (c *Config) applyDefaults(file *configfile.File) {
+	if len(c.Sink.Types) == 0 {
+		sinkType := c.Sink.Type
+		if sinkType == "" {
+			sinkType = sinkMysql
+		}
+		c.Sink.Types = []string{sinkType}
+	}
+	c.Sink.Type = ""
+
+	defaultString(&c.Hive.SkipProperty, defaultHiveSkipProperty)
+	defaultString(&c.Hive.Temporary.Action, temporaryTag)
+	defaultDuration(file, "hive.metastore.timeout", &c.Hive.Metastore.Timeout, defaultMetastoreTimeout)
+	defaultInt(file, "hive.retry.times", &c.Hive.Retry.Times, defaultHiveRetryTimes)
+	defaultDuration(file, "hive.retry.interval", &c.Hive.Retry.Interval, defaultHiveRetryInterval)
+	defaultInt(file, "mysql.retry.times", &c.Mysql.Retry.Times, defaultMysqlRetryTimes)
+	defaultDuration(file, "mysql.retry.interval", &c.Mysql.Retry.Interval, defaultMysqlRetryInterval)
+	defaultString(&c.Clickhouse.Table, defaultClickhouseTable)
+	defaultInt(file, "clickhouse.batch_size", &c.Clickhouse.BatchSize, defaultClickhouseBatchSize)
+	defaultDuration(file, "clickhouse.timeout", &c.Clickhouse.Timeout, defaultClickhouseTimeout)
+	defaultString(&c.Elasticsearch.IndexPrefix, defaultElasticsearchIndexPrefix)
+	defaultInt(file, "elasticsearch.batch_size", &c.Elasticsearch.BatchSize, defaultElasticsearchBatchSize)
+	defaultDuration(file, "elasticsearch.timeout", &c.Elasticsearch.Timeout, defaultElasticsearchTimeout)
+	defaultString(&c.Influxdb.Measurement, defaultInfluxdbMeasurement)
+	defaultInt(file, "influxdb.batch_size", &c.Influxdb.BatchSize, defaultInfluxdbBatchSize)
+	defaultDuration(file, "influxdb.timeout", &c.Influxdb.Timeout, defaultInfluxdbTimeout)
+	defaultInt(file, "kafka.batch_size", &c.Kafka.BatchSize, defaultKafkaBatchSize)
+	defaultDuration(file, "kafka.timeout", &c.Kafka.Timeout, defaultKafkaTimeout)
+	defaultString(&c.Pushgateway.Job, defaultPushgatewayJob)
+	defaultDuration(file, "remote_write.timeout", &c.RemoteWrite.Timeout, defaultRemoteWriteTimeout)
+	defaultString(&c.Otlp.ServiceName, defaultOtlpServiceName)
+	defaultDuration(file, "otlp.timeout", &c.Otlp.Timeout, defaultOtlpTimeout)
+
+	defaultInt(file, "forecast.history", &c.Forecast.History, defaultForecastHistory)
+	if len(c.Forecast.Horizons) == 0 {
+		c.Forecast.Horizons = append([]int(nil), defaultForecastHorizons...)
+	}
+	if c.SmallFiles.AvgSize == 0 && !file.Has("small_files.avg_size") {
+		c.SmallFiles.AvgSize = defaultSmallFileAvgSize
+	}
+	if c.SmallFiles.MinFiles == 0 && !file.Has("small_files.min_files") {
+		c.SmallFiles.MinFiles = defaultSmallFileMinFiles
+	}
+	if c.SmallFiles.TargetSize == 0 && !file.Has("small_files.target_size") {
+		c.SmallFiles.TargetSize = defaultSmallFileTargetSize
+	}
+	defaultFloat(file, "partition_skew.percent", &c.PartitionSkew.Percent, defaultPartitionSkewPercent)
+	if c.PartitionSkew.MinSize == 0 && !file.Has("partition_skew.min_size") {
+		c.PartitionSkew.MinSize = defaultPartitionSkewMinSize
+	}
+	defaultInt(file, "largest_files.top", &c.LargestFiles.Top, defaultLargestFilesTop)
+	if c.LargestFiles.MinSize == 0 && !file.Has("largest_files.min_size") {
+		c.LargestFiles.MinSize = defaultLargestFilesMinSize
+	}
+	defaultInt(file, "acid.max_deltas", &c.Acid.MaxDeltas, defaultAcidMaxDeltas)
+	if c.Replication.Enabled && c.Replication.Default <= 0 {
+		c.Replication.Default = (&hdfssize.Config{ConfDir: c.Hadoop.Conf.Dir}).DefaultReplication()
+	}
+	if c.Prune.Keep == 0 && !file.Has("prune.keep") {
+		c.Prune.Keep = defaultPruneKeep
+	}
+	if len(c.Retention.Candidates) == 0 {
+		c.Retention.Candidates = append([]int(nil), defaultRetentionCandidates...)
+	}
+	defaultInt(file, "retention.min_keep", &c.Retention.MinKeep, defaultRetentionMinKeep)
+	defaultInt(file, "retention.lookback", &c.Retention.Lookback, defaultRetentionLookback)
+
+	defaultString(&c.Filters.Mysql.Query, defaultFilterQuery)
+	defaultDuration(file, "filters.timeout", &c.Filters.Timeout, defaultFilterTimeout)
+	defaultString(&c.Shard.Zookeeper.Path, defaultShardPath)
+	defaultDuration(file, "shard.zookeeper.settle", &c.Shard.Zookeeper.Settle, defaultShardSettle)
+	defaultString(&c.Serve.Listen, defaultServeListen)
+	defaultDuration(file, "serve.shutdown_timeout", &c.Serve.ShutdownTimeout, defaultServeShutdownTimeout)
+	defaultString(&c.Serve.LeaderElection.Path, defaultLeaderPath)
+
+	defaultString(&c.Users.Root, defaultUsersRoot)
+	defaultString(&c.Hbase.Root, defaultHbaseRoot)
+	defaultDuration(file, "kudu.timeout", &c.Kudu.Timeout, defaultKuduTimeout)
+	defaultDuration(file, "yarn.timeout", &c.Yarn.Timeout, defaultYarnTimeout)
+	defaultDuration(file, "kafka_topics.timeout", &c.KafkaTopics.Timeout, defaultKafkaTopicsTimeout)
+	defaultDuration(file, "elasticsearch_indices.timeout", &c.ElasticsearchIndices.Timeout, defaultEsIndicesTimeout)
+	defaultDuration(file, "capacity.timeout", &c.Capacity.Timeout, defaultCapacityTimeout)
+	defaultFloat(file, "runway.percent", &c.Runway.Percent, defaultRunwayPercent)
+	defaultString(&c.S3.Region, defaultS3Region)
+	defaultDuration(file, "s3.timeout", &c.S3.Timeout, defaultS3Timeout)
+	defaultInt(file, "s3.retry.times", &c.S3.Retry.Times, defaultS3RetryTimes)
+	defaultDuration(file, "s3.retry.interval", &c.S3.Retry.Interval, defaultS3RetryInterval)
+	defaultFloat(file, "s3.retry.multiplier", &c.S3.Retry.Multiplier, defaultS3RetryMultiplier)
+	defaultInt(file, "logs.cleanup_days", &c.Logs.CleanupDays, defaultLogsCleanupDays)
+	defaultDuration(file, "ozone.timeout", &c.Ozone.Timeout, defaultOzoneTimeout)
+
+	defaultString(&c.Log.Level, levelInfo)
+	defaultString(&c.Log.Format, logFormatText)
+	defaultDuration(file, "log.slow_query", &c.Log.SlowQuery, defaultLogSlowQuery)
+	defaultDuration(file, "log.slow_table", &c.Log.SlowTable, defaultLogSlowTable)
+}
+
+func defaultString(value *string, def string) {
+	if *value == "" {
+		*value = def
+	}
+}
+
+func defaultInt(file *configfile.File, key string, value *int, def int) {
+	if *value == 0 && !file.Has(key) {
+		*value = def
+	}
+}
+
+func defaultFloat(file *configfile.File, key string, value *float64, def float64) {
+	if *value == 0 && !file.Has(key) {
+		*value = def
+	}
+}
+
+func defaultDuration(file *configfile.File, key string, value *time.Duration, def time.Duration) {
+	if *value == 0 && !file.Has(key) {
+		*value = def
+	}
+}
+
+// runConfig 查看实际生效的配置
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintln(os.Stderr, "Usage: counter config show [--redact-secrets]")
+		os.Exit(2)
+	}
+	flags := flag.NewFlagSet("config show", flag.ExitOnError)
+	redactSecrets := flags.Bool("redact-secrets", false, "隐藏密码、token、dsn 等敏感配置")
+	flags.Parse(args[1:])
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	var secrets, urls []string
+	if *redactSecrets {
+		secrets, urls = configSecrets, configURLs
+	}
+	out, err := configfile.Marshal(config, secrets, urls)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	os.Stdout.Write(out)
+}
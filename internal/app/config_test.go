@@ -0,0 +1,43 @@
+package app
+
+import (
+	"github.com/rea1shane/counter/internal/configfile"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultConfigHasNoWarnings(t *testing.T) {
+	var c *Config
+	file, err := configfile.Load("config.yaml", &c, configDeprecations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Warnings) != 0 {
+		t.Errorf("warnings = %q, want none", file.Warnings)
+	}
+}
+
+func TestApplyDefaultsKeepsExplicitZero(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := ioutil.WriteFile(path, []byte("hive:\n  retry:\n    times: 0\ns3:\n  retry:\n    times:\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c *Config
+	file, err := configfile.Load(path, &c, configDeprecations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.applyDefaults(file)
+	if c.Hive.Retry.Times != 0 {
+		t.Errorf("hive.retry.times = %d, want 0", c.Hive.Retry.Times)
+	}
+	// 没有设置或者值为空时使用默认值
+	if c.Mysql.Retry.Times != defaultMysqlRetryTimes {
+		t.Errorf("mysql.retry.times = %d, want %d", c.Mysql.Retry.Times, defaultMysqlRetryTimes)
+	}
+	if c.S3.Retry.Times != defaultS3RetryTimes {
+		t.Errorf("s3.retry.times = %d, want %d", c.S3.Retry.Times, defaultS3RetryTimes)
+	}
+}
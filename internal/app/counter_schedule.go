@@ -0,0 +1,200 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"github.com/morikuni/failure"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// counterSchedule counters 中一个计数器的运行方式，schedule 和 interval 都为空时只通过 counters 子命令运行
+type counterSchedule struct {
+	Name    string `yaml:"name"`
+	Enabled bool   `yaml:"enabled"`
+	// Schedule 每天运行的时间，格式为 15:04
+	Schedule string `yaml:"schedule"`
+	// Interval 按固定间隔运行，例如 yarn 队列采样，配置后忽略 schedule
+	Interval time.Duration `yaml:"interval"`
+}
+
+// scheduledCounter 开启的计数器和解析后的运行时间
+type scheduledCounter struct {
+	counterSchedule
+	counter *counter
+	at      time.Time
+}
+
+// counterStatus serve 模式下计数器最近一次运行的状态
+type counterStatus struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule,omitempty"`
+	Interval string     `json:"interval,omitempty"`
+	Running  bool       `json:"running"`
+	Rows     int        `json:"rows"`
+	Error    string     `json:"error,omitempty"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Next     *time.Time `json:"next,omitempty"`
+}
+
+// enabledCounters 校验 counters 配置并返回开启的计数器，名称不存在或重复时返回错误
+func enabledCounters() ([]*scheduledCounter, error) {
+	var (
+		scheduled []*scheduledCounter
+		seen      = make(map[string]bool)
+	)
+	for _, item := range config.Counters {
+		c := findCounter(item.Name)
+		if c == nil {
+			return nil, failure.Wrap(fmt.Errorf("unknown counter %q", item.Name))
+		}
+		if seen[item.Name] {
+			return nil, failure.Wrap(fmt.Errorf("duplicate counter %q", item.Name))
+		}
+		seen[item.Name] = true
+		if !item.Enabled {
+			continue
+		}
+		sc := &scheduledCounter{counterSchedule: item, counter: c}
+		if item.Schedule != "" {
+			at, err := time.ParseInLocation("15:04", item.Schedule, time.Local)
+			if err != nil {
+				return nil, failure.Wrap(fmt.Errorf("invalid schedule %q of counter %s", item.Schedule, item.Name))
+			}
+			sc.at = at
+		}
+		scheduled = append(scheduled, sc)
+	}
+	return scheduled, nil
+}
+
+// next 下一次运行的时间，没有配置 schedule 和 interval 时 ok 为 false
+func (sc *scheduledCounter) next(now time.Time) (next time.Time, ok bool) {
+	if sc.Interval > 0 {
+		return now.Add(sc.Interval), true
+	}
+	if sc.Schedule == "" {
+		return time.Time{}, false
+	}
+	next = time.Date(now.Year(), now.Month(), now.Day(), sc.at.Hour(), sc.at.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, true
+}
+
+// runCounters 依次运行 counters 中开启的所有计数器，适合通过 cron 调度，结果写入同一个数据库，任意一个失败时退出码为 1
+func runCounters(args []string) {
+	flags := flag.NewFlagSet("counters", flag.ExitOnError)
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+	scheduled, err := enabledCounters()
+	if err != nil {
+		log.Fatal("解析 counters 失败: " + err.Error())
+	}
+	if len(scheduled) == 0 {
+		log.Fatal("counters 中没有开启的计数器")
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+	date := currentDate()
+	failed := 0
+	for _, sc := range scheduled {
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		rows, err := sc.counter.run(ctx, date)
+		if err != nil {
+			log.Printf("运行计数器 %s 失败: %+v", sc.Name, err)
+			failed++
+			continue
+		}
+		log.Printf("计数器 %s 写入 %d 行，耗时 %s", sc.Name, rows, time.Since(start).Round(time.Millisecond))
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// startCounters serve 模式下为每个配置了 schedule 或 interval 的计数器启动定时运行，与 Hive 的抓取相互独立
+func (s *server) startCounters(scheduled []*scheduledCounter, leader *leaderElection) {
+	for _, sc := range scheduled {
+		status := &counterStatus{Name: sc.Name, Schedule: sc.Schedule}
+		if sc.Interval > 0 {
+			status.Interval = sc.Interval.String()
+		}
+		s.mu.Lock()
+		s.counters = append(s.counters, status)
+		s.mu.Unlock()
+		if _, ok := sc.next(time.Now()); ok {
+			go s.scheduleCounter(sc, status, leader)
+		}
+	}
+}
+
+// scheduleCounter 同一个计数器不会同时运行多次，开启选举时只有 leader 运行
+func (s *server) scheduleCounter(sc *scheduledCounter, status *counterStatus, leader *leaderElection) {
+	for {
+		next, _ := sc.next(time.Now())
+		s.mu.Lock()
+		status.Next = &next
+		s.mu.Unlock()
+		time.Sleep(time.Until(next))
+
+		if leader != nil && !leader.isLeader() {
+			log.Printf("不是 leader，跳过计数器 %s", sc.Name)
+			continue
+		}
+		s.mu.Lock()
+		if s.stopping {
+			s.mu.Unlock()
+			return
+		}
+		started := time.Now()
+		status.Running = true
+		status.Started = &started
+		s.mu.Unlock()
+
+		rows, err := sc.counter.run(s.ctx, currentDate())
+
+		finished := time.Now()
+		s.mu.Lock()
+		status.Running = false
+		status.Finished = &finished
+		status.Rows = rows
+		status.Error = ""
+		if err != nil {
+			status.Error = err.Error()
+		}
+		s.mu.Unlock()
+		if err != nil {
+			log.Printf("运行计数器 %s 失败: %+v", sc.Name, err)
+			continue
+		}
+		log.Printf("计数器 %s 写入 %d 行", sc.Name, rows)
+	}
+}
+
+// handleCounters GET /counters 返回 serve 模式下开启的计数器和最近一次运行的状态
+func (s *server) handleCounters(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+	s.mu.Lock()
+	statuses := make([]counterStatus, 0, len(s.counters))
+	for _, status := range s.counters {
+		statuses = append(statuses, *status)
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, statuses)
+}
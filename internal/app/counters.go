@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/morikuni/failure"
+	"gorm.io/gorm/clause"
+	"log"
+	"os"
+	"reflect"
+	"time"
+)
+
+// counter 除 Hive 表以外的计数器，通过同名子命令运行，结果按天保存到各自的表
+type counter struct {
+	name  string
+	usage string
+	// model 结果表，keys 为除 date 以外的唯一键，重复运行时覆盖当天的结果
+	model interface{ TableName() string }
+	keys  []string
+	// collect 返回 model 的指针切片，以及输出到终端的表格
+	collect func(ctx context.Context, date time.Time) (records interface{}, header []string, rows [][]interface{}, err error)
+}
+
+// counters 所有计数器
+var counters = []*counter{
+	pathCounter,
+	quotaCounter,
+	userCounter,
+	trashCounter,
+	hbaseCounter,
+	kuduCounter,
+	yarnCounter,
+	kafkaTopicCounter,
+	esIndexCounter,
+	capacityCounter,
+	snapshotCounter,
+	partitionCounter,
+	dorisCounter,
+	s3Counter,
+	schemaCounter,
+	logCounter,
+	ozoneCounter,
+	databaseCounter,
+}
+
+func findCounter(name string) *counter {
+	for _, c := range counters {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// runCounter 运行一个计数器，结果写入数据库并输出
+func runCounter(c *counter, args []string) {
+	flags := flag.NewFlagSet(c.name, flag.ExitOnError)
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	dryRun := flags.Bool("dry-run", false, "只输出结果，不写入数据库")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: counter %s [flags]\n\n%s\n\n", c.name, c.usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+	records, header, rows, err := c.collect(ctx, currentDate())
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	if !*dryRun {
+		err = c.save(ctx, records)
+		if err != nil {
+			log.Fatal("写入数据库失败: " + err.Error())
+		}
+	}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run 采集并写入数据库，返回写入的行数
+func (c *counter) run(ctx context.Context, date time.Time) (int, error) {
+	records, _, _, err := c.collect(ctx, date)
+	if err != nil {
+		return 0, err
+	}
+	return reflect.ValueOf(records).Len(), c.save(ctx, records)
+}
+
+// save 按 keys 和 date upsert 到 model 对应的表
+func (c *counter) save(ctx context.Context, records interface{}) error {
+	if reflect.ValueOf(records).Len() == 0 {
+		return nil
+	}
+	store, err := openStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	err = store.Migrate(ctx, c.model)
+	if err != nil {
+		return err
+	}
+
+	columns := make([]clause.Column, 0, len(c.keys)+1)
+	for _, key := range append(c.keys, "date") {
+		columns = append(columns, clause.Column{Name: key})
+	}
+	upsert := clause.OnConflict{Columns: columns, UpdateAll: true}
+	return failure.Wrap(store.DB().WithContext(ctx).Clauses(upsert).CreateInBatches(records, 1000).Error)
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"strconv"
+)
+
+var (
+	csvResultHeader = []string{"db", "table", "location", "size", "date"}
+	csvErrorHeader  = []string{"db", "table", "location", "desc", "date"}
+)
+
+// csvSink 将成功的结果和失败的记录分别写入两个 CSV 文件
+type csvSink struct {
+	hdfsClient *hdfs.Client
+}
+
+func (s *csvSink) Open(ctx context.Context) error {
+	return nil
+}
+
+func (s *csvSink) WriteBatch(ctx context.Context, entities []*model.Hive) error {
+	date := currentDate()
+	if len(entities) > 0 {
+		date = entities[0].Date
+	}
+
+	results := [][]string{csvResultHeader}
+	errs := [][]string{csvErrorHeader}
+	for _, entity := range entities {
+		if entity.Desc != "" {
+			errs = append(errs, []string{entity.Db, entity.Table, entity.Location, entity.Desc, entity.Date.Format("2006-01-02")})
+			continue
+		}
+		results = append(results, []string{entity.Db, entity.Table, entity.Location, strconv.FormatInt(entity.Size, 10), entity.Date.Format("2006-01-02")})
+	}
+
+	err := s.writeFile("hive_"+date.Format("20060102")+".csv", results)
+	if err != nil {
+		return err
+	}
+	return s.writeFile("hive_errors_"+date.Format("20060102")+".csv", errs)
+}
+
+func (s *csvSink) writeFile(name string, records [][]string) error {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	err := writer.WriteAll(records)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	return writeOutputFile(s.hdfsClient, config.Csv.Dir, name, buf.Bytes())
+}
+
+func (s *csvSink) Close() error {
+	return nil
+}
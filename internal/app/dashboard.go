@@ -0,0 +1,158 @@
+package app
+
+import (
+	_ "embed"
+	"github.com/rea1shane/counter/pkg/model"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultDashboardDays  = 90
+	defaultDashboardLimit = 20
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// dashboardData /api/dashboard 的返回值，大小单位均为 bytes
+type dashboardData struct {
+	Date      string           `json:"date"`
+	Dbs       int              `json:"dbs"`
+	Tables    int              `json:"tables"`
+	Size      int64            `json:"size"`
+	Failed    int              `json:"failed"`
+	TopDbs    []dashboardDb    `json:"top_dbs"`
+	TopTables []dashboardTable `json:"top_tables"`
+	Series    []dashboardPoint `json:"series"`
+	Failures  []dashboardTable `json:"failures"`
+	Runs      []serveRun       `json:"runs"`
+}
+
+type dashboardDb struct {
+	Db     string `json:"db"`
+	Tables int    `json:"tables"`
+	Size   int64  `json:"size"`
+}
+
+type dashboardTable struct {
+	Db    string `json:"db"`
+	Table string `json:"table"`
+	Size  int64  `json:"size"`
+	Desc  string `json:"desc,omitempty"`
+}
+
+type dashboardPoint struct {
+	Date string `json:"date"`
+	Size int64  `json:"size"`
+}
+
+// handleDashboard 页面本身是静态的，数据通过 /api/dashboard 获取
+func (s *server) handleDashboard(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// handleDashboardData GET /api/dashboard?days=90&limit=20 返回最近一次抓取的总量、最大的库和表、获取失败的表、每天的总大小和最近的运行
+func (s *server) handleDashboardData(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	days, limit := defaultDashboardDays, defaultDashboardLimit
+	var err error
+	if value := query.Get("days"); value != "" {
+		days, err = strconv.Atoi(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	ctx := req.Context()
+	store, err := s.database()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	date, err := latestDate(ctx, store)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	history, err := loadRecordsBetween(ctx, store, date.AddDate(0, 0, -days), date)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	data := &dashboardData{
+		Date:      date.Format("2006-01-02"),
+		TopDbs:    []dashboardDb{},
+		TopTables: []dashboardTable{},
+		Series:    []dashboardPoint{},
+		Failures:  []dashboardTable{},
+		Runs:      []serveRun{},
+	}
+	var (
+		records []*model.Hive
+		daily   = make(map[int32]int64)
+		today   = epochDays(date)
+	)
+	for _, record := range history {
+		day := epochDays(record.Date)
+		if record.Size > 0 {
+			daily[day] += record.Size
+		}
+		if day == today {
+			records = append(records, record)
+		}
+	}
+	for day, size := range daily {
+		data.Series = append(data.Series, dashboardPoint{Date: time.Unix(int64(day)*86400, 0).UTC().Format("2006-01-02"), Size: size})
+	}
+	sort.Slice(data.Series, func(i, j int) bool {
+		return data.Series[i].Date < data.Series[j].Date
+	})
+
+	dbs, total := topDbs(records)
+	data.Dbs, data.Tables, data.Size = len(dbs), len(records), total
+	for _, db := range dbs {
+		if len(data.TopDbs) >= limit {
+			break
+		}
+		data.TopDbs = append(data.TopDbs, dashboardDb{Db: db.name, Tables: db.tables, Size: db.size})
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+	for _, record := range records {
+		if record.Desc != "" {
+			data.Failed++
+		}
+		if record.Desc != "" && len(data.Failures) < limit {
+			data.Failures = append(data.Failures, dashboardTable{Db: record.Db, Table: record.Table, Size: record.Size, Desc: record.Desc})
+		}
+		if len(data.TopTables) < limit {
+			data.TopTables = append(data.TopTables, dashboardTable{Db: record.Db, Table: record.Table, Size: record.Size})
+		}
+	}
+
+	s.mu.Lock()
+	for i := len(s.runs) - 1; i >= 0 && len(data.Runs) < limit; i-- {
+		data.Runs = append(data.Runs, *s.runs[i])
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, data)
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"github.com/colinmarc/hdfs"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/model"
+	"sort"
+	"strings"
+	"time"
+)
+
+// databaseCounter 直接获取每个库目录的大小，比逐表抓取快得多，适合每天运行得到总量，逐表抓取可以降低频率
+var databaseCounter = &counter{
+	name:  "databases",
+	usage: "通过 DESCRIBE DATABASE 获取每个库的目录，直接统计库目录的大小并保存到 hive_database 表，不包括目录在库目录之外的外部表",
+	model: &model.HiveDatabase{},
+	keys:  []string{"db"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		client, err := newHdfsClient()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		defer client.Close()
+		defer hdfssize.CloseOnDone(ctx, client)()
+
+		records, err := countDatabases(ctx, client, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"db", "location", "size", "raw_size", "files", "dirs", "desc"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{record.Db, record.Location, byteSize(record.Size), byteSize(record.RawSize), record.Files, record.Dirs, record.Desc})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countDatabases 按大小降序返回每个库，跳过黑名单中的库和不在 hdfs 上的库，单个库失败时 size 为 -1
+func countDatabases(ctx context.Context, client *hdfs.Client, date time.Time) ([]*model.HiveDatabase, error) {
+	lists, err := loadDbLists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hiveConnection, err := connectHive()
+	if err != nil {
+		return nil, err
+	}
+	defer hiveConnection.Close()
+	cursor := hiveConnection.Cursor()
+	defer cursor.Close()
+	source, err := newHiveSource(ctx, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	dbs, err := source.Dbs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var records []*model.HiveDatabase
+	for _, db := range dbs {
+		if lists.skip(db) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record := &model.HiveDatabase{Db: db, Date: date}
+		records = append(records, record)
+		record.Location, err = source.DbLocation(ctx, db)
+		if err != nil {
+			record.Size = -1
+			record.Desc = err.Error()
+			continue
+		}
+		if !strings.Contains(record.Location, hdfsFlag) {
+			record.Desc = "not on hdfs"
+			continue
+		}
+		summary, err := getHdfsContentSummary(client, record.Location)
+		if err != nil {
+			record.Size = -1
+			record.Desc = err.Error()
+			continue
+		}
+		record.Size = summary.Size()
+		record.RawSize = summary.SizeAfterReplication()
+		record.Files = int64(summary.FileCount())
+		record.Dirs = int64(summary.DirectoryCount())
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+	return records, nil
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"github.com/rea1shane/counter/pkg/model"
+	"time"
+)
+
+// fillDeltas 写入前与结果数据库中 date 之前最近一次抓取比较，设置每张表的 delta_bytes 和 delta_pct，
+// 看板不再需要自关联查询。上一次没有该表、任一次获取失败或者通过表属性跳过时为空，上一次大小为 0 时 delta_pct 为空
+func fillDeltas(ctx context.Context, date time.Time, entities []*model.Hive) error {
+	store, err := openStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	previous, ok, err := previousDate(ctx, store, date)
+	if err != nil || !ok {
+		return err
+	}
+	records, err := loadRecords(ctx, store, previous)
+	if err != nil {
+		return err
+	}
+	sizes := make(map[string]int64, len(records))
+	for _, record := range withoutSkipped(records) {
+		sizes[record.Db+"."+record.Table] = record.Size
+	}
+
+	for _, entity := range withoutSkipped(entities) {
+		from, ok := sizes[entity.Db+"."+entity.Table]
+		if !ok || from < 0 || entity.Size < 0 {
+			continue
+		}
+		delta := entity.Size - from
+		entity.DeltaBytes = &delta
+		if from > 0 {
+			pct := float64(delta) / float64(from) * 100
+			entity.DeltaPct = &pct
+		}
+	}
+	return nil
+}
+
+// withoutSkipped 去掉通过表属性跳过的表，跳过的表没有获取大小，不能参与比较
+func withoutSkipped(records []*model.Hive) []*model.Hive {
+	result := make([]*model.Hive, 0, len(records))
+	for _, record := range records {
+		if !record.Skipped() {
+			result = append(result, record)
+		}
+	}
+	return result
+}
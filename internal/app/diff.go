@@ -0,0 +1,192 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"os"
+	"sort"
+)
+
+// 表在两个日期之间的变化
+const (
+	diffNew     = "new"
+	diffDropped = "dropped"
+	// diffFailed 任一日期获取大小失败，变化量无意义
+	diffFailed = "failed"
+	// diffSkipped 任一日期通过表属性跳过，没有获取大小
+	diffSkipped = "skipped"
+)
+
+type sizeDelta struct {
+	db     string
+	table  string
+	from   int64
+	to     int64
+	status string
+}
+
+func (d *sizeDelta) delta() int64 {
+	if d.status == diffFailed || d.status == diffSkipped || d.from < 0 || d.to < 0 {
+		return 0
+	}
+	return d.to - d.from
+}
+
+func (d *sizeDelta) percent() percent {
+	if d.status != "" || d.from <= 0 {
+		return nil
+	}
+	value := float64(d.to-d.from) / float64(d.from) * 100
+	return &value
+}
+
+// runDiff 比较两个日期的结果，按增长量降序输出每个库和每张表的变化
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	fromFlag := flags.String("from", "", "起始日期，格式为 2006-01-02")
+	toFlag := flags.String("to", "", "结束日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	by := flags.String("by", "", "只输出某一层级的变化，可选 db、table，默认两者都输出")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+	if *fromFlag == "" {
+		log.Fatal("需要指定 --from")
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	from, err := resolveDate(ctx, store, *fromFlag)
+	if err != nil {
+		log.Fatal("解析起始日期失败: " + err.Error())
+	}
+	to, err := resolveDate(ctx, store, *toFlag)
+	if err != nil {
+		log.Fatal("解析结束日期失败: " + err.Error())
+	}
+
+	fromRecords, err := loadRecords(ctx, store, from)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	toRecords, err := loadRecords(ctx, store, to)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	tables := diffTables(fromRecords, toRecords)
+	dbs := diffDbs(tables)
+
+	header := []string{"level", "db", "table", "from", "to", "delta", "delta_percent", "status"}
+	var rows [][]interface{}
+	if *by == "" || *by == "db" {
+		for _, d := range dbs {
+			rows = append(rows, []interface{}{"db", d.db, "", byteSize(d.from), byteSize(d.to), byteSize(d.delta()), d.percent(), d.status})
+		}
+	}
+	if *by == "" || *by == "table" {
+		for _, d := range tables {
+			rows = append(rows, []interface{}{"table", d.db, d.table, byteSize(d.from), byteSize(d.to), byteSize(d.delta()), d.percent(), d.status})
+		}
+	}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+// diffTables 按 db.table 匹配两次的结果，按增长量降序排列
+func diffTables(fromRecords, toRecords []*model.Hive) []*sizeDelta {
+	var (
+		deltas  []*sizeDelta
+		index   = make(map[string]*sizeDelta)
+		skipped = make(map[*sizeDelta]bool)
+	)
+	for _, record := range fromRecords {
+		d := &sizeDelta{db: record.Db, table: record.Table, from: record.Size, status: diffDropped}
+		index[record.Db+"."+record.Table] = d
+		deltas = append(deltas, d)
+		skipped[d] = record.Skipped()
+	}
+	for _, record := range toRecords {
+		d, ok := index[record.Db+"."+record.Table]
+		if ok {
+			d.status = ""
+		} else {
+			d = &sizeDelta{db: record.Db, table: record.Table, status: diffNew}
+			deltas = append(deltas, d)
+		}
+		d.to = record.Size
+		skipped[d] = skipped[d] || record.Skipped()
+	}
+	for _, d := range deltas {
+		switch {
+		case skipped[d]:
+			// 新增和删除的表保留状态，生命周期仍然记录
+			if d.status == "" {
+				d.status = diffSkipped
+			}
+		case d.from < 0 || d.to < 0:
+			d.status = diffFailed
+		}
+	}
+	sortDeltas(deltas)
+	return deltas
+}
+
+// diffDbs 汇总每个库的变化，获取大小失败和跳过的表不计入，库下所有表都是新增或删除时库也标记为新增或删除
+func diffDbs(tables []*sizeDelta) []*sizeDelta {
+	var (
+		deltas  []*sizeDelta
+		index   = make(map[string]*sizeDelta)
+		hasFrom = make(map[string]bool)
+		hasTo   = make(map[string]bool)
+	)
+	for _, table := range tables {
+		d, ok := index[table.db]
+		if !ok {
+			d = &sizeDelta{db: table.db}
+			index[table.db] = d
+			deltas = append(deltas, d)
+		}
+		hasFrom[table.db] = hasFrom[table.db] || table.status != diffNew
+		hasTo[table.db] = hasTo[table.db] || table.status != diffDropped
+		if table.status == diffFailed || table.status == diffSkipped || table.from < 0 || table.to < 0 {
+			continue
+		}
+		d.from += table.from
+		d.to += table.to
+	}
+	for _, d := range deltas {
+		if !hasFrom[d.db] {
+			d.status = diffNew
+		} else if !hasTo[d.db] {
+			d.status = diffDropped
+		}
+	}
+	sortDeltas(deltas)
+	return deltas
+}
+
+func sortDeltas(deltas []*sizeDelta) {
+	sort.SliceStable(deltas, func(i, j int) bool {
+		if deltas[i].delta() != deltas[j].delta() {
+			return deltas[i].delta() > deltas[j].delta()
+		}
+		if deltas[i].db != deltas[j].db {
+			return deltas[i].db < deltas[j].db
+		}
+		return deltas[i].table < deltas[j].table
+	})
+}
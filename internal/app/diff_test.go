@@ -0,0 +1,39 @@
+package app
+
+import (
+	"github.com/rea1shane/counter/pkg/model"
+	"testing"
+)
+
+func TestDiffSkippedTables(t *testing.T) {
+	skipped := func(db, table string) *model.Hive {
+		record := &model.Hive{Db: db, Table: table, Size: -1}
+		record.SetExtra(model.ExtraSkipped, true)
+		return record
+	}
+	from := []*model.Hive{
+		{Db: "ods", Table: "orders", Size: 100},
+		{Db: "ods", Table: "users", Size: 50},
+	}
+	to := []*model.Hive{
+		skipped("ods", "orders"),
+		{Db: "ods", Table: "users", Size: 60},
+		skipped("ods", "scratch"),
+	}
+
+	tables := diffTables(from, to)
+	status := make(map[string]*sizeDelta)
+	for _, d := range tables {
+		status[d.table] = d
+	}
+	if d := status["orders"]; d.status != diffSkipped || d.delta() != 0 || d.percent() != nil {
+		t.Errorf("orders = %+v, want skipped without delta", d)
+	}
+	if d := status["scratch"]; d.status != diffNew || d.delta() != 0 {
+		t.Errorf("scratch = %+v, want new without delta", d)
+	}
+	dbs := diffDbs(tables)
+	if len(dbs) != 1 || dbs[0].from != 50 || dbs[0].to != 60 {
+		t.Errorf("dbs = %+v, want only ods.users counted", dbs[0])
+	}
+}
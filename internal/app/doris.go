@@ -0,0 +1,177 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dorisSystemDbs FE 内置的库，不统计
+var dorisSystemDbs = map[string]bool{
+	"information_schema":  true,
+	"mysql":               true,
+	"sys":                 true,
+	"__internal_schema":   true,
+	"_statistics_":        true,
+	"_starrocks_audit_db": true,
+}
+
+// dorisSummaryRows SHOW DATA 最后的汇总行
+var dorisSummaryRows = map[string]bool{
+	"Total": true,
+	"Quota": true,
+	"Left":  true,
+}
+
+// dorisCounter 通过 FE 的 MySQL 协议执行 SHOW DATA，Doris 和 StarRocks 都适用
+var dorisCounter = &counter{
+	name:  "doris",
+	usage: "通过 doris.dsn 连接 Doris 或 StarRocks 的 FE，统计每张表的数据量和副本数量并保存到 doris_table 表",
+	model: &model.DorisTable{},
+	keys:  []string{"db", "table"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		records, err := countDoris(ctx, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"db", "table", "size", "remote_size", "replicas"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{record.Db, record.Table, byteSize(record.Size), byteSize(record.RemoteSize), record.Replicas})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countDoris 按大小降序返回每张表，跳过系统库和 doris.exclude 中的库
+func countDoris(ctx context.Context, date time.Time) ([]*model.DorisTable, error) {
+	if config.Doris.Dsn == "" {
+		return nil, failure.Wrap(fmt.Errorf("doris.dsn is empty"))
+	}
+	db, err := sql.Open("mysql", config.Doris.Dsn)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	defer db.Close()
+	// SHOW DATA 只返回当前库的表，需要在同一个连接上先 USE
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	defer conn.Close()
+
+	dbs, err := queryStrings(ctx, conn, "SHOW DATABASES")
+	if err != nil {
+		return nil, err
+	}
+	exclude := make(map[string]bool)
+	for _, name := range config.Doris.Exclude {
+		exclude[name] = true
+	}
+
+	var records []*model.DorisTable
+	for _, name := range dbs {
+		if dorisSystemDbs[name] || exclude[name] {
+			continue
+		}
+		tables, err := showDorisData(ctx, conn, name)
+		if err != nil {
+			log.Printf("获取库 %s 的数据量失败: %+v", name, err)
+			records = append(records, &model.DorisTable{Db: name, Size: -1, Desc: err.Error(), Date: date})
+			continue
+		}
+		for _, record := range tables {
+			record.Date = date
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+	return records, nil
+}
+
+// showDorisData 按列名读取 SHOW DATA 的结果，不同版本的列不完全相同
+func showDorisData(ctx context.Context, conn *sql.Conn, db string) ([]*model.DorisTable, error) {
+	_, err := conn.ExecContext(ctx, "USE `"+db+"`")
+	if err != nil {
+		return nil, failure.Wrap(err, failure.Context{"op": "use", "db": db})
+	}
+	rows, err := conn.QueryContext(ctx, "SHOW DATA")
+	if err != nil {
+		return nil, failure.Wrap(err, failure.Context{"op": "show data", "db": db})
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, failure.Wrap(err, failure.Context{"op": "show data", "db": db})
+	}
+
+	var records []*model.DorisTable
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, failure.Wrap(err, failure.Context{"op": "show data", "db": db})
+		}
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			row[column] = values[i].String
+		}
+		if dorisSummaryRows[row["TableName"]] {
+			continue
+		}
+		record := &model.DorisTable{Db: db, Table: row["TableName"]}
+		record.Size, err = parseDorisSize(row["Size"])
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := row["RemoteSize"]; ok {
+			record.RemoteSize, err = parseDorisSize(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		record.Replicas, _ = strconv.ParseInt(row["ReplicaCount"], 10, 64)
+		records = append(records, record)
+	}
+	return records, failure.Wrap(rows.Err())
+}
+
+// parseDorisSize 解析 SHOW DATA 中 1.234 GB 形式的大小，FE 按 1024 进位但单位写作 KB、MB
+func parseDorisSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if len(value) > 2 && strings.HasSuffix(value, "B") && value[len(value)-2] != ' ' {
+		value = value[:len(value)-1] + "iB"
+	}
+	return parseByteSize(value)
+}
+
+// queryStrings 返回只有一列的查询结果
+func queryStrings(ctx context.Context, conn *sql.Conn, query string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, failure.Wrap(err)
+		}
+		values = append(values, value)
+	}
+	return values, failure.Wrap(rows.Err())
+}
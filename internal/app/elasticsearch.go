@@ -0,0 +1,145 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultElasticsearchIndexPrefix = "counter-hive"
+	defaultElasticsearchBatchSize   = 5000
+	defaultElasticsearchTimeout     = time.Minute
+)
+
+// elasticsearchSink 通过 bulk 接口写入按天划分的索引，兼容 OpenSearch
+type elasticsearchSink struct {
+	client *http.Client
+}
+
+type elasticsearchDocument struct {
+	jsonRow
+	Timestamp string `json:"@timestamp"`
+}
+
+type elasticsearchBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+func newElasticsearchSink() *elasticsearchSink {
+	timeout := config.Elasticsearch.Timeout
+	if timeout <= 0 {
+		timeout = defaultElasticsearchTimeout
+	}
+	return &elasticsearchSink{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *elasticsearchSink) Open(ctx context.Context) error {
+	return nil
+}
+
+func (s *elasticsearchSink) WriteBatch(ctx context.Context, entities []*model.Hive) error {
+	batchSize := config.Elasticsearch.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultElasticsearchBatchSize
+	}
+
+	for start := 0; start < len(entities); start += batchSize {
+		end := start + batchSize
+		if end > len(entities) {
+			end = len(entities)
+		}
+		if err := s.bulk(ctx, entities[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *elasticsearchSink) bulk(ctx context.Context, entities []*model.Hive) error {
+	prefix := config.Elasticsearch.IndexPrefix
+	if prefix == "" {
+		prefix = defaultElasticsearchIndexPrefix
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, entity := range entities {
+		// 以 db.table 作为文档 id，同一天重复抓取时覆盖
+		action := map[string]map[string]string{
+			"index": {
+				"_index": prefix + "-" + entity.Date.Format("2006.01.02"),
+				"_id":    entity.Db + "." + entity.Table,
+			},
+		}
+		if err := encoder.Encode(action); err != nil {
+			return failure.Wrap(err)
+		}
+		err := encoder.Encode(elasticsearchDocument{
+			jsonRow:   newJSONRow(entity),
+			Timestamp: entity.Date.Format(time.RFC3339),
+		})
+		if err != nil {
+			return failure.Wrap(err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(config.Elasticsearch.Url, "/")+"/_bulk", &body)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if config.Elasticsearch.ApiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+config.Elasticsearch.ApiKey)
+	} else if config.Elasticsearch.Username != "" {
+		req.SetBasicAuth(config.Elasticsearch.Username, config.Elasticsearch.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return failure.Wrap(fmt.Errorf("elasticsearch bulk failed with %s: %s", resp.Status, bytes.TrimSpace(message)))
+	}
+
+	var result elasticsearchBulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return failure.Wrap(err)
+	}
+	if !result.Errors {
+		return nil
+	}
+	for _, item := range result.Items {
+		for _, status := range item {
+			if status.Status/100 != 2 {
+				return failure.Wrap(fmt.Errorf("elasticsearch bulk item failed with %d: %s: %s", status.Status, status.Error.Type, status.Error.Reason))
+			}
+		}
+	}
+	return nil
+}
+
+func (s *elasticsearchSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
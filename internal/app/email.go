@@ -0,0 +1,197 @@
+package app
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 邮件附件
+const (
+	emailAttachHTML = "html"
+	emailAttachXlsx = "xlsx"
+)
+
+// emailAttachment 邮件附件
+type emailAttachment struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// sendSummaryEmail 发送运行摘要，按配置附带 HTML 报表和 xlsx 报表
+func sendSummaryEmail(summary *runSummary, entities []*model.Hive) error {
+	var attachments []emailAttachment
+	for _, attach := range config.Email.Attach {
+		name := "counter-" + summary.date.Format("2006-01-02") + "." + attach
+		switch attach {
+		case emailAttachHTML:
+			data := buildReport(entities, summary.previous, 20)
+			data.Date = summary.date.Format("2006-01-02")
+			if len(summary.previous) > 0 {
+				data.CompareDate = summary.date.AddDate(0, 0, -1).Format("2006-01-02")
+			}
+			content, err := renderReport(reportHTML, data)
+			if err != nil {
+				return err
+			}
+			attachments = append(attachments, emailAttachment{name: name, contentType: "text/html; charset=utf-8", data: content})
+		case emailAttachXlsx:
+			var buf bytes.Buffer
+			err := writeXlsxReport(&buf, entities)
+			if err != nil {
+				return err
+			}
+			attachments = append(attachments, emailAttachment{name: name, contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", data: buf.Bytes()})
+		default:
+			return failure.Wrap(fmt.Errorf("unknown email attachment: %s", attach))
+		}
+	}
+
+	subject := summary.title()
+	if config.Email.SubjectPrefix != "" {
+		subject = config.Email.SubjectPrefix + " " + subject
+	}
+	message, err := buildEmail(subject, summary.text(), attachments)
+	if err != nil {
+		return err
+	}
+	return sendEmail(message)
+}
+
+// buildEmail 构造 multipart/mixed 邮件，正文为纯文本
+func buildEmail(subject, body string, attachments []emailAttachment) ([]byte, error) {
+	var (
+		buf    bytes.Buffer
+		writer = multipart.NewWriter(&buf)
+	)
+	fmt.Fprintf(&buf, "From: %s\r\n", config.Email.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(config.Email.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	writeBase64(part, []byte(body))
+
+	for _, attachment := range attachments {
+		part, err = writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.name})},
+		})
+		if err != nil {
+			return nil, failure.Wrap(err)
+		}
+		writeBase64(part, attachment.data)
+	}
+	err = writer.Close()
+	return buf.Bytes(), failure.Wrap(err)
+}
+
+// writeBase64 每行 76 个字符
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+// sendEmail 端口为 465 或开启 tls 时直接使用 TLS 连接，否则在服务端支持时使用 STARTTLS
+func sendEmail(message []byte) error {
+	port := config.Email.Port
+	if port == 0 {
+		port = 25
+	}
+	address := net.JoinHostPort(config.Email.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: config.Email.Host}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if config.Email.Tls || port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	client, err := smtp.NewClient(conn, config.Email.Host)
+	if err != nil {
+		conn.Close()
+		return failure.Wrap(err)
+	}
+	defer client.Close()
+
+	if _, ok := conn.(*tls.Conn); !ok {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			err = client.StartTLS(tlsConfig)
+			if err != nil {
+				return failure.Wrap(err)
+			}
+		}
+	}
+	if config.Email.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", config.Email.Username, config.Email.Password, config.Email.Host))
+		if err != nil {
+			return failure.Wrap(err)
+		}
+	}
+	err = client.Mail(emailAddress(config.Email.From))
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	for _, to := range config.Email.To {
+		err = client.Rcpt(emailAddress(to))
+		if err != nil {
+			return failure.Wrap(err)
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	_, err = writer.Write(message)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	return failure.Wrap(client.Quit())
+}
+
+// emailAddress 从 "名称 <地址>" 中取出地址
+func emailAddress(address string) string {
+	if start := strings.LastIndex(address, "<"); start >= 0 {
+		if end := strings.LastIndex(address, ">"); end > start {
+			return address[start+1 : end]
+		}
+	}
+	return strings.TrimSpace(address)
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultEsIndicesTimeout = 30 * time.Second
+
+// esCatIndex _cat/indices 以 json 格式返回的一行，数值也是字符串，关闭的索引没有大小和文档数量
+type esCatIndex struct {
+	Index        string `json:"index"`
+	Health       string `json:"health"`
+	Status       string `json:"status"`
+	Pri          string `json:"pri"`
+	Rep          string `json:"rep"`
+	DocsCount    string `json:"docs.count"`
+	StoreSize    string `json:"store.size"`
+	PriStoreSize string `json:"pri.store.size"`
+}
+
+// esIndexCounter 通过 _cat/indices 接口统计每个索引的大小，兼容 OpenSearch
+var esIndexCounter = &counter{
+	name:  "elasticsearch",
+	usage: "通过 elasticsearch_indices.url 的 _cat/indices 接口统计每个索引的大小、文档数量和分片数量并保存到 es_index 表",
+	model: &model.EsIndex{},
+	keys:  []string{"index"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		records, err := countEsIndices(ctx, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"index", "health", "status", "shards", "replicas", "docs", "size", "raw_size"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{record.Index, record.Health, record.Status, record.Shards, record.Replicas, record.DocsCount, byteSize(record.Size), byteSize(record.RawSize)})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countEsIndices 按大小降序返回每个索引，跳过以 . 开头的系统索引
+func countEsIndices(ctx context.Context, date time.Time) ([]*model.EsIndex, error) {
+	if config.ElasticsearchIndices.Url == "" {
+		return nil, failure.Wrap(fmt.Errorf("elasticsearch_indices.url is empty"))
+	}
+	indices, err := fetchEsCatIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*model.EsIndex
+	for _, index := range indices {
+		if strings.HasPrefix(index.Index, ".") {
+			continue
+		}
+		record := &model.EsIndex{
+			Index:  index.Index,
+			Health: index.Health,
+			Status: index.Status,
+			Date:   date,
+		}
+		// 关闭的索引这些字段为空，保持为 0
+		record.Shards, _ = strconv.Atoi(index.Pri)
+		record.Replicas, _ = strconv.Atoi(index.Rep)
+		record.DocsCount, _ = strconv.ParseInt(index.DocsCount, 10, 64)
+		record.Size, _ = strconv.ParseInt(index.PriStoreSize, 10, 64)
+		record.RawSize, _ = strconv.ParseInt(index.StoreSize, 10, 64)
+		if index.Status == "close" {
+			record.Desc = "索引已关闭"
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+	return records, nil
+}
+
+// fetchEsCatIndices 大小以 bytes 为单位返回
+func fetchEsCatIndices(ctx context.Context) ([]esCatIndex, error) {
+	timeout := config.ElasticsearchIndices.Timeout
+	if timeout <= 0 {
+		timeout = defaultEsIndicesTimeout
+	}
+	url := strings.TrimSuffix(config.ElasticsearchIndices.Url, "/") + "/_cat/indices?format=json&bytes=b&h=index,health,status,pri,rep,docs.count,store.size,pri.store.size"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	if config.ElasticsearchIndices.ApiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+config.ElasticsearchIndices.ApiKey)
+	} else if config.ElasticsearchIndices.Username != "" {
+		req.SetBasicAuth(config.ElasticsearchIndices.Username, config.ElasticsearchIndices.Password)
+	}
+
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return nil, failure.Wrap(fmt.Errorf("_cat/indices failed with %s: %s", resp.Status, bytes.TrimSpace(message)))
+	}
+
+	var indices []esCatIndex
+	err = json.NewDecoder(resp.Body).Decode(&indices)
+	return indices, failure.Wrap(err)
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+const (
+	exportXlsx = "xlsx"
+
+	// exportTopTables 每个工作表中高亮的最大的表的数量
+	exportTopTables = 10
+)
+
+const gigabyte = 1 << 30
+
+// runExport 将数据库中某一天的结果导出为报表
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", exportXlsx, "导出格式，可选 xlsx")
+	dateFlag := flags.String("date", "", "导出的日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	fileFlag := flags.String("output-file", "", "导出文件，默认为 counter-<date>.<format>")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+	if *format != exportXlsx {
+		log.Fatal(fmt.Sprintf("不支持的导出格式: %s", *format))
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	date, err := resolveDate(ctx, store, *dateFlag)
+	if err != nil {
+		log.Fatal("获取导出日期失败: " + err.Error())
+	}
+
+	records, err := loadRecords(ctx, store, date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	if len(records) == 0 {
+		log.Fatal(fmt.Sprintf("%s 没有数据", date.Format("2006-01-02")))
+	}
+
+	path := *fileFlag
+	if path == "" {
+		path = fmt.Sprintf("counter-%s.%s", date.Format("2006-01-02"), *format)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatal("创建导出文件失败: " + err.Error())
+	}
+	err = writeXlsxReport(file, records)
+	if err == nil {
+		err = failure.Wrap(file.Close())
+	} else {
+		file.Close()
+	}
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	log.Printf("已导出 %d 张表到 %s", len(records), path)
+}
+
+// writeXlsxReport 第一个工作表为按库汇总，之后每个库一个工作表，均按大小降序排列，并高亮最大的表
+func writeXlsxReport(w io.Writer, records []*model.Hive) error {
+	type dbSummary struct {
+		name     string
+		records  []*model.Hive
+		size     int64
+		failures int64
+	}
+
+	var (
+		dbs     []*dbSummary
+		current *dbSummary
+	)
+	for _, record := range records {
+		if current == nil || current.name != record.Db {
+			current = &dbSummary{name: record.Db}
+			dbs = append(dbs, current)
+		}
+		current.records = append(current.records, record)
+		if record.Size > 0 {
+			current.size += record.Size
+		}
+		if record.Desc != "" {
+			current.failures++
+		}
+	}
+	sort.SliceStable(dbs, func(i, j int) bool {
+		return dbs[i].size > dbs[j].size
+	})
+
+	var workbook xlsxWorkbook
+
+	summary := workbook.addSheet("汇总", 32, 12, 20, 14, 12)
+	summary.addRow(
+		xlsxCell{value: "库", style: xlsxStyleHeader},
+		xlsxCell{value: "表数量", style: xlsxStyleHeader},
+		xlsxCell{value: "大小 (bytes)", style: xlsxStyleHeader},
+		xlsxCell{value: "大小 (GiB)", style: xlsxStyleHeader},
+		xlsxCell{value: "失败数", style: xlsxStyleHeader},
+	)
+	var total dbSummary
+	for _, db := range dbs {
+		summary.addRow(
+			xlsxCell{value: db.name},
+			xlsxCell{value: int64(len(db.records)), style: xlsxStyleInteger},
+			xlsxCell{value: db.size, style: xlsxStyleInteger},
+			xlsxCell{value: float64(db.size) / gigabyte, style: xlsxStyleDecimal},
+			xlsxCell{value: db.failures, style: xlsxStyleInteger},
+		)
+		total.size += db.size
+		total.failures += db.failures
+	}
+	last := len(dbs) + 1
+	summary.addRow(
+		xlsxCell{value: "合计", style: xlsxStyleTotal},
+		xlsxCell{value: int64(len(records)), formula: fmt.Sprintf("SUM(B2:B%d)", last), style: xlsxStyleTotalInteger},
+		xlsxCell{value: total.size, formula: fmt.Sprintf("SUM(C2:C%d)", last), style: xlsxStyleTotalInteger},
+		xlsxCell{value: float64(total.size) / gigabyte, formula: fmt.Sprintf("SUM(D2:D%d)", last), style: xlsxStyleTotalDecimal},
+		xlsxCell{value: total.failures, formula: fmt.Sprintf("SUM(E2:E%d)", last), style: xlsxStyleTotalInteger},
+	)
+	summary.highlightTop(fmt.Sprintf("C2:C%d", last), exportTopTables)
+	summary.dataBar(fmt.Sprintf("D2:D%d", last))
+
+	for _, db := range dbs {
+		sort.SliceStable(db.records, func(i, j int) bool {
+			return db.records[i].Size > db.records[j].Size
+		})
+
+		sheet := workbook.addSheet(db.name, 40, 60, 20, 14, 40)
+		sheet.addRow(
+			xlsxCell{value: "表", style: xlsxStyleHeader},
+			xlsxCell{value: "路径", style: xlsxStyleHeader},
+			xlsxCell{value: "大小 (bytes)", style: xlsxStyleHeader},
+			xlsxCell{value: "大小 (GiB)", style: xlsxStyleHeader},
+			xlsxCell{value: "备注", style: xlsxStyleHeader},
+		)
+		for _, record := range db.records {
+			sheet.addRow(
+				xlsxCell{value: record.Table},
+				xlsxCell{value: record.Location},
+				xlsxCell{value: record.Size, style: xlsxStyleInteger},
+				xlsxCell{value: float64(record.Size) / gigabyte, style: xlsxStyleDecimal},
+				xlsxCell{value: record.Desc},
+			)
+		}
+		// 获取失败的表大小为 -1，用 SUMIF 排除
+		last := len(db.records) + 1
+		sheet.addRow(
+			xlsxCell{value: "合计", style: xlsxStyleTotal},
+			xlsxCell{value: "", style: xlsxStyleTotal},
+			xlsxCell{value: db.size, formula: fmt.Sprintf(`SUMIF(C2:C%d,">0")`, last), style: xlsxStyleTotalInteger},
+			xlsxCell{value: float64(db.size) / gigabyte, formula: fmt.Sprintf(`SUMIF(D2:D%d,">0")`, last), style: xlsxStyleTotalDecimal},
+			xlsxCell{value: "", style: xlsxStyleTotal},
+		)
+		sheet.highlightTop(fmt.Sprintf("C2:C%d", last), exportTopTables)
+		sheet.dataBar(fmt.Sprintf("D2:D%d", last))
+	}
+
+	return workbook.write(w)
+}
@@ -0,0 +1,335 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 名单条目的来源
+const (
+	filterSourceConfig = "config"
+	filterSourceStore  = "store"
+	filterSourceMysql  = "mysql"
+	filterSourceURL    = "url"
+)
+
+const (
+	defaultFilterQuery   = "SELECT `list`, `db` FROM `hive_filter`"
+	defaultFilterTimeout = 10 * time.Second
+)
+
+// dbLists 一次运行使用的黑名单和白名单，白名单不为空时只抓取白名单中的库
+type dbLists struct {
+	blacklist map[string]bool
+	whitelist map[string]bool
+}
+
+// skip 库在黑名单中，或者配置了白名单但库不在白名单中
+func (l *dbLists) skip(db string) bool {
+	if l.blacklist[db] {
+		return true
+	}
+	return len(l.whitelist) > 0 && !l.whitelist[db]
+}
+
+// loadDbLists 合并配置文件、filters.mysql、filters.url 和 hive_filter 表中的名单，每次运行时重新读取
+func loadDbLists(ctx context.Context) (*dbLists, error) {
+	lists := &dbLists{blacklist: make(map[string]bool), whitelist: make(map[string]bool)}
+	entries, err := listFilters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		switch entry.List {
+		case model.FilterBlacklist:
+			lists.blacklist[entry.Db] = true
+		case model.FilterWhitelist:
+			lists.whitelist[entry.Db] = true
+		}
+	}
+	return lists, nil
+}
+
+// filterEntry 名单中的一个库
+type filterEntry struct {
+	List    string     `json:"list"`
+	Db      string     `json:"db"`
+	Source  string     `json:"source"`
+	Comment string     `json:"comment,omitempty"`
+	Created *time.Time `json:"created,omitempty"`
+}
+
+// listFilters 返回配置文件、filters.mysql、filters.url 和 hive_filter 表中的所有条目
+func listFilters(ctx context.Context) ([]filterEntry, error) {
+	var entries []filterEntry
+	for _, db := range config.Blacklist.Db {
+		entries = append(entries, filterEntry{List: model.FilterBlacklist, Db: db, Source: filterSourceConfig})
+	}
+	for _, db := range config.Whitelist.Db {
+		entries = append(entries, filterEntry{List: model.FilterWhitelist, Db: db, Source: filterSourceConfig})
+	}
+	if config.Filters.Mysql.Dsn != "" {
+		remote, err := mysqlFilters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, remote...)
+	}
+	if config.Filters.Url != "" {
+		remote, err := urlFilters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, remote...)
+	}
+	if !config.Filters.Store {
+		return entries, nil
+	}
+
+	store, err := openStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	err = store.Migrate(ctx, &model.HiveFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var records []*model.HiveFilter
+	err = store.DB().WithContext(ctx).Order("id").Find(&records).Error
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	for _, record := range records {
+		created := record.Created
+		entries = append(entries, filterEntry{List: record.List, Db: record.Db, Source: filterSourceStore, Comment: record.Comment, Created: &created})
+	}
+	return entries, nil
+}
+
+// mysqlFilters 执行 filters.mysql.query 读取名单，不认识的名单类型跳过
+func mysqlFilters(ctx context.Context) ([]filterEntry, error) {
+	db, err := sql.Open("mysql", config.Filters.Mysql.Dsn)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, config.Filters.Mysql.Query)
+	if err != nil {
+		return nil, failure.Wrap(err, failure.Context{"op": "load filters", "query": config.Filters.Mysql.Query})
+	}
+	defer rows.Close()
+	var entries []filterEntry
+	for rows.Next() {
+		entry := filterEntry{Source: filterSourceMysql}
+		err = rows.Scan(&entry.List, &entry.Db)
+		if err != nil {
+			return nil, failure.Wrap(err, failure.Context{"op": "load filters", "query": config.Filters.Mysql.Query})
+		}
+		if validFilterList(entry.List) && entry.Db != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, failure.Wrap(rows.Err(), failure.Context{"op": "load filters", "query": config.Filters.Mysql.Query})
+}
+
+// urlFilters 从 filters.url 读取名单，不认识的名单类型跳过
+func urlFilters(ctx context.Context) ([]filterEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.Filters.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.Filters.Url, nil)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	for name, value := range config.Filters.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, failure.Wrap(err, failure.Context{"op": "load filters", "url": config.Filters.Url})
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, failure.Wrap(fmt.Errorf("unexpected status %s", resp.Status), failure.Context{"op": "load filters", "url": config.Filters.Url})
+	}
+
+	var remote []filterEntry
+	err = json.NewDecoder(resp.Body).Decode(&remote)
+	if err != nil {
+		return nil, failure.Wrap(err, failure.Context{"op": "load filters", "url": config.Filters.Url})
+	}
+	var entries []filterEntry
+	for _, entry := range remote {
+		if validFilterList(entry.List) && entry.Db != "" {
+			entries = append(entries, filterEntry{List: entry.List, Db: entry.Db, Source: filterSourceURL, Comment: entry.Comment})
+		}
+	}
+	return entries, nil
+}
+
+// handleFilters GET /filters 返回所有名单；POST /filters/{list} 添加，请求体为 {"db": "xxx", "comment": "xxx"}；DELETE /filters/{list}/{db} 删除
+//
+// 只能删除通过 API 添加的条目，配置文件中的条目需要修改配置文件，filters.mysql 和 filters.url 中的条目需要在来源中修改
+//
+// POST 和 DELETE 需要 serve.bearer_token
+func (s *server) handleFilters(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, "/filters"), "/"), "/")
+	if parts[0] == "" {
+		parts = nil
+	}
+	ctx := req.Context()
+
+	switch {
+	case req.Method == http.MethodGet && len(parts) <= 1:
+		entries, err := listFilters(ctx)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		result := []filterEntry{}
+		for _, entry := range entries {
+			if len(parts) == 0 || entry.List == parts[0] {
+				result = append(result, entry)
+			}
+		}
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].List < result[j].List
+		})
+		writeJSON(w, http.StatusOK, result)
+	case req.Method == http.MethodPost && len(parts) == 1:
+		if !authorize(w, req) {
+			return
+		}
+		if !validFilterList(parts[0]) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("unknown list %s", parts[0]))
+			return
+		}
+		var body struct {
+			Db      string `json:"db"`
+			Comment string `json:"comment"`
+		}
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if body.Db == "" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("db is required"))
+			return
+		}
+		record, err := addFilter(ctx, parts[0], body.Db, body.Comment)
+		if err != nil {
+			writeError(w, filterErrorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, filterEntry{List: record.List, Db: record.Db, Source: filterSourceStore, Comment: record.Comment, Created: &record.Created})
+	case req.Method == http.MethodDelete && len(parts) == 2:
+		if !authorize(w, req) {
+			return
+		}
+		if !validFilterList(parts[0]) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("unknown list %s", parts[0]))
+			return
+		}
+		err := removeFilter(ctx, parts[0], parts[1])
+		if err != nil {
+			writeError(w, filterErrorStatus(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+	}
+}
+
+func validFilterList(list string) bool {
+	return list == model.FilterBlacklist || list == model.FilterWhitelist
+}
+
+var (
+	errFilterStoreDisabled = errors.New("filters.store is not enabled")
+	errFilterExists        = errors.New("db already in list")
+	errFilterNotFound      = errors.New("db not in list")
+	errFilterInConfig      = errors.New("db is configured in config file")
+)
+
+func filterErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errFilterStoreDisabled), errors.Is(err, errFilterExists), errors.Is(err, errFilterInConfig):
+		return http.StatusConflict
+	case errors.Is(err, errFilterNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// addFilter 将库添加到 hive_filter 表，下一次运行生效
+func addFilter(ctx context.Context, list, db, comment string) (*model.HiveFilter, error) {
+	if !config.Filters.Store {
+		return nil, failure.Wrap(errFilterStoreDisabled)
+	}
+	store, err := openStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	err = store.Migrate(ctx, &model.HiveFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	err = store.DB().WithContext(ctx).Model(&model.HiveFilter{}).Where("list = ? AND db = ?", list, db).Count(&count).Error
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	if count > 0 {
+		return nil, failure.Wrap(fmt.Errorf("%w: %s", errFilterExists, db))
+	}
+	record := &model.HiveFilter{List: list, Db: db, Comment: comment, Created: time.Now()}
+	return record, failure.Wrap(store.DB().WithContext(ctx).Create(record).Error)
+}
+
+// removeFilter 从 hive_filter 表删除库，下一次运行生效
+func removeFilter(ctx context.Context, list, db string) error {
+	configured := config.Blacklist.Db
+	if list == model.FilterWhitelist {
+		configured = config.Whitelist.Db
+	}
+	for _, name := range configured {
+		if name == db {
+			return failure.Wrap(fmt.Errorf("%w: %s", errFilterInConfig, db))
+		}
+	}
+	if !config.Filters.Store {
+		return failure.Wrap(errFilterStoreDisabled)
+	}
+
+	store, err := openStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	err = store.Migrate(ctx, &model.HiveFilter{})
+	if err != nil {
+		return err
+	}
+	result := store.DB().WithContext(ctx).Where("list = ? AND db = ?", list, db).Delete(&model.HiveFilter{})
+	if result.Error != nil {
+		return failure.Wrap(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return failure.Wrap(fmt.Errorf("%w: %s", errFilterNotFound, db))
+	}
+	return nil
+}
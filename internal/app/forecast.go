@@ -0,0 +1,181 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultForecastHistory = 90
+
+	// levelCluster 整个集群的汇总
+	levelCluster = "cluster"
+)
+
+var defaultForecastHorizons = []int{30, 90, 180}
+
+// forecast 对一个库或整个集群的历史大小做线性回归
+type forecast struct {
+	level string
+	db    string
+	size  int64
+	// slope 平均每天增长的大小
+	slope     float64
+	intercept float64
+}
+
+// at 预测第 day 天（距 1970-01-01 的天数）的大小
+func (f *forecast) at(day int32) int64 {
+	return int64(math.Round(f.intercept + f.slope*float64(day)))
+}
+
+// runForecast 根据历史记录预测每个库和整个集群未来的大小，配置了集群容量时给出预计写满的日期
+func runForecast(args []string) {
+	flags := flag.NewFlagSet("forecast", flag.ExitOnError)
+	dateFlag := flags.String("date", "", "预测的起始日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	date, err := resolveDate(ctx, store, *dateFlag)
+	if err != nil {
+		log.Fatal("解析日期失败: " + err.Error())
+	}
+	history := config.Forecast.History
+	if history <= 0 {
+		history = defaultForecastHistory
+	}
+	records, err := loadRecordsBetween(ctx, store, date.AddDate(0, 0, -history), date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	horizons := config.Forecast.Horizons
+	if len(horizons) == 0 {
+		horizons = defaultForecastHorizons
+	}
+	today := epochDays(date)
+
+	header := []string{"level", "db", "size", "growth_per_day"}
+	for _, horizon := range horizons {
+		header = append(header, "forecast_"+strconv.Itoa(horizon)+"d")
+	}
+	header = append(header, "capacity_date")
+
+	var rows [][]interface{}
+	for _, f := range computeForecasts(records, today) {
+		row := []interface{}{f.level, f.db, byteSize(f.size), byteSize(int64(f.slope))}
+		for _, horizon := range horizons {
+			row = append(row, byteSize(f.at(today+int32(horizon))))
+		}
+		capacityDate := ""
+		if f.level == levelCluster && config.Forecast.Capacity > 0 {
+			capacityDate = forecastCapacityDate(f, date, today, config.Forecast.Capacity)
+		}
+		rows = append(rows, append(row, capacityDate))
+	}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+// computeForecasts 先按天汇总每个库和整个集群的大小，再分别做最小二乘拟合，集群排在第一位，库按大小降序排列
+func computeForecasts(records []*model.Hive, today int32) []*forecast {
+	var (
+		dbs     []string
+		series  = make(map[string]map[int32]int64)
+		cluster = make(map[int32]int64)
+	)
+	for _, record := range records {
+		if record.Size < 0 {
+			continue
+		}
+		points, ok := series[record.Db]
+		if !ok {
+			points = make(map[int32]int64)
+			series[record.Db] = points
+			dbs = append(dbs, record.Db)
+		}
+		day := epochDays(record.Date)
+		points[day] += record.Size
+		cluster[day] += record.Size
+	}
+
+	var forecasts []*forecast
+	if f := fitForecast(cluster, today); f != nil {
+		f.level = levelCluster
+		forecasts = append(forecasts, f)
+	}
+	var dbForecasts []*forecast
+	for _, db := range dbs {
+		if f := fitForecast(series[db], today); f != nil {
+			f.level = model.LevelDb
+			f.db = db
+			dbForecasts = append(dbForecasts, f)
+		}
+	}
+	sort.SliceStable(dbForecasts, func(i, j int) bool {
+		return dbForecasts[i].size > dbForecasts[j].size
+	})
+	return append(forecasts, dbForecasts...)
+}
+
+// fitForecast 当天没有数据或者历史数据少于两天时无法预测，返回 nil
+func fitForecast(points map[int32]int64, today int32) *forecast {
+	size, ok := points[today]
+	if !ok || len(points) < 2 {
+		return nil
+	}
+
+	// 以 today 为原点计算，避免天数过大损失精度
+	var sumX, sumY, sumXY, sumXX float64
+	for day, value := range points {
+		x := float64(day - today)
+		y := float64(value)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(points))
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	intercept := (sumY - slope*sumX) / n
+	return &forecast{
+		size:      size,
+		slope:     slope,
+		intercept: intercept - slope*float64(today),
+	}
+}
+
+// forecastCapacityDate 按拟合的增长速度预计达到容量的日期，已经超过容量时为 date，不增长时为空
+func forecastCapacityDate(f *forecast, date time.Time, today int32, capacity int64) string {
+	current := f.at(today)
+	if current >= capacity {
+		return date.Format("2006-01-02")
+	}
+	if f.slope <= 0 {
+		return ""
+	}
+	days := int(math.Ceil(float64(capacity-current) / f.slope))
+	return date.AddDate(0, 0, days).Format("2006-01-02")
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rea1shane/counter/pkg/model"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Grafana JSON 数据源的指标名称，db: 和 table: 后为库名和 db.table
+const (
+	grafanaCluster     = "cluster"
+	grafanaDbPrefix    = "db:"
+	grafanaTablePrefix = "table:"
+)
+
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Hide   bool   `json:"hide"`
+	} `json:"targets"`
+}
+
+type grafanaSeries struct {
+	Target string `json:"target"`
+	// Datapoints [大小, 毫秒时间戳]
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// registerGrafana 在 prefix 下实现 Grafana JSON 数据源（simpod-json-datasource 和 SimpleJSON）的接口
+func (s *server) registerGrafana(mux *http.ServeMux, prefix string) {
+	mux.HandleFunc(prefix+"/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != prefix+"/" {
+			http.NotFound(w, req)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc(prefix+"/search", s.handleGrafanaSearch)
+	mux.HandleFunc(prefix+"/metrics", s.handleGrafanaMetrics)
+	mux.HandleFunc(prefix+"/metric-payload-options", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, []interface{}{})
+	})
+	mux.HandleFunc(prefix+"/query", s.handleGrafanaQuery)
+}
+
+// grafanaTargets 最近一次抓取的集群、库和表，keyword 不为空时只返回包含 keyword 的指标
+func (s *server) grafanaTargets(req *http.Request, keyword string) ([]string, error) {
+	ctx := req.Context()
+	store, err := s.database()
+	if err != nil {
+		return nil, err
+	}
+	date, err := latestDate(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	records, err := loadRecords(ctx, store, date)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []string{grafanaCluster}
+	dbs, _ := topDbs(records)
+	for _, db := range dbs {
+		targets = append(targets, grafanaDbPrefix+db.name)
+	}
+	for _, record := range records {
+		targets = append(targets, grafanaTablePrefix+record.Db+"."+record.Table)
+	}
+	if keyword == "" {
+		return targets, nil
+	}
+	var matched []string
+	for _, target := range targets {
+		if strings.Contains(target, keyword) {
+			matched = append(matched, target)
+		}
+	}
+	return matched, nil
+}
+
+// handleGrafanaSearch SimpleJSON 的 /search，请求体为 {"target": "关键字"}，返回指标名称的列表
+func (s *server) handleGrafanaSearch(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Target string `json:"target"`
+	}
+	json.NewDecoder(req.Body).Decode(&body)
+	targets, err := s.grafanaTargets(req, body.Target)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if targets == nil {
+		targets = []string{}
+	}
+	writeJSON(w, http.StatusOK, targets)
+}
+
+// handleGrafanaMetrics simpod-json-datasource 的 /metrics，请求体为 {"metric": "关键字"}
+func (s *server) handleGrafanaMetrics(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Metric string `json:"metric"`
+	}
+	json.NewDecoder(req.Body).Decode(&body)
+	targets, err := s.grafanaTargets(req, body.Metric)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	metrics := []map[string]string{}
+	for _, target := range targets {
+		metrics = append(metrics, map[string]string{"label": target, "value": target})
+	}
+	writeJSON(w, http.StatusOK, metrics)
+}
+
+// handleGrafanaQuery 返回时间范围内每个指标每天的大小，获取失败的表当天没有数据点
+func (s *server) handleGrafanaQuery(w http.ResponseWriter, req *http.Request) {
+	var query grafanaQuery
+	err := json.NewDecoder(req.Body).Decode(&query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := req.Context()
+	store, err := s.database()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	from := query.Range.From.In(time.Local)
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	records, err := loadRecordsBetween(ctx, store, from, query.Range.To.In(time.Local))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := []grafanaSeries{}
+	for _, target := range query.Targets {
+		if target.Hide || target.Target == "" {
+			continue
+		}
+		match, err := grafanaMatcher(target.Target)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		daily := make(map[int32]int64)
+		for _, record := range records {
+			if record.Size >= 0 && match(record) {
+				daily[epochDays(record.Date)] += record.Size
+			}
+		}
+		series := grafanaSeries{Target: target.Target, Datapoints: [][2]float64{}}
+		for day, size := range daily {
+			year, month, dayOfMonth := time.Unix(int64(day)*86400, 0).UTC().Date()
+			timestamp := time.Date(year, month, dayOfMonth, 0, 0, 0, 0, time.Local).UnixNano() / int64(time.Millisecond)
+			series.Datapoints = append(series.Datapoints, [2]float64{float64(size), float64(timestamp)})
+		}
+		sort.Slice(series.Datapoints, func(i, j int) bool {
+			return series.Datapoints[i][1] < series.Datapoints[j][1]
+		})
+		result = append(result, series)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func grafanaMatcher(target string) (func(*model.Hive) bool, error) {
+	switch {
+	case target == grafanaCluster:
+		return func(*model.Hive) bool { return true }, nil
+	case strings.HasPrefix(target, grafanaDbPrefix):
+		db := strings.TrimPrefix(target, grafanaDbPrefix)
+		return func(record *model.Hive) bool { return record.Db == db }, nil
+	case strings.HasPrefix(target, grafanaTablePrefix):
+		name := strings.TrimPrefix(target, grafanaTablePrefix)
+		return func(record *model.Hive) bool { return record.Db+"."+record.Table == name }, nil
+	}
+	return nil, fmt.Errorf("unknown target: %s", target)
+}
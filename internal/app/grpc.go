@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"github.com/rea1shane/counter/pkg/api"
+	"github.com/rea1shane/counter/pkg/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+)
+
+// grpcServer 实现 api.CounterServer，运行队列和结果数据库与 HTTP API 共用
+type grpcServer struct {
+	api.UnimplementedCounterServer
+	server *server
+}
+
+// serveGrpc 阻塞直到监听失败
+func serveGrpc(listen string, s *server) error {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	g := grpc.NewServer()
+	api.RegisterCounterServer(g, &grpcServer{server: s})
+	log.Printf("gRPC 监听 %s", listen)
+	return g.Serve(listener)
+}
+
+func (g *grpcServer) TriggerRun(ctx context.Context, req *api.TriggerRunRequest) (*api.Run, error) {
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		authorization = md.Get("authorization")[0]
+	}
+	err := checkBearerToken(authorization)
+	if err == errTokenNotConfigured {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	r, err := g.server.trigger(&runFilter{Dbs: req.Dbs, Tables: req.Tables})
+	if err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return newAPIRun(r), nil
+}
+
+func (g *grpcServer) GetRunStatus(_ context.Context, req *api.GetRunStatusRequest) (*api.Run, error) {
+	r, ok := g.server.get(req.Id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "run %s not found", req.Id)
+	}
+	return newAPIRun(r), nil
+}
+
+func (g *grpcServer) QueryUsage(ctx context.Context, req *api.QueryUsageRequest) (*api.QueryUsageResponse, error) {
+	store, err := g.server.database()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	date, err := resolveDate(ctx, store, req.Date)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	db := store.DB().WithContext(ctx).Where("date = ?", date)
+	if req.Db != "" {
+		db = db.Where("db = ?", req.Db)
+	}
+	if req.Limit > 0 {
+		db = db.Limit(int(req.Limit))
+	}
+	var records []*model.Hive
+	err = db.Order("size desc").Find(&records).Error
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &api.QueryUsageResponse{Date: date.Format("2006-01-02")}
+	for _, record := range records {
+		resp.Usages = append(resp.Usages, &api.Usage{
+			Db:       record.Db,
+			Table:    record.Table,
+			Location: record.Location,
+			Size:     record.Size,
+			Desc:     record.Desc,
+		})
+	}
+	return resp, nil
+}
+
+func (g *grpcServer) Diff(ctx context.Context, req *api.DiffRequest) (*api.DiffResponse, error) {
+	if req.From == "" {
+		return nil, status.Error(codes.InvalidArgument, "from is required")
+	}
+	store, err := g.server.database()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	from, err := resolveDate(ctx, store, req.From)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	to, err := resolveDate(ctx, store, req.To)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	fromRecords, err := loadRecords(ctx, store, from)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	toRecords, err := loadRecords(ctx, store, to)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &api.DiffResponse{From: from.Format("2006-01-02"), To: to.Format("2006-01-02")}
+	add := func(level string, deltas []*sizeDelta) {
+		for _, d := range deltas {
+			if req.Limit > 0 && len(resp.Deltas) >= int(req.Limit) {
+				return
+			}
+			resp.Deltas = append(resp.Deltas, &api.Delta{
+				Level:    level,
+				Db:       d.db,
+				Table:    d.table,
+				FromSize: d.from,
+				ToSize:   d.to,
+				Delta:    d.delta(),
+				Percent:  d.percent(),
+				Status:   d.status,
+			})
+		}
+	}
+	tables := diffTables(fromRecords, toRecords)
+	if req.Level == "" || req.Level == model.LevelDb {
+		add(model.LevelDb, diffDbs(tables))
+	}
+	if req.Level == "" || req.Level == model.LevelTable {
+		add(model.LevelTable, tables)
+	}
+	return resp, nil
+}
+
+func newAPIRun(r serveRun) *api.Run {
+	run := &api.Run{
+		Id:      r.ID,
+		Status:  r.Status,
+		Done:    int64(r.Done),
+		Total:   int64(r.Total),
+		Tables:  int64(r.Tables),
+		Error:   r.Error,
+		Created: r.Created.Unix(),
+	}
+	if r.Started != nil {
+		run.Started = r.Started.Unix()
+	}
+	if r.Finished != nil {
+		run.Finished = r.Finished.Unix()
+	}
+	if r.Filter != nil {
+		run.FilterDbs = r.Filter.Dbs
+		run.FilterTables = r.Filter.Tables
+	}
+	return run
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/model"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultHbaseRoot = "/hbase"
+
+// hbaseCounter 按 hbase.rootdir/data/命名空间/表/region 的目录结构统计每张 HBase 表的大小和 region 数量
+var hbaseCounter = &counter{
+	name:  "hbase",
+	usage: "统计 hbase.root（默认 /hbase）下每张 HBase 表的大小和 region 数量并保存到 hbase 表",
+	model: &model.Hbase{},
+	keys:  []string{"namespace", "table"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		client, err := newHdfsClient()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		defer client.Close()
+		defer hdfssize.CloseOnDone(ctx, client)()
+
+		records, err := countHbase(client, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"namespace", "table", "regions", "size", "raw_size", "files", "desc"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{record.Namespace, record.Table, record.Regions, byteSize(record.Size), byteSize(record.RawSize), record.Files, record.Desc})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countHbase 跳过 hbase.exclude_namespaces 中的命名空间，结果按命名空间和表名排序
+func countHbase(client *hdfs.Client, date time.Time) ([]*model.Hbase, error) {
+	root := config.Hbase.Root
+	if root == "" {
+		root = defaultHbaseRoot
+	}
+	data := path.Join(root, "data")
+	namespaces, err := client.ReadDir(data)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	exclude := make(map[string]bool)
+	for _, namespace := range config.Hbase.ExcludeNamespaces {
+		exclude[namespace] = true
+	}
+
+	var records []*model.Hbase
+	for _, namespace := range namespaces {
+		if !namespace.IsDir() || exclude[namespace.Name()] {
+			continue
+		}
+		tables, err := client.ReadDir(path.Join(data, namespace.Name()))
+		if err != nil {
+			return nil, failure.Wrap(err)
+		}
+		for _, table := range tables {
+			if !table.IsDir() {
+				continue
+			}
+			record := &model.Hbase{
+				Namespace: namespace.Name(),
+				Table:     table.Name(),
+				Path:      path.Join(data, namespace.Name(), table.Name()),
+				Date:      date,
+			}
+			err := measureHbaseTable(client, record)
+			if err != nil {
+				record.Size = -1
+				record.Desc = err.Error()
+			}
+			records = append(records, record)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Namespace != records[j].Namespace {
+			return records[i].Namespace < records[j].Namespace
+		}
+		return records[i].Table < records[j].Table
+	})
+	return records, nil
+}
+
+// measureHbaseTable 表目录下除 .tabledesc、.tmp 等隐藏目录外的子目录均为 region
+func measureHbaseTable(client *hdfs.Client, record *model.Hbase) error {
+	children, err := client.ReadDir(record.Path)
+	if err != nil {
+		return failure.Wrap(err, failure.Context{"op": "readdir", "path": record.Path})
+	}
+	for _, child := range children {
+		if child.IsDir() && !strings.HasPrefix(child.Name(), ".") {
+			record.Regions++
+		}
+	}
+	summary, err := getPathContentSummary(client, record.Path)
+	if err != nil {
+		return err
+	}
+	record.Size = summary.Size()
+	record.RawSize = summary.SizeAfterReplication()
+	record.Files = int64(summary.FileCount())
+	return nil
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"context"
+	"github.com/rea1shane/counter/pkg/sink"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// readyCacheTTL 就绪检查会连接 Hive、HDFS 和数据库，在该时间内复用上一次的结果
+	readyCacheTTL = 30 * time.Second
+
+	// defaultReadyFailures 最近连续失败的运行达到该数量时视为未就绪
+	defaultReadyFailures = 3
+)
+
+type healthCheck struct {
+	Name  string `json:"name"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// readiness 缓存最近一次的依赖检查结果，mu 只保护缓存，连接依赖时不持有
+type readiness struct {
+	mu      sync.Mutex
+	checked time.Time
+	checks  []healthCheck
+	// checking 正在连接依赖时为 true，其他请求直接返回上一次的结果
+	checking bool
+}
+
+// handleHealthz 进程存活即返回 200
+func (s *server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz Hive、HDFS 和数据库都可以连接，最近的运行没有连续失败并且没有在退出时返回 200，否则返回 503
+func (s *server) handleReadyz(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	stopping := s.stopping
+	s.mu.Unlock()
+	if stopping {
+		writeJSON(w, http.StatusServiceUnavailable, []healthCheck{{Name: "server", Error: "shutting down"}})
+		return
+	}
+
+	checks := s.ready.check(req.Context(), s.checkDependencies)
+	checks = append(checks, s.checkRuns())
+
+	status := http.StatusOK
+	for _, c := range checks {
+		if !c.Ok {
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, status, checks)
+}
+
+// check 缓存过期时调用 dial 重新检查，其他请求在检查期间返回上一次的结果，还没有结果时各自检查
+func (r *readiness) check(ctx context.Context, dial func(context.Context) []healthCheck) []healthCheck {
+	r.mu.Lock()
+	if time.Since(r.checked) < readyCacheTTL || r.checking && r.checks != nil {
+		checks := append([]healthCheck(nil), r.checks...)
+		r.mu.Unlock()
+		return checks
+	}
+	r.checking = true
+	r.mu.Unlock()
+
+	checks := dial(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checking = false
+	r.checks, r.checked = checks, time.Now()
+	return append([]healthCheck(nil), checks...)
+}
+
+// checkDependencies 按抓取时的方式连接元数据来源和 HDFS，并检查启动时打开的数据库，没有配置数据库 sink 时不检查
+func (s *server) checkDependencies(ctx context.Context) []healthCheck {
+	checks := []healthCheck{
+		newHealthCheck("hive", checkSource(ctx)),
+		newHealthCheck("hdfs", checkHdfs()),
+	}
+	if s.store != nil {
+		checks = append(checks, newHealthCheck("database", pingStore(ctx, s.store)))
+	}
+	return checks
+}
+
+// checkRuns 最近 defaultReadyFailures 次运行全部失败时视为处于崩溃循环
+func (s *server) checkRuns() healthCheck {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failures := 0
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		r := s.runs[i]
+		if r.Status == runPending || r.Status == runRunning {
+			continue
+		}
+		if r.Status != runFailed {
+			break
+		}
+		failures++
+		if failures >= defaultReadyFailures {
+			return healthCheck{Name: "runs", Error: "recent runs failed: " + r.Error}
+		}
+	}
+	return healthCheck{Name: "runs", Ok: true}
+}
+
+// checkSource 打开 plugins.source 配置的外部程序或者 Hive，与抓取使用同一个 openSource
+func checkSource(ctx context.Context) error {
+	_, closeSource, err := openSource(ctx)
+	if err != nil {
+		return err
+	}
+	closeSource()
+	return nil
+}
+
+func pingStore(ctx context.Context, store *sink.Gorm) error {
+	db, err := store.DB().DB()
+	if err != nil {
+		return err
+	}
+	return db.PingContext(ctx)
+}
+
+func checkHdfs() error {
+	client, err := newHdfsClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	_, err = client.Stat("/")
+	return err
+}
+
+func newHealthCheck(name string, err error) healthCheck {
+	if err != nil {
+		return healthCheck{Name: name, Error: err.Error()}
+	}
+	return healthCheck{Name: name, Ok: true}
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReadinessDoesNotBlockWhileChecking(t *testing.T) {
+	r := &readiness{}
+	r.check(context.Background(), func(context.Context) []healthCheck {
+		return []healthCheck{{Name: "hive", Ok: true}}
+	})
+	r.checked = time.Now().Add(-readyCacheTTL)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan []healthCheck)
+	go func() {
+		done <- r.check(context.Background(), func(context.Context) []healthCheck {
+			close(started)
+			<-release
+			return []healthCheck{{Name: "hive", Error: "timeout"}}
+		})
+	}()
+	<-started
+
+	checked := make(chan []healthCheck)
+	go func() {
+		checked <- r.check(context.Background(), func(context.Context) []healthCheck {
+			t.Error("checked twice at the same time")
+			return nil
+		})
+	}()
+	select {
+	case checks := <-checked:
+		if len(checks) != 1 || !checks[0].Ok {
+			t.Errorf("checks = %+v, want the previous result", checks)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("check blocked by a slow dependency")
+	}
+
+	close(release)
+	if checks := <-done; len(checks) != 1 || checks[0].Ok {
+		t.Errorf("checks = %+v, want the new result", checks)
+	}
+	if checks := r.check(context.Background(), nil); len(checks) != 1 || checks[0].Ok {
+		t.Errorf("cached checks = %+v, want the new result", checks)
+	}
+}
@@ -0,0 +1,638 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/beltran/gohive"
+	"github.com/colinmarc/hdfs"
+	hivecounter "github.com/rea1shane/counter/pkg/counter"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/hivemeta"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	hdfsFlag = hdfssize.Flag
+
+	// defaultHiveSkipProperty 表的所有者选择不统计时设置的表属性
+	defaultHiveSkipProperty = "counter.skip"
+
+	// hive.temporary.action 可选的值
+	temporaryTag     = "tag"
+	temporaryExclude = "exclude"
+
+	// 元数据查询遇到临时错误时默认的重试次数和间隔
+	defaultHiveRetryTimes    = 2
+	defaultHiveRetryInterval = 5 * time.Second
+
+	defaultMetastoreTimeout = 30 * time.Second
+)
+
+var (
+	config *Config
+
+	output     = flag.String("output", "", "输出格式，指定后不再写入配置的 sink，可选 jsonl")
+	outputFile = flag.String("output-file", "", "输出文件，默认为标准输出")
+)
+
+// TODO 改为多线程
+
+// commandContext 收到 SIGTERM 或 SIGINT 时取消，正在进行的请求随之停止，分片、运行记录等清理仍然会执行
+func commandContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+}
+
+func currentDate() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// Main counter 命令的入口，第一个参数为子命令，没有子命令时抓取一次 hive 表的大小
+func Main() {
+	// 子命令
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "top":
+			runTop(os.Args[2:])
+			return
+		case "trend":
+			runTrend(os.Args[2:])
+			return
+		case "forecast":
+			runForecast(os.Args[2:])
+			return
+		case "runway":
+			runRunway(os.Args[2:])
+			return
+		case "chargeback":
+			runChargeback(os.Args[2:])
+			return
+		case "report":
+			runReport(os.Args[2:])
+			return
+		case "smallfiles":
+			runSmallFiles(os.Args[2:])
+			return
+		case "namespace":
+			runNamespace(os.Args[2:])
+			return
+		case "cold":
+			runCold(os.Args[2:])
+			return
+		case "retention":
+			runRetention(os.Args[2:])
+			return
+		case "lifecycle":
+			runLifecycle(os.Args[2:])
+			return
+		case "rollup":
+			runRollup(os.Args[2:])
+			return
+		case "prune":
+			runPrune(os.Args[2:])
+			return
+		case "serve":
+			Serve(os.Args[2:])
+			return
+		case "rescan":
+			runRescan(os.Args[2:])
+			return
+		case "counters":
+			runCounters(os.Args[2:])
+			return
+		case "config":
+			runConfig(os.Args[2:])
+			return
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		case "version":
+			runVersion(os.Args[2:])
+			return
+		}
+		if c := findCounter(os.Args[1]); c != nil {
+			runCounter(c, os.Args[2:])
+			return
+		}
+	}
+
+	flag.Parse()
+
+	// 读取配置文件
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+	entities, err := run(ctx, nil, nil)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	// 部分表获取失败时按配置的退出码退出，便于 Kubernetes Job 区分部分失败
+	if config.Exit.PartialFailure != 0 {
+		for _, entity := range entities {
+			if entity.Desc != "" {
+				log.Printf("部分表获取失败，退出码 %d", config.Exit.PartialFailure)
+				os.Exit(config.Exit.PartialFailure)
+			}
+		}
+	}
+}
+
+// run 抓取一次 hive 表的大小并写入 sink，filter 不为空时只抓取匹配的库或表，progress 用于报告进度，均可以为 nil。
+// runErr 只包括导致抓取失败的错误，汇总、推送和通知失败只记录日志，不影响运行结果
+func run(ctx context.Context, filter *runFilter, progress func(done, total int)) (_ []*model.Hive, runErr error) {
+	// 归属映射在抓取前读取，避免抓取完成后才发现格式错误
+	rules, err := loadOwnershipRules()
+	if err != nil {
+		return nil, err
+	}
+
+	// 黑名单和白名单
+	lists, err := loadDbLists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分片，指定了库或表的运行（包括重新抓取）由收到请求的实例完整执行，不分片
+	var shard *shard
+	if filter.empty() {
+		shard, err = joinShard()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 获取当前日期
+	start := time.Now()
+	date := currentDate()
+
+	// 运行指标、审计记录和回调
+	stats := newRunStats(start)
+	defaultLogger.setRunID(stats.id)
+	defer defaultLogger.setRunID("")
+	defer func() { stats.finish(ctx, date, filter, shard, runErr) }()
+
+	// stepErr 只记录日志的 sink、汇总、推送和通知中最后一个错误
+	var stepErr error
+
+	// trace
+	tracer := newTracer()
+	defer tracer.flush()
+	// 根 span 记录运行的错误，运行成功时记录只记录日志的汇总、推送失败
+	root := tracer.start("run", newOtlpAttribute("run.id", stats.id), newOtlpAttribute("date", date.Format("2006-01-02")))
+	defer func() {
+		if runErr != nil {
+			root.end(runErr)
+			return
+		}
+		root.end(stepErr)
+	}()
+
+	// 元数据来源
+	source, closeSource, err := openSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSource()
+
+	// hdfs
+	hdfsClient, err := newHdfsClient()
+	if err != nil {
+		return nil, err
+	}
+	defer hdfsClient.Close()
+	defer hdfssize.CloseOnDone(ctx, hdfsClient)()
+
+	// sink
+	output, err := openSink(ctx, hdfsClient)
+	if err != nil {
+		return nil, err
+	}
+	defer output.Close()
+
+	// fetch
+	scan := &scanHooks{stats: stats, hdfsClient: hdfsClient}
+	hooks := scan.hooks()
+	scan.stage = root.child("hive.fetch")
+	entities, err := hivecounter.Fetch(ctx, source, func(db string) bool {
+		return lists.skip(db) || !filter.matchDb(db) || !shard.owns(db)
+	}, func(db, table string) bool {
+		return !filter.matchTable(db, table)
+	}, hooks)
+	scan.stage.end(err)
+	if err != nil {
+		return nil, err
+	}
+	stats.setEntities(entities)
+
+	sizes, closeSizes, err := openSizes(ctx, hdfsClient)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSizes()
+
+	// size
+	scan.stage = root.child("hdfs.size")
+	err = hivecounter.Measure(ctx, sizes, entities, progress, hooks)
+	scan.stage.end(err)
+	if err != nil {
+		return nil, err
+	}
+
+	// ownership
+	tagOwnership(entities, rules)
+
+	// write to sink
+	for _, entity := range entities {
+		entity.Date = date
+		entity.RunID = stats.id
+		entity.CounterVersion = counterVersion()
+	}
+	if storeConfigured() {
+		err := fillDeltas(ctx, date, entities)
+		if err != nil {
+			log.Printf("计算与上一次抓取的变化失败: %+v", err)
+		}
+	}
+	writeSpan := root.child("sink.write", newOtlpAttribute("sink", strings.Join(config.Sink.Types, ",")), newOtlpAttribute("rows", strconv.Itoa(len(entities))))
+	writeStart := time.Now()
+	err = output.WriteBatch(ctx, entities)
+	stats.observe(stageSinkWrite, writeStart)
+	writeSpan.end(err)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := output.(*multiSink); ok && len(m.Failed()) > 0 {
+		// 部分 sink 失败时数据已经写入其他 sink，继续生成汇总和通知
+		stepErr = fmt.Errorf("failed sinks: %s", strings.Join(m.Failed(), ", "))
+		log.Println("部分 sink 写入失败: " + stepErr.Error())
+	}
+	if progress != nil {
+		progress(len(entities), len(entities))
+	}
+
+	// 只抓取部分库或表、或者只抓取一个分片时结果不完整，不生成汇总、指标和通知
+	if !filter.empty() || shard != nil {
+		return entities, nil
+	}
+
+	// 汇总、增长趋势和生命周期
+	if config.Aggregate.Db || config.Aggregate.Cluster || config.Trend.Enabled || config.Lifecycle.Enabled {
+		summariesSpan := root.child("summaries")
+		err := saveSummaries(ctx, date, entities)
+		summariesSpan.end(err)
+		if err != nil {
+			stepErr = err
+			log.Println("生成汇总数据失败: " + err.Error())
+		}
+	}
+
+	// 清理过期记录
+	if config.Prune.Enabled {
+		store, err := openStore(ctx)
+		if err == nil {
+			err = prune(ctx, store, date, false)
+			store.Close()
+		}
+		if err != nil {
+			log.Println("清理过期记录失败: " + err.Error())
+		}
+	}
+
+	// push metrics
+	end := time.Now()
+	metrics := collectMetrics(entities, stats, end)
+	if config.Pushgateway.Url != "" {
+		if err := pushMetrics(metrics); err != nil {
+			stepErr = err
+			log.Println("推送指标到 Pushgateway 失败: " + err.Error())
+		}
+	}
+	if config.RemoteWrite.Url != "" {
+		if err := remoteWrite(metrics, end); err != nil {
+			stepErr = err
+			log.Println("通过 remote_write 推送指标失败: " + err.Error())
+		}
+	}
+	if config.Otlp.Endpoint != "" {
+		if err := exportOtlpMetrics(metrics, end); err != nil {
+			stepErr = err
+			log.Println("通过 OTLP 导出指标失败: " + err.Error())
+		}
+	}
+
+	// notify
+	if config.Email.Enabled || len(config.Notify.Webhooks) > 0 {
+		summary := newRunSummary(ctx, date, end.Sub(start), entities)
+		if config.Email.Enabled {
+			if err := sendSummaryEmail(summary, entities); err != nil {
+				stepErr = err
+				log.Println("发送邮件失败: " + err.Error())
+			}
+		}
+		if len(config.Notify.Webhooks) > 0 {
+			if err := notify(summary.title(), summary.text()); err != nil {
+				stepErr = err
+				log.Println("发送通知失败: " + err.Error())
+			}
+		}
+	}
+
+	// alert
+	if alerts := checkThresholds(entities); len(alerts) > 0 {
+		sendAlert(fmt.Sprintf("Hive 存储超过阈值 %s", date.Format("2006-01-02")), alerts)
+	}
+	if config.Alert.Anomaly.Percent > 0 || config.Alert.Anomaly.Size > 0 {
+		if alerts := checkAnomalies(ctx, date, entities); len(alerts) > 0 {
+			sendAlert(fmt.Sprintf("Hive 表大小异常变化 %s", date.Format("2006-01-02")), alerts)
+		}
+	}
+	return entities, nil
+}
+
+// scanHooks 将 counter 各阶段的回调接到 trace、运行统计上，并按配置补充 extra，每次运行使用新的实例
+type scanHooks struct {
+	stats      *runStats
+	hdfsClient *hdfs.Client
+	// stage 当前阶段的 span，db 当前库的 span
+	stage *span
+	db    *span
+}
+
+func (h *scanHooks) hooks() *hivecounter.Hooks {
+	return &hivecounter.Hooks{
+		Db:      h.startDb,
+		Query:   h.query,
+		Wait:    waitQuiet,
+		Table:   tagTable,
+		Measure: h.measure,
+	}
+}
+
+func (h *scanHooks) startDb(stage hivecounter.Stage, db string) func(error) {
+	name := "hive.db"
+	if stage == hivecounter.StageMeasure {
+		name = "hdfs.db"
+	}
+	h.db = h.stage.child(name, newOtlpAttribute("db", db))
+	return h.db.end
+}
+
+// query 获取表目录失败时计入失败，列出库和表失败时会中断运行，不计入
+func (h *scanHooks) query(db, table string) func(error) {
+	start := time.Now()
+	var tableSpan *span
+	if table != "" {
+		tableSpan = h.db.child("hive.table", newOtlpAttribute("db", db), newOtlpAttribute("table", table))
+	}
+	return func(err error) {
+		h.stats.observe(stageHiveQuery, start)
+		if table == "" {
+			return
+		}
+		tableSpan.end(err)
+		if err != nil {
+			h.stats.fail(stageHiveQuery, err)
+		}
+	}
+}
+
+// measure 只获取 hdfs 路径的大小，配置了 plugins.sizes 时由插件决定，成功后按配置获取访问时间、事务表目录等
+func (h *scanHooks) measure(entity *model.Hive) func(error) {
+	if !strings.Contains(entity.Location, hdfsFlag) && !config.Plugins.Sizes.Enabled() {
+		return nil
+	}
+	tableSpan := h.db.child("hdfs.table", newOtlpAttribute("db", entity.Db), newOtlpAttribute("table", entity.Table), newOtlpAttribute("location", entity.Location))
+	start := time.Now()
+	return func(err error) {
+		h.stats.observe(stageHdfs, start)
+		if err != nil {
+			h.stats.fail(stageHdfs, err)
+			observeSizeTime(entity, start)
+			tableSpan.end(err)
+			return
+		}
+		if strings.Contains(entity.Location, hdfsFlag) {
+			measureDetails(h.hdfsClient, entity)
+		}
+		observeSizeTime(entity, start)
+		tableSpan.end(nil)
+	}
+}
+
+// measureDetails 按配置获取访问时间、事务表目录、最大的文件、副本数和分区大小，失败时只记录日志
+func measureDetails(hdfsClient *hdfs.Client, entity *model.Hive) {
+	if config.Hdfs.AccessTime {
+		accessTime, err := getLatestAccessTime(hdfsClient, entity.Location)
+		if err != nil {
+			log.Printf("获取 %s.%s 的访问时间失败: %+v", entity.Db, entity.Table, err)
+		} else if !accessTime.IsZero() {
+			entity.SetExtra(model.ExtraAccessTime, accessTime.Unix())
+		}
+	}
+	if _, ok := entity.Extra[model.ExtraTransactional]; ok && config.Acid.Enabled {
+		err := measureAcid(hdfsClient, entity)
+		if err != nil {
+			log.Printf("获取 %s.%s 的事务表目录大小失败: %+v", entity.Db, entity.Table, err)
+		}
+	}
+	if config.LargestFiles.Enabled {
+		err := measureLargestFiles(hdfsClient, entity)
+		if err != nil {
+			log.Printf("获取 %s.%s 最大的文件失败: %+v", entity.Db, entity.Table, err)
+		}
+	}
+	if config.Replication.Enabled {
+		err := measureReplication(hdfsClient, entity)
+		if err != nil {
+			log.Printf("获取 %s.%s 的副本数失败: %+v", entity.Db, entity.Table, err)
+		}
+	}
+	if config.PartitionSkew.Enabled {
+		err := measurePartitionSkew(hdfsClient, entity)
+		if err != nil {
+			log.Printf("获取 %s.%s 的分区大小失败: %+v", entity.Db, entity.Table, err)
+		}
+	}
+}
+
+// tagTable 按表属性和 hive.temporary 补充 extra，hive.temporary.action 为 exclude 时不写入临时表
+func tagTable(entity *model.Hive, t *hivemeta.Table) bool {
+	temporary := isTemporaryTable(entity.Table, t)
+	if temporary && config.Hive.Temporary.Action == temporaryExclude {
+		return false
+	}
+	if t.Fallback != "" {
+		logf(levelWarn, map[string]string{"db": entity.Db, "table": entity.Table, "fallback": t.Fallback}, "SHOW CREATE TABLE 失败，已回退: %s", t.FallbackReason)
+		entity.SetExtra(model.ExtraMetadataFallback, t.Fallback)
+		entity.SetExtra(model.ExtraMetadataFallbackReason, t.FallbackReason)
+	}
+	if temporary {
+		entity.SetExtra(model.ExtraTemporary, true)
+	}
+	if t.MaterializedView {
+		entity.SetExtra(model.ExtraMaterializedView, true)
+	}
+	if len(t.SkewedBy) > 0 {
+		entity.SetExtra(model.ExtraSkewedBy, strings.Join(t.SkewedBy, ","))
+		entity.SetExtra(model.ExtraSkewedValues, t.SkewedValues)
+	}
+	if strings.EqualFold(t.Properties[config.Hive.SkipProperty], "true") {
+		entity.SetExtra(model.ExtraSkipped, true)
+	}
+	if isTransactional(t) {
+		entity.SetExtra(model.ExtraTransactional, true)
+	}
+	return true
+}
+
+// observeSizeTime 记录从 start 开始获取大小的耗时，包括访问时间和分区大小，超过 log.slow_table 时输出警告
+func observeSizeTime(entity *model.Hive, start time.Time) {
+	elapsed := time.Since(start)
+	entity.SizeMs = elapsed.Milliseconds()
+	if elapsed > config.Log.SlowTable {
+		logf(levelWarn, map[string]string{"db": entity.Db, "table": entity.Table, "location": entity.Location}, "获取大小耗时 %s，目录树可能过深或者文件过多", elapsed.Round(time.Millisecond))
+	}
+}
+
+// isTemporaryTable 通过 CREATE TEMPORARY TABLE 创建的表，或者表名符合 hive.temporary.patterns 的表
+func isTemporaryTable(name string, t *hivemeta.Table) bool {
+	if t.Temporary {
+		return true
+	}
+	for _, pattern := range config.Hive.Temporary.Patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// newHiveSource 按 hive.dialect 解析 SHOW 和 DESCRIBE 的输出，没有配置时自动识别并输出识别的结果
+func newHiveSource(ctx context.Context, cursor *gohive.Cursor) (*hivemeta.Source, error) {
+	source := hivemeta.NewSource(cursor)
+	if config.Hive.Dialect != "" {
+		dialect, err := hivemeta.ParseDialect(config.Hive.Dialect)
+		if err != nil {
+			return nil, err
+		}
+		source.Dialect = &dialect
+		return source, nil
+	}
+	logf(levelInfo, map[string]string{"component": "hive"}, "识别到 HiveServer2 的输出格式为 %s", source.Detect(ctx))
+	return source, nil
+}
+
+// connectHive 通过 ZooKeeper 服务发现连接 HiveServer2
+func connectHive() (*gohive.Connection, error) {
+	return hivemeta.Connect(hivemeta.Config{
+		Username:  config.Hive.Username,
+		Password:  config.Hive.Password,
+		Zookeeper: config.Hive.Zookeeper.Quorum,
+	})
+}
+
+// hdfsConfig 连接 hdfs 的配置
+func hdfsConfig() *hdfssize.Config {
+	router := config.Hdfs.Router
+	router.OnRetry = func(path string, attempt int, err error) {
+		logf(levelWarn, map[string]string{"component": "hdfs", "path": path}, "router 故障切换，第 %d 次重试: %v", attempt, err)
+	}
+	return &hdfssize.Config{
+		ConfDir: config.Hadoop.Conf.Dir,
+		User:    config.Hdfs.Username,
+		Router:  router,
+	}
+}
+
+// newHdfsClient 开启 Router-Based Federation 时连接 router，否则连接 hadoop 配置中的 NameNode
+func newHdfsClient() (*hdfs.Client, error) {
+	return hdfsConfig().NewClient()
+}
+
+// hdfsAddresses 开启 Router-Based Federation 时返回 router 的地址，否则返回 hadoop 配置中的 NameNode
+func hdfsAddresses() ([]string, error) {
+	return hdfsConfig().Addresses()
+}
+
+// getHdfsContentSummary 获取 location 的逻辑大小、包含副本的实际占用空间和文件数量
+func getHdfsContentSummary(client *hdfs.Client, location string) (*hdfs.ContentSummary, error) {
+	return hdfsConfig().LocationSummary(client, location)
+}
+
+// getPathContentSummary 获取 hdfs 路径的 content summary，开启 router 时失败会重试
+func getPathContentSummary(client *hdfs.Client, path string) (*hdfs.ContentSummary, error) {
+	return hdfsConfig().ContentSummary(client, path)
+}
+
+// hdfsPath 将 location 转换为 hdfs 客户端可以直接访问的路径
+func hdfsPath(location string) (string, error) {
+	return hdfsConfig().Path(location)
+}
+
+// runFilter 只抓取部分库或表，db.table 形式的表名和库名均支持 path.Match 的通配符
+type runFilter struct {
+	Dbs    []string `json:"dbs,omitempty"`
+	Tables []string `json:"tables,omitempty"`
+}
+
+func (f *runFilter) empty() bool {
+	return f == nil || len(f.Dbs) == 0 && len(f.Tables) == 0
+}
+
+// matchDb 库名匹配 Dbs，或者 Tables 中有该库的表
+func (f *runFilter) matchDb(db string) bool {
+	if f.empty() {
+		return true
+	}
+	for _, pattern := range f.Dbs {
+		if ok, _ := path.Match(pattern, db); ok {
+			return true
+		}
+	}
+	for _, pattern := range f.Tables {
+		if ok, _ := path.Match(strings.SplitN(pattern, ".", 2)[0], db); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *runFilter) matchTable(db, table string) bool {
+	if f.empty() {
+		return true
+	}
+	for _, pattern := range f.Dbs {
+		if ok, _ := path.Match(pattern, db); ok {
+			return true
+		}
+	}
+	for _, pattern := range f.Tables {
+		if ok, _ := path.Match(pattern, db+"."+table); ok {
+			return true
+		}
+	}
+	return false
+}
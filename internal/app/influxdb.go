@@ -0,0 +1,135 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInfluxdbMeasurement = "hive"
+	defaultInfluxdbBatchSize   = 5000
+	defaultInfluxdbTimeout     = time.Minute
+)
+
+// influxdbSink 以 line protocol 写入表和库的大小，token 不为空时使用 v2 接口，否则使用 v1 接口
+type influxdbSink struct {
+	client *http.Client
+}
+
+func newInfluxdbSink() *influxdbSink {
+	timeout := config.Influxdb.Timeout
+	if timeout <= 0 {
+		timeout = defaultInfluxdbTimeout
+	}
+	return &influxdbSink{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *influxdbSink) Open(ctx context.Context) error {
+	return nil
+}
+
+func (s *influxdbSink) WriteBatch(ctx context.Context, entities []*model.Hive) error {
+	measurement := config.Influxdb.Measurement
+	if measurement == "" {
+		measurement = defaultInfluxdbMeasurement
+	}
+
+	var (
+		lines []string
+		dbs   = make(map[string]int64)
+		order []string
+	)
+	for _, entity := range entities {
+		if _, ok := dbs[entity.Db]; !ok {
+			order = append(order, entity.Db)
+			dbs[entity.Db] = 0
+		}
+		// 失败的表只计数不写入大小
+		if entity.Desc != "" {
+			continue
+		}
+		dbs[entity.Db] += entity.Size
+		lines = append(lines, fmt.Sprintf("%s,db=%s,table=%s size=%di %d",
+			measurement, escapeInfluxTag(entity.Db), escapeInfluxTag(entity.Table), entity.Size, entity.Date.Unix()))
+	}
+	for _, db := range order {
+		lines = append(lines, fmt.Sprintf("%s_db,db=%s size=%di %d",
+			measurement, escapeInfluxTag(db), dbs[db], entities[0].Date.Unix()))
+	}
+
+	batchSize := config.Influxdb.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultInfluxdbBatchSize
+	}
+	for start := 0; start < len(lines); start += batchSize {
+		end := start + batchSize
+		if end > len(lines) {
+			end = len(lines)
+		}
+		if err := s.post(ctx, strings.Join(lines[start:end], "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *influxdbSink) post(ctx context.Context, body string) error {
+	influx := config.Influxdb
+	query := url.Values{}
+	query.Set("precision", "s")
+
+	var endpoint string
+	if influx.Token != "" {
+		endpoint = "/api/v2/write"
+		query.Set("org", influx.Org)
+		query.Set("bucket", influx.Bucket)
+	} else {
+		endpoint = "/write"
+		query.Set("db", influx.Database)
+		if influx.RetentionPolicy != "" {
+			query.Set("rp", influx.RetentionPolicy)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(influx.Url, "/")+endpoint+"?"+query.Encode(), strings.NewReader(body))
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	if influx.Token != "" {
+		req.Header.Set("Authorization", "Token "+influx.Token)
+	} else if influx.Username != "" {
+		req.SetBasicAuth(influx.Username, influx.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return failure.Wrap(fmt.Errorf("influxdb write failed with %s: %s", resp.Status, bytes.TrimSpace(message)))
+	}
+	return nil
+}
+
+func (s *influxdbSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// escapeInfluxTag 转义 tag 中的逗号、等号和空格
+func escapeInfluxTag(value string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(value)
+}
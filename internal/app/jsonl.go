@@ -0,0 +1,53 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"os"
+)
+
+// jsonlSink 每张表输出一行 JSON，便于接入 jq、Vector、Fluent Bit 等工具
+type jsonlSink struct {
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// newJSONLSink path 为空时输出到标准输出
+func newJSONLSink(path string) *jsonlSink {
+	return &jsonlSink{path: path}
+}
+
+func (s *jsonlSink) Open(ctx context.Context) error {
+	s.file = os.Stdout
+	if s.path != "" {
+		file, err := os.Create(s.path)
+		if err != nil {
+			return failure.Wrap(err)
+		}
+		s.file = file
+	}
+	s.writer = bufio.NewWriter(s.file)
+	return nil
+}
+
+func (s *jsonlSink) WriteBatch(ctx context.Context, entities []*model.Hive) error {
+	encoder := json.NewEncoder(s.writer)
+	for _, entity := range entities {
+		err := encoder.Encode(newJSONRow(entity))
+		if err != nil {
+			return failure.Wrap(err)
+		}
+	}
+	return failure.Wrap(s.writer.Flush())
+}
+
+func (s *jsonlSink) Close() error {
+	if s.file == nil || s.file == os.Stdout {
+		return nil
+	}
+	return failure.Wrap(s.file.Close())
+}
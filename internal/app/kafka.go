@@ -0,0 +1,162 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/segmentio/kafka-go"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	kafkaFormatJSON = "json"
+	kafkaFormatAvro = "avro"
+
+	defaultKafkaBatchSize = 1000
+	defaultKafkaTimeout   = 30 * time.Second
+)
+
+// hiveAvroSchema 与 jsonRow 字段一致，不包含 extra，date 使用 Avro 的 date 逻辑类型
+const hiveAvroSchema = `{"type":"record","name":"Hive","namespace":"counter","fields":[` +
+	`{"name":"db","type":"string"},` +
+	`{"name":"table","type":"string"},` +
+	`{"name":"location","type":"string"},` +
+	`{"name":"size","type":"long"},` +
+	`{"name":"desc","type":"string"},` +
+	`{"name":"date","type":{"type":"int","logicalType":"date"}}]}`
+
+// kafkaSink 每张表发送一条消息，key 为 db.table
+type kafkaSink struct {
+	writer   *kafka.Writer
+	schemaId int32
+}
+
+func newKafkaSink() *kafkaSink {
+	batchSize := config.Kafka.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultKafkaBatchSize
+	}
+	timeout := config.Kafka.Timeout
+	if timeout <= 0 {
+		timeout = defaultKafkaTimeout
+	}
+
+	return &kafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(config.Kafka.Brokers...),
+			Topic:        config.Kafka.Topic,
+			Balancer:     &kafka.Hash{},
+			BatchSize:    batchSize,
+			WriteTimeout: timeout,
+			RequiredAcks: kafka.RequireAll,
+		},
+	}
+}
+
+func (s *kafkaSink) Open(ctx context.Context) error {
+	switch config.Kafka.Format {
+	case "", kafkaFormatJSON:
+		return nil
+	case kafkaFormatAvro:
+		id, err := registerAvroSchema(ctx)
+		if err != nil {
+			return err
+		}
+		s.schemaId = id
+		return nil
+	default:
+		return failure.Wrap(fmt.Errorf("unknown kafka format: %s", config.Kafka.Format))
+	}
+}
+
+func (s *kafkaSink) WriteBatch(ctx context.Context, entities []*model.Hive) error {
+	messages := make([]kafka.Message, 0, len(entities))
+	for _, entity := range entities {
+		value, err := s.encode(entity)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, kafka.Message{
+			Key:   []byte(entity.Db + "." + entity.Table),
+			Value: value,
+		})
+	}
+	return failure.Wrap(s.writer.WriteMessages(ctx, messages...))
+}
+
+func (s *kafkaSink) encode(entity *model.Hive) ([]byte, error) {
+	if config.Kafka.Format != kafkaFormatAvro {
+		value, err := json.Marshal(newJSONRow(entity))
+		return value, failure.Wrap(err)
+	}
+
+	// Confluent wire format: magic byte + 4 字节 schema id + Avro 二进制数据
+	var buf bytes.Buffer
+	buf.WriteByte(0)
+	binary.Write(&buf, binary.BigEndian, s.schemaId)
+	writeAvroString(&buf, entity.Db)
+	writeAvroString(&buf, entity.Table)
+	writeAvroString(&buf, entity.Location)
+	writeAvroLong(&buf, entity.Size)
+	writeAvroString(&buf, entity.Desc)
+	writeAvroLong(&buf, int64(epochDays(entity.Date)))
+	return buf.Bytes(), nil
+}
+
+func (s *kafkaSink) Close() error {
+	return failure.Wrap(s.writer.Close())
+}
+
+// registerAvroSchema 向 schema registry 注册 schema，已存在时返回原有的 id
+func registerAvroSchema(ctx context.Context) (int32, error) {
+	registry := config.Kafka.SchemaRegistry
+	subject := registry.Subject
+	if subject == "" {
+		subject = config.Kafka.Topic + "-value"
+	}
+
+	body, err := json.Marshal(map[string]string{"schema": hiveAvroSchema})
+	if err != nil {
+		return 0, failure.Wrap(err)
+	}
+	url := strings.TrimSuffix(registry.Url, "/") + "/subjects/" + subject + "/versions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, failure.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return 0, failure.Wrap(fmt.Errorf("register schema failed with %s: %s", resp.Status, bytes.TrimSpace(message)))
+	}
+	var result struct {
+		Id int32 `json:"id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return result.Id, failure.Wrap(err)
+}
+
+// writeAvroLong Avro 的 int 和 long 都使用 zigzag 编码的变长整数
+func writeAvroLong(buf *bytes.Buffer, value int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], value)
+	buf.Write(b[:n])
+}
+
+func writeAvroString(buf *bytes.Buffer, value string) {
+	writeAvroLong(buf, int64(len(value)))
+	buf.WriteString(value)
+}
@@ -0,0 +1,249 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultKafkaTopicsTimeout = 30 * time.Second
+
+// kafkaTopicConfigs 从 topic 配置中读取的保留设置
+var kafkaTopicConfigs = []string{"retention.ms", "retention.bytes", "cleanup.policy"}
+
+func init() {
+	protocol.Register(&describeLogDirsRequest{}, &describeLogDirsResponse{})
+}
+
+// describeLogDirsRequest kafka-go 没有实现 DescribeLogDirs，这里按协议定义 v0 和 v1，Topics 为 null 时返回所有 topic
+type describeLogDirsRequest struct {
+	Topics []describeLogDirsRequestTopic `kafka:"min=v0,max=v1,nullable"`
+
+	// broker 请求发送到的 broker，不参与编码
+	broker int32
+}
+
+type describeLogDirsRequestTopic struct {
+	Topic      string  `kafka:"min=v0,max=v1"`
+	Partitions []int32 `kafka:"min=v0,max=v1"`
+}
+
+func (r *describeLogDirsRequest) ApiKey() protocol.ApiKey { return protocol.DescribeLogDirs }
+
+// Broker 每个 broker 只返回自己的日志目录，所以需要发送到指定的 broker
+func (r *describeLogDirsRequest) Broker(cluster protocol.Cluster) (protocol.Broker, error) {
+	broker, ok := cluster.Brokers[r.broker]
+	if !ok {
+		return broker, failure.Wrap(fmt.Errorf("broker %d not found", r.broker))
+	}
+	return broker, nil
+}
+
+type describeLogDirsResponse struct {
+	ThrottleTimeMs int32                   `kafka:"min=v0,max=v1"`
+	Results        []describeLogDirsResult `kafka:"min=v0,max=v1"`
+}
+
+type describeLogDirsResult struct {
+	ErrorCode int16                  `kafka:"min=v0,max=v1"`
+	LogDir    string                 `kafka:"min=v0,max=v1"`
+	Topics    []describeLogDirsTopic `kafka:"min=v0,max=v1"`
+}
+
+type describeLogDirsTopic struct {
+	Name       string                     `kafka:"min=v0,max=v1"`
+	Partitions []describeLogDirsPartition `kafka:"min=v0,max=v1"`
+}
+
+type describeLogDirsPartition struct {
+	PartitionIndex int32 `kafka:"min=v0,max=v1"`
+	PartitionSize  int64 `kafka:"min=v0,max=v1"`
+	OffsetLag      int64 `kafka:"min=v0,max=v1"`
+	// IsFutureKey 为 true 时是正在迁移的副本，不计入大小
+	IsFutureKey bool `kafka:"min=v0,max=v1"`
+}
+
+func (r *describeLogDirsResponse) ApiKey() protocol.ApiKey { return protocol.DescribeLogDirs }
+
+// kafkaTopicCounter 通过 AdminClient 的 Metadata、DescribeLogDirs 和 DescribeConfigs 统计每个 topic 的磁盘占用和保留设置
+var kafkaTopicCounter = &counter{
+	name:  "kafka",
+	usage: "通过 kafka_topics.brokers 统计每个 Kafka topic 的磁盘占用和保留设置并保存到 kafka_topic 表",
+	model: &model.KafkaTopic{},
+	keys:  []string{"topic"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		records, err := countKafkaTopics(ctx, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"topic", "partitions", "replication_factor", "size", "raw_size", "retention_ms", "retention_bytes", "cleanup_policy"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{record.Topic, record.Partitions, record.ReplicationFactor, byteSize(record.Size), byteSize(record.RawSize), record.RetentionMs, record.RetentionBytes, record.CleanupPolicy})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countKafkaTopics 按大小降序返回每个 topic，跳过内部 topic，部分 broker 无法访问时结果不完整
+func countKafkaTopics(ctx context.Context, date time.Time) ([]*model.KafkaTopic, error) {
+	if len(config.KafkaTopics.Brokers) == 0 {
+		return nil, failure.Wrap(fmt.Errorf("kafka_topics.brokers is empty"))
+	}
+	timeout := config.KafkaTopics.Timeout
+	if timeout <= 0 {
+		timeout = defaultKafkaTopicsTimeout
+	}
+	client := &kafka.Client{
+		Addr:    kafka.TCP(config.KafkaTopics.Brokers...),
+		Timeout: timeout,
+	}
+
+	metadata, err := client.Metadata(ctx, &kafka.MetadataRequest{})
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	var (
+		topics  = make(map[string]*model.KafkaTopic)
+		leaders = make(map[string]map[int32]int)
+	)
+	for _, topic := range metadata.Topics {
+		if topic.Internal {
+			continue
+		}
+		if topic.Error != nil {
+			log.Printf("获取 topic %s 的元数据失败: %+v", topic.Name, topic.Error)
+			continue
+		}
+		record := &model.KafkaTopic{
+			Topic:          topic.Name,
+			Partitions:     len(topic.Partitions),
+			RetentionMs:    -1,
+			RetentionBytes: -1,
+			Date:           date,
+		}
+		leaders[topic.Name] = make(map[int32]int)
+		for _, partition := range topic.Partitions {
+			leaders[topic.Name][int32(partition.ID)] = partition.Leader.ID
+			if len(partition.Replicas) > record.ReplicationFactor {
+				record.ReplicationFactor = len(partition.Replicas)
+			}
+		}
+		topics[topic.Name] = record
+	}
+
+	var failed []string
+	for _, broker := range metadata.Brokers {
+		results, err := describeLogDirs(ctx, client, broker.ID)
+		if err != nil {
+			log.Printf("获取 broker %d 的日志目录失败: %+v", broker.ID, err)
+			failed = append(failed, strconv.Itoa(broker.ID))
+			continue
+		}
+		for _, result := range results {
+			if result.ErrorCode != 0 {
+				log.Printf("broker %d 的日志目录 %s 不可用: %s", broker.ID, result.LogDir, kafka.Error(result.ErrorCode))
+				continue
+			}
+			for _, topic := range result.Topics {
+				record, ok := topics[topic.Name]
+				if !ok {
+					continue
+				}
+				for _, partition := range topic.Partitions {
+					if partition.IsFutureKey {
+						continue
+					}
+					record.RawSize += partition.PartitionSize
+					if leaders[topic.Name][partition.PartitionIndex] == broker.ID {
+						record.Size += partition.PartitionSize
+					}
+				}
+			}
+		}
+	}
+	if len(failed) == len(metadata.Brokers) {
+		return nil, failure.Wrap(fmt.Errorf("all brokers failed: %s", strings.Join(failed, ", ")))
+	}
+
+	err = describeKafkaTopicConfigs(ctx, client, topics)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*model.KafkaTopic, 0, len(topics))
+	for _, record := range topics {
+		if len(failed) > 0 {
+			record.Desc = "部分 broker 无法访问: " + strings.Join(failed, ", ")
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+	return records, nil
+}
+
+// describeLogDirs 返回 broker 上所有日志目录中的 topic 分区大小
+func describeLogDirs(ctx context.Context, client *kafka.Client, broker int) ([]describeLogDirsResult, error) {
+	transport := client.Transport
+	if transport == nil {
+		transport = kafka.DefaultTransport
+	}
+	ctx, cancel := context.WithTimeout(ctx, client.Timeout)
+	defer cancel()
+	m, err := transport.RoundTrip(ctx, client.Addr, &describeLogDirsRequest{broker: int32(broker)})
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	return m.(*describeLogDirsResponse).Results, nil
+}
+
+// describeKafkaTopicConfigs 填充每个 topic 的保留设置，单个 topic 失败时记录到备注
+func describeKafkaTopicConfigs(ctx context.Context, client *kafka.Client, topics map[string]*model.KafkaTopic) error {
+	if len(topics) == 0 {
+		return nil
+	}
+	req := &kafka.DescribeConfigsRequest{}
+	for name := range topics {
+		req.Resources = append(req.Resources, kafka.DescribeConfigRequestResource{
+			ResourceType: kafka.ResourceTypeTopic,
+			ResourceName: name,
+			ConfigNames:  kafkaTopicConfigs,
+		})
+	}
+	resp, err := client.DescribeConfigs(ctx, req)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	for _, resource := range resp.Resources {
+		record, ok := topics[resource.ResourceName]
+		if !ok {
+			continue
+		}
+		if resource.Error != nil {
+			log.Printf("获取 topic %s 的配置失败: %+v", resource.ResourceName, resource.Error)
+			record.Desc = "获取配置失败"
+			continue
+		}
+		for _, entry := range resource.ConfigEntries {
+			switch entry.ConfigName {
+			case "retention.ms":
+				record.RetentionMs, _ = strconv.ParseInt(entry.ConfigValue, 10, 64)
+			case "retention.bytes":
+				record.RetentionBytes, _ = strconv.ParseInt(entry.ConfigValue, 10, 64)
+			case "cleanup.policy":
+				record.CleanupPolicy = entry.ConfigValue
+			}
+		}
+	}
+	return nil
+}
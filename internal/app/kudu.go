@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultKuduTimeout = 30 * time.Second
+
+// kuduEntity tablet server /metrics 接口返回的一个实体
+type kuduEntity struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Attributes map[string]string `json:"attributes"`
+	Metrics    []struct {
+		Name  string `json:"name"`
+		Value int64  `json:"value"`
+	} `json:"metrics"`
+}
+
+// kuduCounter 汇总 kudu.tservers 中每个 tablet server 上 tablet 的 on_disk_size
+var kuduCounter = &counter{
+	name:  "kudu",
+	usage: "通过 kudu.tservers 的 /metrics 接口统计每张 Kudu 表的磁盘占用和 tablet 数量并保存到 kudu 表",
+	model: &model.Kudu{},
+	keys:  []string{"table"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		records, err := countKudu(ctx, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"table", "tablets", "replicas", "size"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{record.Table, record.Tablets, record.Replicas, byteSize(record.Size)})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countKudu 按大小降序返回每张表，所有 tablet server 都无法访问时返回错误，部分无法访问时结果不完整
+func countKudu(ctx context.Context, date time.Time) ([]*model.Kudu, error) {
+	if len(config.Kudu.Tservers) == 0 {
+		return nil, failure.Wrap(fmt.Errorf("kudu.tservers is empty"))
+	}
+
+	var (
+		tables  = make(map[string]*model.Kudu)
+		tablets = make(map[string]map[string]bool)
+		failed  []string
+	)
+	for _, address := range config.Kudu.Tservers {
+		entities, err := fetchKuduMetrics(ctx, address)
+		if err != nil {
+			log.Printf("获取 %s 的指标失败: %+v", address, err)
+			failed = append(failed, address)
+			continue
+		}
+		for _, entity := range entities {
+			name := entity.Attributes["table_name"]
+			if entity.Type != "tablet" || name == "" {
+				continue
+			}
+			record, ok := tables[name]
+			if !ok {
+				record = &model.Kudu{Table: name, Date: date}
+				tables[name] = record
+				tablets[name] = make(map[string]bool)
+			}
+			tablets[name][entity.ID] = true
+			record.Replicas++
+			for _, metric := range entity.Metrics {
+				if metric.Name == "on_disk_size" {
+					record.Size += metric.Value
+				}
+			}
+		}
+	}
+	if len(failed) == len(config.Kudu.Tservers) {
+		return nil, failure.Wrap(fmt.Errorf("all tablet servers failed: %s", strings.Join(failed, ", ")))
+	}
+
+	records := make([]*model.Kudu, 0, len(tables))
+	for name, record := range tables {
+		record.Tablets = len(tablets[name])
+		if len(failed) > 0 {
+			record.Desc = "部分 tablet server 无法访问: " + strings.Join(failed, ", ")
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+	return records, nil
+}
+
+// fetchKuduMetrics 只获取 on_disk_size 指标
+func fetchKuduMetrics(ctx context.Context, address string) ([]kuduEntity, error) {
+	timeout := config.Kudu.Timeout
+	if timeout <= 0 {
+		timeout = defaultKuduTimeout
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(address, "/")+"/metrics?metrics=on_disk_size&include_schema=false", nil)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, failure.Wrap(fmt.Errorf("metrics failed with %s", resp.Status))
+	}
+
+	var entities []kuduEntity
+	err = json.NewDecoder(resp.Body).Decode(&entities)
+	return entities, failure.Wrap(err)
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultLargestFilesTop     = 5
+	defaultLargestFilesMinSize = 10 << 30
+)
+
+// unsplittableExtensions 不能切分的压缩格式，整个文件只能由一个 task 读取。
+// bzip2 可以切分，LZO 建立索引后可以切分，这里按没有索引处理
+var unsplittableExtensions = map[string]bool{
+	".gz":      true,
+	".gzip":    true,
+	".zst":     true,
+	".snappy":  true,
+	".lz4":     true,
+	".lzo":     true,
+	".deflate": true,
+}
+
+// isSplittable 按扩展名判断，列式格式和未压缩的文本都可以切分
+func isSplittable(name string) bool {
+	return !unsplittableExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// measureLargestFiles 遍历表目录，将最大的 largest_files.top 个文件写入 extra
+func measureLargestFiles(client *hdfs.Client, entity *model.Hive) error {
+	root, err := hdfsPath(entity.Location)
+	if err != nil {
+		return err
+	}
+	top := config.LargestFiles.Top
+	var files []model.LargeFile
+	err = client.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || (len(files) == top && info.Size() <= files[top-1].Size) {
+			return nil
+		}
+		file := model.LargeFile{Path: name, Size: info.Size(), Splittable: isSplittable(name)}
+		i := sort.Search(len(files), func(i int) bool {
+			return files[i].Size < file.Size
+		})
+		files = append(files, model.LargeFile{})
+		copy(files[i+1:], files[i:])
+		files[i] = file
+		if len(files) > top {
+			files = files[:top]
+		}
+		return nil
+	})
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	if len(files) > 0 {
+		entity.SetExtra(model.ExtraLargestFiles, files)
+	}
+	return nil
+}
+
+// largeFile 超过 largest_files.min_size 的文件和所在的表
+type largeFile struct {
+	record *model.Hive
+	file   model.LargeFile
+}
+
+// findLargeFiles 筛选超过 largest_files.min_size 的文件，不能切分的文件在前，同类按大小降序排列
+func findLargeFiles(records []*model.Hive) []*largeFile {
+	var large []*largeFile
+	for _, record := range records {
+		for _, file := range record.Extra.LargestFiles() {
+			if file.Size >= int64(config.LargestFiles.MinSize) {
+				large = append(large, &largeFile{record: record, file: file})
+			}
+		}
+	}
+	sort.SliceStable(large, func(i, j int) bool {
+		if large[i].file.Splittable != large[j].file.Splittable {
+			return !large[i].file.Splittable
+		}
+		return large[i].file.Size > large[j].file.Size
+	})
+	return large
+}
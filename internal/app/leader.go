@@ -0,0 +1,135 @@
+package app
+
+import (
+	"fmt"
+	"github.com/go-zookeeper/zk"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLeaderPath = "/counter/leader"
+
+	leaderSessionTimeout = 10 * time.Second
+	leaderRetryInterval  = 10 * time.Second
+)
+
+// leaderElection 在 ZooKeeper 上创建临时顺序节点，序号最小的实例为 leader，多个副本中只有 leader 执行定时抓取
+type leaderElection struct {
+	servers []string
+	path    string
+
+	mu     sync.Mutex
+	leader bool
+}
+
+// startLeaderElection 使用 hive.zookeeper.quorum 的 ZooKeeper 进行选举，连接断开时放弃 leader 并重新参与选举
+func startLeaderElection() *leaderElection {
+	e := &leaderElection{
+		servers: strings.Split(config.Hive.Zookeeper.Quorum, ","),
+		path:    config.Serve.LeaderElection.Path,
+	}
+	if e.path == "" {
+		e.path = defaultLeaderPath
+	}
+	go func() {
+		for {
+			err := e.campaign()
+			e.setLeader(false)
+			log.Printf("leader 选举中断，%s 后重试: %v", leaderRetryInterval, err)
+			time.Sleep(leaderRetryInterval)
+		}
+	}()
+	return e
+}
+
+func (e *leaderElection) isLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+func (e *leaderElection) setLeader(leader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leader != leader {
+		log.Printf("leader 状态变为 %t", leader)
+	}
+	e.leader = leader
+}
+
+// campaign 参与一次选举，直到会话断开或出错时返回
+func (e *leaderElection) campaign() error {
+	conn, events, err := zk.Connect(e.servers, leaderSessionTimeout, zk.WithLogInfo(false))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = createParents(conn, e.path)
+	if err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	node, err := conn.Create(e.path+"/n_", []byte(hostname), zk.FlagEphemeral|zk.FlagSequence, zk.WorldACL(zk.PermAll))
+	if err != nil {
+		return err
+	}
+	name := path.Base(node)
+
+	for {
+		children, _, err := conn.Children(e.path)
+		if err != nil {
+			return err
+		}
+		sort.Strings(children)
+		index := sort.SearchStrings(children, name)
+		if index == len(children) || children[index] != name {
+			return fmt.Errorf("election node %s lost", node)
+		}
+
+		if index == 0 {
+			e.setLeader(true)
+			for event := range events {
+				if event.State == zk.StateDisconnected || event.State == zk.StateExpired {
+					return fmt.Errorf("zookeeper session %s", event.State)
+				}
+			}
+			return fmt.Errorf("zookeeper connection closed")
+		}
+
+		// 只监听前一个节点，避免羊群效应
+		exists, _, watch, err := conn.ExistsW(e.path + "/" + children[index-1])
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		select {
+		case <-watch:
+		case event := <-events:
+			if event.State == zk.StateExpired {
+				return fmt.Errorf("zookeeper session %s", event.State)
+			}
+		}
+	}
+}
+
+// createParents 逐级创建持久节点
+func createParents(conn *zk.Conn, p string) error {
+	current := ""
+	for _, part := range strings.Split(strings.Trim(p, "/"), "/") {
+		current += "/" + part
+		_, err := conn.Create(current, nil, 0, zk.WorldACL(zk.PermAll))
+		if err != nil && err != zk.ErrNodeExists {
+			return err
+		}
+	}
+	return nil
+}
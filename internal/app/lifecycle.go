@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/rea1shane/counter/pkg/sink"
+	"gorm.io/gorm/clause"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// lifecycleUpsert 同一天重复运行时覆盖
+var lifecycleUpsert = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "db"}, {Name: "table"}, {Name: "date"}},
+	DoUpdates: clause.AssignmentColumns([]string{"event", "location", "size", "previous_date"}),
+}
+
+// saveLifecycle 与数据库中前一次抓取的结果比较，将新建和删除的表写入 hive_lifecycle 表，没有前一次的结果时不记录
+func saveLifecycle(ctx context.Context, store *sink.Gorm, date time.Time, entities []*model.Hive) error {
+	err := store.Migrate(ctx, &model.HiveLifecycle{})
+	if err != nil {
+		return err
+	}
+
+	previousDate, ok, err := previousDate(ctx, store, date)
+	if err != nil || !ok {
+		return err
+	}
+	previous, err := loadRecords(ctx, store, previousDate)
+	if err != nil {
+		return err
+	}
+
+	events := diffLifecycle(previous, entities)
+	if len(events) == 0 {
+		return nil
+	}
+	for _, event := range events {
+		event.PreviousDate = previousDate
+		event.Date = date
+	}
+	return failure.Wrap(store.DB().WithContext(ctx).Clauses(lifecycleUpsert).CreateInBatches(events, 1000).Error)
+}
+
+// diffLifecycle 只在 to 中出现的表为新建，只在 from 中出现的表为删除
+func diffLifecycle(from, to []*model.Hive) []*model.HiveLifecycle {
+	var events []*model.HiveLifecycle
+	for _, d := range diffTables(from, to) {
+		switch d.status {
+		case diffNew:
+			events = append(events, &model.HiveLifecycle{Db: d.db, Table: d.table, Event: model.LifecycleCreated, Size: d.to})
+		case diffDropped:
+			events = append(events, &model.HiveLifecycle{Db: d.db, Table: d.table, Event: model.LifecycleDropped, Size: d.from})
+		}
+	}
+	locations := make(map[string]string)
+	for _, record := range from {
+		locations[record.Db+"."+record.Table] = record.Location
+	}
+	for _, record := range to {
+		locations[record.Db+"."+record.Table] = record.Location
+	}
+	for _, event := range events {
+		event.Location = locations[event.Db+"."+event.Table]
+	}
+	return events
+}
+
+// runLifecycle 输出一段时间内新建和删除的表
+func runLifecycle(args []string) {
+	flags := flag.NewFlagSet("lifecycle", flag.ExitOnError)
+	fromFlag := flags.String("from", "", "起始日期，格式为 2006-01-02，默认为结束日期前 30 天")
+	toFlag := flags.String("to", "", "结束日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	event := flags.String("event", "", "只输出某一种事件，可选 created、dropped，默认都输出")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	to, err := resolveDate(ctx, store, *toFlag)
+	if err != nil {
+		log.Fatal("解析结束日期失败: " + err.Error())
+	}
+	from := to.AddDate(0, 0, -30)
+	if *fromFlag != "" {
+		from, err = resolveDate(ctx, store, *fromFlag)
+		if err != nil {
+			log.Fatal("解析起始日期失败: " + err.Error())
+		}
+	}
+
+	err = store.Migrate(ctx, &model.HiveLifecycle{})
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	query := store.DB().WithContext(ctx).Where("date >= ? AND date <= ?", from, to)
+	if *event != "" {
+		query = query.Where("event = ?", *event)
+	}
+	var events []*model.HiveLifecycle
+	err = query.Find(&events).Error
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", failure.Wrap(err)))
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Date.Equal(events[j].Date) {
+			return events[i].Date.Before(events[j].Date)
+		}
+		if events[i].Db != events[j].Db {
+			return events[i].Db < events[j].Db
+		}
+		return events[i].Table < events[j].Table
+	})
+
+	header := []string{"date", "event", "db", "table", "location", "size", "previous_date"}
+	var rows [][]interface{}
+	for _, e := range events {
+		rows = append(rows, []interface{}{e.Date.Format("2006-01-02"), e.Event, e.Db, e.Table, e.Location, byteSize(e.Size), e.PreviousDate.Format("2006-01-02")})
+	}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
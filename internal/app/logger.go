@@ -0,0 +1,216 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 日志级别
+const (
+	levelDebug = "debug"
+	levelInfo  = "info"
+	levelWarn  = "warn"
+	levelError = "error"
+)
+
+var logLevels = map[string]int{levelDebug: 0, levelInfo: 1, levelWarn: 2, levelError: 3}
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+
+	defaultLogSlowQuery = 200 * time.Millisecond
+	defaultLogSlowTable = time.Minute
+)
+
+// appLogger 所有日志统一输出到 stderr，包括标准库 log、gorm、hdfs router 重试和插件的 stderr，
+// 抓取期间带有 run_id 字段，便于在日志系统中筛选一次运行的所有日志
+type appLogger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	json  bool
+	level int
+	runID string
+}
+
+var defaultLogger = &appLogger{out: os.Stderr, level: logLevels[levelInfo]}
+
+// setupLogger 按 log 配置设置级别和格式，并接管标准库 log 的输出
+func setupLogger() {
+	defaultLogger.mu.Lock()
+	defaultLogger.json = config.Log.Format == logFormatJSON
+	if level, ok := logLevels[config.Log.Level]; ok {
+		defaultLogger.level = level
+	}
+	defaultLogger.mu.Unlock()
+
+	log.SetFlags(0)
+	log.SetOutput(newLogWriter(levelInfo, nil))
+}
+
+// setRunID 抓取开始时设置，结束时以空字符串清除
+func (l *appLogger) setRunID(id string) {
+	l.mu.Lock()
+	l.runID = id
+	l.mu.Unlock()
+}
+
+func (l *appLogger) enabled(level string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return logLevels[level] >= l.level
+}
+
+// log 输出一行日志，fields 按 key 排序
+func (l *appLogger) log(level, msg string, fields map[string]string) {
+	if !l.enabled(level) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.json {
+		entry := map[string]string{"time": now.Format(time.RFC3339Nano), "level": level, "msg": msg}
+		if l.runID != "" {
+			entry["run_id"] = l.runID
+		}
+		for key, value := range fields {
+			entry[key] = value
+		}
+		line, _ := json.Marshal(entry)
+		l.out.Write(append(line, '\n'))
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(now.Format("2006/01/02 15:04:05"))
+	b.WriteString(" level=" + level)
+	if l.runID != "" {
+		b.WriteString(" run_id=" + l.runID)
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.WriteString(" " + key + "=" + fields[key])
+	}
+	b.WriteString(" " + msg + "\n")
+	io.WriteString(l.out, b.String())
+}
+
+// logWriter 将写入的每一行作为一条日志，用于标准库 log 和插件的 stderr
+type logWriter struct {
+	level  string
+	fields map[string]string
+	mu     sync.Mutex
+	buffer []byte
+}
+
+func newLogWriter(level string, fields map[string]string) *logWriter {
+	return &logWriter{level: level, fields: fields}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buffer = append(w.buffer, p...)
+	for {
+		i := strings.IndexByte(string(w.buffer), '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimRight(string(w.buffer[:i]), "\r"); line != "" {
+			defaultLogger.log(w.level, line, w.fields)
+		}
+		w.buffer = w.buffer[i+1:]
+	}
+	return len(p), nil
+}
+
+// logf 输出带级别的日志，供 hdfs router 重试等回调使用
+func logf(level string, fields map[string]string, format string, args ...interface{}) {
+	defaultLogger.log(level, fmt.Sprintf(format, args...), fields)
+}
+
+// gormLogger 将 gorm 的日志转到 appLogger，SQL 执行失败为 error，超过 log.slow_query 为 warn，其余 SQL 只在 debug 级别输出
+type gormLogger struct {
+	level     logger.LogLevel
+	slowQuery time.Duration
+}
+
+var gormFields = map[string]string{"component": "gorm"}
+
+func newGormLogger() logger.Interface {
+	return &gormLogger{level: logger.Info, slowQuery: config.Log.SlowQuery}
+}
+
+func (g *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	copied := *g
+	copied.level = level
+	return &copied
+}
+
+func (g *gormLogger) Info(_ context.Context, format string, args ...interface{}) {
+	if g.level >= logger.Info {
+		logf(levelInfo, gormFields, format, args...)
+	}
+}
+
+func (g *gormLogger) Warn(_ context.Context, format string, args ...interface{}) {
+	if g.level >= logger.Warn {
+		logf(levelWarn, gormFields, format, args...)
+	}
+}
+
+func (g *gormLogger) Error(_ context.Context, format string, args ...interface{}) {
+	if g.level >= logger.Error {
+		logf(levelError, gormFields, format, args...)
+	}
+}
+
+func (g *gormLogger) Trace(_ context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if g.level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	var level string
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && g.level >= logger.Error:
+		level = levelError
+	case elapsed > g.slowQuery && g.level >= logger.Warn:
+		level = levelWarn
+	case g.level >= logger.Info:
+		level = levelDebug
+	default:
+		return
+	}
+	if !defaultLogger.enabled(level) {
+		return
+	}
+
+	sql, rows := fc()
+	fields := map[string]string{
+		"component": "gorm",
+		"elapsed":   elapsed.Round(time.Microsecond).String(),
+		"rows":      fmt.Sprint(rows),
+	}
+	msg := sql
+	if err != nil {
+		msg = err.Error() + ": " + sql
+	}
+	defaultLogger.log(level, msg, fields)
+}
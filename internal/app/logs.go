@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/colinmarc/hdfs"
+	"github.com/colinmarc/hdfs/protocol/hadoop_hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"os"
+	"time"
+)
+
+const defaultLogsCleanupDays = 30
+
+// logCounter 统计 spark.eventLog.dir 和 yarn.nodemanager.remote-app-log-dir，这两个目录没有清理时会持续增长
+var logCounter = &counter{
+	name:  "logs",
+	usage: "统计 logs 中 Spark event log 和 YARN 聚合日志目录的大小、文件修改时间分布和可以清理的文件并保存到 hdfs_log_dir 表",
+	model: &model.HdfsLogDir{},
+	keys:  []string{"path"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		client, err := newHdfsClient()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		defer client.Close()
+		defer hdfssize.CloseOnDone(ctx, client)()
+
+		records, err := countLogs(client, date, time.Now())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"kind", "path", "size", "files", "1d", "7d", "30d", "older", "candidates", "candidate_size", "oldest", "desc"}
+		var rows [][]interface{}
+		for _, record := range records {
+			oldest := ""
+			if record.Oldest != nil {
+				oldest = record.Oldest.Format("2006-01-02 15:04")
+			}
+			rows = append(rows, []interface{}{record.Kind, record.Path, byteSize(record.Size), record.Files, byteSize(record.Day), byteSize(record.Week), byteSize(record.Month), byteSize(record.Older), record.Candidates, byteSize(record.CandidateSize), oldest, record.Desc})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countLogs 按配置的顺序返回每个日志目录，单个目录失败时 size 为 -1
+func countLogs(client *hdfs.Client, date, now time.Time) ([]*model.HdfsLogDir, error) {
+	if len(config.Logs.SparkEventLogDirs) == 0 && len(config.Logs.YarnLogDirs) == 0 {
+		return nil, failure.Wrap(fmt.Errorf("logs.spark_event_log_dirs and logs.yarn_log_dirs are empty"))
+	}
+	cleanupDays := config.Logs.CleanupDays
+	if cleanupDays <= 0 {
+		cleanupDays = defaultLogsCleanupDays
+	}
+	before := now.AddDate(0, 0, -cleanupDays)
+
+	var records []*model.HdfsLogDir
+	for _, dir := range config.Logs.SparkEventLogDirs {
+		records = append(records, &model.HdfsLogDir{Kind: model.LogDirSpark, Path: dir, Date: date})
+	}
+	for _, dir := range config.Logs.YarnLogDirs {
+		records = append(records, &model.HdfsLogDir{Kind: model.LogDirYarn, Path: dir, Date: date})
+	}
+	for i, record := range records {
+		err := measureLogDir(client, record, now, before)
+		if err != nil {
+			log.Printf("统计日志目录 %s 失败: %+v", record.Path, err)
+			records[i] = &model.HdfsLogDir{Kind: record.Kind, Path: record.Path, Size: -1, Desc: err.Error(), Date: date}
+		}
+	}
+	return records, nil
+}
+
+// measureLogDir 遍历目录下的所有文件，按修改时间统计，before 之前修改的文件视为可以清理
+func measureLogDir(client *hdfs.Client, record *model.HdfsLogDir, now, before time.Time) error {
+	err := client.Walk(record.Path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		size := info.Size()
+		record.Size += size
+		record.Files++
+		if fileInfo, ok := info.(*hdfs.FileInfo); ok {
+			record.RawSize += size * int64(fileInfo.Sys().(*hadoop_hdfs.HdfsFileStatusProto).GetBlockReplication())
+		}
+
+		modTime := info.ModTime()
+		if record.Oldest == nil || modTime.Before(*record.Oldest) {
+			record.Oldest = &modTime
+		}
+		switch age := now.Sub(modTime); {
+		case age < 24*time.Hour:
+			record.Day += size
+		case age < 7*24*time.Hour:
+			record.Week += size
+		case age < 30*24*time.Hour:
+			record.Month += size
+		default:
+			record.Older += size
+		}
+		if modTime.Before(before) {
+			record.Candidates++
+			record.CandidateSize += size
+		}
+		return nil
+	})
+	return failure.Wrap(err, failure.Context{"op": "walk", "path": record.Path})
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"github.com/rea1shane/counter/pkg/model"
+	"sort"
+	"time"
+)
+
+// metricFamily 同名指标的集合，均为 gauge
+type metricFamily struct {
+	name    string
+	help    string
+	samples []metricSample
+}
+
+type metricSample struct {
+	labels []metricLabel
+	value  float64
+}
+
+type metricLabel struct {
+	name  string
+	value string
+}
+
+// collectMetrics 按库汇总本次抓取的结果，并附带运行信息
+func collectMetrics(entities []*model.Hive, timings *runStats, end time.Time) []metricFamily {
+	type dbStats struct {
+		size     int64
+		tables   int
+		failures int
+	}
+
+	dbs := make(map[string]*dbStats)
+	for _, entity := range entities {
+		stats, ok := dbs[entity.Db]
+		if !ok {
+			stats = &dbStats{}
+			dbs[entity.Db] = stats
+		}
+		stats.tables++
+		if entity.Desc != "" {
+			stats.failures++
+		}
+		if entity.Size > 0 {
+			stats.size += entity.Size
+		}
+	}
+
+	names := make([]string, 0, len(dbs))
+	for name := range dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	size := metricFamily{name: "counter_hive_db_size_bytes", help: "库下所有表占用的存储空间"}
+	tables := metricFamily{name: "counter_hive_db_tables", help: "库下表的数量"}
+	dbFailures := metricFamily{name: "counter_hive_db_failures", help: "库下获取大小失败的表的数量"}
+	for _, name := range names {
+		labels := []metricLabel{{name: "db", value: name}}
+		size.samples = append(size.samples, metricSample{labels: labels, value: float64(dbs[name].size)})
+		tables.samples = append(tables.samples, metricSample{labels: labels, value: float64(dbs[name].tables)})
+		dbFailures.samples = append(dbFailures.samples, metricSample{labels: labels, value: float64(dbs[name].failures)})
+	}
+
+	families := []metricFamily{size, tables, dbFailures}
+	families = append(families, timings.families(end)...)
+	return append(families, metricFamily{name: "counter_run_last_success_timestamp_seconds", help: "最近一次运行完成的时间", samples: []metricSample{{value: float64(end.Unix())}}})
+}
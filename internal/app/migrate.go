@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+// runMigrate 执行结果数据库中未执行的迁移，migrate status 只输出每个迁移的执行情况
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: counter migrate [status]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	status := false
+	switch flags.Arg(0) {
+	case "":
+	case "status":
+		status = true
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx := context.Background()
+	store, err := configuredStore()
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	store.SkipMigrations = true
+	err = store.Open(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	if status {
+		states, err := store.MigrationStatus(ctx)
+		if err != nil {
+			log.Fatal(fmt.Sprintf("%+v", err))
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, state := range states {
+			applied := "pending"
+			if state.Applied() {
+				applied = state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", state.Version, state.Name, applied)
+		}
+		w.Flush()
+		return
+	}
+
+	applied, err := store.MigrateUp(ctx)
+	for _, m := range applied {
+		log.Printf("已执行迁移 %d %s", m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	if len(applied) == 0 {
+		log.Printf("没有需要执行的迁移")
+	}
+}
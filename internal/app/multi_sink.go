@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/internal/retry"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/rea1shane/counter/pkg/sink"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// multiSink 并行写入多个 sink，每个 sink 独立重试，单个 sink 失败不会阻塞其他 sink，也不会中断抓取
+type multiSink struct {
+	types []string
+	// local 只在本次抓取中使用的 sink，优先于 sink 包中注册的同名 sink
+	local map[string]sink.Factory
+	names []string
+	sinks []sink.Sink
+	// failed 创建或打开失败的 sink
+	failed []string
+}
+
+func newMultiSink(types []string, local map[string]sink.Factory) *multiSink {
+	return &multiSink{types: types, local: local}
+}
+
+func (m *multiSink) Open(ctx context.Context) error {
+	for _, sinkType := range m.types {
+		if sinkType == "" {
+			sinkType = sinkMysql
+		}
+		var (
+			s   sink.Sink
+			err error
+		)
+		if factory, ok := m.local[sinkType]; ok {
+			s, err = factory(sinkOptions(sinkType))
+		} else {
+			s, err = sink.New(sinkType, sinkOptions(sinkType))
+		}
+		if err == nil {
+			err = s.Open(ctx)
+		}
+		if err != nil {
+			log.Printf("打开 sink %s 失败: %+v", sinkType, err)
+			m.failed = append(m.failed, sinkType)
+			continue
+		}
+		m.names = append(m.names, sinkType)
+		m.sinks = append(m.sinks, s)
+	}
+	if len(m.sinks) == 0 {
+		return failure.Wrap(fmt.Errorf("no available sink: %s", strings.Join(m.failed, ", ")))
+	}
+	return nil
+}
+
+// WriteBatch 每个 sink 写入各自的副本，避免 gorm 回写 ID 等修改在 sink 之间竞争；
+// 只有所有 sink 都失败时返回错误，部分失败通过 Failed 获取，不中断抓取
+func (m *multiSink) WriteBatch(ctx context.Context, entities []*model.Hive) error {
+	var (
+		wg      sync.WaitGroup
+		lock    sync.Mutex
+		written int
+	)
+	for i := range m.sinks {
+		wg.Add(1)
+		go func(name string, s sink.Sink, entities []*model.Hive) {
+			defer wg.Done()
+			err := writeWithRetry(ctx, name, s, entities)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				log.Printf("写入 sink %s 失败: %+v", name, err)
+				m.failed = append(m.failed, name)
+				return
+			}
+			written++
+		}(m.names[i], m.sinks[i], copyEntities(entities))
+	}
+	wg.Wait()
+
+	if written == 0 {
+		return failure.Wrap(fmt.Errorf("failed sinks: %s", strings.Join(m.failed, ", ")))
+	}
+	return nil
+}
+
+// Failed 返回打开或写入失败的 sink
+func (m *multiSink) Failed() []string {
+	return m.failed
+}
+
+func copyEntities(entities []*model.Hive) []*model.Hive {
+	copies := make([]*model.Hive, len(entities))
+	for i, entity := range entities {
+		c := *entity
+		copies[i] = &c
+	}
+	return copies
+}
+
+// writeWithRetry 整批数据保留在内存中，失败后按配置的策略重新写入
+func writeWithRetry(ctx context.Context, name string, s sink.Sink, entities []*model.Hive) error {
+	retrier := retry.Retrier{
+		Policy: config.Sink.Retry,
+		OnRetry: func(attempt int, wait time.Duration, err error) {
+			log.Printf("写入 sink %s 失败，%s 后第 %d 次重试: %s", name, wait, attempt, err.Error())
+		},
+	}
+	return retrier.Do(ctx, func() error {
+		return s.WriteBatch(ctx, entities)
+	})
+}
+
+func (m *multiSink) Close() error {
+	var failed []string
+	for i, s := range m.sinks {
+		if err := s.Close(); err != nil {
+			log.Printf("关闭 sink %s 失败: %+v", m.names[i], err)
+			failed = append(failed, m.names[i])
+		}
+	}
+	if len(failed) > 0 {
+		return failure.Wrap(fmt.Errorf("failed to close sinks: %s", strings.Join(failed, ", ")))
+	}
+	return nil
+}
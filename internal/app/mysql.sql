@@ -0,0 +1,29 @@
+-- 写入时会自动执行 sink.Migrations 中的迁移，没有 DDL 权限时可由 DBA 事先执行本文件，并配置 migrate.manual: true
+CREATE TABLE IF NOT EXISTS `hive` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT,
+    `db` VARCHAR(128) NOT NULL COMMENT '库名',
+    `table` VARCHAR(128) NOT NULL COMMENT '表名',
+    `location` VARCHAR(4000) NOT NULL COMMENT '路径，为空代表没有路径',
+    `size` BIGINT NOT NULL COMMENT '占用存储空间大小，单位 bytes，-1 表示没有路径或者获取路径错误',
+    `desc` VARCHAR(4096) NOT NULL COMMENT '备注',
+    `date` DATE COMMENT '抓取数据时间',
+    `extra` JSON COMMENT '扩展属性',
+    `run_id` VARCHAR(36) COMMENT '运行 ID',
+    `counter_version` VARCHAR(64) COMMENT 'counter 版本',
+    `metadata_ms` BIGINT COMMENT '获取元数据耗时，单位毫秒',
+    `size_ms` BIGINT COMMENT '获取大小耗时，单位毫秒',
+    `delta_bytes` BIGINT COMMENT '与上一次抓取相比的变化，单位 bytes',
+    `delta_pct` DOUBLE COMMENT '与上一次抓取相比的变化，单位 %',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uniq_record` (`db`, `table`, `date`),
+    KEY `idx_hive_date` (`date`)
+) ENGINE = InnoDB AUTO_INCREMENT = 1 DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `schema_migrations` (
+    `version` BIGINT NOT NULL COMMENT '迁移版本',
+    `name` VARCHAR(128) NOT NULL COMMENT '迁移名称',
+    `applied_at` DATETIME(3) NOT NULL COMMENT '执行时间',
+    PRIMARY KEY (`version`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+INSERT IGNORE INTO `schema_migrations` (`version`, `name`, `applied_at`) VALUES (1, 'create hive', NOW()), (2, 'add run_id and counter_version', NOW()), (3, 'add metadata_ms and size_ms', NOW()), (4, 'add delta_bytes and delta_pct', NOW()), (5, 'add unique index uniq_record', NOW());
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"os"
+	"sort"
+)
+
+// jvmMetricsBean NameNode /jmx 中 Hadoop:service=NameNode,name=JvmMetrics 的字段
+type jvmMetricsBean struct {
+	MemHeapUsedM float64 `json:"MemHeapUsedM"`
+	MemHeapMaxM  float64 `json:"MemHeapMaxM"`
+}
+
+// namespaceDb 一个库在 NameNode 中的对象数，blocks 为估算值
+type namespaceDb struct {
+	db     string
+	tables int
+	size   int64
+	files  int64
+	dirs   int64
+	blocks int64
+}
+
+func (d *namespaceDb) objects() int64 {
+	return d.files + d.dirs + d.blocks
+}
+
+// runNamespace 按 NameNode 对象数输出每个库的排名，配置了 capacity.namenodes 时按对象数占比估算占用的堆内存
+func runNamespace(args []string) {
+	flags := flag.NewFlagSet("namespace", flag.ExitOnError)
+	dateFlag := flags.String("date", "", "日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	limit := flags.Int("limit", 50, "输出的数量，0 表示全部输出")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	date, err := resolveDate(ctx, store, *dateFlag)
+	if err != nil {
+		log.Fatal("解析日期失败: " + err.Error())
+	}
+	records, err := loadRecords(ctx, store, date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	dbs := countNamespace(records)
+	if *limit > 0 && len(dbs) > *limit {
+		dbs = dbs[:*limit]
+	}
+
+	// NameNode 中的对象总数和堆内存，无法获取时只输出对象数
+	var (
+		totalObjects int64
+		heapUsed     int64
+	)
+	if len(config.Capacity.Namenodes) > 0 {
+		address, namesystem, err := activeNamenode(ctx)
+		if err != nil {
+			log.Printf("获取 NameNode 指标失败: %+v", err)
+		} else {
+			var jvm jvmMetricsBean
+			err = fetchJmxBean(ctx, address, "Hadoop:service=NameNode,name=JvmMetrics", &jvm)
+			if err != nil {
+				log.Printf("获取 NameNode 堆内存失败: %+v", err)
+			}
+			totalObjects = namesystem.FilesTotal + namesystem.BlocksTotal
+			heapUsed = int64(jvm.MemHeapUsedM * (1 << 20))
+			log.Printf("NameNode %s 共 %d 个对象，堆内存已使用 %s，最大 %s", address, totalObjects, formatBytes(heapUsed), formatBytes(int64(jvm.MemHeapMaxM*(1<<20))))
+		}
+	}
+
+	header := []string{"rank", "db", "tables", "size", "files", "dirs", "blocks", "objects", "objects_percent", "heap"}
+	var rows [][]interface{}
+	for i, d := range dbs {
+		heap := "-"
+		if totalObjects > 0 && heapUsed > 0 {
+			heap = formatBytes(int64(float64(heapUsed) * float64(d.objects()) / float64(totalObjects)))
+		}
+		rows = append(rows, []interface{}{i + 1, d.db, d.tables, byteSize(d.size), d.files, d.dirs, d.blocks, d.objects(), sizePercent(d.objects(), totalObjects), heap})
+	}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+// countNamespace 按库汇总文件数和目录数，按对象数降序排列
+//
+// 每个非空文件至少占用一个 block，block 数按 max(文件数, ceil(size / small_files.target_size)) 估算。
+func countNamespace(records []*model.Hive) []*namespaceDb {
+	blockSize := int64(config.SmallFiles.TargetSize)
+	if blockSize <= 0 {
+		blockSize = defaultSmallFileTargetSize
+	}
+	var (
+		dbs   []*namespaceDb
+		index = make(map[string]*namespaceDb)
+	)
+	for _, record := range records {
+		files, ok := record.Extra.Int64(model.ExtraFileCount)
+		if !ok || record.Size < 0 {
+			continue
+		}
+		d, ok := index[record.Db]
+		if !ok {
+			d = &namespaceDb{db: record.Db}
+			index[record.Db] = d
+			dbs = append(dbs, d)
+		}
+		dirs, _ := record.Extra.Int64(model.ExtraDirCount)
+		blocks := (record.Size + blockSize - 1) / blockSize
+		if blocks < files {
+			blocks = files
+		}
+		d.tables++
+		d.size += record.Size
+		d.files += files
+		d.dirs += dirs
+		d.blocks += blocks
+	}
+	sort.SliceStable(dbs, func(i, j int) bool {
+		return dbs[i].objects() > dbs[j].objects()
+	})
+	return dbs
+}
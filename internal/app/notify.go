@@ -0,0 +1,117 @@
+package app
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 通知渠道
+const (
+	webhookSlack    = "slack"
+	webhookDingtalk = "dingtalk"
+	webhookWecom    = "wecom"
+)
+
+const defaultWebhookTimeout = 30 * time.Second
+
+// webhook 即时通讯群机器人
+type webhook struct {
+	Type string `yaml:"type"`
+	Url  string `yaml:"url"`
+	// Secret 钉钉机器人开启加签时的密钥
+	Secret string `yaml:"secret"`
+}
+
+// notify 向所有配置的 webhook 发送消息，单个 webhook 失败不影响其他 webhook
+func notify(title, text string) error {
+	var failed []string
+	for _, hook := range config.Notify.Webhooks {
+		err := hook.send(title, text)
+		if err != nil {
+			log.Printf("发送 %s 通知失败: %+v", hook.Type, err)
+			failed = append(failed, hook.Type)
+		}
+	}
+	if len(failed) > 0 {
+		return failure.Wrap(fmt.Errorf("failed webhooks: %s", strings.Join(failed, ", ")))
+	}
+	return nil
+}
+
+func (h webhook) send(title, text string) error {
+	var (
+		address = h.Url
+		payload interface{}
+	)
+	switch h.Type {
+	case webhookSlack:
+		payload = map[string]string{"text": "*" + title + "*\n```\n" + text + "```"}
+	case webhookDingtalk:
+		if h.Secret != "" {
+			address = dingtalkSign(address, h.Secret, time.Now())
+		}
+		payload = map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": title + "\n\n" + text},
+		}
+	case webhookWecom:
+		payload = map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": title + "\n\n" + text},
+		}
+	default:
+		return failure.Wrap(fmt.Errorf("unknown webhook type: %s", h.Type))
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	resp, err := (&http.Client{Timeout: defaultWebhookTimeout}).Post(address, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	message, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return failure.Wrap(fmt.Errorf("webhook failed with %s: %s", resp.Status, bytes.TrimSpace(message)))
+	}
+	// 钉钉和企业微信出错时同样返回 200，需要检查 errcode
+	if h.Type != webhookSlack {
+		var result struct {
+			Errcode int    `json:"errcode"`
+			Errmsg  string `json:"errmsg"`
+		}
+		if json.Unmarshal(message, &result) == nil && result.Errcode != 0 {
+			return failure.Wrap(fmt.Errorf("webhook failed with errcode %d: %s", result.Errcode, result.Errmsg))
+		}
+	}
+	return nil
+}
+
+// dingtalkSign 在 url 上附加 timestamp 和 sign 参数
+func dingtalkSign(address, secret string, now time.Time) string {
+	timestamp := strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	separator := "?"
+	if strings.Contains(address, "?") {
+		separator = "&"
+	}
+	return address + separator + "timestamp=" + timestamp + "&sign=" + url.QueryEscape(sign)
+}
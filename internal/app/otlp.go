@@ -0,0 +1,149 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOtlpServiceName = "counter"
+	defaultOtlpTimeout     = 30 * time.Second
+)
+
+// 以下为 OTLP/HTTP JSON 编码所需的最小结构，字段名见 opentelemetry-proto
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Gauge       otlpGauge `json:"gauge"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	TimeUnixNano string          `json:"timeUnixNano"`
+	AsDouble     float64         `json:"asDouble"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// exportOtlpMetrics 通过 OTLP/HTTP 以 JSON 编码导出指标，可以发送到任意 OpenTelemetry Collector
+func exportOtlpMetrics(families []metricFamily, timestamp time.Time) error {
+	resource := newOtlpResource()
+	timeUnixNano := strconv.FormatInt(timestamp.UnixNano(), 10)
+	metrics := make([]otlpMetric, 0, len(families))
+	for _, family := range families {
+		metric := otlpMetric{Name: family.name, Description: family.help}
+		for _, sample := range family.samples {
+			point := otlpDataPoint{TimeUnixNano: timeUnixNano, AsDouble: sample.value}
+			for _, label := range sample.labels {
+				point.Attributes = append(point.Attributes, newOtlpAttribute(label.name, label.value))
+			}
+			metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, point)
+		}
+		metrics = append(metrics, metric)
+	}
+
+	body, err := json.Marshal(otlpMetricsRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: resource,
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: "github.com/rea1shane/counter"},
+				Metrics: metrics,
+			}},
+		}},
+	})
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	return postOtlp("/v1/metrics", body)
+}
+
+// newOtlpResource 包含 service.name 和配置的资源属性
+func newOtlpResource() otlpResource {
+	serviceName := config.Otlp.ServiceName
+	if serviceName == "" {
+		serviceName = defaultOtlpServiceName
+	}
+	resource := otlpResource{Attributes: []otlpAttribute{newOtlpAttribute("service.name", serviceName)}}
+	keys := make([]string, 0, len(config.Otlp.ResourceAttributes))
+	for key := range config.Otlp.ResourceAttributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		resource.Attributes = append(resource.Attributes, newOtlpAttribute(key, config.Otlp.ResourceAttributes[key]))
+	}
+	return resource
+}
+
+// postOtlp 发送到 collector 的对应接口，endpoint 不包含 /v1/... 路径
+func postOtlp(path string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(config.Otlp.Endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range config.Otlp.Headers {
+		req.Header.Set(key, value)
+	}
+
+	timeout := config.Otlp.Timeout
+	if timeout <= 0 {
+		timeout = defaultOtlpTimeout
+	}
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return failure.Wrap(fmt.Errorf("otlp export failed with %s: %s", resp.Status, bytes.TrimSpace(message)))
+	}
+	return nil
+}
+
+func newOtlpAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: value}}
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// ownershipRule 将库或表映射到团队、项目和成本中心
+//
+// pattern 不包含 . 时匹配库名，否则匹配 db.table，支持 path.Match 的通配符，例如 dw_*、ods.user_*。
+type ownershipRule struct {
+	Pattern    string `yaml:"pattern"`
+	Team       string `yaml:"team"`
+	Project    string `yaml:"project"`
+	CostCenter string `yaml:"cost_center"`
+}
+
+// loadOwnershipRules 读取映射文件，没有配置时返回 nil
+func loadOwnershipRules() ([]ownershipRule, error) {
+	if config.Ownership.File == "" {
+		return nil, nil
+	}
+	file, err := ioutil.ReadFile(config.Ownership.File)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	var rules []ownershipRule
+	err = yaml.Unmarshal(file, &rules)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	for _, rule := range rules {
+		_, err = path.Match(rule.Pattern, "")
+		if err != nil {
+			return nil, failure.Wrap(err)
+		}
+	}
+	return rules, nil
+}
+
+// tagOwnership 按顺序匹配，第一个匹配的规则生效，结果写入 Extra
+func tagOwnership(entities []*model.Hive, rules []ownershipRule) {
+	for _, entity := range entities {
+		for _, rule := range rules {
+			name := entity.Db
+			if strings.Contains(rule.Pattern, ".") {
+				name = entity.Db + "." + entity.Table
+			}
+			if ok, _ := path.Match(rule.Pattern, name); !ok {
+				continue
+			}
+			if rule.Team != "" {
+				entity.SetExtra(model.ExtraTeam, rule.Team)
+			}
+			if rule.Project != "" {
+				entity.SetExtra(model.ExtraProject, rule.Project)
+			}
+			if rule.CostCenter != "" {
+				entity.SetExtra(model.ExtraCostCenter, rule.CostCenter)
+			}
+			break
+		}
+	}
+}
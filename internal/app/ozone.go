@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOzoneTimeout = 30 * time.Second
+
+	// ozonePageSize Recon 接口每页返回的数量
+	ozonePageSize = 1000
+)
+
+// ozoneVolume Recon /api/v1/volumes 返回的 volume
+type ozoneVolume struct {
+	Volume           string `json:"volume"`
+	QuotaInBytes     int64  `json:"quotaInBytes"`
+	QuotaInNamespace int64  `json:"quotaInNamespace"`
+}
+
+// ozoneBucket Recon /api/v1/buckets 返回的 bucket，usedNamespace 为 key 数量
+type ozoneBucket struct {
+	VolumeName       string `json:"volumeName"`
+	BucketName       string `json:"bucketName"`
+	BucketLayout     string `json:"bucketLayout"`
+	UsedBytes        int64  `json:"usedBytes"`
+	UsedNamespace    int64  `json:"usedNamespace"`
+	QuotaInBytes     int64  `json:"quotaInBytes"`
+	QuotaInNamespace int64  `json:"quotaInNamespace"`
+}
+
+// ozoneCounter 通过 Recon 的 REST 接口统计每个 volume 和 bucket 的使用量，用于跟踪从 HDFS 迁移到 Ozone 的冷数据
+var ozoneCounter = &counter{
+	name:  "ozone",
+	usage: "通过 ozone.recon 统计每个 Ozone volume 和 bucket 的已用空间和 key 数量并保存到 ozone_bucket 表",
+	model: &model.OzoneBucket{},
+	keys:  []string{"level", "volume", "bucket"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		records, err := countOzone(ctx, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"level", "volume", "bucket", "layout", "buckets", "size", "keys", "quota", "quota_percent"}
+		var rows [][]interface{}
+		for _, record := range records {
+			quota := "-"
+			if record.QuotaBytes >= 0 {
+				quota = formatBytes(record.QuotaBytes)
+			}
+			rows = append(rows, []interface{}{record.Level, record.Volume, record.Bucket, record.Layout, record.Buckets, byteSize(record.Size), record.Keys, quota, sizePercent(record.Size, record.QuotaBytes)})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countOzone 先列出 volume 级别的汇总，再列出 bucket，都按已用空间降序排列
+func countOzone(ctx context.Context, date time.Time) ([]*model.OzoneBucket, error) {
+	if config.Ozone.Recon == "" {
+		return nil, failure.Wrap(fmt.Errorf("ozone.recon is empty"))
+	}
+	timeout := config.Ozone.Timeout
+	if timeout <= 0 {
+		timeout = defaultOzoneTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+
+	var volumes []ozoneVolume
+	err := listOzone(ctx, client, "/api/v1/volumes", nil, func(data []byte) (string, int, error) {
+		var page struct {
+			Volumes []ozoneVolume `json:"volumes"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil || len(page.Volumes) == 0 {
+			return "", 0, err
+		}
+		volumes = append(volumes, page.Volumes...)
+		return page.Volumes[len(page.Volumes)-1].Volume, len(page.Volumes), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var totals, buckets []*model.OzoneBucket
+	for _, volume := range volumes {
+		total := &model.OzoneBucket{
+			Level:      model.LevelVolume,
+			Volume:     volume.Volume,
+			QuotaBytes: volume.QuotaInBytes,
+			QuotaKeys:  volume.QuotaInNamespace,
+			Date:       date,
+		}
+		err := listOzone(ctx, client, "/api/v1/buckets", url.Values{"volume": {volume.Volume}}, func(data []byte) (string, int, error) {
+			var page struct {
+				Buckets []ozoneBucket `json:"buckets"`
+			}
+			if err := json.Unmarshal(data, &page); err != nil || len(page.Buckets) == 0 {
+				return "", 0, err
+			}
+			for _, bucket := range page.Buckets {
+				buckets = append(buckets, &model.OzoneBucket{
+					Level:      model.LevelBucket,
+					Volume:     bucket.VolumeName,
+					Bucket:     bucket.BucketName,
+					Layout:     bucket.BucketLayout,
+					Buckets:    1,
+					Size:       bucket.UsedBytes,
+					Keys:       bucket.UsedNamespace,
+					QuotaBytes: bucket.QuotaInBytes,
+					QuotaKeys:  bucket.QuotaInNamespace,
+					Date:       date,
+				})
+				total.Buckets++
+				total.Size += bucket.UsedBytes
+				total.Keys += bucket.UsedNamespace
+			}
+			return page.Buckets[len(page.Buckets)-1].BucketName, len(page.Buckets), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+	for _, records := range [][]*model.OzoneBucket{totals, buckets} {
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].Size > records[j].Size
+		})
+	}
+	return append(totals, buckets...), nil
+}
+
+// listOzone 按 prevKey 分页请求 Recon 接口，page 解析一页并返回最后一项的名称和数量，数量小于每页大小时结束
+func listOzone(ctx context.Context, client *http.Client, api string, query url.Values, page func(data []byte) (string, int, error)) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("limit", strconv.Itoa(ozonePageSize))
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(config.Ozone.Recon, "/")+api+"?"+query.Encode(), nil)
+		if err != nil {
+			return failure.Wrap(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return failure.Wrap(err)
+		}
+		var data json.RawMessage
+		err = json.NewDecoder(resp.Body).Decode(&data)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return failure.Wrap(fmt.Errorf("%s failed with %s", api, resp.Status))
+		}
+		if err != nil {
+			return failure.Wrap(err)
+		}
+		last, count, err := page(data)
+		if err != nil {
+			return failure.Wrap(err)
+		}
+		if count < ozonePageSize {
+			return nil
+		}
+		query.Set("prevKey", last)
+	}
+}
@@ -0,0 +1,272 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"strings"
+)
+
+// Parquet 格式中用到的枚举值，见 parquet-format 中的 parquet.thrift
+const (
+	parquetInt32     int32 = 1
+	parquetInt64     int32 = 2
+	parquetByteArray int32 = 6
+
+	parquetUtf8 int32 = 0
+	parquetDate int32 = 6
+
+	parquetRequired     int32 = 0
+	parquetPlain        int32 = 0
+	parquetRle          int32 = 3
+	parquetDataPage     int32 = 0
+	parquetUncompressed int32 = 0
+
+	parquetNoConvertedType int32 = -1
+)
+
+const parquetMagic = "PAR1"
+
+// parquetSink 将结果写为单个 Parquet 文件，按日期分区存放，可以直接建 Hive 外表查询
+type parquetSink struct {
+	hdfsClient *hdfs.Client
+}
+
+// parquetColumn 一个 REQUIRED 列，values 为 PLAIN 编码后的数据
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	convertedType int32
+	values        bytes.Buffer
+}
+
+func (s *parquetSink) Open(ctx context.Context) error {
+	return nil
+}
+
+func (s *parquetSink) WriteBatch(ctx context.Context, entities []*model.Hive) error {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	columns := []*parquetColumn{
+		{name: "db", physicalType: parquetByteArray, convertedType: parquetUtf8},
+		{name: "table", physicalType: parquetByteArray, convertedType: parquetUtf8},
+		{name: "location", physicalType: parquetByteArray, convertedType: parquetUtf8},
+		{name: "size", physicalType: parquetInt64, convertedType: parquetNoConvertedType},
+		{name: "desc", physicalType: parquetByteArray, convertedType: parquetUtf8},
+		{name: "date", physicalType: parquetInt32, convertedType: parquetDate},
+		{name: "run_id", physicalType: parquetByteArray, convertedType: parquetUtf8},
+		{name: "counter_version", physicalType: parquetByteArray, convertedType: parquetUtf8},
+		{name: "metadata_ms", physicalType: parquetInt64, convertedType: parquetNoConvertedType},
+		{name: "size_ms", physicalType: parquetInt64, convertedType: parquetNoConvertedType},
+	}
+	for _, entity := range entities {
+		columns[0].appendString(entity.Db)
+		columns[1].appendString(entity.Table)
+		columns[2].appendString(entity.Location)
+		columns[3].appendInt64(entity.Size)
+		columns[4].appendString(entity.Desc)
+		columns[5].appendInt32(epochDays(entity.Date))
+		columns[6].appendString(entity.RunID)
+		columns[7].appendString(entity.CounterVersion)
+		columns[8].appendInt64(entity.MetadataMs)
+		columns[9].appendInt64(entity.SizeMs)
+	}
+
+	data, err := encodeParquet(columns, int64(len(entities)))
+	if err != nil {
+		return err
+	}
+	dir := strings.TrimSuffix(config.Parquet.Dir, "/") + "/dt=" + entities[0].Date.Format("2006-01-02")
+	return writeOutputFile(s.hdfsClient, dir, "hive.parquet", data)
+}
+
+func (s *parquetSink) Close() error {
+	return nil
+}
+
+func (c *parquetColumn) appendString(value string) {
+	binary.Write(&c.values, binary.LittleEndian, uint32(len(value)))
+	c.values.WriteString(value)
+}
+
+func (c *parquetColumn) appendInt64(value int64) {
+	binary.Write(&c.values, binary.LittleEndian, value)
+}
+
+func (c *parquetColumn) appendInt32(value int32) {
+	binary.Write(&c.values, binary.LittleEndian, value)
+}
+
+// encodeParquet 将所有列写入同一个 row group，每列一个未压缩的数据页
+func encodeParquet(columns []*parquetColumn, numRows int64) ([]byte, error) {
+	var (
+		file      bytes.Buffer
+		offsets   = make([]int64, len(columns))
+		chunkSize = make([]int64, len(columns))
+		totalSize int64
+	)
+	file.WriteString(parquetMagic)
+
+	for i, column := range columns {
+		header := newThriftEncoder()
+		header.writeStruct(func() {
+			header.writeI32(1, parquetDataPage)
+			header.writeI32(2, int32(column.values.Len()))
+			header.writeI32(3, int32(column.values.Len()))
+			header.writeField(5, thrift.STRUCT)
+			header.writeStruct(func() {
+				header.writeI32(1, int32(numRows))
+				header.writeI32(2, parquetPlain)
+				header.writeI32(3, parquetRle)
+				header.writeI32(4, parquetRle)
+			})
+		})
+		headerBytes, err := header.bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		offsets[i] = int64(file.Len())
+		file.Write(headerBytes)
+		file.Write(column.values.Bytes())
+		chunkSize[i] = int64(len(headerBytes) + column.values.Len())
+		totalSize += chunkSize[i]
+	}
+
+	footer := newThriftEncoder()
+	footer.writeStruct(func() {
+		footer.writeI32(1, 1)
+
+		// schema
+		footer.writeList(2, thrift.STRUCT, len(columns)+1)
+		footer.writeStruct(func() {
+			footer.writeString(4, "schema")
+			footer.writeI32(5, int32(len(columns)))
+		})
+		for _, column := range columns {
+			footer.writeStruct(func() {
+				footer.writeI32(1, column.physicalType)
+				footer.writeI32(3, parquetRequired)
+				footer.writeString(4, column.name)
+				if column.convertedType != parquetNoConvertedType {
+					footer.writeI32(6, column.convertedType)
+				}
+			})
+		}
+
+		footer.writeI64(3, numRows)
+
+		// row groups
+		footer.writeList(4, thrift.STRUCT, 1)
+		footer.writeStruct(func() {
+			footer.writeList(1, thrift.STRUCT, len(columns))
+			for i, column := range columns {
+				footer.writeStruct(func() {
+					footer.writeI64(2, offsets[i])
+					footer.writeField(3, thrift.STRUCT)
+					footer.writeStruct(func() {
+						footer.writeI32(1, column.physicalType)
+						footer.writeList(2, thrift.I32, 1)
+						footer.writeI32Value(parquetPlain)
+						footer.writeList(3, thrift.STRING, 1)
+						footer.writeStringValue(column.name)
+						footer.writeI32(4, parquetUncompressed)
+						footer.writeI64(5, numRows)
+						footer.writeI64(6, chunkSize[i])
+						footer.writeI64(7, chunkSize[i])
+						footer.writeI64(9, offsets[i])
+					})
+				})
+			}
+			footer.writeI64(2, totalSize)
+			footer.writeI64(3, numRows)
+		})
+
+		footer.writeString(6, "counter")
+	})
+	footerBytes, err := footer.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	file.Write(footerBytes)
+	binary.Write(&file, binary.LittleEndian, uint32(len(footerBytes)))
+	file.WriteString(parquetMagic)
+	return file.Bytes(), nil
+}
+
+// thriftEncoder 以 thrift compact 协议编码 Parquet 的元数据，只保留第一个出现的错误
+type thriftEncoder struct {
+	ctx    context.Context
+	buffer *thrift.TMemoryBuffer
+	proto  *thrift.TCompactProtocol
+	err    error
+}
+
+func newThriftEncoder() *thriftEncoder {
+	buffer := thrift.NewTMemoryBuffer()
+	return &thriftEncoder{
+		ctx:    context.Background(),
+		buffer: buffer,
+		proto:  thrift.NewTCompactProtocol(buffer),
+	}
+}
+
+func (e *thriftEncoder) check(err error) {
+	if e.err == nil && err != nil {
+		e.err = failure.Wrap(err)
+	}
+}
+
+func (e *thriftEncoder) writeStruct(fields func()) {
+	e.check(e.proto.WriteStructBegin(e.ctx, ""))
+	fields()
+	e.check(e.proto.WriteFieldStop(e.ctx))
+	e.check(e.proto.WriteStructEnd(e.ctx))
+}
+
+func (e *thriftEncoder) writeField(id int16, typeId thrift.TType) {
+	e.check(e.proto.WriteFieldBegin(e.ctx, "", typeId, id))
+}
+
+func (e *thriftEncoder) writeI32(id int16, value int32) {
+	e.writeField(id, thrift.I32)
+	e.writeI32Value(value)
+}
+
+func (e *thriftEncoder) writeI32Value(value int32) {
+	e.check(e.proto.WriteI32(e.ctx, value))
+}
+
+func (e *thriftEncoder) writeI64(id int16, value int64) {
+	e.writeField(id, thrift.I64)
+	e.check(e.proto.WriteI64(e.ctx, value))
+}
+
+func (e *thriftEncoder) writeString(id int16, value string) {
+	e.writeField(id, thrift.STRING)
+	e.writeStringValue(value)
+}
+
+func (e *thriftEncoder) writeStringValue(value string) {
+	e.check(e.proto.WriteString(e.ctx, value))
+}
+
+func (e *thriftEncoder) writeList(id int16, elemType thrift.TType, size int) {
+	e.writeField(id, thrift.LIST)
+	e.check(e.proto.WriteListBegin(e.ctx, elemType, size))
+}
+
+func (e *thriftEncoder) bytes() ([]byte, error) {
+	e.check(e.proto.Flush(e.ctx))
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.buffer.Bytes(), nil
+}
@@ -0,0 +1,18 @@
+CREATE EXTERNAL TABLE IF NOT EXISTS `counter_hive` (
+    `db` STRING COMMENT '库名',
+    `table` STRING COMMENT '表名',
+    `location` STRING COMMENT '路径，为空代表没有路径',
+    `size` BIGINT COMMENT '占用存储空间大小，单位 bytes，-1 表示没有路径或者获取路径错误',
+    `desc` STRING COMMENT '备注',
+    `date` DATE COMMENT '抓取数据时间',
+    `run_id` STRING COMMENT '运行 ID',
+    `counter_version` STRING COMMENT 'counter 版本',
+    `metadata_ms` BIGINT COMMENT '获取元数据耗时，单位毫秒',
+    `size_ms` BIGINT COMMENT '获取大小耗时，单位毫秒'
+)
+PARTITIONED BY (`dt` STRING)
+STORED AS PARQUET
+LOCATION 'hdfs://nameservice/user/ods/counter/hive';
+
+-- 每次写入新的分区后执行
+MSCK REPAIR TABLE `counter_hive`;
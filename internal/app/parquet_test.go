@@ -0,0 +1,195 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/rea1shane/counter/pkg/model"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// thriftStruct 按字段 ID 解码的 thrift 结构体，与 encodeParquet 无关，按 parquet-format 中的 parquet.thrift 读取
+type thriftStruct map[int16]interface{}
+
+func readThriftValue(ctx context.Context, p thrift.TProtocol, typ thrift.TType) (interface{}, error) {
+	switch typ {
+	case thrift.STRUCT:
+		value := thriftStruct{}
+		if _, err := p.ReadStructBegin(ctx); err != nil {
+			return nil, err
+		}
+		for {
+			_, fieldType, id, err := p.ReadFieldBegin(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if fieldType == thrift.STOP {
+				break
+			}
+			value[id], err = readThriftValue(ctx, p, fieldType)
+			if err != nil {
+				return nil, err
+			}
+			if err = p.ReadFieldEnd(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return value, p.ReadStructEnd(ctx)
+	case thrift.LIST:
+		elemType, size, err := p.ReadListBegin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, size)
+		for i := range values {
+			values[i], err = readThriftValue(ctx, p, elemType)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return values, p.ReadListEnd(ctx)
+	case thrift.I32:
+		return p.ReadI32(ctx)
+	case thrift.I64:
+		return p.ReadI64(ctx)
+	case thrift.STRING:
+		return p.ReadString(ctx)
+	}
+	return nil, fmt.Errorf("unexpected thrift type %s", typ)
+}
+
+// readThriftStruct 从 data 开头读取一个 compact 协议的结构体，返回结构体和占用的字节数
+func readThriftStruct(data []byte) (thriftStruct, int, error) {
+	buffer := thrift.NewTMemoryBuffer()
+	buffer.Write(data)
+	value, err := readThriftValue(context.Background(), thrift.NewTCompactProtocol(buffer), thrift.STRUCT)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value.(thriftStruct), len(data) - buffer.Len(), nil
+}
+
+// readParquet 读取 REQUIRED 列、PLAIN 编码、未压缩的 Parquet 文件，返回每列的名称和值
+func readParquet(data []byte) (names []string, columns [][]interface{}, err error) {
+	if !bytes.HasPrefix(data, []byte(parquetMagic)) || !bytes.HasSuffix(data, []byte(parquetMagic)) {
+		return nil, nil, fmt.Errorf("missing magic")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	metadata, _, err := readThriftStruct(data[len(data)-8-footerLength : len(data)-8])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	numRows := metadata[3].(int64)
+	schema := metadata[2].([]interface{})
+	if root := schema[0].(thriftStruct); root[5].(int32) != int32(len(schema)-1) {
+		return nil, nil, fmt.Errorf("root has %d children, want %d", root[5], len(schema)-1)
+	}
+	rowGroups := metadata[4].([]interface{})
+	if len(rowGroups) != 1 {
+		return nil, nil, fmt.Errorf("row groups = %d, want 1", len(rowGroups))
+	}
+	rowGroup := rowGroups[0].(thriftStruct)
+	if rowGroup[3].(int64) != numRows {
+		return nil, nil, fmt.Errorf("row group rows = %d, want %d", rowGroup[3], numRows)
+	}
+
+	for i, chunk := range rowGroup[1].([]interface{}) {
+		element := schema[i+1].(thriftStruct)
+		if element[3].(int32) != parquetRequired {
+			return nil, nil, fmt.Errorf("column %s is not required", element[4])
+		}
+		meta := chunk.(thriftStruct)[3].(thriftStruct)
+		if meta[3].([]interface{})[0] != element[4] || meta[1] != element[1] || meta[4].(int32) != parquetUncompressed {
+			return nil, nil, fmt.Errorf("column chunk %d does not match schema %v", i, element)
+		}
+
+		offset := meta[9].(int64)
+		header, headerLength, err := readThriftStruct(data[offset:])
+		if err != nil {
+			return nil, nil, err
+		}
+		if header[1].(int32) != parquetDataPage || int64(headerLength)+int64(header[3].(int32)) != meta[7].(int64) {
+			return nil, nil, fmt.Errorf("unexpected page header %v", header)
+		}
+		page := header[5].(thriftStruct)
+		if int64(page[1].(int32)) != numRows || page[2].(int32) != parquetPlain {
+			return nil, nil, fmt.Errorf("unexpected data page header %v", page)
+		}
+
+		values := bytes.NewReader(data[offset+int64(headerLength) : offset+int64(headerLength)+int64(header[2].(int32))])
+		column := make([]interface{}, numRows)
+		for row := range column {
+			switch element[1].(int32) {
+			case parquetByteArray:
+				var length uint32
+				binary.Read(values, binary.LittleEndian, &length)
+				value := make([]byte, length)
+				values.Read(value)
+				column[row] = string(value)
+			case parquetInt64:
+				var value int64
+				binary.Read(values, binary.LittleEndian, &value)
+				column[row] = value
+			case parquetInt32:
+				var value int32
+				binary.Read(values, binary.LittleEndian, &value)
+				column[row] = value
+			}
+		}
+		if values.Len() != 0 {
+			return nil, nil, fmt.Errorf("column %s has %d trailing bytes", element[4], values.Len())
+		}
+		names = append(names, element[4].(string))
+		columns = append(columns, column)
+	}
+	return names, columns, nil
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	previous := config
+	defer func() { config = previous }()
+	config = &Config{}
+	config.Parquet.Dir = t.TempDir()
+
+	date := time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)
+	entities := []*model.Hive{
+		{Db: "ods", Table: "orders", Location: "hdfs://nameservice1/user/hive/warehouse/ods.db/orders", Size: math.MaxInt64, Date: date, RunID: "run", CounterVersion: "v1", MetadataMs: 12, SizeMs: 345},
+		{Db: "ods", Table: "视图", Size: -1, Desc: "have no location", Date: date, RunID: "run", CounterVersion: "v1"},
+	}
+	err := (&parquetSink{}).WriteBatch(context.Background(), entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(config.Parquet.Dir, "dt=2026-10-16", "hive.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, columns, err := readParquet(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantNames := []string{"db", "table", "location", "size", "desc", "date", "run_id", "counter_version", "metadata_ms", "size_ms"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("columns = %v, want %v", names, wantNames)
+	}
+	for row, entity := range entities {
+		want := []interface{}{entity.Db, entity.Table, entity.Location, entity.Size, entity.Desc, epochDays(date), entity.RunID, entity.CounterVersion, entity.MetadataMs, entity.SizeMs}
+		for i := range names {
+			if columns[i][row] != want[i] {
+				t.Errorf("row %d %s = %v, want %v", row, names[i], columns[i][row], want[i])
+			}
+		}
+	}
+	if days := epochDays(date); days != 20742 {
+		t.Errorf("epoch days = %d, want 20742", days)
+	}
+}
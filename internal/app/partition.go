@@ -0,0 +1,117 @@
+package app
+
+import (
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultPartitionSkewPercent = 50
+	defaultPartitionSkewMinSize = 1 << 30
+)
+
+// partitionDir 表目录下 key=value 形式的一级分区目录
+type partitionDir struct {
+	name     string
+	value    string
+	location string
+}
+
+// listPartitionDirs 列出表目录下的一级分区目录，非分区表返回空
+func listPartitionDirs(client *hdfs.Client, location string) ([]partitionDir, error) {
+	path, err := hdfsPath(location)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := client.ReadDir(path)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	var dirs []partitionDir
+	for _, info := range infos {
+		parts := strings.SplitN(info.Name(), "=", 2)
+		if !info.IsDir() || len(parts) != 2 {
+			continue
+		}
+		dirs = append(dirs, partitionDir{
+			name:     info.Name(),
+			value:    parts[1],
+			location: strings.TrimSuffix(location, "/") + "/" + info.Name(),
+		})
+	}
+	return dirs, nil
+}
+
+// measurePartitionSkew 获取每个一级分区的大小，将分区数量和最大的分区写入 extra
+func measurePartitionSkew(client *hdfs.Client, entity *model.Hive) error {
+	dirs, err := listPartitionDirs(client, entity.Location)
+	if err != nil || len(dirs) == 0 {
+		return err
+	}
+	var (
+		maxName string
+		maxSize int64 = -1
+	)
+	for _, dir := range dirs {
+		summary, err := getHdfsContentSummary(client, dir.location)
+		if err != nil {
+			return err
+		}
+		if summary.Size() > maxSize {
+			maxName, maxSize = dir.name, summary.Size()
+		}
+	}
+	entity.SetExtra(model.ExtraPartitions, len(dirs))
+	entity.SetExtra(model.ExtraMaxPartition, maxName)
+	entity.SetExtra(model.ExtraMaxPartitionSize, maxSize)
+	return nil
+}
+
+// skewedPartition 最大的分区占表大小的比例超过阈值的表
+type skewedPartition struct {
+	record     *model.Hive
+	partitions int64
+	partition  string
+	size       int64
+	percent    float64
+}
+
+// findSkewedPartitions 筛选至少有两个分区、大小不小于 min_size 且最大的分区占比超过 percent 的表，按最大分区的大小降序排列
+func findSkewedPartitions(records []*model.Hive) []*skewedPartition {
+	threshold := config.PartitionSkew.Percent
+	if threshold <= 0 {
+		threshold = defaultPartitionSkewPercent
+	}
+	minSize := int64(config.PartitionSkew.MinSize)
+	if minSize <= 0 {
+		minSize = defaultPartitionSkewMinSize
+	}
+
+	var skewed []*skewedPartition
+	for _, record := range records {
+		partitions, ok := record.Extra.Int64(model.ExtraPartitions)
+		if !ok || partitions < 2 || record.Size < minSize {
+			continue
+		}
+		size, _ := record.Extra.Int64(model.ExtraMaxPartitionSize)
+		percent := float64(size) / float64(record.Size) * 100
+		if percent <= threshold {
+			continue
+		}
+		name, _ := record.Extra[model.ExtraMaxPartition].(string)
+		skewed = append(skewed, &skewedPartition{
+			record:     record,
+			partitions: partitions,
+			partition:  name,
+			size:       size,
+			percent:    percent,
+		})
+	}
+	sort.SliceStable(skewed, func(i, j int) bool {
+		return skewed[i].size > skewed[j].size
+	})
+	return skewed
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"github.com/beltran/gohive"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/hivemeta"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"sort"
+	"time"
+)
+
+// partitionCounter 只通过 HiveServer2 统计每张表的分区数量，不访问 HDFS
+var partitionCounter = &counter{
+	name:  "partitions",
+	usage: "通过 SHOW PARTITIONS 统计每张表的分区数量并保存到 hive_partition_count 表，跳过黑名单中的库",
+	model: &model.HivePartitionCount{},
+	keys:  []string{"db", "table"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		records, err := countPartitions(ctx, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"db", "table", "partitions", "desc"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{record.Db, record.Table, record.Partitions, record.Desc})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countPartitions 按分区数量降序返回每张表，包括非分区表
+func countPartitions(ctx context.Context, date time.Time) ([]*model.HivePartitionCount, error) {
+	lists, err := loadDbLists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hiveConnection, err := connectHive()
+	if err != nil {
+		return nil, err
+	}
+	defer hiveConnection.Close()
+	cursor := hiveConnection.Cursor()
+	defer cursor.Close()
+	source, err := newHiveSource(ctx, cursor)
+	if err != nil {
+		return nil, err
+	}
+	dialect := source.Detect(ctx)
+
+	dbs, err := source.Dbs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var records []*model.HivePartitionCount
+	for _, db := range dbs {
+		if lists.skip(db) {
+			continue
+		}
+		tables, err := source.Tables(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			err = waitQuiet(ctx)
+			if err != nil {
+				return nil, err
+			}
+			record := &model.HivePartitionCount{Db: db, Table: table, Date: date}
+			record.Partitions, err = countTablePartitions(ctx, cursor, dialect, db, table)
+			if err != nil {
+				log.Printf("获取 %s.%s 的分区数量失败: %+v", db, table, err)
+				record.Partitions = -1
+				record.Desc = err.Error()
+			}
+			records = append(records, record)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Partitions > records[j].Partitions
+	})
+	return records, nil
+}
+
+// countTablePartitions 逐行读取 SHOW PARTITIONS 的结果计数，非分区表返回 0
+func countTablePartitions(ctx context.Context, cursor *gohive.Cursor, dialect hivemeta.Dialect, db, table string) (int64, error) {
+	cursor.Exec(ctx, "SHOW PARTITIONS "+db+"."+table)
+	if cursor.Err != nil {
+		if dialect.NotPartitioned(cursor.Err) {
+			return 0, nil
+		}
+		return 0, failure.Wrap(cursor.Err, failure.Context{"op": "show partitions", "db": db, "table": table})
+	}
+
+	var (
+		partition string
+		count     int64
+	)
+	for cursor.HasMore(ctx) {
+		cursor.FetchOne(ctx, &partition)
+		if cursor.Err != nil {
+			return 0, failure.Wrap(cursor.Err, failure.Context{"op": "show partitions", "db": db, "table": table})
+		}
+		count++
+	}
+	return count, nil
+}
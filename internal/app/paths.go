@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/model"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pathCounter 统计 paths 中配置的 HDFS 路径的大小，路径支持 path.Match 的通配符
+var pathCounter = &counter{
+	name:  "paths",
+	usage: "统计配置文件 paths 中 HDFS 路径的大小并保存到 hdfs_path 表，路径支持通配符，例如 /user/*/.sparkStaging",
+	model: &model.HdfsPath{},
+	keys:  []string{"path"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		client, err := newHdfsClient()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		defer client.Close()
+		defer hdfssize.CloseOnDone(ctx, client)()
+
+		records, err := countPaths(client, config.Paths, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"pattern", "path", "size", "raw_size", "files", "dirs", "desc"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{record.Pattern, record.Path, byteSize(record.Size), byteSize(record.RawSize), record.Files, record.Dirs, record.Desc})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countPaths 展开通配符并获取每个路径的大小，单个路径失败时记录在 Desc 中
+func countPaths(client *hdfs.Client, patterns []string, date time.Time) ([]*model.HdfsPath, error) {
+	var records []*model.HdfsPath
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		paths, err := globHdfs(client, pattern)
+		if err != nil {
+			records = append(records, &model.HdfsPath{Pattern: pattern, Path: pattern, Size: -1, Desc: err.Error(), Date: date})
+			continue
+		}
+		for _, p := range paths {
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+
+			record := &model.HdfsPath{Pattern: pattern, Path: p, Date: date}
+			summary, err := getPathContentSummary(client, p)
+			if err != nil {
+				record.Size = -1
+				record.Desc = err.Error()
+			} else {
+				record.Size = summary.Size()
+				record.RawSize = summary.SizeAfterReplication()
+				record.Files = int64(summary.FileCount())
+				record.Dirs = int64(summary.DirectoryCount())
+			}
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// globHdfs 逐级展开路径中包含通配符的部分，没有通配符时直接返回路径
+func globHdfs(client *hdfs.Client, pattern string) ([]string, error) {
+	pattern = path.Clean("/" + pattern)
+	matches := []string{"/"}
+	for _, segment := range strings.Split(strings.TrimPrefix(pattern, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		var next []string
+		for _, parent := range matches {
+			if !strings.ContainsAny(segment, "*?[\\") {
+				next = append(next, path.Join(parent, segment))
+				continue
+			}
+			infos, err := client.ReadDir(parent)
+			if err != nil {
+				return nil, failure.Wrap(err, failure.Context{"op": "glob", "path": parent})
+			}
+			for _, info := range infos {
+				ok, err := path.Match(segment, info.Name())
+				if err != nil {
+					return nil, failure.Wrap(err, failure.Context{"op": "glob", "path": pattern})
+				}
+				if ok {
+					next = append(next, path.Join(parent, info.Name()))
+				}
+			}
+		}
+		matches = next
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
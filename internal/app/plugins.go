@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"github.com/colinmarc/hdfs"
+	"github.com/rea1shane/counter/internal/retry"
+	hivecounter "github.com/rea1shane/counter/pkg/counter"
+	"github.com/rea1shane/counter/pkg/hivemeta"
+	"github.com/rea1shane/counter/pkg/plugin"
+	"github.com/rea1shane/counter/pkg/sink"
+	"log"
+	"time"
+)
+
+// openSource 配置了 plugins.source 时通过外部程序获取元数据，否则连接 HiveServer2，close 用于释放连接
+func openSource(ctx context.Context) (source hivecounter.MetadataSource, close func(), err error) {
+	if config.Plugins.Source.Enabled() {
+		p, err := plugin.StartSource(ctx, pluginCommand("source", config.Plugins.Source))
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, closePlugin(p.Process), nil
+	}
+
+	hiveConnection, err := connectHive()
+	if err != nil {
+		return nil, nil, err
+	}
+	hiveCursor := hiveConnection.Cursor()
+	hiveSource, err := newHiveSource(ctx, hiveCursor)
+	if err != nil {
+		hiveCursor.Close()
+		hiveConnection.Close()
+		return nil, nil, err
+	}
+	if config.Hive.Metastore.Uris != "" {
+		hiveSource.Metastore, err = hivemeta.OpenMetastore(config.Hive.Metastore.Uris, config.Hive.Metastore.Timeout)
+		if err != nil {
+			hiveCursor.Close()
+			hiveConnection.Close()
+			return nil, nil, err
+		}
+	}
+	return &retryingSource{source: hiveSource, policy: config.Hive.Retry}, func() {
+		if hiveSource.Metastore != nil {
+			hiveSource.Metastore.Close()
+		}
+		hiveCursor.Close()
+		hiveConnection.Close()
+	}, nil
+}
+
+// retryingSource 临时错误时按 policy 重试元数据查询
+type retryingSource struct {
+	source hivecounter.MetadataSource
+	policy retry.Policy
+}
+
+func (s *retryingSource) do(ctx context.Context, op string, fn func() error) error {
+	retrier := retry.Retrier{
+		Policy:    s.policy,
+		Retryable: retry.Transient,
+		OnRetry: func(attempt int, wait time.Duration, err error) {
+			logf(levelWarn, map[string]string{"component": "hive", "op": op}, "%s 后第 %d 次重试: %v", wait, attempt, err)
+		},
+	}
+	return retrier.Do(ctx, fn)
+}
+
+func (s *retryingSource) Dbs(ctx context.Context) (dbs []string, err error) {
+	err = s.do(ctx, "show databases", func() error {
+		dbs, err = s.source.Dbs(ctx)
+		return err
+	})
+	return
+}
+
+func (s *retryingSource) Tables(ctx context.Context, db string) (tables []string, err error) {
+	err = s.do(ctx, "show tables", func() error {
+		tables, err = s.source.Tables(ctx, db)
+		return err
+	})
+	return
+}
+
+func (s *retryingSource) Location(ctx context.Context, db, table string) (location string, err error) {
+	err = s.do(ctx, "show create table", func() error {
+		location, err = s.source.Location(ctx, db, table)
+		return err
+	})
+	return
+}
+
+func (s *retryingSource) Table(ctx context.Context, db, table string) (t *hivemeta.Table, err error) {
+	err = s.do(ctx, "show create table", func() error {
+		t, err = hivecounter.Describe(ctx, s.source, db, table)
+		return err
+	})
+	return
+}
+
+// openSizes 配置了 plugins.sizes 时通过外部程序获取大小，否则通过 hdfs 获取
+func openSizes(ctx context.Context, hdfsClient *hdfs.Client) (sizes hivecounter.SizeProvider, close func(), err error) {
+	if config.Plugins.Sizes.Enabled() {
+		p, err := plugin.StartSizer(ctx, pluginCommand("sizes", config.Plugins.Sizes))
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, closePlugin(p.Process), nil
+	}
+	return hdfsConfig().NewSizer(hdfsClient), func() {}, nil
+}
+
+// pluginCommand 外部程序的 stderr 按行输出为 warn 级别的日志
+func pluginCommand(name string, command plugin.Command) plugin.Command {
+	command.Stderr = newLogWriter(levelWarn, map[string]string{"component": "plugin", "plugin": name})
+	return command
+}
+
+func closePlugin(p *plugin.Process) func() {
+	return func() {
+		err := p.Close()
+		if err != nil {
+			log.Printf("关闭插件失败: %+v", err)
+		}
+	}
+}
+
+// registerPluginSinks 注册 plugins.sinks 中的外部程序，sink 名称为配置中的 key
+func registerPluginSinks() {
+	for name, command := range config.Plugins.Sinks {
+		command := pluginCommand(name, command)
+		sink.Register(name, func(sink.Options) (sink.Sink, error) {
+			return plugin.NewSink(command), nil
+		})
+	}
+}
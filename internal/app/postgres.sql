@@ -0,0 +1,44 @@
+-- 写入时会自动执行 sink.Migrations 中的迁移，没有 DDL 权限时可由 DBA 事先执行本文件，并配置 migrate.manual: true
+CREATE TABLE IF NOT EXISTS "hive" (
+    "id" BIGSERIAL NOT NULL,
+    "db" VARCHAR(128) NOT NULL,
+    "table" VARCHAR(128) NOT NULL,
+    "location" VARCHAR(4000) NOT NULL,
+    "size" BIGINT NOT NULL,
+    "desc" VARCHAR(4096) NOT NULL,
+    "date" DATE,
+    "extra" JSONB,
+    "run_id" VARCHAR(36),
+    "counter_version" VARCHAR(64),
+    "metadata_ms" BIGINT,
+    "size_ms" BIGINT,
+    "delta_bytes" BIGINT,
+    "delta_pct" DOUBLE PRECISION,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uniq_record" UNIQUE ("db", "table", "date")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_hive_date" ON "hive" ("date");
+
+COMMENT ON COLUMN "hive"."db" IS '库名';
+COMMENT ON COLUMN "hive"."table" IS '表名';
+COMMENT ON COLUMN "hive"."location" IS '路径，为空代表没有路径';
+COMMENT ON COLUMN "hive"."size" IS '占用存储空间大小，单位 bytes，-1 表示没有路径或者获取路径错误';
+COMMENT ON COLUMN "hive"."desc" IS '备注';
+COMMENT ON COLUMN "hive"."date" IS '抓取数据时间';
+COMMENT ON COLUMN "hive"."extra" IS '扩展属性';
+COMMENT ON COLUMN "hive"."run_id" IS '运行 ID';
+COMMENT ON COLUMN "hive"."counter_version" IS 'counter 版本';
+COMMENT ON COLUMN "hive"."metadata_ms" IS '获取元数据耗时，单位毫秒';
+COMMENT ON COLUMN "hive"."size_ms" IS '获取大小耗时，单位毫秒';
+COMMENT ON COLUMN "hive"."delta_bytes" IS '与上一次抓取相比的变化，单位 bytes';
+COMMENT ON COLUMN "hive"."delta_pct" IS '与上一次抓取相比的变化，单位 %';
+
+CREATE TABLE IF NOT EXISTS "schema_migrations" (
+    "version" BIGINT NOT NULL,
+    "name" VARCHAR(128) NOT NULL,
+    "applied_at" TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("version")
+);
+
+INSERT INTO "schema_migrations" ("version", "name", "applied_at") VALUES (1, 'create hive', NOW()), (2, 'add run_id and counter_version', NOW()), (3, 'add metadata_ms and size_ms', NOW()), (4, 'add delta_bytes and delta_pct', NOW()), (5, 'add unique index uniq_record', NOW()) ON CONFLICT DO NOTHING;
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/rea1shane/counter/pkg/sink"
+	"gopkg.in/yaml.v3"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPruneKeep 没有配置保留时间时保留的天数
+const defaultPruneKeep = 400
+
+// pruneTable 可以清理的结果表和表示日期的列
+type pruneTable struct {
+	model  interface{ TableName() string }
+	column string
+}
+
+// pruneTables 可以清理的结果表，计数器的结果表见 counters
+var pruneTables = []pruneTable{
+	{&model.Hive{}, "date"},
+	{&model.HiveDb{}, "date"},
+	{&model.HiveCluster{}, "date"},
+	{&model.HiveTrend{}, "date"},
+	{&model.HiveLifecycle{}, "date"},
+	{&model.HiveRetention{}, "date"},
+	{&model.HiveRollup{}, "period"},
+	{&model.HiveRun{}, "date"},
+}
+
+// days 配置文件中的保留时间，格式为 400d 或天数
+type days int
+
+func (d *days) UnmarshalYAML(node *yaml.Node) error {
+	value, err := parseDays(node.Value)
+	if err != nil {
+		return err
+	}
+	*d = days(value)
+	return nil
+}
+
+func parseDays(value string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "d"))
+	if err != nil || n <= 0 {
+		return 0, failure.Wrap(fmt.Errorf("invalid days: %s", value))
+	}
+	return n, nil
+}
+
+// runPrune 删除结果数据库中超过保留时间的记录
+func runPrune(args []string) {
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	keep := flags.String("keep", "", "保留时间，例如 400d，默认使用配置中的 prune.keep，对配置了 prune.tables 的表不生效")
+	dryRun := flags.Bool("dry-run", false, "只输出将要删除的记录数")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+	if *keep != "" {
+		value, err := parseDays(*keep)
+		if err != nil {
+			log.Fatal("解析保留时间失败: " + err.Error())
+		}
+		config.Prune.Keep = days(value)
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	err = prune(ctx, store, currentDate(), *dryRun)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+// prune 按 prune.tables 中每张表的保留时间删除，没有配置的表使用 prune.keep，不存在的表跳过
+func prune(ctx context.Context, store *sink.Gorm, date time.Time, dryRun bool) error {
+	tables := pruneTables
+	for _, c := range counters {
+		tables = append(tables, pruneTable{c.model, "date"})
+	}
+	for _, t := range tables {
+		name := t.model.TableName()
+		if !store.DB().WithContext(ctx).Migrator().HasTable(t.model) {
+			continue
+		}
+		keep, ok := config.Prune.Tables[name]
+		if !ok {
+			keep = config.Prune.Keep
+		}
+		if keep <= 0 {
+			keep = defaultPruneKeep
+		}
+		cutoff := date.AddDate(0, 0, -int(keep))
+
+		query := store.DB().WithContext(ctx).Where(t.column+" < ?", cutoff)
+		if dryRun {
+			var count int64
+			err := query.Model(t.model).Count(&count).Error
+			if err != nil {
+				return failure.Wrap(err)
+			}
+			log.Printf("%s 将删除 %s 之前的 %d 条记录", name, cutoff.Format("2006-01-02"), count)
+			continue
+		}
+		result := query.Delete(t.model)
+		if result.Error != nil {
+			return failure.Wrap(result.Error)
+		}
+		log.Printf("%s 删除了 %s 之前的 %d 条记录", name, cutoff.Format("2006-01-02"), result.RowsAffected)
+	}
+	return nil
+}
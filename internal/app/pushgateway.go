@@ -0,0 +1,68 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/morikuni/failure"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultPushgatewayJob = "counter_hive"
+
+// pushMetrics 以 PUT 方式推送，覆盖同一分组下上一次运行的指标
+func pushMetrics(families []metricFamily) error {
+	job := config.Pushgateway.Job
+	if job == "" {
+		job = defaultPushgatewayJob
+	}
+	u := strings.TrimSuffix(config.Pushgateway.Url, "/") + "/metrics/job/" + url.PathEscape(job)
+	if config.Pushgateway.Instance != "" {
+		u += "/instance/" + url.PathEscape(config.Pushgateway.Instance)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(encodeMetricsText(families)))
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return failure.Wrap(fmt.Errorf("push metrics failed with %s: %s", resp.Status, bytes.TrimSpace(message)))
+	}
+	return nil
+}
+
+// encodeMetricsText 编码为 Prometheus 文本格式
+func encodeMetricsText(families []metricFamily) []byte {
+	var buf bytes.Buffer
+	for _, family := range families {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(&buf, "# TYPE %s gauge\n", family.name)
+		for _, sample := range family.samples {
+			buf.WriteString(family.name)
+			if len(sample.labels) > 0 {
+				buf.WriteByte('{')
+				for i, label := range sample.labels {
+					if i > 0 {
+						buf.WriteByte(',')
+					}
+					buf.WriteString(label.name + "=" + strconv.Quote(label.value))
+				}
+				buf.WriteByte('}')
+			}
+			buf.WriteString(" " + strconv.FormatFloat(sample.value, 'g', -1, 64) + "\n")
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/morikuni/failure"
+	"gopkg.in/yaml.v3"
+	"log"
+	"time"
+)
+
+// 静默时段内的处理方式
+const (
+	quietPause    = "pause"
+	quietThrottle = "throttle"
+)
+
+const defaultQuietInterval = time.Second
+
+// quietWindow 静默时段，例如业务高峰期，end 小于 start 时跨越零点
+type quietWindow struct {
+	Start  clock  `yaml:"start"`
+	End    clock  `yaml:"end"`
+	Action string `yaml:"action"`
+	// Interval throttle 时每张表之间等待的时间
+	Interval time.Duration `yaml:"interval"`
+}
+
+// clock 配置文件中一天内的时间，格式为 15:04，保存为从零点开始的分钟数
+type clock int
+
+func (c *clock) UnmarshalYAML(node *yaml.Node) error {
+	t, err := time.Parse("15:04", node.Value)
+	if err != nil {
+		return failure.Wrap(fmt.Errorf("invalid time %q, need HH:MM", node.Value))
+	}
+	*c = clock(t.Hour()*60 + t.Minute())
+	return nil
+}
+
+func (c clock) MarshalYAML() (interface{}, error) {
+	return fmt.Sprintf("%02d:%02d", c/60, c%60), nil
+}
+
+// contains now 是否在时段内，返回时段结束的时间
+func (w quietWindow) contains(now time.Time) (bool, time.Time) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	minute := clock(now.Hour()*60 + now.Minute())
+	at := func(day time.Time, c clock) time.Time {
+		return day.Add(time.Duration(c) * time.Minute)
+	}
+	switch {
+	case w.Start < w.End:
+		return minute >= w.Start && minute < w.End, at(midnight, w.End)
+	case w.Start > w.End:
+		if minute >= w.Start {
+			return true, at(midnight.AddDate(0, 0, 1), w.End)
+		}
+		return minute < w.End, at(midnight, w.End)
+	}
+	return false, time.Time{}
+}
+
+// activeQuietWindow 返回 now 所在的静默时段
+func activeQuietWindow(now time.Time) (quietWindow, time.Time, bool) {
+	for _, window := range config.QuietWindows {
+		if ok, end := window.contains(now); ok {
+			return window, end, true
+		}
+	}
+	return quietWindow{}, time.Time{}, false
+}
+
+// waitQuiet 处理每张表之前调用，在静默时段内暂停到时段结束，或者等待 interval 降低对 Hive 和 HDFS 的压力
+func waitQuiet(ctx context.Context) error {
+	paused := false
+	for {
+		window, end, ok := activeQuietWindow(time.Now())
+		if !ok {
+			if paused {
+				log.Println("静默时段结束，继续抓取")
+			}
+			return nil
+		}
+
+		wait := window.Interval
+		if window.Action == quietThrottle {
+			if wait <= 0 {
+				wait = defaultQuietInterval
+			}
+		} else {
+			wait = time.Until(end)
+			if !paused {
+				log.Printf("进入静默时段，暂停到 %s", end.Format("2006-01-02 15:04"))
+				paused = true
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return failure.Wrap(ctx.Err())
+		case <-time.After(wait):
+		}
+		if window.Action == quietThrottle {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/colinmarc/hdfs"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/model"
+	"strings"
+	"time"
+)
+
+// quotaCounter 统计 quota.paths 中的目录和每个库目录的配额使用情况
+var quotaCounter = &counter{
+	name:  "quota",
+	usage: "统计 HDFS 目录的名称配额、空间配额和使用率并保存到 hdfs_quota 表，使用率超过 quota.alert_percent 时告警",
+	model: &model.HdfsQuota{},
+	keys:  []string{"path"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		client, err := newHdfsClient()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		defer client.Close()
+		defer hdfssize.CloseOnDone(ctx, client)()
+
+		records, err := countQuotas(ctx, client, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if config.Quota.AlertPercent > 0 {
+			if alerts := checkQuotas(records); len(alerts) > 0 {
+				sendAlert(fmt.Sprintf("HDFS 配额即将用尽 %s", date.Format("2006-01-02")), alerts)
+			}
+		}
+
+		header := []string{"path", "db", "name_quota", "name_used", "name_percent", "space_quota", "space_used", "space_percent", "desc"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{
+				record.Path, record.Db,
+				record.NameQuota, record.NameUsed, sizePercent(record.NameUsed, record.NameQuota),
+				byteSize(record.SpaceQuota), byteSize(record.SpaceUsed), sizePercent(record.SpaceUsed, record.SpaceQuota),
+				record.Desc,
+			})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countQuotas 配置的路径支持通配符，开启 quota.databases 时通过 Hive 获取每个库的目录
+func countQuotas(ctx context.Context, client *hdfs.Client, date time.Time) ([]*model.HdfsQuota, error) {
+	var records []*model.HdfsQuota
+	for _, pattern := range config.Quota.Paths {
+		paths, err := globHdfs(client, pattern)
+		if err != nil {
+			records = append(records, &model.HdfsQuota{Path: pattern, NameQuota: -1, SpaceQuota: -1, Desc: err.Error(), Date: date})
+			continue
+		}
+		for _, p := range paths {
+			records = append(records, getQuota(client, p, "", date))
+		}
+	}
+
+	if config.Quota.Databases {
+		dbRecords, err := countDbQuotas(ctx, client, date)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, dbRecords...)
+	}
+	return records, nil
+}
+
+func countDbQuotas(ctx context.Context, client *hdfs.Client, date time.Time) ([]*model.HdfsQuota, error) {
+	lists, err := loadDbLists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hiveConnection, err := connectHive()
+	if err != nil {
+		return nil, err
+	}
+	defer hiveConnection.Close()
+	cursor := hiveConnection.Cursor()
+	defer cursor.Close()
+	source, err := newHiveSource(ctx, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	dbs, err := source.Dbs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var records []*model.HdfsQuota
+	for _, db := range dbs {
+		if lists.skip(db) {
+			continue
+		}
+		location, err := source.DbLocation(ctx, db)
+		if err != nil {
+			records = append(records, &model.HdfsQuota{Path: db, Db: db, NameQuota: -1, SpaceQuota: -1, Desc: err.Error(), Date: date})
+			continue
+		}
+		if !strings.Contains(location, hdfsFlag) {
+			continue
+		}
+		p, err := hdfsPath(location)
+		if err != nil {
+			records = append(records, &model.HdfsQuota{Path: location, Db: db, NameQuota: -1, SpaceQuota: -1, Desc: err.Error(), Date: date})
+			continue
+		}
+		records = append(records, getQuota(client, strings.TrimSuffix(p, "/"), db, date))
+	}
+	return records, nil
+}
+
+// getQuota 获取失败时记录在 Desc 中
+func getQuota(client *hdfs.Client, path, db string, date time.Time) *model.HdfsQuota {
+	record := &model.HdfsQuota{Path: path, Db: db, NameQuota: -1, SpaceQuota: -1, Date: date}
+	summary, err := getPathContentSummary(client, path)
+	if err != nil {
+		record.Desc = err.Error()
+		return record
+	}
+	record.NameUsed = int64(summary.FileCount() + summary.DirectoryCount())
+	record.SpaceUsed = summary.SizeAfterReplication()
+	if quota := summary.NameQuota(); quota > 0 {
+		record.NameQuota = int64(quota)
+		record.NamePercent = float64(record.NameUsed) * 100 / float64(quota)
+	}
+	if quota := summary.SpaceQuota(); quota > 0 {
+		record.SpaceQuota = quota
+		record.SpacePercent = float64(record.SpaceUsed) * 100 / float64(quota)
+	}
+	return record
+}
+
+// checkQuotas 返回名称或空间配额使用率超过 quota.alert_percent 的目录
+func checkQuotas(records []*model.HdfsQuota) []string {
+	var alerts []string
+	for _, record := range records {
+		if record.NamePercent >= config.Quota.AlertPercent {
+			alerts = append(alerts, fmt.Sprintf("%s 名称配额使用 %.2f%%（%d/%d）", record.Path, record.NamePercent, record.NameUsed, record.NameQuota))
+		}
+		if record.SpacePercent >= config.Quota.AlertPercent {
+			alerts = append(alerts, fmt.Sprintf("%s 空间配额使用 %.2f%%（%s/%s）", record.Path, record.SpacePercent, formatBytes(record.SpaceUsed), formatBytes(record.SpaceQuota)))
+		}
+	}
+	return alerts
+}
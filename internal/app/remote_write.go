@@ -0,0 +1,95 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/morikuni/failure"
+	"google.golang.org/protobuf/encoding/protowire"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const defaultRemoteWriteTimeout = 30 * time.Second
+
+// remoteWrite 通过 Prometheus remote_write 协议推送指标，可直接写入 VictoriaMetrics、Mimir 等
+func remoteWrite(families []metricFamily, timestamp time.Time) error {
+	body := snappy.Encode(nil, encodeWriteRequest(families, timestamp))
+
+	req, err := http.NewRequest(http.MethodPost, config.RemoteWrite.Url, bytes.NewReader(body))
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if config.RemoteWrite.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.RemoteWrite.BearerToken)
+	} else if config.RemoteWrite.Username != "" {
+		req.SetBasicAuth(config.RemoteWrite.Username, config.RemoteWrite.Password)
+	}
+
+	timeout := config.RemoteWrite.Timeout
+	if timeout <= 0 {
+		timeout = defaultRemoteWriteTimeout
+	}
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return failure.Wrap(fmt.Errorf("remote write failed with %s: %s", resp.Status, bytes.TrimSpace(message)))
+	}
+	return nil
+}
+
+// encodeWriteRequest 按 prometheus/prompb 中 WriteRequest 的定义编码：
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(families []metricFamily, timestamp time.Time) []byte {
+	var request []byte
+	for _, family := range families {
+		for _, sample := range family.samples {
+			labels := append([]metricLabel{{name: "__name__", value: family.name}}, sample.labels...)
+			for name, value := range config.RemoteWrite.Labels {
+				labels = append(labels, metricLabel{name: name, value: value})
+			}
+			sort.Slice(labels, func(i, j int) bool {
+				return labels[i].name < labels[j].name
+			})
+
+			var series []byte
+			for _, label := range labels {
+				var l []byte
+				l = protowire.AppendTag(l, 1, protowire.BytesType)
+				l = protowire.AppendString(l, label.name)
+				l = protowire.AppendTag(l, 2, protowire.BytesType)
+				l = protowire.AppendString(l, label.value)
+
+				series = protowire.AppendTag(series, 1, protowire.BytesType)
+				series = protowire.AppendBytes(series, l)
+			}
+
+			var s []byte
+			s = protowire.AppendTag(s, 1, protowire.Fixed64Type)
+			s = protowire.AppendFixed64(s, math.Float64bits(sample.value))
+			s = protowire.AppendTag(s, 2, protowire.VarintType)
+			s = protowire.AppendVarint(s, uint64(timestamp.UnixNano()/int64(time.Millisecond)))
+			series = protowire.AppendTag(series, 2, protowire.BytesType)
+			series = protowire.AppendBytes(series, s)
+
+			request = protowire.AppendTag(request, 1, protowire.BytesType)
+			request = protowire.AppendBytes(request, series)
+		}
+	}
+	return request
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// measureReplication 遍历表目录，按副本数统计文件的大小写入 extra，并记录有效副本数和是否存在与集群默认值不同的文件。
+// 纠删码的文件副本数为 1，开启纠删码的目录会被视为与默认值不同
+func measureReplication(client *hdfs.Client, entity *model.Hive) error {
+	root, err := hdfsPath(entity.Location)
+	if err != nil {
+		return err
+	}
+	sizes := make(map[string]int64)
+	var size, rawSize int64
+	err = client.Walk(root, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		status, ok := info.Sys().(interface{ GetBlockReplication() uint32 })
+		if !ok {
+			return nil
+		}
+		replication := int64(status.GetBlockReplication())
+		sizes[strconv.FormatInt(replication, 10)] += info.Size()
+		size += info.Size()
+		rawSize += info.Size() * replication
+		return nil
+	})
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	if size == 0 {
+		return nil
+	}
+
+	deviates := false
+	for replication := range sizes {
+		if replication != strconv.Itoa(config.Replication.Default) {
+			deviates = true
+		}
+	}
+	entity.SetExtra(model.ExtraReplication, math.Round(float64(rawSize)/float64(size)*100)/100)
+	entity.SetExtra(model.ExtraReplicationSizes, sizes)
+	entity.SetExtra(model.ExtraReplicationDeviates, deviates)
+	return nil
+}
+
+// replicationDeviation 存在副本数与集群默认值不同的文件的表
+type replicationDeviation struct {
+	record      *model.Hive
+	replication float64
+	sizes       map[string]int64
+	// extra 与按默认副本数存储相比多占用的空间，为负数时说明副本数不足，数据有丢失的风险
+	extra int64
+}
+
+// findReplicationDeviations 按多占用或少占用的空间的绝对值降序排列
+func findReplicationDeviations(records []*model.Hive) []*replicationDeviation {
+	var deviations []*replicationDeviation
+	for _, record := range records {
+		if deviates, _ := record.Extra[model.ExtraReplicationDeviates].(bool); !deviates {
+			continue
+		}
+		deviation := &replicationDeviation{record: record, sizes: replicationSizes(record.Extra)}
+		deviation.replication, _ = record.Extra[model.ExtraReplication].(float64)
+		for replication, size := range deviation.sizes {
+			n, _ := strconv.ParseInt(replication, 10, 64)
+			deviation.extra += size * (n - int64(config.Replication.Default))
+		}
+		deviations = append(deviations, deviation)
+	}
+	sort.SliceStable(deviations, func(i, j int) bool {
+		return abs(deviations[i].extra) > abs(deviations[j].extra)
+	})
+	return deviations
+}
+
+// replicationSizes 读取 ExtraReplicationSizes，从数据库或 JSON 读出的值为 map[string]interface{}
+func replicationSizes(extra model.Extra) map[string]int64 {
+	switch v := extra[model.ExtraReplicationSizes].(type) {
+	case map[string]int64:
+		return v
+	case map[string]interface{}:
+		sizes := make(map[string]int64, len(v))
+		for replication := range v {
+			sizes[replication], _ = model.Extra(v).Int64(replication)
+		}
+		return sizes
+	}
+	return nil
+}
+
+// formatReplicationSizes 按副本数升序输出，例如 1: 10.00 GiB, 3: 2.00 TiB
+func formatReplicationSizes(sizes map[string]int64) string {
+	replications := make([]string, 0, len(sizes))
+	for replication := range sizes {
+		replications = append(replications, replication)
+	}
+	sort.Slice(replications, func(i, j int) bool {
+		a, _ := strconv.Atoi(replications[i])
+		b, _ := strconv.Atoi(replications[j])
+		return a < b
+	})
+	parts := make([]string, 0, len(replications))
+	for _, replication := range replications {
+		parts = append(parts, replication+": "+formatBytes(sizes[replication]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,322 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	reportHTML     = "html"
+	reportMarkdown = "markdown"
+
+	// smallFileSize 平均文件大小低于该值的表视为小文件过多，取 HDFS 默认块大小的一半
+	smallFileSize = 64 << 20
+)
+
+//go:embed report.html.tmpl
+var reportHTMLTemplate string
+
+//go:embed report.md.tmpl
+var reportMarkdownTemplate string
+
+// reportData 报表模板的数据
+type reportData struct {
+	Date        string
+	CompareDate string
+	Tables      int
+	Size        int64
+	// MaterializedViews MaterializedViewSize 物化视图的数量和大小，已包含在 Tables 和 Size 中
+	MaterializedViews    int
+	MaterializedViewSize int64
+	Failures             []*model.Hive
+	Top                  []*model.Hive
+	Growers              []reportGrower
+	SmallFiles           []reportSmallFile
+	Skewed               []reportSkew
+	Backlogs             []reportBacklog
+	LargeFiles           []reportLargeFile
+	Replications         []reportReplication
+}
+
+type reportGrower struct {
+	Db      string
+	Table   string
+	From    int64
+	To      int64
+	Delta   int64
+	Percent string
+	Status  string
+}
+
+type reportSmallFile struct {
+	Db       string
+	Table    string
+	Files    int64
+	Size     int64
+	AvgSize  int64
+	Location string
+}
+
+type reportSkew struct {
+	Db            string
+	Table         string
+	Partitions    int64
+	Partition     string
+	PartitionSize int64
+	Size          int64
+	Percent       string
+}
+
+type reportLargeFile struct {
+	Db         string
+	Table      string
+	Path       string
+	Size       int64
+	Splittable bool
+}
+
+type reportReplication struct {
+	Db          string
+	Table       string
+	Replication float64
+	Sizes       string
+	Extra       int64
+}
+
+type reportBacklog struct {
+	Db              string
+	Table           string
+	MaxDeltas       int64
+	DeltaDirs       int64
+	BaseSize        int64
+	DeltaSize       int64
+	DeleteDeltaSize int64
+}
+
+// runReport 生成某一天的报表，内容为最大的表、增长最快的表、获取失败的表、小文件过多的表、分区倾斜的表、compaction 积压的事务表、过大的文件和副本数异常的表
+func runReport(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	dateFlag := flags.String("date", "", "日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	format := flags.String("format", reportHTML, "报表格式，可选 html、markdown")
+	compare := flags.Int("compare", 7, "计算增长时与多少天前比较")
+	limit := flags.Int("limit", 20, "每个部分输出的数量")
+	file := flags.String("output-file", "", "输出文件，默认为标准输出")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	date, err := resolveDate(ctx, store, *dateFlag)
+	if err != nil {
+		log.Fatal("解析日期失败: " + err.Error())
+	}
+	records, err := loadRecords(ctx, store, date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	compareDate := date.AddDate(0, 0, -*compare)
+	previous, err := loadRecords(ctx, store, compareDate)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	data := buildReport(records, previous, *limit)
+	data.Date = date.Format("2006-01-02")
+	if len(previous) > 0 {
+		data.CompareDate = compareDate.Format("2006-01-02")
+	}
+
+	content, err := renderReport(*format, data)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	if *file == "" {
+		os.Stdout.Write(content)
+		return
+	}
+	err = ioutil.WriteFile(*file, content, 0644)
+	if err != nil {
+		log.Fatal("写入报表失败: " + err.Error())
+	}
+}
+
+func buildReport(records, previous []*model.Hive, limit int) *reportData {
+	data := &reportData{Tables: len(records)}
+	for _, record := range records {
+		if record.Size > 0 {
+			data.Size += record.Size
+		}
+		if _, ok := record.Extra[model.ExtraMaterializedView]; ok {
+			data.MaterializedViews++
+			if record.Size > 0 {
+				data.MaterializedViewSize += record.Size
+			}
+		}
+		if record.Desc != "" {
+			data.Failures = append(data.Failures, record)
+		}
+		files, ok := record.Extra.Int64(model.ExtraFileCount)
+		if ok && files > 0 && record.Size >= 0 && record.Size/files < smallFileSize {
+			data.SmallFiles = append(data.SmallFiles, reportSmallFile{
+				Db:       record.Db,
+				Table:    record.Table,
+				Files:    files,
+				Size:     record.Size,
+				AvgSize:  record.Size / files,
+				Location: record.Location,
+			})
+		}
+	}
+
+	top := append([]*model.Hive(nil), records...)
+	sort.SliceStable(top, func(i, j int) bool {
+		return top[i].Size > top[j].Size
+	})
+	data.Top = limitHive(top, limit)
+
+	if len(previous) > 0 {
+		for _, d := range diffTables(previous, records) {
+			if d.delta() <= 0 || len(data.Growers) >= limit {
+				break
+			}
+			data.Growers = append(data.Growers, reportGrower{
+				Db:      d.db,
+				Table:   d.table,
+				From:    d.from,
+				To:      d.to,
+				Delta:   d.delta(),
+				Percent: formatValue(d.percent(), true),
+				Status:  d.status,
+			})
+		}
+	}
+
+	sort.SliceStable(data.SmallFiles, func(i, j int) bool {
+		return data.SmallFiles[i].Files > data.SmallFiles[j].Files
+	})
+	if len(data.SmallFiles) > limit {
+		data.SmallFiles = data.SmallFiles[:limit]
+	}
+	data.Failures = limitHive(data.Failures, limit)
+
+	for _, skew := range findSkewedPartitions(records) {
+		if limit > 0 && len(data.Skewed) >= limit {
+			break
+		}
+		value := skew.percent
+		data.Skewed = append(data.Skewed, reportSkew{
+			Db:            skew.record.Db,
+			Table:         skew.record.Table,
+			Partitions:    skew.partitions,
+			Partition:     skew.partition,
+			PartitionSize: skew.size,
+			Size:          skew.record.Size,
+			Percent:       formatValue(percent(&value), true),
+		})
+	}
+
+	for _, backlog := range findCompactionBacklog(records) {
+		if limit > 0 && len(data.Backlogs) >= limit {
+			break
+		}
+		data.Backlogs = append(data.Backlogs, reportBacklog{
+			Db:              backlog.record.Db,
+			Table:           backlog.record.Table,
+			MaxDeltas:       backlog.maxDeltas,
+			DeltaDirs:       backlog.deltaDirs,
+			BaseSize:        backlog.baseSize,
+			DeltaSize:       backlog.deltaSize,
+			DeleteDeltaSize: backlog.deleteDelta,
+		})
+	}
+
+	for _, large := range findLargeFiles(records) {
+		if limit > 0 && len(data.LargeFiles) >= limit {
+			break
+		}
+		data.LargeFiles = append(data.LargeFiles, reportLargeFile{
+			Db:         large.record.Db,
+			Table:      large.record.Table,
+			Path:       large.file.Path,
+			Size:       large.file.Size,
+			Splittable: large.file.Splittable,
+		})
+	}
+
+	for _, deviation := range findReplicationDeviations(records) {
+		if limit > 0 && len(data.Replications) >= limit {
+			break
+		}
+		data.Replications = append(data.Replications, reportReplication{
+			Db:          deviation.record.Db,
+			Table:       deviation.record.Table,
+			Replication: deviation.replication,
+			Sizes:       formatReplicationSizes(deviation.sizes),
+			Extra:       deviation.extra,
+		})
+	}
+	return data
+}
+
+func limitHive(records []*model.Hive, limit int) []*model.Hive {
+	if limit > 0 && len(records) > limit {
+		return records[:limit]
+	}
+	return records
+}
+
+// reportFuncs 模板中使用的函数
+var reportFuncs = map[string]interface{}{
+	"bytes": func(size int64) string {
+		return formatBytes(size)
+	},
+	// inc 序号从 1 开始
+	"inc": func(i int) int {
+		return i + 1
+	},
+	// cell 转义 Markdown 表格中的竖线和换行
+	"cell": func(s string) string {
+		return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+	},
+	"now": func() string {
+		return time.Now().Format("2006-01-02 15:04:05")
+	},
+}
+
+func renderReport(format string, data *reportData) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	switch format {
+	case reportHTML:
+		err = htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(reportHTMLTemplate)).Execute(&buf, data)
+	case reportMarkdown, "md":
+		err = texttemplate.Must(texttemplate.New("report").Funcs(reportFuncs).Parse(reportMarkdownTemplate)).Execute(&buf, data)
+	default:
+		err = fmt.Errorf("unknown report format: %s", format)
+	}
+	return buf.Bytes(), failure.Wrap(err)
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultRescanServer = "http://127.0.0.1:8080"
+
+// rescanRequest POST /rescans 的请求体
+type rescanRequest struct {
+	Tables []string `json:"tables"`
+}
+
+// enqueueRescan 将表加入待重新抓取的集合，已经在集合中的表不会重复抓取
+func (s *server) enqueueRescan(tables []string) ([]string, error) {
+	for _, table := range tables {
+		if !strings.Contains(table, ".") {
+			return nil, fmt.Errorf("invalid table %q, need db.table", table)
+		}
+	}
+
+	s.mu.Lock()
+	for _, table := range tables {
+		s.rescans[table] = true
+	}
+	pending := s.pendingRescans()
+	s.mu.Unlock()
+
+	select {
+	case s.rescan <- struct{}{}:
+	default:
+	}
+	return pending, nil
+}
+
+// pendingRescans 需要持有 s.mu
+func (s *server) pendingRescans() []string {
+	tables := make([]string, 0, len(s.rescans))
+	for table := range s.rescans {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// triggerRescan 将待重新抓取的表合并为一次只抓取这些表的运行，结果写入当天的数据
+func (s *server) triggerRescan() {
+	s.mu.Lock()
+	tables := s.pendingRescans()
+	s.rescans = make(map[string]bool)
+	s.mu.Unlock()
+	if len(tables) == 0 {
+		return
+	}
+
+	r, err := s.trigger(&runFilter{Tables: tables})
+	if err != nil {
+		log.Printf("重新抓取 %d 张表失败: %+v", len(tables), err)
+		return
+	}
+	log.Printf("运行 %s 重新抓取 %d 张表", r.ID, len(tables))
+}
+
+// handleRescans POST 将表加入待重新抓取的集合，需要 serve.bearer_token，请求体为 {"tables": ["db.table"]}，在没有其他运行时执行；GET 返回还未执行的表
+func (s *server) handleRescans(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		if !authorize(w, req) {
+			return
+		}
+		var body rescanRequest
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(body.Tables) == 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("tables is required"))
+			return
+		}
+		pending, err := s.enqueueRescan(body.Tables)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, rescanRequest{Tables: pending})
+	case http.MethodGet:
+		s.mu.Lock()
+		pending := s.pendingRescans()
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, rescanRequest{Tables: pending})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+	}
+}
+
+// runRescan 通知 serve 模式的常驻进程重新抓取指定的表，例如合并小文件之后
+func runRescan(args []string) {
+	flags := flag.NewFlagSet("rescan", flag.ExitOnError)
+	server := flags.String("server", defaultRescanServer, "serve 模式的 HTTP 地址")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: counter rescan [--server URL] db.table...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	body, _ := json.Marshal(rescanRequest{Tables: flags.Args()})
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(strings.TrimSuffix(*server, "/")+"/rescans", "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Fatal("提交重新抓取失败: " + err.Error())
+	}
+	defer resp.Body.Close()
+	message, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusAccepted {
+		log.Fatalf("提交重新抓取失败: %s %s", resp.Status, bytes.TrimSpace(message))
+	}
+
+	var pending rescanRequest
+	json.Unmarshal(message, &pending)
+	fmt.Printf("已提交，等待重新抓取的表: %s\n", strings.Join(pending.Tables, ", "))
+}
@@ -0,0 +1,246 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"gorm.io/gorm/clause"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetentionMinKeep  = 30
+	defaultRetentionLookback = 30
+
+	// retentionGrowthWindow 计算增长速度的天数
+	retentionGrowthWindow = 30
+)
+
+var (
+	defaultRetentionCandidates = []int{30, 90, 180, 365}
+
+	// retentionDateLayouts 分区值中支持的日期格式
+	retentionDateLayouts = []string{"2006-01-02", "20060102", "2006-01", "200601"}
+)
+
+// retentionUpsert 同一天重复计算时覆盖
+var retentionUpsert = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "db"}, {Name: "table"}, {Name: "date"}},
+	DoUpdates: clause.AssignmentColumns([]string{"partitions", "oldest_days", "needed_days", "keep_days", "size", "reclaimable", "rate"}),
+}
+
+// retentionPartition 按日期分区的一级分区
+type retentionPartition struct {
+	days int
+	size int64
+	// accessed 在 lookback 天内被读取过
+	accessed bool
+}
+
+// runRetention 分析最大的几张按日期分区的表，给出保留天数的建议和可以释放的空间，并写入 hive_retention 表
+func runRetention(args []string) {
+	flags := flag.NewFlagSet("retention", flag.ExitOnError)
+	dateFlag := flags.String("date", "", "日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	tables := flags.Int("tables", 100, "分析的表的数量，按大小降序选取")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	hdfsClient, err := newHdfsClient()
+	if err != nil {
+		log.Fatal("创建 hdfs 客户端失败: " + err.Error())
+	}
+	defer hdfsClient.Close()
+
+	date, err := resolveDate(ctx, store, *dateFlag)
+	if err != nil {
+		log.Fatal("解析日期失败: " + err.Error())
+	}
+	records, err := loadRecords(ctx, store, date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	history, err := loadRecordsBetween(ctx, store, date.AddDate(0, 0, -retentionGrowthWindow), date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+	var retentions []*model.HiveRetention
+	for _, record := range records {
+		if len(retentions) >= *tables || record.Size <= 0 {
+			break
+		}
+		if !strings.Contains(record.Location, hdfsFlag) {
+			continue
+		}
+		partitions, err := listDatePartitions(hdfsClient, record.Location, date)
+		if err != nil {
+			log.Printf("获取 %s.%s 的分区失败: %+v", record.Db, record.Table, err)
+			continue
+		}
+		if len(partitions) == 0 {
+			continue
+		}
+		retention := recommendRetention(partitions)
+		retention.Db = record.Db
+		retention.Table = record.Table
+		retention.Rate = tableGrowthRate(history, record, date)
+		retention.Date = date
+		retentions = append(retentions, retention)
+	}
+
+	sort.SliceStable(retentions, func(i, j int) bool {
+		return retentions[i].Reclaimable > retentions[j].Reclaimable
+	})
+	if len(retentions) > 0 {
+		err = store.Migrate(ctx, &model.HiveRetention{})
+		if err == nil {
+			err = failure.Wrap(store.DB().WithContext(ctx).Clauses(retentionUpsert).CreateInBatches(retentions, 1000).Error)
+		}
+		if err != nil {
+			log.Fatal(fmt.Sprintf("%+v", err))
+		}
+	}
+
+	header := []string{"rank", "db", "table", "partitions", "oldest_days", "needed_days", "keep_days", "size", "reclaimable", "growth_per_day", "suggestion"}
+	var rows [][]interface{}
+	for i, r := range retentions {
+		suggestion := ""
+		if r.Reclaimable > 0 {
+			suggestion = fmt.Sprintf("保留 %d 天可释放 %s", r.KeepDays, formatBytes(r.Reclaimable))
+		}
+		rows = append(rows, []interface{}{i + 1, r.Db, r.Table, r.Partitions, r.OldestDays, r.NeededDays, r.KeepDays, byteSize(r.Size), byteSize(r.Reclaimable), byteSize(int64(r.Rate)), suggestion})
+	}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+// listDatePartitions 列出表目录下能从分区值解析出日期的一级分区，例如 dt=2006-01-02，开启 hdfs.access_time 时同时获取访问时间
+func listDatePartitions(client *hdfs.Client, location string, date time.Time) ([]*retentionPartition, error) {
+	dirs, err := listPartitionDirs(client, location)
+	if err != nil {
+		return nil, err
+	}
+
+	lookback := config.Retention.Lookback
+	if lookback <= 0 {
+		lookback = defaultRetentionLookback
+	}
+	var partitions []*retentionPartition
+	for _, dir := range dirs {
+		partitionDate, ok := parsePartitionDate(dir.value)
+		if !ok {
+			continue
+		}
+		summary, err := getHdfsContentSummary(client, dir.location)
+		if err != nil {
+			return nil, err
+		}
+		partition := &retentionPartition{
+			days: int(date.Sub(partitionDate).Hours() / 24),
+			size: summary.Size(),
+		}
+		if config.Hdfs.AccessTime {
+			accessTime, err := getLatestAccessTime(client, dir.location)
+			if err != nil {
+				return nil, err
+			}
+			partition.accessed = !accessTime.Before(date.AddDate(0, 0, -lookback))
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions, nil
+}
+
+func parsePartitionDate(value string) (time.Time, bool) {
+	for _, layout := range retentionDateLayouts {
+		t, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// recommendRetention 需要保留的天数为覆盖最近被读取的最早分区的天数，没有访问时间时为 min_keep，
+// 建议保留的天数为不小于需要保留天数的最小候选值，没有满足的候选值时建议全部保留
+func recommendRetention(partitions []*retentionPartition) *model.HiveRetention {
+	retention := &model.HiveRetention{Partitions: len(partitions)}
+	tracked := config.Hdfs.AccessTime
+	for _, p := range partitions {
+		retention.Size += p.size
+		if p.days > retention.OldestDays {
+			retention.OldestDays = p.days
+		}
+		if tracked && p.accessed && p.days+1 > retention.NeededDays {
+			retention.NeededDays = p.days + 1
+		}
+	}
+	if !tracked {
+		retention.NeededDays = config.Retention.MinKeep
+		if retention.NeededDays <= 0 {
+			retention.NeededDays = defaultRetentionMinKeep
+		}
+	}
+
+	candidates := append([]int(nil), config.Retention.Candidates...)
+	if len(candidates) == 0 {
+		candidates = append(candidates, defaultRetentionCandidates...)
+	}
+	sort.Ints(candidates)
+	retention.KeepDays = retention.OldestDays
+	for _, candidate := range candidates {
+		if candidate >= retention.NeededDays {
+			if candidate < retention.KeepDays {
+				retention.KeepDays = candidate
+			}
+			break
+		}
+	}
+	for _, p := range partitions {
+		if p.days >= retention.KeepDays && retention.KeepDays < retention.OldestDays {
+			retention.Reclaimable += p.size
+		}
+	}
+	return retention
+}
+
+// tableGrowthRate 表在 history 中最早一天到 date 的平均每天增长
+func tableGrowthRate(history []*model.Hive, record *model.Hive, date time.Time) float64 {
+	var base *model.Hive
+	for _, h := range history {
+		if h.Db != record.Db || h.Table != record.Table || h.Size < 0 || !h.Date.Before(date) {
+			continue
+		}
+		if base == nil || h.Date.Before(base.Date) {
+			base = h
+		}
+	}
+	if base == nil {
+		return 0
+	}
+	return float64(record.Size-base.Size) / float64(epochDays(date)-epochDays(base.Date))
+}
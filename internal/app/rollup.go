@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/rea1shane/counter/pkg/sink"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+// rollupUpsert 重复汇总同一个周期时覆盖
+var rollupUpsert = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "granularity"}, {Name: "db"}, {Name: "table"}, {Name: "period"}},
+	DoUpdates: clause.AssignmentColumns([]string{"location", "days", "avg_size", "max_size", "last_size"}),
+}
+
+// runRollup 将早于 N 天的每日记录按周或按月汇总到 hive_rollup 表，并删除已汇总的每日记录
+func runRollup(args []string) {
+	flags := flag.NewFlagSet("rollup", flag.ExitOnError)
+	olderThan := flags.Int("older-than", 90, "汇总早于多少天的每日记录")
+	granularity := flags.String("granularity", model.GranularityMonth, "汇总粒度，可选 week、month")
+	dryRun := flags.Bool("dry-run", false, "只输出将要汇总的周期，不写入也不删除")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+	if *granularity != model.GranularityWeek && *granularity != model.GranularityMonth {
+		log.Fatal(fmt.Sprintf("不支持的汇总粒度: %s", *granularity))
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	err = store.Migrate(ctx, &model.HiveRollup{})
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	cutoff := currentDate().AddDate(0, 0, -*olderThan)
+	err = rollup(ctx, store, *granularity, cutoff, *dryRun)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+// rollup 从最早的记录开始逐个周期汇总，只汇总结束日期早于 cutoff 的完整周期，避免同一周期被拆成两次汇总
+func rollup(ctx context.Context, store *sink.Gorm, granularity string, cutoff time.Time, dryRun bool) error {
+	var earliest []model.Hive
+	err := store.DB().WithContext(ctx).Order("date").Limit(1).Find(&earliest).Error
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	if len(earliest) == 0 {
+		return nil
+	}
+
+	for start := periodStart(earliest[0].Date, granularity); ; {
+		next := nextPeriod(start, granularity)
+		end := next.AddDate(0, 0, -1)
+		if !end.Before(cutoff) {
+			return nil
+		}
+		records, err := loadRecordsBetween(ctx, store, start, end)
+		if err != nil {
+			return err
+		}
+		if len(records) > 0 {
+			rollups := rollupRecords(records, granularity, start)
+			log.Printf("汇总 %s 至 %s 的 %d 条记录为 %d 条", start.Format("2006-01-02"), end.Format("2006-01-02"), len(records), len(rollups))
+			if !dryRun {
+				err = store.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+					err := tx.Clauses(rollupUpsert).CreateInBatches(rollups, 1000).Error
+					if err != nil {
+						return err
+					}
+					return tx.Where("date >= ? AND date <= ?", start, end).Delete(&model.Hive{}).Error
+				})
+				if err != nil {
+					return failure.Wrap(err)
+				}
+			}
+		}
+		start = next
+	}
+}
+
+// rollupRecords 按表汇总一个周期内的记录，获取失败的记录不参与计算
+func rollupRecords(records []*model.Hive, granularity string, period time.Time) []*model.HiveRollup {
+	var (
+		rollups     []*model.HiveRollup
+		index       = make(map[string]*model.HiveRollup)
+		totals      = make(map[string]int64)
+		last        = make(map[string]time.Time)
+		lastSuccess = make(map[string]time.Time)
+	)
+	for _, record := range records {
+		key := record.Db + "." + record.Table
+		r, ok := index[key]
+		if !ok {
+			r = &model.HiveRollup{Granularity: granularity, Db: record.Db, Table: record.Table, AvgSize: -1, MaxSize: -1, LastSize: -1, Period: period}
+			index[key] = r
+			rollups = append(rollups, r)
+		}
+		if !record.Date.Before(last[key]) {
+			last[key] = record.Date
+			r.Location = record.Location
+		}
+		if record.Size < 0 {
+			continue
+		}
+		r.Days++
+		totals[key] += record.Size
+		if record.Size > r.MaxSize {
+			r.MaxSize = record.Size
+		}
+		if !record.Date.Before(lastSuccess[key]) {
+			lastSuccess[key] = record.Date
+			r.LastSize = record.Size
+		}
+	}
+	for key, r := range index {
+		if r.Days > 0 {
+			r.AvgSize = totals[key] / int64(r.Days)
+		}
+	}
+	return rollups
+}
+
+// periodStart 返回 date 所在周期的第一天，周从周一开始
+func periodStart(date time.Time, granularity string) time.Time {
+	year, month, day := date.Date()
+	if granularity == model.GranularityMonth {
+		return time.Date(year, month, 1, 0, 0, 0, 0, date.Location())
+	}
+	offset := (int(date.Weekday()) + 6) % 7
+	return time.Date(year, month, day-offset, 0, 0, 0, 0, date.Location())
+}
+
+func nextPeriod(start time.Time, granularity string) time.Time {
+	if granularity == model.GranularityMonth {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 7)
+}
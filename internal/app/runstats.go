@@ -0,0 +1,270 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 运行中记录耗时的阶段
+const (
+	stageHiveQuery = "hive_query"
+	stageHdfs      = "hdfs"
+	stageSinkWrite = "sink_write"
+)
+
+var stages = []string{stageHiveQuery, stageHdfs, stageSinkWrite}
+
+// runStats 一次运行中各阶段的耗时和按类型统计的失败数量
+type runStats struct {
+	id    string
+	start time.Time
+
+	mu       sync.Mutex
+	entities []*model.Hive
+	calls    map[string]int
+	total    map[string]time.Duration
+	max      map[string]time.Duration
+	failures map[string]int
+}
+
+func newRunStats(start time.Time) *runStats {
+	return &runStats{
+		id:       newRunID(),
+		start:    start,
+		calls:    make(map[string]int),
+		total:    make(map[string]time.Duration),
+		max:      make(map[string]time.Duration),
+		failures: make(map[string]int),
+	}
+}
+
+// observe 记录一次从 since 开始的调用
+func (s *runStats) observe(stage string, since time.Time) {
+	elapsed := time.Since(since)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[stage]++
+	s.total[stage] += elapsed
+	if elapsed > s.max[stage] {
+		s.max[stage] = elapsed
+	}
+}
+
+// fail 记录一张表获取失败
+func (s *runStats) fail(stage string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[failureClass(stage, err)]++
+}
+
+// failureClass 失败类型，由阶段和原因组成，例如 hdfs_not_found
+func failureClass(stage string, err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return stage + "_not_found"
+	case errors.Is(err, os.ErrPermission):
+		return stage + "_permission"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return stage + "_timeout"
+	}
+	return stage + "_error"
+}
+
+// newRunID 运行 ID，随机生成的 UUID v4，例如 0f8fad5b-d9cb-469f-a165-70867728950e
+func newRunID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// setEntities 抓取到的表，用于统计数量和总大小
+func (s *runStats) setEntities(entities []*model.Hive) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entities = entities
+}
+
+// families 本次运行的指标
+func (s *runStats) families(end time.Time) []metricFamily {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	duration := end.Sub(s.start).Seconds()
+	var failures int
+	for _, n := range s.failures {
+		failures += n
+	}
+
+	calls := metricFamily{name: "counter_run_stage_calls", help: "本次运行各阶段的调用次数"}
+	seconds := metricFamily{name: "counter_run_stage_seconds", help: "本次运行各阶段的总耗时"}
+	maxSeconds := metricFamily{name: "counter_run_stage_max_seconds", help: "本次运行各阶段单次调用的最大耗时"}
+	for _, stage := range stages {
+		labels := []metricLabel{{name: "stage", value: stage}}
+		calls.samples = append(calls.samples, metricSample{labels: labels, value: float64(s.calls[stage])})
+		seconds.samples = append(seconds.samples, metricSample{labels: labels, value: s.total[stage].Seconds()})
+		maxSeconds.samples = append(maxSeconds.samples, metricSample{labels: labels, value: s.max[stage].Seconds()})
+	}
+
+	classes := make([]string, 0, len(s.failures))
+	for class := range s.failures {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	failureClasses := metricFamily{name: "counter_run_failures_by_class", help: "本次运行按类型统计的失败数量"}
+	for _, class := range classes {
+		failureClasses.samples = append(failureClasses.samples, metricSample{labels: []metricLabel{{name: "class", value: class}}, value: float64(s.failures[class])})
+	}
+
+	return []metricFamily{
+		{name: "counter_run_duration_seconds", help: "本次运行耗时", samples: []metricSample{{value: duration}}},
+		{name: "counter_run_tables", help: "本次运行抓取的表的数量", samples: []metricSample{{value: float64(len(s.entities))}}},
+		{name: "counter_run_failures", help: "本次运行获取大小失败的表的数量", samples: []metricSample{{value: float64(failures)}}},
+		{name: "counter_run_tables_per_second", help: "本次运行平均每秒抓取的表的数量", samples: []metricSample{{value: tablesPerSecond(len(s.entities), duration)}}},
+		calls,
+		seconds,
+		maxSeconds,
+		failureClasses,
+	}
+}
+
+// audit 本次运行的审计记录
+func (s *runStats) audit(end time.Time, date time.Time, filter *runFilter, shard *shard, err error) *model.HiveRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := &model.HiveRun{
+		RunID:               s.id,
+		Start:               s.start,
+		End:                 end,
+		Status:              runSucceeded,
+		Tables:              len(s.entities),
+		DurationSeconds:     end.Sub(s.start).Seconds(),
+		HiveQueries:         s.calls[stageHiveQuery],
+		HiveQuerySeconds:    s.total[stageHiveQuery].Seconds(),
+		HiveQueryMaxSeconds: s.max[stageHiveQuery].Seconds(),
+		HdfsCalls:           s.calls[stageHdfs],
+		HdfsSeconds:         s.total[stageHdfs].Seconds(),
+		HdfsMaxSeconds:      s.max[stageHdfs].Seconds(),
+		SinkWriteSeconds:    s.total[stageSinkWrite].Seconds(),
+		Date:                date,
+		CounterVersion:      counterVersion(),
+		BuildDate:           buildDate,
+		GoVersion:           runtime.Version(),
+	}
+	record.TablesPerSecond = tablesPerSecond(record.Tables, record.DurationSeconds)
+	for _, entity := range s.entities {
+		if entity.Size > 0 {
+			record.Size += entity.Size
+		}
+	}
+	if err != nil {
+		record.Status = runFailed
+		record.Error = err.Error()
+	}
+	if !filter.empty() {
+		b, _ := json.Marshal(filter)
+		record.Filter = string(b)
+	}
+	if shard != nil {
+		record.Shard = fmt.Sprintf("%d/%d", shard.index, shard.count)
+	}
+	for _, n := range s.failures {
+		record.Failures += n
+	}
+	if len(s.failures) > 0 {
+		b, _ := json.Marshal(s.failures)
+		record.FailureClasses = string(b)
+	}
+	return record
+}
+
+func tablesPerSecond(tables int, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(tables) / seconds
+}
+
+// finish 更新 /metrics 的指标，开启 audit 时保存审计记录，并调用配置的回调
+func (s *runStats) finish(ctx context.Context, date time.Time, filter *runFilter, shard *shard, err error) {
+	end := time.Now()
+	selfMetrics.record(s.families(end), end, err)
+	record := s.audit(end, date, filter, shard, err)
+
+	if config.Audit.Enabled {
+		store, saveErr := openStore(ctx)
+		if saveErr == nil {
+			saveErr = store.Migrate(ctx, &model.HiveRun{})
+			if saveErr == nil {
+				saveErr = failure.Wrap(store.DB().WithContext(ctx).Create(record).Error)
+			}
+			store.Close()
+		}
+		if saveErr != nil {
+			log.Println("保存运行记录失败: " + saveErr.Error())
+		}
+	}
+
+	if len(config.Callback.Webhooks) > 0 {
+		callback(record)
+	}
+}
+
+// selfMetrics 进程内最近一次运行的指标，serve 模式下通过 /metrics 暴露
+var selfMetrics = &runMetrics{runs: make(map[string]int)}
+
+type runMetrics struct {
+	mu          sync.Mutex
+	last        []metricFamily
+	lastSuccess time.Time
+	runs        map[string]int
+}
+
+func (m *runMetrics) record(families []metricFamily, end time.Time, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = families
+	if err != nil {
+		m.runs[runFailed]++
+		return
+	}
+	m.runs[runSucceeded]++
+	m.lastSuccess = end
+}
+
+func (m *runMetrics) families() []metricFamily {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	runs := metricFamily{name: "counter_runs", help: "进程启动以来完成的运行次数"}
+	for _, status := range []string{runSucceeded, runFailed} {
+		runs.samples = append(runs.samples, metricSample{labels: []metricLabel{{name: "status", value: status}}, value: float64(m.runs[status])})
+	}
+	families := append([]metricFamily{runs}, m.last...)
+	if !m.lastSuccess.IsZero() {
+		families = append(families, metricFamily{name: "counter_run_last_success_timestamp_seconds", help: "最近一次运行完成的时间", samples: []metricSample{{value: float64(m.lastSuccess.Unix())}}})
+	}
+	return families
+}
+
+// handleMetrics Prometheus 文本格式的运行指标
+func (s *server) handleMetrics(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(encodeMetricsText(selfMetrics.families()))
+}
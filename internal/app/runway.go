@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/rea1shane/counter/pkg/sink"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+const defaultRunwayPercent = 85
+
+// runway 按已使用空间的增长速度，集群使用率达到 runway.percent 还剩多少天
+type runway struct {
+	record *model.HdfsCapacity
+	// growth 拟合的平均每天增长
+	growth float64
+	target int64
+	// days 已经达到时为 0，不增长时为 -1
+	days int
+}
+
+// runwayDate 预计达到阈值的日期，不增长时为空
+func (r *runway) runwayDate() string {
+	if r.days < 0 {
+		return ""
+	}
+	return r.record.Date.AddDate(0, 0, r.days).Format("2006-01-02")
+}
+
+// runRunway 根据 hdfs_capacity 的历史记录输出集群容量的剩余天数，低于 runway.alert_days 时告警
+func runRunway(args []string) {
+	flags := flag.NewFlagSet("runway", flag.ExitOnError)
+	dateFlag := flags.String("date", "", "日期，格式为 2006-01-02，默认为最近一次记录容量的日期")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	var date time.Time
+	if *dateFlag != "" {
+		date, err = time.ParseInLocation("2006-01-02", *dateFlag, time.Local)
+	} else {
+		date, err = latestCapacityDate(ctx, store)
+	}
+	if err != nil {
+		log.Fatal("解析日期失败: " + err.Error())
+	}
+	records, err := loadCapacityHistory(ctx, store, date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	r := computeRunway(records)
+	if r == nil {
+		log.Fatal("hdfs_capacity 中当天没有记录或者历史记录少于两天，先通过 capacity 子命令记录集群容量")
+	}
+	alertRunway(r)
+
+	header := []string{"date", "namenode", "capacity", "used", "used_percent", "growth_per_day", "threshold", "target_date", "runway_days"}
+	rows := [][]interface{}{{
+		r.record.Date.Format("2006-01-02"), r.record.Namenode, byteSize(r.record.Capacity), byteSize(r.record.Used),
+		sizePercent(r.record.Used, r.record.Capacity), byteSize(int64(r.growth)), byteSize(r.target), r.runwayDate(), r.days,
+	}}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+// computeRunway 最后一条记录为当天，对已使用空间做线性拟合，当天没有数据或者历史数据少于两天时返回 nil
+func computeRunway(records []*model.HdfsCapacity) *runway {
+	if len(records) == 0 {
+		return nil
+	}
+	latest := records[len(records)-1]
+	today := epochDays(latest.Date)
+	points := make(map[int32]int64, len(records))
+	for _, record := range records {
+		points[epochDays(record.Date)] = record.Used
+	}
+	f := fitForecast(points, today)
+	if f == nil {
+		return nil
+	}
+
+	percent := config.Runway.Percent
+	if percent <= 0 {
+		percent = defaultRunwayPercent
+	}
+	r := &runway{
+		record: latest,
+		growth: f.slope,
+		target: int64(float64(latest.Capacity) * percent / 100),
+	}
+	switch {
+	case latest.Used >= r.target:
+		r.days = 0
+	case f.slope <= 0:
+		r.days = -1
+	default:
+		r.days = int(math.Ceil(float64(r.target-latest.Used) / f.slope))
+	}
+	return r
+}
+
+// alertRunway 剩余天数低于 runway.alert_days 时告警
+func alertRunway(r *runway) {
+	if config.Runway.AlertDays <= 0 || r.days < 0 || r.days >= config.Runway.AlertDays {
+		return
+	}
+	sendAlert(fmt.Sprintf("HDFS 容量剩余 %d 天 %s", r.days, r.record.Date.Format("2006-01-02")), []string{
+		fmt.Sprintf("预计 %s 达到 %s，当前已使用 %s/%s，平均每天增长 %s",
+			r.runwayDate(), formatBytes(r.target), formatBytes(r.record.Used), formatBytes(r.record.Capacity), formatBytes(int64(r.growth))),
+	})
+}
+
+// checkRunway capacity 计数器记录当天的容量后调用，历史记录来自 hdfs_capacity
+func checkRunway(ctx context.Context, record *model.HdfsCapacity) error {
+	store, err := openStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	err = store.Migrate(ctx, &model.HdfsCapacity{})
+	if err != nil {
+		return err
+	}
+	records, err := loadCapacityHistory(ctx, store, record.Date.AddDate(0, 0, -1))
+	if err != nil {
+		return err
+	}
+	if r := computeRunway(append(records, record)); r != nil {
+		alertRunway(r)
+	}
+	return nil
+}
+
+func latestCapacityDate(ctx context.Context, store *sink.Gorm) (time.Time, error) {
+	var latest []model.HdfsCapacity
+	err := store.DB().WithContext(ctx).Order("date desc").Limit(1).Find(&latest).Error
+	if err != nil {
+		return time.Time{}, failure.Wrap(err)
+	}
+	if len(latest) == 0 {
+		return time.Time{}, failure.Wrap(fmt.Errorf("no capacity records found"))
+	}
+	return latest[0].Date, nil
+}
+
+// loadCapacityHistory 读取 date 之前 forecast.history 天到 date 的容量记录，按日期升序排列
+func loadCapacityHistory(ctx context.Context, store *sink.Gorm, date time.Time) ([]*model.HdfsCapacity, error) {
+	history := config.Forecast.History
+	if history <= 0 {
+		history = defaultForecastHistory
+	}
+	var records []*model.HdfsCapacity
+	err := store.DB().WithContext(ctx).Where("date BETWEEN ? AND ?", date.AddDate(0, 0, -history), date).Order("date").Find(&records).Error
+	return records, failure.Wrap(err)
+}
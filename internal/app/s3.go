@@ -0,0 +1,241 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/internal/retry"
+	"github.com/rea1shane/counter/pkg/model"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region          = "us-east-1"
+	defaultS3Timeout         = 30 * time.Second
+	defaultS3RetryTimes      = 3
+	defaultS3RetryInterval   = time.Second
+	defaultS3RetryMultiplier = 2
+
+	// s3EmptyPayload 空请求体的 sha256
+	s3EmptyPayload = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// s3Bucket 需要统计的桶，prefixes 为空时统计整个桶
+type s3Bucket struct {
+	Bucket   string   `yaml:"bucket"`
+	Prefixes []string `yaml:"prefixes"`
+}
+
+// s3ListResult ListObjectsV2 的返回值
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+// s3Counter 通过 ListObjectsV2 遍历对象统计大小，兼容 OSS、COS、MinIO 等 S3 协议的对象存储
+var s3Counter = &counter{
+	name:  "s3",
+	usage: "遍历 s3.buckets 中每个桶或前缀下的对象，统计总大小和对象数量并保存到 s3_usage 表",
+	model: &model.S3Usage{},
+	keys:  []string{"bucket", "prefix"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		records, err := countS3(ctx, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"bucket", "prefix", "size", "objects", "desc"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{record.Bucket, record.Prefix, byteSize(record.Size), record.Objects, record.Desc})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countS3 按大小降序返回每个桶或前缀，单个前缀失败时 size 为 -1
+func countS3(ctx context.Context, date time.Time) ([]*model.S3Usage, error) {
+	if config.S3.Endpoint == "" {
+		return nil, failure.Wrap(fmt.Errorf("s3.endpoint is empty"))
+	}
+	timeout := config.S3.Timeout
+	if timeout <= 0 {
+		timeout = defaultS3Timeout
+	}
+	client := &http.Client{Timeout: timeout}
+
+	var records []*model.S3Usage
+	for _, bucket := range config.S3.Buckets {
+		prefixes := bucket.Prefixes
+		if len(prefixes) == 0 {
+			prefixes = []string{""}
+		}
+		for _, prefix := range prefixes {
+			record := &model.S3Usage{Bucket: bucket.Bucket, Prefix: prefix, Date: date}
+			err := listS3Objects(ctx, client, bucket.Bucket, prefix, func(result *s3ListResult) {
+				for _, object := range result.Contents {
+					record.Size += object.Size
+					record.Objects++
+				}
+			})
+			if err != nil {
+				log.Printf("遍历 %s/%s 失败: %+v", bucket.Bucket, prefix, err)
+				record.Size = -1
+				record.Desc = err.Error()
+			}
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+	return records, nil
+}
+
+// listS3Objects 分页调用 ListObjectsV2，每页最多 1000 个对象
+func listS3Objects(ctx context.Context, client *http.Client, bucket, prefix string, page func(*s3ListResult)) error {
+	endpoint, err := url.Parse(config.S3.Endpoint)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	if endpoint.Scheme == "" {
+		endpoint, err = url.Parse("https://" + config.S3.Endpoint)
+		if err != nil {
+			return failure.Wrap(err)
+		}
+	}
+	if config.S3.PathStyle {
+		endpoint.Path = "/" + bucket
+	} else {
+		endpoint.Host = bucket + "." + endpoint.Host
+		endpoint.Path = "/"
+	}
+
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "max-keys": {"1000"}}
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		endpoint.RawQuery = s3Query(query)
+
+		var result s3ListResult
+		retrier := retry.Retrier{Policy: config.S3.Retry, Retryable: retry.Any(retry.Transient, isS3Retryable)}
+		err = retrier.Do(ctx, func() error {
+			result = s3ListResult{}
+			return getS3Page(ctx, client, endpoint.String(), &result)
+		})
+		if err != nil {
+			return failure.Wrap(err, failure.Context{"op": "list objects", "bucket": bucket, "prefix": prefix})
+		}
+		page(&result)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// s3StatusError ListObjectsV2 返回的非 200 响应
+type s3StatusError struct {
+	status int
+	msg    string
+}
+
+func (e *s3StatusError) Error() string {
+	return e.msg
+}
+
+// isS3Retryable 限流和服务端错误可以重试
+func isS3Retryable(err error) bool {
+	var statusErr *s3StatusError
+	return errors.As(err, &statusErr) && (statusErr.status == http.StatusTooManyRequests || statusErr.status >= http.StatusInternalServerError)
+}
+
+// getS3Page 请求一页对象并解析到 result
+func getS3Page(ctx context.Context, client *http.Client, endpoint string, result *s3ListResult) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	signS3Request(req, time.Now().UTC())
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &s3StatusError{status: resp.StatusCode, msg: fmt.Sprintf("%s: %s", resp.Status, strings.TrimSpace(string(body)))}
+	}
+	return xml.Unmarshal(body, result)
+}
+
+// signS3Request 按 AWS Signature Version 4 签名，没有配置 access_key 时匿名访问
+func signS3Request(req *http.Request, now time.Time) {
+	if config.S3.AccessKey == "" {
+		return
+	}
+	region := config.S3.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3EmptyPayload)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + s3EmptyPayload,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		s3EmptyPayload,
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	digest := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(digest[:])
+
+	key := []byte("AWS4" + config.S3.SecretKey)
+	for _, part := range []string{day, region, "s3", "aws4_request"} {
+		key = hmacSha256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+config.S3.AccessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// s3Query 按键排序编码查询参数，空格编码为 %20，与签名时的规范格式一致
+func s3Query(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
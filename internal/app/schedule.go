@@ -0,0 +1,29 @@
+package app
+
+import (
+	"log"
+	"time"
+)
+
+// schedule 每天在 serve.schedule 指定的时间触发一次完整的抓取，开启选举时只有 leader 触发
+func (s *server) schedule(at time.Time, leader *leaderElection) {
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(time.Until(next))
+
+		if leader != nil && !leader.isLeader() {
+			log.Println("不是 leader，跳过定时抓取")
+			continue
+		}
+		r, err := s.trigger(nil)
+		if err != nil {
+			log.Println("触发定时抓取失败: " + err.Error())
+			continue
+		}
+		log.Printf("触发定时抓取 %s", r.ID)
+	}
+}
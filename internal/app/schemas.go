@@ -0,0 +1,126 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"sort"
+	"time"
+)
+
+// schemaInstance 需要统计的 MySQL 实例，例如 Hive metastore 和保存结果的数据库
+type schemaInstance struct {
+	Name string `yaml:"name"`
+	Dsn  string `yaml:"dsn"`
+	// Exclude 跳过的库，系统库默认跳过
+	Exclude []string `yaml:"exclude"`
+}
+
+// mysqlSystemSchemas MySQL 内置的库，不统计
+var mysqlSystemSchemas = []interface{}{"mysql", "information_schema", "performance_schema", "sys"}
+
+// schemaCounter 从 information_schema.tables 读取每个库和每张表的大小，不扫描数据
+var schemaCounter = &counter{
+	name:  "schemas",
+	usage: "统计 schemas.instances 中每个 MySQL 实例每个库和每张表的数据和索引大小并保存到 mysql_schema 表",
+	model: &model.MysqlSchema{},
+	keys:  []string{"instance", "level", "schema", "table"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		records, err := countSchemas(ctx, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"instance", "level", "schema", "table", "tables", "data_size", "index_size", "free_size", "rows"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{record.Instance, record.Level, record.Schema, record.Table, record.Tables, byteSize(record.DataSize), byteSize(record.IndexSize), byteSize(record.FreeSize), record.Rows})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countSchemas 每个实例先列出库级别的汇总，再列出表，都按数据和索引大小之和降序排列，单个实例失败时跳过
+func countSchemas(ctx context.Context, date time.Time) ([]*model.MysqlSchema, error) {
+	if len(config.Schemas.Instances) == 0 {
+		return nil, failure.Wrap(fmt.Errorf("schemas.instances is empty"))
+	}
+	var (
+		records []*model.MysqlSchema
+		failed  int
+	)
+	for _, instance := range config.Schemas.Instances {
+		tables, err := listSchemaTables(ctx, instance)
+		if err != nil {
+			log.Printf("获取实例 %s 的表大小失败: %+v", instance.Name, err)
+			failed++
+			continue
+		}
+		schemas := make(map[string]*model.MysqlSchema)
+		var dbs []*model.MysqlSchema
+		for _, table := range tables {
+			table.Date = date
+			schema, ok := schemas[table.Schema]
+			if !ok {
+				schema = &model.MysqlSchema{Instance: instance.Name, Level: model.LevelDb, Schema: table.Schema, Date: date}
+				schemas[table.Schema] = schema
+				dbs = append(dbs, schema)
+			}
+			schema.Tables++
+			schema.DataSize += table.DataSize
+			schema.IndexSize += table.IndexSize
+			schema.FreeSize += table.FreeSize
+			schema.Rows += table.Rows
+		}
+		sortSchemas(dbs)
+		sortSchemas(tables)
+		records = append(records, dbs...)
+		records = append(records, tables...)
+	}
+	if failed == len(config.Schemas.Instances) {
+		return nil, failure.Wrap(fmt.Errorf("all instances failed"))
+	}
+	return records, nil
+}
+
+func sortSchemas(records []*model.MysqlSchema) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].DataSize+records[i].IndexSize > records[j].DataSize+records[j].IndexSize
+	})
+}
+
+// listSchemaTables 返回实例中除系统库和 exclude 以外的所有表，不包括视图
+func listSchemaTables(ctx context.Context, instance schemaInstance) ([]*model.MysqlSchema, error) {
+	db, err := sql.Open("mysql", instance.Dsn)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	defer db.Close()
+
+	exclude := make(map[string]bool)
+	for _, name := range instance.Exclude {
+		exclude[name] = true
+	}
+	rows, err := db.QueryContext(ctx, "SELECT table_schema, table_name, COALESCE(data_length, 0), COALESCE(index_length, 0), COALESCE(data_free, 0), COALESCE(table_rows, 0) "+
+		"FROM information_schema.tables WHERE table_type = 'BASE TABLE' AND table_schema NOT IN (?, ?, ?, ?)", mysqlSystemSchemas...)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	defer rows.Close()
+
+	var tables []*model.MysqlSchema
+	for rows.Next() {
+		table := &model.MysqlSchema{Instance: instance.Name, Level: model.LevelTable, Tables: 1}
+		err = rows.Scan(&table.Schema, &table.Table, &table.DataSize, &table.IndexSize, &table.FreeSize, &table.Rows)
+		if err != nil {
+			return nil, failure.Wrap(err)
+		}
+		if exclude[table.Schema] {
+			continue
+		}
+		tables = append(tables, table)
+	}
+	return tables, failure.Wrap(rows.Err())
+}
@@ -0,0 +1,457 @@
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/rea1shane/counter/pkg/sink"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultServeListen = ":8080"
+
+	defaultServeShutdownTimeout = 30 * time.Second
+
+	// serveRunHistory 内存中保留的运行记录数量
+	serveRunHistory = 100
+	// serveCancelWait 取消正在进行的抓取后等待其退出的时间
+	serveCancelWait = 10 * time.Second
+)
+
+// 运行状态
+const (
+	runPending   = "pending"
+	runRunning   = "running"
+	runSucceeded = "succeeded"
+	runFailed    = "failed"
+)
+
+// serveRun 通过 API 触发的一次运行
+type serveRun struct {
+	ID       string     `json:"id"`
+	Filter   *runFilter `json:"filter,omitempty"`
+	Status   string     `json:"status"`
+	Done     int        `json:"done"`
+	Total    int        `json:"total"`
+	Tables   int        `json:"tables"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// server 同一时间只执行一次运行，新的运行排队等待
+type server struct {
+	mu     sync.Mutex
+	runs   []*serveRun
+	nextID int
+	queue  chan *serveRun
+	ready  readiness
+	// rescans 等待重新抓取的表，在没有其他运行时合并为一次运行
+	rescans map[string]bool
+	rescan  chan struct{}
+	// counters counters 中开启的计数器的状态
+	counters []*counterStatus
+	// stopping 收到退出信号后为 true
+	stopping bool
+	// ctx 运行和计数器使用，等待 serve.shutdown_timeout 后仍未完成时取消
+	ctx    context.Context
+	cancel context.CancelFunc
+	// store 启动时打开的结果数据库，查询接口共用同一个连接池，没有配置数据库 sink 时为空，storeErr 为原因
+	store    *sink.Gorm
+	storeErr error
+}
+
+// Serve 常驻运行，通过 HTTP API 触发抓取、查询运行进度和最近的结果，并在 / 提供仪表盘，counterd 和 counter serve 的入口
+func Serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", "", "监听地址，默认使用配置中的 serve.listen")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+	if *listen == "" {
+		*listen = config.Serve.Listen
+	}
+	if *listen == "" {
+		*listen = defaultServeListen
+	}
+	log.Printf("counter %s", counterVersion())
+
+	s := &server{
+		queue:   make(chan *serveRun, serveRunHistory),
+		rescans: make(map[string]bool),
+		rescan:  make(chan struct{}, 1),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if storeConfigured() {
+		s.store, err = openStore(s.ctx)
+		if err != nil {
+			log.Fatal("打开数据库失败: " + err.Error())
+		}
+		defer s.store.Close()
+	} else {
+		_, s.storeErr = configuredStore()
+	}
+	go s.work()
+
+	var leader *leaderElection
+	if config.Serve.LeaderElection.Enabled {
+		leader = startLeaderElection()
+	}
+	if config.Serve.Schedule != "" {
+		at, err := time.ParseInLocation("15:04", config.Serve.Schedule, time.Local)
+		if err != nil {
+			log.Fatal("解析 serve.schedule 失败: " + err.Error())
+		}
+		go s.schedule(at, leader)
+	}
+	scheduled, err := enabledCounters()
+	if err != nil {
+		log.Fatal("解析 counters 失败: " + err.Error())
+	}
+	s.startCounters(scheduled, leader)
+
+	if config.Serve.GrpcListen != "" {
+		go func() {
+			log.Fatal(serveGrpc(config.Serve.GrpcListen, s))
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/runs", s.handleRuns)
+	mux.HandleFunc("/runs/", s.handleRun)
+	mux.HandleFunc("/rescans", s.handleRescans)
+	mux.HandleFunc("/counters", s.handleCounters)
+	mux.HandleFunc("/filters", s.handleFilters)
+	mux.HandleFunc("/filters/", s.handleFilters)
+	mux.HandleFunc("/results", s.handleResults)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/api/dashboard", s.handleDashboardData)
+	s.registerGrafana(mux, "/grafana")
+	mux.HandleFunc("/", s.handleDashboard)
+	httpServer := &http.Server{Addr: *listen, Handler: mux}
+	go s.shutdownOnSignal(httpServer)
+	log.Printf("监听 %s", *listen)
+	err = httpServer.ListenAndServe()
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	s.wait()
+}
+
+// shutdownOnSignal 收到 SIGTERM 或 SIGINT 后 readyz 返回 503，不再接受新的请求
+func (s *server) shutdownOnSignal(httpServer *http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	sig := <-signals
+	log.Printf("收到 %s，停止服务", sig)
+
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+	defer cancel()
+	err := httpServer.Shutdown(ctx)
+	if err != nil {
+		log.Println("停止 HTTP 服务失败: " + err.Error())
+	}
+}
+
+// wait 等待正在进行的抓取完成，超过 serve.shutdown_timeout 时取消正在进行的抓取，再等待 serveCancelWait 后退出
+func (s *server) wait() {
+	defer s.cancel()
+	if s.waitIdle(s.shutdownTimeout()) {
+		return
+	}
+	log.Println("等待抓取完成超时，取消正在进行的抓取")
+	s.cancel()
+	if !s.waitIdle(serveCancelWait) {
+		log.Println("等待取消抓取超时")
+	}
+}
+
+// waitIdle 等待没有正在进行的运行和计数器，超时返回 false
+func (s *server) waitIdle(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		busy := false
+		for _, r := range s.runs {
+			if r.Status == runRunning {
+				busy = true
+			}
+		}
+		for _, status := range s.counters {
+			if status.Running {
+				busy = true
+			}
+		}
+		s.mu.Unlock()
+		if !busy {
+			return true
+		}
+		time.Sleep(time.Second)
+	}
+	return false
+}
+
+func (s *server) shutdownTimeout() time.Duration {
+	if config.Serve.ShutdownTimeout > 0 {
+		return config.Serve.ShutdownTimeout
+	}
+	return defaultServeShutdownTimeout
+}
+
+// work 依次执行队列中的运行，队列为空时执行等待重新抓取的表
+func (s *server) work() {
+	for {
+		var r *serveRun
+		select {
+		case r = <-s.queue:
+		default:
+			select {
+			case r = <-s.queue:
+			case <-s.rescan:
+				s.triggerRescan()
+				continue
+			}
+		}
+		s.update(r, func(r *serveRun) {
+			now := time.Now()
+			r.Status = runRunning
+			r.Started = &now
+		})
+		entities, err := run(s.ctx, r.Filter, func(done, total int) {
+			s.update(r, func(r *serveRun) {
+				r.Done, r.Total = done, total
+			})
+		})
+		s.update(r, func(r *serveRun) {
+			now := time.Now()
+			r.Finished = &now
+			r.Tables = len(entities)
+			if err != nil {
+				r.Status = runFailed
+				r.Error = err.Error()
+				return
+			}
+			r.Status = runSucceeded
+		})
+		if err != nil {
+			log.Printf("运行 %s 失败: %+v", r.ID, err)
+		}
+	}
+}
+
+// trigger 将一次运行加入队列，返回加入时的状态
+func (s *server) trigger(filter *runFilter) (serveRun, error) {
+	s.mu.Lock()
+	s.nextID++
+	r := &serveRun{ID: strconv.Itoa(s.nextID), Status: runPending, Created: time.Now()}
+	if !filter.empty() {
+		r.Filter = filter
+	}
+	s.runs = append(s.runs, r)
+	if len(s.runs) > serveRunHistory {
+		s.runs = s.runs[len(s.runs)-serveRunHistory:]
+	}
+	snapshot := *r
+	s.mu.Unlock()
+
+	select {
+	case s.queue <- r:
+		return snapshot, nil
+	default:
+		s.update(r, func(r *serveRun) {
+			r.Status = runFailed
+			r.Error = "too many pending runs"
+		})
+		return serveRun{}, fmt.Errorf("too many pending runs")
+	}
+}
+
+// get 返回一次运行当前的状态
+func (s *server) get(id string) (serveRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.runs {
+		if r.ID == id {
+			return *r, true
+		}
+	}
+	return serveRun{}, false
+}
+
+func (s *server) update(r *serveRun, f func(*serveRun)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(r)
+}
+
+// handleRuns POST 触发一次运行，需要 serve.bearer_token，请求体为可选的 {"dbs": [...], "tables": ["db.table"]}；GET 返回最近的运行
+func (s *server) handleRuns(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		if !authorize(w, req) {
+			return
+		}
+		filter := &runFilter{}
+		if req.ContentLength != 0 {
+			err := json.NewDecoder(req.Body).Decode(filter)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		r, err := s.trigger(filter)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, r)
+	case http.MethodGet:
+		s.mu.Lock()
+		runs := make([]serveRun, 0, len(s.runs))
+		for i := len(s.runs) - 1; i >= 0; i-- {
+			runs = append(runs, *s.runs[i])
+		}
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, runs)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+	}
+}
+
+// handleRun GET /runs/{id} 返回一次运行的进度
+func (s *server) handleRun(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+	id := strings.TrimPrefix(req.URL.Path, "/runs/")
+	r, ok := s.get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("run %s not found", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, r)
+}
+
+// handleResults GET /results?date=2006-01-02&db=xxx&limit=100 返回某一天的结果，默认为最近一次抓取的日期，按大小降序排列
+func (s *server) handleResults(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+	query := req.URL.Query()
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	ctx := req.Context()
+	store, err := s.database()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	date, err := resolveDate(ctx, store, query.Get("date"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	db := store.DB().WithContext(ctx).Where("date = ?", date)
+	if value := query.Get("db"); value != "" {
+		db = db.Where("db = ?", value)
+	}
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	records := []*model.Hive{}
+	err = db.Order("size desc").Find(&records).Error
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	rows := make([]jsonRow, 0, len(records))
+	for _, record := range records {
+		rows = append(rows, newJSONRow(record))
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
+
+// database 返回启动时打开的结果数据库，请求之间共用，不需要关闭
+func (s *server) database() (*sink.Gorm, error) {
+	if s.store == nil {
+		return nil, s.storeErr
+	}
+	return s.store, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// checkBearerToken 检查修改状态的请求带的 Authorization 是否与 serve.bearer_token 一致，没有配置 token 时拒绝所有修改请求
+func checkBearerToken(authorization string) error {
+	if config.Serve.BearerToken == "" {
+		return errTokenNotConfigured
+	}
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(config.Serve.BearerToken)) != 1 {
+		return errInvalidToken
+	}
+	return nil
+}
+
+var (
+	errTokenNotConfigured = errors.New("serve.bearer_token is not configured")
+	errInvalidToken       = errors.New("invalid bearer token")
+)
+
+// authorize 检查 HTTP 请求的 bearer token，失败时写入错误响应
+func authorize(w http.ResponseWriter, req *http.Request) bool {
+	err := checkBearerToken(req.Header.Get("Authorization"))
+	switch err {
+	case nil:
+		return true
+	case errTokenNotConfigured:
+		writeError(w, http.StatusForbidden, err)
+	default:
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, err)
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
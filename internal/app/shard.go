@@ -0,0 +1,199 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-zookeeper/zk"
+	"github.com/morikuni/failure"
+	"hash/fnv"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultShardPath   = "/counter/shards"
+	defaultShardSettle = 30 * time.Second
+)
+
+// shard 多个实例按库名的哈希拆分库列表，每个实例只抓取 hash(db) % count == index 的库
+type shard struct {
+	index int
+	count int
+}
+
+// owns 没有分片时返回 true
+func (s *shard) owns(db string) bool {
+	if s == nil || s.count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(db))
+	return int(h.Sum32()%uint32(s.count)) == s.index
+}
+
+// joinShard 开启 shard.zookeeper 时返回进程内分片组当前的分片，分片组在第一次调用时注册，之后监听成员变化更新；
+// 否则使用配置的 index 和 count
+func joinShard() (*shard, error) {
+	cfg := config.Shard
+	if !cfg.Zookeeper.Enabled {
+		if cfg.Count <= 1 {
+			return nil, nil
+		}
+		if cfg.Index < 0 || cfg.Index >= cfg.Count {
+			return nil, failure.Wrap(fmt.Errorf("shard index %d out of range [0, %d)", cfg.Index, cfg.Count))
+		}
+		return &shard{index: cfg.Index, count: cfg.Count}, nil
+	}
+
+	shardGroupMu.Lock()
+	defer shardGroupMu.Unlock()
+	if defaultShardGroup == nil {
+		g, err := joinShardGroup()
+		if err != nil {
+			return nil, err
+		}
+		defaultShardGroup = g
+	}
+	return defaultShardGroup.current()
+}
+
+var (
+	shardGroupMu      sync.Mutex
+	defaultShardGroup *shardGroup
+)
+
+var errShardNodeLost = errors.New("shard node lost")
+
+// shardGroup 在 ZooKeeper 上注册的临时顺序节点，按注册顺序确定序号，实例数量为当前注册的实例数，进程退出时会话关闭，节点随之删除
+type shardGroup struct {
+	conn   *zk.Conn
+	events <-chan zk.Event
+	root   string
+
+	mu    sync.Mutex
+	node  string
+	shard *shard
+}
+
+// joinShardGroup 注册后等待 settle，让同时启动的实例完成注册，避免各实例第一次运行时看到的实例数量不一致
+func joinShardGroup() (*shardGroup, error) {
+	cfg := config.Shard.Zookeeper
+	root := cfg.Path
+	if root == "" {
+		root = defaultShardPath
+	}
+	settle := cfg.Settle
+	if settle <= 0 {
+		settle = defaultShardSettle
+	}
+	conn, events, err := zk.Connect(strings.Split(config.Hive.Zookeeper.Quorum, ","), leaderSessionTimeout, zk.WithLogInfo(false))
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	g := &shardGroup{conn: conn, events: events, root: root}
+	err = createParents(conn, root)
+	if err == nil {
+		err = g.register()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, failure.Wrap(err)
+	}
+
+	time.Sleep(settle)
+	watch, err := g.refresh()
+	if err != nil {
+		conn.Close()
+		return nil, failure.Wrap(err)
+	}
+	go g.watch(watch)
+	return g, nil
+}
+
+func (g *shardGroup) register() error {
+	node, err := g.conn.Create(g.root+"/n_", nil, zk.FlagEphemeral|zk.FlagSequence, zk.WorldACL(zk.PermAll))
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.node = path.Base(node)
+	g.mu.Unlock()
+	return nil
+}
+
+// refresh 按当前注册的实例计算分片，返回成员变化的 watch
+func (g *shardGroup) refresh() (<-chan zk.Event, error) {
+	children, _, watch, err := g.conn.ChildrenW(g.root)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(children)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	index := sort.SearchStrings(children, g.node)
+	if index == len(children) || children[index] != g.node {
+		g.node = ""
+		g.shard = nil
+		return nil, errShardNodeLost
+	}
+	s := &shard{index: index, count: len(children)}
+	if g.shard == nil || *g.shard != *s {
+		log.Printf("分片 %d/%d", s.index, s.count)
+	}
+	g.shard = s
+	return watch, nil
+}
+
+// watch 成员变化时重新计算分片，会话过期导致节点被删除时重新注册
+func (g *shardGroup) watch(watch <-chan zk.Event) {
+	for {
+		select {
+		case <-watch:
+		case event, ok := <-g.events:
+			if !ok {
+				return
+			}
+			if event.State != zk.StateExpired {
+				continue
+			}
+		}
+
+		for {
+			var err error
+			watch, err = g.update()
+			if err == nil {
+				break
+			}
+			log.Printf("更新分片失败，%s 后重试: %v", leaderRetryInterval, err)
+			time.Sleep(leaderRetryInterval)
+		}
+	}
+}
+
+// update 节点丢失时重新注册后再计算分片
+func (g *shardGroup) update() (<-chan zk.Event, error) {
+	watch, err := g.refresh()
+	if err != errShardNodeLost {
+		return watch, err
+	}
+	err = g.register()
+	if err != nil {
+		return nil, err
+	}
+	return g.refresh()
+}
+
+// current 节点丢失且还没有重新注册时返回错误，避免与其他实例重复或遗漏
+func (g *shardGroup) current() (*shard, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.shard == nil {
+		return nil, failure.Wrap(fmt.Errorf("not registered in shard group %s", g.root))
+	}
+	return g.shard, nil
+}
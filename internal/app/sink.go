@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/rea1shane/counter/pkg/sink"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sinkMysql      = "mysql"
+	sinkPostgres   = "postgres"
+	sinkClickhouse = "clickhouse"
+	sinkSqlite     = "sqlite"
+	sinkCsv        = "csv"
+	sinkParquet    = "parquet"
+	sinkJSONL      = "jsonl"
+	sinkKafka      = "kafka"
+	sinkInfluxdb   = "influxdb"
+	sinkElastic    = "elasticsearch"
+)
+
+// jsonRow 一条记录的 JSON 表示，日期只保留到天
+type jsonRow struct {
+	Db       string      `json:"db"`
+	Table    string      `json:"table"`
+	Location string      `json:"location"`
+	Size     int64       `json:"size"`
+	Desc     string      `json:"desc"`
+	Date     string      `json:"date"`
+	Extra    model.Extra `json:"extra,omitempty"`
+
+	RunID          string   `json:"run_id,omitempty"`
+	CounterVersion string   `json:"counter_version,omitempty"`
+	MetadataMs     int64    `json:"metadata_ms,omitempty"`
+	SizeMs         int64    `json:"size_ms,omitempty"`
+	DeltaBytes     *int64   `json:"delta_bytes,omitempty"`
+	DeltaPct       *float64 `json:"delta_pct,omitempty"`
+}
+
+func newJSONRow(entity *model.Hive) jsonRow {
+	return jsonRow{
+		Db:       entity.Db,
+		Table:    entity.Table,
+		Location: entity.Location,
+		Size:     entity.Size,
+		Desc:     entity.Desc,
+		Date:     entity.Date.Format("2006-01-02"),
+		Extra:    entity.Extra,
+
+		RunID:          entity.RunID,
+		CounterVersion: entity.CounterVersion,
+		MetadataMs:     entity.MetadataMs,
+		SizeMs:         entity.SizeMs,
+		DeltaBytes:     entity.DeltaBytes,
+		DeltaPct:       entity.DeltaPct,
+	}
+}
+
+// epochDays Parquet、Avro 的 date 类型存储的是距 1970-01-01 的天数
+func epochDays(date time.Time) int32 {
+	year, month, day := date.Date()
+	return int32(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// registerSinksOnce serve 模式下每次抓取都会打开 sink，sink.Register 重复注册同名 sink 会 panic
+var registerSinksOnce sync.Once
+
+// registerSinks 注册内置的 sink 和 plugins.sinks 中的外部程序，进程内只注册一次，大部分 sink 使用配置文件中各自的顶层配置
+func registerSinks() {
+	registerSinksOnce.Do(func() {
+		sink.Register(sinkMysql, func(sink.Options) (sink.Sink, error) {
+			return newStore(sinkMysql), nil
+		})
+		sink.Register(sinkPostgres, func(sink.Options) (sink.Sink, error) {
+			return newStore(sinkPostgres), nil
+		})
+		sink.Register(sinkSqlite, func(sink.Options) (sink.Sink, error) {
+			return newStore(sinkSqlite), nil
+		})
+		sink.Register(sinkClickhouse, func(sink.Options) (sink.Sink, error) {
+			return newClickhouseSink(), nil
+		})
+		sink.Register(sinkKafka, func(sink.Options) (sink.Sink, error) {
+			return newKafkaSink(), nil
+		})
+		sink.Register(sinkInfluxdb, func(sink.Options) (sink.Sink, error) {
+			return newInfluxdbSink(), nil
+		})
+		sink.Register(sinkElastic, func(sink.Options) (sink.Sink, error) {
+			return newElasticsearchSink(), nil
+		})
+		registerPluginSinks()
+	})
+}
+
+// hdfsSinks 写入 hdfs 的 sink 使用本次抓取的 hdfs 客户端，不注册到 sink 包，每次抓取单独创建
+func hdfsSinks(hdfsClient *hdfs.Client) map[string]sink.Factory {
+	return map[string]sink.Factory{
+		sinkCsv: func(sink.Options) (sink.Sink, error) {
+			return &csvSink{hdfsClient: hdfsClient}, nil
+		},
+		sinkParquet: func(sink.Options) (sink.Sink, error) {
+			return &parquetSink{hdfsClient: hdfsClient}, nil
+		},
+	}
+}
+
+// openSink 创建并打开 sink，命令行指定了 --output 时忽略配置文件中的 sink
+func openSink(ctx context.Context, hdfsClient *hdfs.Client) (sink.Sink, error) {
+	var s sink.Sink
+	if *output != "" {
+		switch *output {
+		case sinkJSONL:
+			s = newJSONLSink(*outputFile)
+		default:
+			return nil, failure.Wrap(fmt.Errorf("unknown output: %s", *output))
+		}
+	} else {
+		registerSinks()
+		s = newMultiSink(config.Sink.Types, hdfsSinks(hdfsClient))
+	}
+	return s, s.Open(ctx)
+}
+
+// sinkOptions 返回 sink.options 中对应的自定义配置
+func sinkOptions(name string) sink.Options {
+	if node, ok := config.Sink.Options[name]; ok {
+		return &node
+	}
+	return nil
+}
+
+// writeOutputFile 将文件写入 dir 下，dir 以 hdfs:// 开头时写入 hdfs，否则写入本地，已存在的文件会被覆盖
+func writeOutputFile(hdfsClient *hdfs.Client, dir, name string, data []byte) error {
+	if !strings.HasPrefix(dir, hdfsFlag) {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return failure.Wrap(err)
+		}
+		return failure.Wrap(ioutil.WriteFile(filepath.Join(dir, name), data, 0644))
+	}
+
+	dir, err := hdfsPath(dir)
+	if err != nil {
+		return err
+	}
+	err = hdfsClient.MkdirAll(dir, 0755)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	path := dir + name
+	err = hdfsClient.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return failure.Wrap(err)
+	}
+	writer, err := hdfsClient.Create(path)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	_, err = writer.Write(data)
+	if err != nil {
+		writer.Close()
+		return failure.Wrap(err)
+	}
+	return failure.Wrap(writer.Close())
+}
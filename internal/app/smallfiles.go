@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"os"
+	"sort"
+)
+
+const (
+	defaultSmallFileAvgSize    = 16 << 20
+	defaultSmallFileMinFiles   = 10000
+	defaultSmallFileTargetSize = 128 << 20
+)
+
+// smallFileTable 小文件过多的表，合并后的文件数按 target_size 估算
+type smallFileTable struct {
+	record  *model.Hive
+	files   int64
+	avgSize int64
+	// compacted 合并后的文件数
+	compacted int64
+	// savings 合并后减少的 NameNode 对象数
+	savings int64
+}
+
+// runSmallFiles 输出平均文件大小低于阈值的表，按合并后减少的 NameNode 对象数降序排列
+func runSmallFiles(args []string) {
+	flags := flag.NewFlagSet("smallfiles", flag.ExitOnError)
+	dateFlag := flags.String("date", "", "日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	limit := flags.Int("limit", 50, "输出的数量，0 表示全部输出")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	date, err := resolveDate(ctx, store, *dateFlag)
+	if err != nil {
+		log.Fatal("解析日期失败: " + err.Error())
+	}
+	records, err := loadRecords(ctx, store, date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	tables := findSmallFiles(records)
+	if *limit > 0 && len(tables) > *limit {
+		tables = tables[:*limit]
+	}
+
+	header := []string{"rank", "db", "table", "location", "files", "size", "avg_size", "compacted_files", "object_savings"}
+	var rows [][]interface{}
+	for i, t := range tables {
+		rows = append(rows, []interface{}{i + 1, t.record.Db, t.record.Table, t.record.Location, t.files, byteSize(t.record.Size), byteSize(t.avgSize), t.compacted, t.savings})
+	}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+// findSmallFiles 筛选平均文件大小低于 avg_size 且文件数超过 min_files 的表
+//
+// 每个文件至少占用一个 inode 和一个 block，合并前按 2 * 文件数估算对象数，合并后按 2 * ceil(size / target_size) 估算。
+func findSmallFiles(records []*model.Hive) []*smallFileTable {
+	avgSize := int64(config.SmallFiles.AvgSize)
+	if avgSize <= 0 {
+		avgSize = defaultSmallFileAvgSize
+	}
+	minFiles := config.SmallFiles.MinFiles
+	if minFiles <= 0 {
+		minFiles = defaultSmallFileMinFiles
+	}
+	targetSize := int64(config.SmallFiles.TargetSize)
+	if targetSize <= 0 {
+		targetSize = defaultSmallFileTargetSize
+	}
+
+	var tables []*smallFileTable
+	for _, record := range records {
+		files, ok := record.Extra.Int64(model.ExtraFileCount)
+		if !ok || record.Size < 0 || files <= minFiles || record.Size/files >= avgSize {
+			continue
+		}
+		compacted := (record.Size + targetSize - 1) / targetSize
+		if compacted == 0 {
+			compacted = 1
+		}
+		tables = append(tables, &smallFileTable{
+			record:    record,
+			files:     files,
+			avgSize:   record.Size / files,
+			compacted: compacted,
+			savings:   2 * (files - compacted),
+		})
+	}
+	sort.SliceStable(tables, func(i, j int) bool {
+		return tables[i].savings > tables[j].savings
+	})
+	return tables
+}
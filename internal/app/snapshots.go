@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/colinmarc/hdfs"
+	"github.com/colinmarc/hdfs/protocol/hadoop_hdfs"
+	"github.com/colinmarc/hdfs/rpc"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"path"
+	"sort"
+	"time"
+)
+
+// snapshotDeleted SnapshotDiffReport 中删除的标记
+const snapshotDeleted = "-"
+
+// snapshotCounter 统计每个 snapshottable 目录只被快照引用的数据大小，用于评估删除旧快照可以释放的空间
+var snapshotCounter = &counter{
+	name:  "snapshots",
+	usage: "统计每个 snapshottable 目录中已经删除、只被快照引用的数据大小并保存到 hdfs_snapshot 表",
+	model: &model.HdfsSnapshot{},
+	keys:  []string{"path"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		records, err := countSnapshots(date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"path", "snapshots", "quota", "oldest", "oldest_time", "deleted", "size", "raw_size", "desc"}
+		var rows [][]interface{}
+		for _, record := range records {
+			oldest := ""
+			if record.OldestTime != nil {
+				oldest = record.OldestTime.Format("2006-01-02 15:04")
+			}
+			rows = append(rows, []interface{}{record.Path, record.Snapshots, record.SnapshotQuota, record.Oldest, oldest, record.Deleted, byteSize(record.Size), byteSize(record.RawSize), record.Desc})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countSnapshots 按大小降序返回每个 snapshottable 目录
+// hdfs.Client 没有提供快照相关的接口，所以单独建立 NameNode 连接，hdfs.Client 复用这个连接
+func countSnapshots(date time.Time) ([]*model.HdfsSnapshot, error) {
+	namenodes, err := hdfsAddresses()
+	if err != nil {
+		return nil, err
+	}
+	user := config.Hdfs.Username
+	if user == "" {
+		user, err = hdfs.Username()
+		if err != nil {
+			return nil, failure.Wrap(err)
+		}
+	}
+	namenode, err := rpc.NewNamenodeConnectionWithOptions(rpc.NamenodeConnectionOptions{
+		Addresses: namenodes,
+		User:      user,
+	})
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	client := hdfs.NewForConnection(namenode)
+	defer client.Close()
+
+	listing := &hadoop_hdfs.GetSnapshottableDirListingResponseProto{}
+	err = namenode.Execute("getSnapshottableDirListing", &hadoop_hdfs.GetSnapshottableDirListingRequestProto{}, listing)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+
+	var records []*model.HdfsSnapshot
+	for _, dir := range listing.GetSnapshottableDirList().GetSnapshottableDirListing() {
+		root := path.Join("/", string(dir.GetParentFullpath()), string(dir.GetDirStatus().GetPath()))
+		record := &model.HdfsSnapshot{
+			Path:          root,
+			SnapshotQuota: int(dir.GetSnapshotQuota()),
+			Date:          date,
+		}
+		if err := measureSnapshots(namenode, client, record); err != nil {
+			log.Printf("统计 %s 的快照失败: %+v", root, err)
+			record.Size = -1
+			record.Desc = err.Error()
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+	return records, nil
+}
+
+// measureSnapshots 从最早的快照开始，将每个快照与当前目录对比，只存在于快照中的路径按第一次出现时的快照计算大小
+// 追加或截断的文件在快照中保留的旧数据块不在统计范围内
+func measureSnapshots(namenode *rpc.NamenodeConnection, client *hdfs.Client, record *model.HdfsSnapshot) error {
+	snapshots, err := client.ReadDir(path.Join(record.Path, ".snapshot"))
+	if err != nil {
+		return failure.Wrap(err, failure.Context{"op": "readdir", "path": path.Join(record.Path, ".snapshot")})
+	}
+	record.Snapshots = len(snapshots)
+	if len(snapshots) == 0 {
+		return nil
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ModTime().Before(snapshots[j].ModTime())
+	})
+	oldest := snapshots[0].ModTime()
+	record.Oldest = snapshots[0].Name()
+	record.OldestTime = &oldest
+
+	var (
+		deleted = make(map[string]bool)
+		failed  int
+	)
+	for _, snapshot := range snapshots {
+		entries, err := getSnapshotDiff(namenode, record.Path, snapshot.Name())
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			relative := string(entry.GetFullpath())
+			if entry.GetModificationLabel() != snapshotDeleted || deleted[relative] {
+				continue
+			}
+			deleted[relative] = true
+			summary, err := getPathContentSummary(client, path.Join(record.Path, ".snapshot", snapshot.Name(), relative))
+			if err != nil {
+				log.Printf("获取快照 %s 中 %s 的大小失败: %+v", snapshot.Name(), relative, err)
+				failed++
+				continue
+			}
+			record.Size += summary.Size()
+			record.RawSize += summary.SizeAfterReplication()
+		}
+	}
+	record.Deleted = len(deleted)
+	if failed > 0 {
+		record.Desc = fmt.Sprintf("%d 个路径获取大小失败", failed)
+	}
+	return nil
+}
+
+// getSnapshotDiff 返回快照与当前目录的差异，路径相对于 snapshottable 目录
+func getSnapshotDiff(namenode *rpc.NamenodeConnection, root, snapshot string) ([]*hadoop_hdfs.SnapshotDiffReportEntryProto, error) {
+	// toSnapshot 为空字符串时表示当前目录
+	current := ""
+	req := &hadoop_hdfs.GetSnapshotDiffReportRequestProto{
+		SnapshotRoot: &root,
+		FromSnapshot: &snapshot,
+		ToSnapshot:   &current,
+	}
+	resp := &hadoop_hdfs.GetSnapshotDiffReportResponseProto{}
+	err := namenode.Execute("getSnapshotDiffReport", req, resp)
+	if err != nil {
+		return nil, failure.Wrap(err, failure.Context{"op": "snapshot diff", "path": root, "snapshot": snapshot})
+	}
+	return resp.GetDiffReport().GetDiffReportEntries(), nil
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/rea1shane/counter/pkg/sink"
+	"sort"
+	"time"
+)
+
+// MySQL 写入遇到死锁、连接断开等临时错误时默认的重试次数和间隔
+const (
+	defaultMysqlRetryTimes    = 3
+	defaultMysqlRetryInterval = time.Second
+)
+
+// openStore 打开保存历史结果的数据库，取配置的 sink 中第一个关系型数据库
+func openStore(ctx context.Context) (*sink.Gorm, error) {
+	store, err := configuredStore()
+	if err != nil {
+		return nil, err
+	}
+	return store, store.Open(ctx)
+}
+
+// configuredStore 配置的 sink 中第一个关系型数据库，尚未打开
+func configuredStore() (*sink.Gorm, error) {
+	for _, sinkType := range config.Sink.Types {
+		if store := newStore(sinkType); store != nil {
+			return store, nil
+		}
+	}
+	return nil, failure.Wrap(fmt.Errorf("no database sink configured, need one of %s, %s, %s", sinkMysql, sinkPostgres, sinkSqlite))
+}
+
+// storeConfigured 配置的 sink 中是否有关系型数据库
+func storeConfigured() bool {
+	for _, sinkType := range config.Sink.Types {
+		switch sinkType {
+		case "", sinkMysql, sinkPostgres, sinkSqlite:
+			return true
+		}
+	}
+	return false
+}
+
+func newStore(sinkType string) *sink.Gorm {
+	var store *sink.Gorm
+	switch sinkType {
+	case "", sinkMysql:
+		store = sink.NewMysql(config.Mysql.Dsn)
+		store.Pool = config.Mysql.Pool
+		store.Retry = config.Mysql.Retry
+	case sinkPostgres:
+		store = sink.NewPostgres(config.Postgres.Dsn)
+	case sinkSqlite:
+		store = sink.NewSqlite(config.Sqlite.Path)
+	default:
+		return nil
+	}
+	store.Logger = newGormLogger()
+	store.SkipMigrations = config.Migrate.Manual
+	return store
+}
+
+// latestDate 返回最近一次抓取的日期
+func latestDate(ctx context.Context, store *sink.Gorm) (time.Time, error) {
+	var latest []model.Hive
+	err := store.DB().WithContext(ctx).Order("date desc").Limit(1).Find(&latest).Error
+	if err != nil {
+		return time.Time{}, failure.Wrap(err)
+	}
+	if len(latest) == 0 {
+		return time.Time{}, failure.Wrap(fmt.Errorf("no records found"))
+	}
+	return latest[0].Date, nil
+}
+
+// previousDate 返回 date 之前最近一次抓取的日期，没有时 ok 为 false
+func previousDate(ctx context.Context, store *sink.Gorm, date time.Time) (previous time.Time, ok bool, err error) {
+	var records []model.Hive
+	err = store.DB().WithContext(ctx).Where("date < ?", date).Order("date desc").Limit(1).Find(&records).Error
+	if err != nil || len(records) == 0 {
+		return time.Time{}, false, failure.Wrap(err)
+	}
+	return records[0].Date, true, nil
+}
+
+// resolveDate 解析命令行中 2006-01-02 格式的日期，为空时返回最近一次抓取的日期
+func resolveDate(ctx context.Context, store *sink.Gorm, value string) (time.Time, error) {
+	if value == "" {
+		return latestDate(ctx, store)
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	return date, failure.Wrap(err)
+}
+
+// loadRecords 读取某一天的全部记录，按库名、表名排序
+func loadRecords(ctx context.Context, store *sink.Gorm, date time.Time) ([]*model.Hive, error) {
+	var records []*model.Hive
+	err := store.DB().WithContext(ctx).Where("date = ?", date).Find(&records).Error
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Db != records[j].Db {
+			return records[i].Db < records[j].Db
+		}
+		return records[i].Table < records[j].Table
+	})
+	return records, nil
+}
+
+// loadRecordsBetween 读取 [from, to] 之间的全部记录
+func loadRecordsBetween(ctx context.Context, store *sink.Gorm, from, to time.Time) ([]*model.Hive, error) {
+	var records []*model.Hive
+	err := store.DB().WithContext(ctx).Where("date >= ? AND date <= ?", from, to).Find(&records).Error
+	return records, failure.Wrap(err)
+}
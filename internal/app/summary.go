@@ -0,0 +1,120 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"strings"
+	"time"
+)
+
+// summaryTopMovers 运行摘要中列出的变化最大的表的数量
+const summaryTopMovers = 10
+
+// runSummary 一次运行的摘要，用于邮件和即时通讯通知
+type runSummary struct {
+	date     time.Time
+	duration time.Duration
+	tables   int
+	size     int64
+	failures []*model.Hive
+	// previous 前一天的结果，没有配置数据库或者前一天没有数据时为空
+	previous []*model.Hive
+	delta    int64
+	movers   []*sizeDelta
+}
+
+// newRunSummary 汇总本次结果，配置了数据库时读取前一天的结果计算变化
+func newRunSummary(ctx context.Context, date time.Time, duration time.Duration, entities []*model.Hive) *runSummary {
+	summary := &runSummary{date: date, duration: duration, tables: len(entities)}
+	for _, entity := range entities {
+		if entity.Size > 0 {
+			summary.size += entity.Size
+		}
+		if entity.Desc != "" {
+			summary.failures = append(summary.failures, entity)
+		}
+	}
+
+	// 没有配置数据库时不计算变化
+	store, err := openStore(ctx)
+	if err != nil {
+		return summary
+	}
+	defer store.Close()
+	summary.previous, err = loadRecords(ctx, store, date.AddDate(0, 0, -1))
+	if err != nil {
+		log.Println("读取前一天的结果失败: " + err.Error())
+		return summary
+	}
+	if len(summary.previous) == 0 {
+		return summary
+	}
+
+	deltas := diffTables(summary.previous, entities)
+	for _, d := range deltas {
+		summary.delta += d.delta()
+	}
+	// 按变化量的绝对值取最大的几项
+	for i, j := 0, len(deltas)-1; i <= j && len(summary.movers) < summaryTopMovers; {
+		if deltas[i].delta() >= -deltas[j].delta() {
+			if deltas[i].delta() == 0 {
+				break
+			}
+			summary.movers = append(summary.movers, deltas[i])
+			i++
+		} else {
+			summary.movers = append(summary.movers, deltas[j])
+			j--
+		}
+	}
+	return summary
+}
+
+func (s *runSummary) title() string {
+	return fmt.Sprintf("Hive 存储日报 %s", s.date.Format("2006-01-02"))
+}
+
+// text 纯文本格式的摘要
+func (s *runSummary) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "日期: %s\n", s.date.Format("2006-01-02"))
+	fmt.Fprintf(&b, "耗时: %s\n", s.duration.Round(time.Second))
+	fmt.Fprintf(&b, "表数量: %d\n", s.tables)
+	fmt.Fprintf(&b, "总大小: %s\n", formatBytes(s.size))
+	if len(s.previous) > 0 {
+		fmt.Fprintf(&b, "较前一天: %s\n", signedBytes(s.delta))
+	}
+	fmt.Fprintf(&b, "获取失败: %d\n", len(s.failures))
+
+	if len(s.movers) > 0 {
+		b.WriteString("\n变化最大的表:\n")
+		for _, d := range s.movers {
+			fmt.Fprintf(&b, "  %s.%s %s", d.db, d.table, signedBytes(d.delta()))
+			if d.status != "" {
+				fmt.Fprintf(&b, " (%s)", d.status)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(s.failures) > 0 {
+		b.WriteString("\n获取失败的表:\n")
+		for i, entity := range s.failures {
+			if i >= summaryTopMovers {
+				fmt.Fprintf(&b, "  ... 共 %d 张\n", len(s.failures))
+				break
+			}
+			fmt.Fprintf(&b, "  %s.%s: %s\n", entity.Db, entity.Table, entity.Desc)
+		}
+	}
+	return b.String()
+}
+
+// signedBytes 增长时带上 + 号
+func signedBytes(size int64) string {
+	if size > 0 {
+		return "+" + formatBytes(size)
+	}
+	return formatBytes(size)
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// 子命令的输出格式
+const (
+	formatTable = "table"
+	formatCSV   = "csv"
+	formatJSON  = "json"
+)
+
+// byteSize 表格格式下以 KiB、MiB 等单位显示，其他格式输出原始数值
+type byteSize int64
+
+// percent nil 表示无法计算
+type percent *float64
+
+// printRows 按指定格式输出，values 中的元素为 string、int、int64、float64、byteSize 或 percent，json 格式以 header 作为字段名
+func printRows(w io.Writer, format string, header []string, rows [][]interface{}) error {
+	switch format {
+	case formatTable, "":
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for i, name := range header {
+			if i > 0 {
+				fmt.Fprint(writer, "\t")
+			}
+			fmt.Fprint(writer, name)
+		}
+		fmt.Fprintln(writer)
+		for _, row := range rows {
+			for i, value := range row {
+				if i > 0 {
+					fmt.Fprint(writer, "\t")
+				}
+				fmt.Fprint(writer, formatValue(value, true))
+			}
+			fmt.Fprintln(writer)
+		}
+		return failure.Wrap(writer.Flush())
+	case formatCSV:
+		writer := csv.NewWriter(w)
+		err := writer.Write(header)
+		if err != nil {
+			return failure.Wrap(err)
+		}
+		for _, row := range rows {
+			record := make([]string, len(row))
+			for i, value := range row {
+				record[i] = formatValue(value, false)
+			}
+			err = writer.Write(record)
+			if err != nil {
+				return failure.Wrap(err)
+			}
+		}
+		writer.Flush()
+		return failure.Wrap(writer.Error())
+	case formatJSON:
+		objects := make([]map[string]interface{}, 0, len(rows))
+		for _, row := range rows {
+			object := make(map[string]interface{}, len(row))
+			for i, value := range row {
+				switch v := value.(type) {
+				case byteSize:
+					object[header[i]] = int64(v)
+				case percent:
+					object[header[i]] = (*float64)(v)
+				default:
+					object[header[i]] = v
+				}
+			}
+			objects = append(objects, object)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return failure.Wrap(encoder.Encode(objects))
+	}
+	return failure.Wrap(fmt.Errorf("unknown format: %s", format))
+}
+
+func formatValue(value interface{}, human bool) string {
+	switch v := value.(type) {
+	case byteSize:
+		if human {
+			return formatBytes(int64(v))
+		}
+		return strconv.FormatInt(int64(v), 10)
+	case percent:
+		if v == nil {
+			if human {
+				return "-"
+			}
+			return ""
+		}
+		if human {
+			return strconv.FormatFloat(*v, 'f', 2, 64) + "%"
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// formatBytes 以 1024 为进制转换为可读的大小，负数表示带符号的变化量
+func formatBytes(size int64) string {
+	sign := ""
+	if size < 0 {
+		sign = "-"
+		size = -size
+	}
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%s%d B", sign, size)
+	}
+	value := float64(size)
+	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	i := -1
+	for value >= unit && i < len(units)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%s%.2f %s", sign, value, units[i])
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"os"
+	"sort"
+)
+
+// runTop 输出某一天占用存储空间最大的表或库
+func runTop(args []string) {
+	flags := flag.NewFlagSet("top", flag.ExitOnError)
+	dateFlag := flags.String("date", "", "日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	by := flags.String("by", "table", "统计层级，可选 table、db")
+	limit := flags.Int("limit", 50, "输出的数量，0 表示全部输出")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+	if *by != "table" && *by != "db" {
+		log.Fatal(fmt.Sprintf("不支持的统计层级: %s", *by))
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	date, err := resolveDate(ctx, store, *dateFlag)
+	if err != nil {
+		log.Fatal("解析日期失败: " + err.Error())
+	}
+	records, err := loadRecords(ctx, store, date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	var (
+		header []string
+		rows   [][]interface{}
+	)
+	if *by == "db" {
+		header = []string{"rank", "db", "tables", "size", "percent"}
+		dbs, total := topDbs(records)
+		for i, db := range dbs {
+			rows = append(rows, []interface{}{i + 1, db.name, db.tables, byteSize(db.size), sizePercent(db.size, total)})
+		}
+	} else {
+		header = []string{"rank", "db", "table", "location", "size", "percent"}
+		var total int64
+		for _, record := range records {
+			if record.Size > 0 {
+				total += record.Size
+			}
+		}
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].Size > records[j].Size
+		})
+		for i, record := range records {
+			rows = append(rows, []interface{}{i + 1, record.Db, record.Table, record.Location, byteSize(record.Size), sizePercent(record.Size, total)})
+		}
+	}
+	if *limit > 0 && len(rows) > *limit {
+		rows = rows[:*limit]
+	}
+
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+type dbSize struct {
+	name   string
+	tables int
+	size   int64
+}
+
+// topDbs 按库汇总并按大小降序排列，获取大小失败的表不计入大小
+func topDbs(records []*model.Hive) ([]*dbSize, int64) {
+	var (
+		dbs   []*dbSize
+		index = make(map[string]*dbSize)
+		total int64
+	)
+	for _, record := range records {
+		db, ok := index[record.Db]
+		if !ok {
+			db = &dbSize{name: record.Db}
+			index[record.Db] = db
+			dbs = append(dbs, db)
+		}
+		db.tables++
+		if record.Size > 0 {
+			db.size += record.Size
+			total += record.Size
+		}
+	}
+	sort.SliceStable(dbs, func(i, j int) bool {
+		return dbs[i].size > dbs[j].size
+	})
+	return dbs, total
+}
+
+// sizePercent 占总大小的百分比
+func sizePercent(size, total int64) percent {
+	if size < 0 || total <= 0 {
+		return nil
+	}
+	value := float64(size) / float64(total) * 100
+	return &value
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/morikuni/failure"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// traceBatchSize 结束的 span 达到该数量时导出一次，避免按表记录的 span 占用过多内存
+const traceBatchSize = 5000
+
+// OTLP/HTTP JSON 编码的 trace 结构，traceId 和 spanId 为十六进制字符串
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	// Code 0 为未设置，2 为错误
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// tracer 记录一次运行的 span 并通过 OTLP 导出，未开启 otlp.traces 时为 nil，所有方法都可以在 nil 上调用
+type tracer struct {
+	traceID string
+
+	mu       sync.Mutex
+	finished []otlpSpan
+}
+
+type span struct {
+	tracer     *tracer
+	id         string
+	parentID   string
+	name       string
+	start      time.Time
+	attributes []otlpAttribute
+}
+
+func newTracer() *tracer {
+	if !config.Otlp.Traces || config.Otlp.Endpoint == "" {
+		return nil
+	}
+	return &tracer{traceID: randomHex(16)}
+}
+
+// start 开始一个根 span
+func (t *tracer) start(name string, attributes ...otlpAttribute) *span {
+	if t == nil {
+		return nil
+	}
+	return &span{tracer: t, id: randomHex(8), name: name, start: time.Now(), attributes: attributes}
+}
+
+// child 开始一个子 span
+func (s *span) child(name string, attributes ...otlpAttribute) *span {
+	if s == nil {
+		return nil
+	}
+	child := s.tracer.start(name, attributes...)
+	child.parentID = s.id
+	return child
+}
+
+// end 结束 span，err 不为空时标记为错误
+func (s *span) end(err error) {
+	if s == nil {
+		return
+	}
+	otlp := otlpSpan{
+		TraceID:           s.tracer.traceID,
+		SpanID:            s.id,
+		ParentSpanID:      s.parentID,
+		Name:              s.name,
+		Kind:              1,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Attributes:        s.attributes,
+	}
+	if err != nil {
+		otlp.Status = otlpStatus{Code: 2, Message: err.Error()}
+	}
+
+	t := s.tracer
+	t.mu.Lock()
+	t.finished = append(t.finished, otlp)
+	full := len(t.finished) >= traceBatchSize
+	t.mu.Unlock()
+	if full {
+		t.flush()
+	}
+}
+
+// flush 导出已经结束的 span，失败时只记录日志
+func (t *tracer) flush() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	spans := t.finished
+	t.finished = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(otlpTracesRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: newOtlpResource(),
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/rea1shane/counter"},
+				Spans: spans,
+			}},
+		}},
+	})
+	if err == nil {
+		err = postOtlp("/v1/traces", body)
+	}
+	if err != nil {
+		log.Printf("导出 trace 失败: %+v", failure.Wrap(err))
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
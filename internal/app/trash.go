@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/model"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// trashCurrent 回收站中还没有生成 checkpoint 的目录
+const trashCurrent = "Current"
+
+// trashCheckpointLayouts checkpoint 目录名的时间格式，yyMMddHHmm 为早期版本的格式
+var trashCheckpointLayouts = []string{"060102150405", "0601021504"}
+
+// trashCounter 统计每个用户回收站的大小和 checkpoint 的时间分布，用于评估 fs.trash.interval 是否合适
+var trashCounter = &counter{
+	name:  "trash",
+	usage: "统计 users.root（默认 /user）下每个用户 .Trash 的大小和 checkpoint 的时间分布并保存到 hdfs_trash 表",
+	model: &model.HdfsTrash{},
+	keys:  []string{"user"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		client, err := newHdfsClient()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		defer client.Close()
+		defer hdfssize.CloseOnDone(ctx, client)()
+
+		records, err := countTrash(client, date, time.Now())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"user", "size", "current", "1d", "7d", "older", "checkpoints", "oldest", "desc"}
+		var rows [][]interface{}
+		for _, record := range records {
+			oldest := ""
+			if record.OldestCheckpoint != nil {
+				oldest = record.OldestCheckpoint.Format("2006-01-02 15:04")
+			}
+			rows = append(rows, []interface{}{record.User, byteSize(record.Size), byteSize(record.Current), byteSize(record.Day), byteSize(record.Week), byteSize(record.Older), record.Checkpoints, oldest, record.Desc})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countTrash 按大小降序返回有回收站的用户，跳过 users.exclude 中的用户
+func countTrash(client *hdfs.Client, date, now time.Time) ([]*model.HdfsTrash, error) {
+	root := config.Users.Root
+	if root == "" {
+		root = defaultUsersRoot
+	}
+	infos, err := client.ReadDir(root)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	exclude := make(map[string]bool)
+	for _, user := range config.Users.Exclude {
+		exclude[user] = true
+	}
+
+	var records []*model.HdfsTrash
+	for _, info := range infos {
+		if !info.IsDir() || exclude[info.Name()] {
+			continue
+		}
+		record := &model.HdfsTrash{User: info.Name(), Path: path.Join(root, info.Name(), ".Trash"), Date: date}
+		err := measureTrash(client, record, now)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			record.Size = -1
+			record.Desc = err.Error()
+		}
+		records = append(records, record)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+	return records, nil
+}
+
+// measureTrash 回收站不存在时返回 os.ErrNotExist
+func measureTrash(client *hdfs.Client, record *model.HdfsTrash, now time.Time) error {
+	children, err := client.ReadDir(record.Path)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		summary, err := getPathContentSummary(client, path.Join(record.Path, child.Name()))
+		if err != nil {
+			return err
+		}
+		record.Size += summary.Size()
+		record.RawSize += summary.SizeAfterReplication()
+		record.Files += int64(summary.FileCount())
+
+		if child.Name() == trashCurrent {
+			record.Current += summary.Size()
+			continue
+		}
+		// 无法解析的目录按修改时间统计
+		checkpoint, ok := parseTrashCheckpoint(child.Name())
+		if !ok {
+			checkpoint = child.ModTime()
+		}
+		record.Checkpoints++
+		if record.OldestCheckpoint == nil || checkpoint.Before(*record.OldestCheckpoint) {
+			record.OldestCheckpoint = &checkpoint
+		}
+		switch age := now.Sub(checkpoint); {
+		case age < 24*time.Hour:
+			record.Day += summary.Size()
+		case age < 7*24*time.Hour:
+			record.Week += summary.Size()
+		default:
+			record.Older += summary.Size()
+		}
+	}
+	return nil
+}
+
+func parseTrashCheckpoint(name string) (time.Time, bool) {
+	for _, layout := range trashCheckpointLayouts {
+		if len(name) != len(layout) {
+			continue
+		}
+		t, err := time.ParseInLocation(layout, name, time.Local)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
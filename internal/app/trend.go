@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/rea1shane/counter/pkg/sink"
+	"gorm.io/gorm/clause"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+var defaultTrendWindows = []int{7, 30, 90}
+
+// trendUpsert 同一天重复计算时覆盖
+var trendUpsert = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "level"}, {Name: "db"}, {Name: "table"}, {Name: "window"}, {Name: "date"}},
+	DoUpdates: clause.AssignmentColumns([]string{"days", "base_size", "size", "rate"}),
+}
+
+// runTrend 计算并保存某一天的增长趋势，按增长速度降序输出
+func runTrend(args []string) {
+	flags := flag.NewFlagSet("trend", flag.ExitOnError)
+	dateFlag := flags.String("date", "", "日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	by := flags.String("by", model.LevelTable, "统计层级，可选 table、db")
+	window := flags.Int("window", 0, "输出的统计窗口，单位天，默认为配置中最小的窗口")
+	limit := flags.Int("limit", 50, "输出的数量，0 表示全部输出")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	date, err := resolveDate(ctx, store, *dateFlag)
+	if err != nil {
+		log.Fatal("解析日期失败: " + err.Error())
+	}
+	trends, err := updateTrends(ctx, store, date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	if *window == 0 {
+		*window = trendWindows()[0]
+	}
+	var selected []*model.HiveTrend
+	for _, trend := range trends {
+		if trend.Level == *by && trend.Window == *window {
+			selected = append(selected, trend)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Rate > selected[j].Rate
+	})
+	if *limit > 0 && len(selected) > *limit {
+		selected = selected[:*limit]
+	}
+
+	header := []string{"rank", "db", "table", "window", "days", "base_size", "size", "rate_per_day"}
+	var rows [][]interface{}
+	for i, trend := range selected {
+		rows = append(rows, []interface{}{i + 1, trend.Db, trend.Table, trend.Window, trend.Days, byteSize(trend.BaseSize), byteSize(trend.Size), byteSize(int64(trend.Rate))})
+	}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+}
+
+// trendWindows 升序排列的统计窗口
+func trendWindows() []int {
+	windows := append([]int(nil), config.Trend.Windows...)
+	if len(windows) == 0 {
+		windows = append(windows, defaultTrendWindows...)
+	}
+	sort.Ints(windows)
+	return windows
+}
+
+// updateTrends 根据历史记录计算 date 当天各窗口的增长趋势并写入 hive_trend 表
+func updateTrends(ctx context.Context, store *sink.Gorm, date time.Time) ([]*model.HiveTrend, error) {
+	err := store.Migrate(ctx, &model.HiveTrend{})
+	if err != nil {
+		return nil, err
+	}
+
+	windows := trendWindows()
+	records, err := loadRecordsBetween(ctx, store, date.AddDate(0, 0, -windows[len(windows)-1]), date)
+	if err != nil {
+		return nil, err
+	}
+
+	trends := computeTrends(records, date, windows)
+	if len(trends) == 0 {
+		return nil, nil
+	}
+	err = store.DB().WithContext(ctx).Clauses(trendUpsert).CreateInBatches(trends, 1000).Error
+	return trends, failure.Wrap(err)
+}
+
+// computeTrends 对每个窗口取窗口内最早一天的大小作为起点，计算平均每天的增长，库的大小为当天获取成功的表的大小之和
+func computeTrends(records []*model.Hive, date time.Time, windows []int) []*model.HiveTrend {
+	type seriesKey struct {
+		level string
+		db    string
+		table string
+	}
+
+	var (
+		keys   []seriesKey
+		series = make(map[seriesKey]map[int32]int64)
+	)
+	add := func(key seriesKey, day int32, size int64) {
+		points, ok := series[key]
+		if !ok {
+			points = make(map[int32]int64)
+			series[key] = points
+			keys = append(keys, key)
+		}
+		points[day] += size
+	}
+	for _, record := range records {
+		if record.Size < 0 {
+			continue
+		}
+		day := epochDays(record.Date)
+		add(seriesKey{level: model.LevelTable, db: record.Db, table: record.Table}, day, record.Size)
+		add(seriesKey{level: model.LevelDb, db: record.Db}, day, record.Size)
+	}
+
+	today := epochDays(date)
+	var trends []*model.HiveTrend
+	for _, key := range keys {
+		points := series[key]
+		size, ok := points[today]
+		if !ok {
+			continue
+		}
+		for _, window := range windows {
+			for day := today - int32(window); day < today; day++ {
+				base, ok := points[day]
+				if !ok {
+					continue
+				}
+				days := int(today - day)
+				trends = append(trends, &model.HiveTrend{
+					Level:    key.level,
+					Db:       key.db,
+					Table:    key.table,
+					Window:   window,
+					Days:     days,
+					BaseSize: base,
+					Size:     size,
+					Rate:     float64(size-base) / float64(days),
+					Date:     date,
+				})
+				break
+			}
+		}
+	}
+	return trends
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/model"
+	"path"
+	"sort"
+	"time"
+)
+
+const defaultUsersRoot = "/user"
+
+// userCounter 统计 /user 下每个用户 home 目录的大小和文件数量，用于推动用户自助清理
+var userCounter = &counter{
+	name:  "users",
+	usage: "统计 users.root（默认 /user）下每个用户 home 目录的大小和文件数量并保存到 hdfs_user 表",
+	model: &model.HdfsUser{},
+	keys:  []string{"user"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		client, err := newHdfsClient()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		defer client.Close()
+		defer hdfssize.CloseOnDone(ctx, client)()
+
+		records, err := countUsers(client, date)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header := []string{"user", "path", "owner", "size", "raw_size", "files", "dirs", "desc"}
+		var rows [][]interface{}
+		for _, record := range records {
+			rows = append(rows, []interface{}{record.User, record.Path, record.Owner, byteSize(record.Size), byteSize(record.RawSize), record.Files, record.Dirs, record.Desc})
+		}
+		return records, header, rows, nil
+	},
+}
+
+// countUsers 按大小降序返回每个用户的 home 目录，跳过 users.exclude 中的用户
+func countUsers(client *hdfs.Client, date time.Time) ([]*model.HdfsUser, error) {
+	root := config.Users.Root
+	if root == "" {
+		root = defaultUsersRoot
+	}
+	infos, err := client.ReadDir(root)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	exclude := make(map[string]bool)
+	for _, user := range config.Users.Exclude {
+		exclude[user] = true
+	}
+
+	var records []*model.HdfsUser
+	for _, info := range infos {
+		if !info.IsDir() || exclude[info.Name()] {
+			continue
+		}
+		record := &model.HdfsUser{User: info.Name(), Path: path.Join(root, info.Name()), Date: date}
+		if fileInfo, ok := info.(*hdfs.FileInfo); ok {
+			record.Owner = fileInfo.Owner()
+		}
+		summary, err := getPathContentSummary(client, record.Path)
+		if err != nil {
+			record.Size = -1
+			record.Desc = err.Error()
+		} else {
+			record.Size = summary.Size()
+			record.RawSize = summary.SizeAfterReplication()
+			record.Files = int64(summary.FileCount())
+			record.Dirs = int64(summary.DirectoryCount())
+		}
+		records = append(records, record)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+	return records, nil
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 校验结果
+const (
+	verifyOK       = "ok"
+	verifyMismatch = "mismatch"
+	verifyFailed   = "failed"
+)
+
+// runVerify 重新获取某一天最大的若干张表中随机抽取的表的大小，与已保存的结果比较，差异超过容差时以退出码 1 结束
+//
+// 抓取之后表仍然会写入和清理，容差需要大于两次获取之间的正常变化。
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	dateFlag := flags.String("date", "", "日期，格式为 2006-01-02，默认为最近一次抓取的日期")
+	top := flags.Int("top", 200, "从最大的多少张表中抽样")
+	sample := flags.Int("sample", 20, "抽样的表的数量")
+	tolerance := flags.Float64("tolerance", 5, "允许的差异，单位 %")
+	format := flags.String("format", formatTable, "输出格式，可选 table、csv、json")
+	flags.Parse(args)
+
+	err := loadConfig()
+	if err != nil {
+		log.Fatal("读取配置文件失败: " + err.Error())
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		log.Fatal("打开数据库失败: " + err.Error())
+	}
+	defer store.Close()
+
+	date, err := resolveDate(ctx, store, *dateFlag)
+	if err != nil {
+		log.Fatal("解析日期失败: " + err.Error())
+	}
+	records, err := loadRecords(ctx, store, date)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	candidates := verifyCandidates(records, *top, *sample, rand.New(rand.NewSource(time.Now().UnixNano())))
+	if len(candidates) == 0 {
+		log.Fatal(fmt.Sprintf("%s 没有可以校验的表", date.Format("2006-01-02")))
+	}
+
+	hdfsClient, err := newHdfsClient()
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	defer hdfsClient.Close()
+	sizes, closeSizes, err := openSizes(ctx, hdfsClient)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+	defer closeSizes()
+
+	header := []string{"db", "table", "location", "stored", "measured", "diff", "status"}
+	var (
+		rows       [][]interface{}
+		mismatches int
+	)
+	for _, record := range candidates {
+		measured := &model.Hive{Db: record.Db, Table: record.Table, Location: record.Location}
+		err := sizes.Measure(ctx, measured)
+		status, diff := verifyOK, percent(nil)
+		if err != nil {
+			status = verifyFailed
+			log.Printf("获取 %s.%s 的大小失败: %+v", record.Db, record.Table, err)
+		} else {
+			value := float64(measured.Size-record.Size) / float64(record.Size) * 100
+			diff = &value
+			if math.Abs(value) > *tolerance {
+				status = verifyMismatch
+				mismatches++
+			}
+		}
+		rows = append(rows, []interface{}{record.Db, record.Table, record.Location, byteSize(record.Size), byteSize(measured.Size), diff, status})
+	}
+	err = printRows(os.Stdout, *format, header, rows)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("%+v", err))
+	}
+
+	log.Printf("校验 %s 的 %d 张表，%d 张差异超过 %.1f%%", date.Format("2006-01-02"), len(candidates), mismatches, *tolerance)
+	if mismatches > 0 {
+		os.Exit(1)
+	}
+}
+
+// verifyCandidates 从大小最大的 top 张表中随机抽取 sample 张，只包括获取成功的 hdfs 表，结果按大小降序排列
+func verifyCandidates(records []*model.Hive, top, sample int, r *rand.Rand) []*model.Hive {
+	var candidates []*model.Hive
+	for _, record := range records {
+		if record.Size > 0 && (strings.Contains(record.Location, hdfsFlag) || config.Plugins.Sizes.Enabled()) {
+			candidates = append(candidates, record)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Size > candidates[j].Size
+	})
+	if top > 0 && len(candidates) > top {
+		candidates = candidates[:top]
+	}
+	if sample > 0 && len(candidates) > sample {
+		r.Shuffle(len(candidates), func(i, j int) {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		})
+		candidates = candidates[:sample]
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Size > candidates[j].Size
+		})
+	}
+	return candidates
+}
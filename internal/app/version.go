@@ -0,0 +1,105 @@
+package app
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"text/tabwriter"
+)
+
+// 编译时通过 -ldflags 设置，例如
+//
+//	go build -ldflags "-X github.com/rea1shane/counter/internal/app.version=v1.2.0 -X github.com/rea1shane/counter/internal/app.commit=$(git rev-parse --short HEAD) -X github.com/rea1shane/counter/internal/app.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/counter
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildDrivers version 中输出版本的客户端和驱动
+var buildDrivers = []string{
+	"github.com/beltran/gohive",
+	"github.com/colinmarc/hdfs",
+	"github.com/go-sql-driver/mysql",
+	"github.com/jackc/pgx/v5",
+	"gorm.io/gorm",
+	"gorm.io/driver/mysql",
+	"gorm.io/driver/postgres",
+	"gorm.io/driver/sqlite",
+}
+
+// buildInfo counter version 输出的版本信息
+type buildInfo struct {
+	Version   string            `json:"version"`
+	Commit    string            `json:"commit"`
+	BuildDate string            `json:"build_date"`
+	GoVersion string            `json:"go_version"`
+	Platform  string            `json:"platform"`
+	Drivers   map[string]string `json:"drivers"`
+}
+
+func readBuildInfo() *buildInfo {
+	info := &buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Drivers:   make(map[string]string),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range bi.Deps {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			for _, driver := range buildDrivers {
+				if dep.Path == driver {
+					info.Drivers[driver] = dep.Version
+				}
+			}
+		}
+	}
+	return info
+}
+
+// counterVersion 写入每一行和运行记录的 counter 版本，有 commit 时为 版本+commit，例如 v1.2.0+1a2b3c4
+func counterVersion() string {
+	if commit == "" {
+		return version
+	}
+	return version + "+" + commit
+}
+
+// runVersion 输出版本、commit、编译时间以及编译进来的 Go 和驱动的版本
+func runVersion(args []string) {
+	flags := flag.NewFlagSet("version", flag.ExitOnError)
+	format := flags.String("format", "text", "输出格式，可选 text、json")
+	flags.Parse(args)
+
+	info := readBuildInfo()
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(info)
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "version\t%s\n", info.Version)
+		fmt.Fprintf(w, "commit\t%s\n", info.Commit)
+		fmt.Fprintf(w, "build date\t%s\n", info.BuildDate)
+		fmt.Fprintf(w, "go\t%s\n", info.GoVersion)
+		fmt.Fprintf(w, "platform\t%s\n", info.Platform)
+		for _, driver := range buildDrivers {
+			if v, ok := info.Drivers[driver]; ok {
+				fmt.Fprintf(w, "%s\t%s\n", driver, v)
+			}
+		}
+		w.Flush()
+	default:
+		log.Fatal(fmt.Sprintf("不支持的输出格式: %s", *format))
+	}
+}
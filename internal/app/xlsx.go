@@ -0,0 +1,248 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/morikuni/failure"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 单元格样式，对应 xlsxStyles 中 cellXfs 的下标
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleHeader
+	xlsxStyleInteger
+	xlsxStyleDecimal
+	xlsxStyleTotal
+	xlsxStyleTotalInteger
+	xlsxStyleTotalDecimal
+)
+
+// xlsxStyles 数字格式 3、4 为内置的 #,##0 和 #,##0.00，dxf 0 为 Excel 默认的浅红填充深红文本
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="3"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill><fill><patternFill patternType="solid"><fgColor rgb="FFD9E1F2"/></patternFill></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="7">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/>
+<xf numFmtId="3" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/>
+<xf numFmtId="3" fontId="1" fillId="2" borderId="0" xfId="0" applyNumberFormat="1" applyFont="1" applyFill="1"/>
+<xf numFmtId="4" fontId="1" fillId="2" borderId="0" xfId="0" applyNumberFormat="1" applyFont="1" applyFill="1"/>
+</cellXfs>
+<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>
+<dxfs count="1"><dxf><font><color rgb="FF9C0006"/></font><fill><patternFill><bgColor rgb="FFFFC7CE"/></patternFill></fill></dxf></dxfs>
+</styleSheet>`
+
+// xlsxWorkbook 只包含导出报表需要的最小 SpreadsheetML 子集，字符串均使用 inlineStr，不生成 sharedStrings
+type xlsxWorkbook struct {
+	sheets []*xlsxSheet
+}
+
+type xlsxSheet struct {
+	name   string
+	widths []float64
+	rows   [][]xlsxCell
+	// conditionalFormats 原样写入 conditionalFormatting 节点
+	conditionalFormats []string
+}
+
+// xlsxCell value 为 string、int64 或 float64，formula 不为空时 value 作为缓存的计算结果
+type xlsxCell struct {
+	value   interface{}
+	formula string
+	style   int
+}
+
+// addSheet 工作表名称最长 31 个字符且不能包含 []:*?/\，重名时追加序号
+func (w *xlsxWorkbook) addSheet(name string, widths ...float64) *xlsxSheet {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	base := []rune(name)
+	if len(base) > 31 {
+		base = base[:31]
+	}
+	name = string(base)
+	for i := 2; w.hasSheet(name); i++ {
+		suffix := []rune("~" + strconv.Itoa(i))
+		if len(base)+len(suffix) > 31 {
+			base = base[:31-len(suffix)]
+		}
+		name = string(base) + string(suffix)
+	}
+
+	sheet := &xlsxSheet{name: name, widths: widths}
+	w.sheets = append(w.sheets, sheet)
+	return sheet
+}
+
+func (w *xlsxWorkbook) hasSheet(name string) bool {
+	for _, sheet := range w.sheets {
+		if strings.EqualFold(sheet.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *xlsxSheet) addRow(cells ...xlsxCell) {
+	s.rows = append(s.rows, cells)
+}
+
+// highlightTop 对区域内最大的 rank 个值应用 dxf 0
+func (s *xlsxSheet) highlightTop(ref string, rank int) {
+	s.conditionalFormats = append(s.conditionalFormats, fmt.Sprintf(
+		`<conditionalFormatting sqref="%s"><cfRule type="top10" dxfId="0" priority="%d" rank="%d"/></conditionalFormatting>`,
+		ref, len(s.conditionalFormats)+1, rank))
+}
+
+// dataBar 在单元格内按数值大小绘制数据条
+func (s *xlsxSheet) dataBar(ref string) {
+	s.conditionalFormats = append(s.conditionalFormats, fmt.Sprintf(
+		`<conditionalFormatting sqref="%s"><cfRule type="dataBar" priority="%d"><dataBar><cfvo type="min"/><cfvo type="max"/><color rgb="FF638EC6"/></dataBar></cfRule></conditionalFormatting>`,
+		ref, len(s.conditionalFormats)+1))
+}
+
+func (w *xlsxWorkbook) write(writer io.Writer) error {
+	archive := zip.NewWriter(writer)
+
+	var (
+		contentTypes bytes.Buffer
+		workbook     bytes.Buffer
+		rels         bytes.Buffer
+	)
+	contentTypes.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+`)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId0" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+`)
+	for i, sheet := range w.sheets {
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`+"\n", i+1)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xlsxEscape(sheet.name), i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`+"\n", i+1, i+1)
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", contentTypes.Bytes()},
+		{"_rels/.rels", []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", rels.Bytes()},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+	}
+	for i, sheet := range w.sheets {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet.xml()})
+	}
+
+	for _, file := range files {
+		fileWriter, err := archive.Create(file.name)
+		if err != nil {
+			return failure.Wrap(err)
+		}
+		_, err = fileWriter.Write(file.data)
+		if err != nil {
+			return failure.Wrap(err)
+		}
+	}
+	return failure.Wrap(archive.Close())
+}
+
+// xml 首行作为表头冻结
+func (s *xlsxSheet) xml() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	buf.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	if len(s.widths) > 0 {
+		buf.WriteString(`<cols>`)
+		for i, width := range s.widths {
+			fmt.Fprintf(&buf, `<col min="%d" max="%d" width="%g" customWidth="1"/>`, i+1, i+1, width)
+		}
+		buf.WriteString(`</cols>`)
+	}
+
+	buf.WriteString(`<sheetData>`)
+	for i, row := range s.rows {
+		fmt.Fprintf(&buf, `<row r="%d">`, i+1)
+		for j, cell := range row {
+			ref := xlsxCellRef(j, i)
+			switch value := cell.value.(type) {
+			case string:
+				if cell.formula != "" {
+					fmt.Fprintf(&buf, `<c r="%s" s="%d" t="str"><f>%s</f><v>%s</v></c>`, ref, cell.style, xlsxEscape(cell.formula), xlsxEscape(value))
+				} else {
+					fmt.Fprintf(&buf, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, cell.style, xlsxEscape(value))
+				}
+			case int64:
+				fmt.Fprintf(&buf, `<c r="%s" s="%d">%s<v>%d</v></c>`, ref, cell.style, xlsxFormula(cell.formula), value)
+			case float64:
+				fmt.Fprintf(&buf, `<c r="%s" s="%d">%s<v>%s</v></c>`, ref, cell.style, xlsxFormula(cell.formula), strconv.FormatFloat(value, 'f', -1, 64))
+			default:
+				fmt.Fprintf(&buf, `<c r="%s" s="%d"/>`, ref, cell.style)
+			}
+		}
+		buf.WriteString(`</row>`)
+	}
+	buf.WriteString(`</sheetData>`)
+
+	for _, format := range s.conditionalFormats {
+		buf.WriteString(format)
+	}
+	buf.WriteString(`</worksheet>`)
+	return buf.Bytes()
+}
+
+func xlsxFormula(formula string) string {
+	if formula == "" {
+		return ""
+	}
+	return "<f>" + xlsxEscape(formula) + "</f>"
+}
+
+// xlsxCellRef 将从 0 开始的列号、行号转换为 A1 形式
+func xlsxCellRef(col, row int) string {
+	return xlsxColumn(col) + strconv.Itoa(row+1)
+}
+
+func xlsxColumn(col int) string {
+	var name []byte
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = append([]byte{byte('A' + (col-1)%26)}, name...)
+	}
+	return string(name)
+}
+
+func xlsxEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,259 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultYarnTimeout = 30 * time.Second
+
+// yarnResources 内存单位为 MB
+type yarnResources struct {
+	Memory int64 `json:"memory"`
+	Vcores int64 `json:"vCores"`
+}
+
+// yarnQueueInfo ResourceManager /ws/v1/cluster/scheduler 返回的队列，同时包含 capacity scheduler 和 fair scheduler 的字段
+type yarnQueueInfo struct {
+	Type      string `json:"type"`
+	QueueName string `json:"queueName"`
+
+	// capacity scheduler，根队列只有 capacity、maxCapacity、usedCapacity
+	Capacity             float64       `json:"capacity"`
+	MaxCapacity          float64       `json:"maxCapacity"`
+	UsedCapacity         float64       `json:"usedCapacity"`
+	AbsoluteCapacity     float64       `json:"absoluteCapacity"`
+	AbsoluteMaxCapacity  float64       `json:"absoluteMaxCapacity"`
+	AbsoluteUsedCapacity float64       `json:"absoluteUsedCapacity"`
+	NumApplications      int           `json:"numApplications"`
+	ResourcesUsed        yarnResources `json:"resourcesUsed"`
+	Queues               *struct {
+		Queue []yarnQueueInfo `json:"queue"`
+	} `json:"queues"`
+
+	// fair scheduler，队列名为完整路径
+	RootQueue           *yarnQueueInfo `json:"rootQueue"`
+	UsedResources       yarnResources  `json:"usedResources"`
+	MaxResources        yarnResources  `json:"maxResources"`
+	SteadyFairResources yarnResources  `json:"steadyFairResources"`
+	ClusterResources    yarnResources  `json:"clusterResources"`
+	NumActiveApps       int            `json:"numActiveApps"`
+	ChildQueues         *struct {
+		Queue []yarnQueueInfo `json:"queue"`
+	} `json:"childQueues"`
+}
+
+// yarnSample 一次采样中一个队列的资源使用，百分比均为占整个集群的百分比
+type yarnSample struct {
+	queue        string
+	capacity     float64
+	maxCapacity  float64
+	usedPercent  float64
+	memory       int64
+	vcores       int64
+	applications int
+}
+
+// yarnCounter 采样 YARN 队列的容量和使用，同一天多次运行时更新当天的平均值和最大值，可以通过 cron 每隔几分钟运行一次
+var yarnCounter = &counter{
+	name:  "yarn",
+	usage: "通过 ResourceManager REST 接口采样每个 YARN 队列的容量和使用，合并到 yarn_queue 表当天的平均值和最大值",
+	model: &model.YarnQueue{},
+	keys:  []string{"queue"},
+	collect: func(ctx context.Context, date time.Time) (interface{}, []string, [][]interface{}, error) {
+		samples, err := sampleYarnQueues(ctx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		existing, err := loadYarnQueues(ctx, date)
+		if err != nil {
+			log.Println("读取当天的队列统计失败，只使用本次采样: " + err.Error())
+		}
+		records := mergeYarnSamples(existing, samples, date)
+
+		header := []string{"queue", "samples", "capacity", "max_capacity", "used", "avg_used", "max_used", "memory_mb", "vcores", "applications"}
+		var rows [][]interface{}
+		for _, sample := range samples {
+			record := records[sample.queue]
+			rows = append(rows, []interface{}{
+				sample.queue, record.Samples,
+				fmt.Sprintf("%.2f%%", sample.capacity), fmt.Sprintf("%.2f%%", sample.maxCapacity), fmt.Sprintf("%.2f%%", sample.usedPercent),
+				fmt.Sprintf("%.2f%%", record.AvgUsedPercent), fmt.Sprintf("%.2f%%", record.MaxUsedPercent),
+				sample.memory, sample.vcores, sample.applications,
+			})
+		}
+		list := make([]*model.YarnQueue, 0, len(samples))
+		for _, sample := range samples {
+			list = append(list, records[sample.queue])
+		}
+		return list, header, rows, nil
+	},
+}
+
+// sampleYarnQueues 依次尝试 yarn.resourcemanagers，standby 会重定向到 active
+func sampleYarnQueues(ctx context.Context) ([]yarnSample, error) {
+	if len(config.Yarn.Resourcemanagers) == 0 {
+		return nil, failure.Wrap(fmt.Errorf("yarn.resourcemanagers is empty"))
+	}
+	var err error
+	for _, address := range config.Yarn.Resourcemanagers {
+		var info *yarnQueueInfo
+		info, err = fetchYarnScheduler(ctx, address)
+		if err != nil {
+			log.Printf("访问 ResourceManager %s 失败: %+v", address, err)
+			continue
+		}
+		var samples []yarnSample
+		if info.RootQueue != nil {
+			walkFairQueue(info.RootQueue, &samples)
+		} else {
+			// capacity scheduler 的根队列
+			info.AbsoluteCapacity, info.AbsoluteMaxCapacity, info.AbsoluteUsedCapacity = info.Capacity, info.MaxCapacity, info.UsedCapacity
+			walkCapacityQueue(info, "", &samples)
+		}
+		return samples, nil
+	}
+	return nil, err
+}
+
+func fetchYarnScheduler(ctx context.Context, address string) (*yarnQueueInfo, error) {
+	timeout := config.Yarn.Timeout
+	if timeout <= 0 {
+		timeout = defaultYarnTimeout
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(address, "/")+"/ws/v1/cluster/scheduler", nil)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, failure.Wrap(fmt.Errorf("scheduler failed with %s", resp.Status))
+	}
+
+	var body struct {
+		Scheduler struct {
+			SchedulerInfo yarnQueueInfo `json:"schedulerInfo"`
+		} `json:"scheduler"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	return &body.Scheduler.SchedulerInfo, nil
+}
+
+// walkCapacityQueue capacity scheduler 的队列名不包含父队列，需要拼接
+func walkCapacityQueue(queue *yarnQueueInfo, parent string, samples *[]yarnSample) {
+	name := queue.QueueName
+	if parent != "" {
+		name = parent + "." + name
+	}
+	sample := yarnSample{
+		queue:        name,
+		capacity:     queue.AbsoluteCapacity,
+		maxCapacity:  queue.AbsoluteMaxCapacity,
+		usedPercent:  queue.AbsoluteUsedCapacity,
+		memory:       queue.ResourcesUsed.Memory,
+		vcores:       queue.ResourcesUsed.Vcores,
+		applications: queue.NumApplications,
+	}
+	*samples = append(*samples, sample)
+	if queue.Queues != nil {
+		for i := range queue.Queues.Queue {
+			walkCapacityQueue(&queue.Queues.Queue[i], name, samples)
+		}
+	}
+}
+
+// walkFairQueue fair scheduler 按内存计算占集群的百分比
+func walkFairQueue(queue *yarnQueueInfo, samples *[]yarnSample) {
+	percent := func(memory int64) float64 {
+		if queue.ClusterResources.Memory <= 0 {
+			return 0
+		}
+		return float64(memory) * 100 / float64(queue.ClusterResources.Memory)
+	}
+	*samples = append(*samples, yarnSample{
+		queue:        queue.QueueName,
+		capacity:     percent(queue.SteadyFairResources.Memory),
+		maxCapacity:  percent(queue.MaxResources.Memory),
+		usedPercent:  percent(queue.UsedResources.Memory),
+		memory:       queue.UsedResources.Memory,
+		vcores:       queue.UsedResources.Vcores,
+		applications: queue.NumActiveApps,
+	})
+	if queue.ChildQueues != nil {
+		for i := range queue.ChildQueues.Queue {
+			walkFairQueue(&queue.ChildQueues.Queue[i], samples)
+		}
+	}
+}
+
+// loadYarnQueues 读取当天已有的统计，没有配置数据库或者还没有建表时返回空
+func loadYarnQueues(ctx context.Context, date time.Time) ([]*model.YarnQueue, error) {
+	if !storeConfigured() {
+		return nil, nil
+	}
+	store, err := openStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	if !store.DB().WithContext(ctx).Migrator().HasTable(&model.YarnQueue{}) {
+		return nil, nil
+	}
+	var records []*model.YarnQueue
+	err = store.DB().WithContext(ctx).Where("date = ?", date).Find(&records).Error
+	return records, failure.Wrap(err)
+}
+
+// mergeYarnSamples 将本次采样合并到当天的平均值和最大值，容量使用最新的值
+func mergeYarnSamples(existing []*model.YarnQueue, samples []yarnSample, date time.Time) map[string]*model.YarnQueue {
+	records := make(map[string]*model.YarnQueue)
+	for _, record := range existing {
+		records[record.Queue] = record
+	}
+	for _, sample := range samples {
+		record, ok := records[sample.queue]
+		if !ok {
+			record = &model.YarnQueue{Queue: sample.queue, Date: date}
+			records[sample.queue] = record
+		}
+		n := float64(record.Samples)
+		record.AvgUsedPercent = (record.AvgUsedPercent*n + sample.usedPercent) / (n + 1)
+		record.AvgMemory = (record.AvgMemory*n + float64(sample.memory)) / (n + 1)
+		record.AvgVcores = (record.AvgVcores*n + float64(sample.vcores)) / (n + 1)
+		record.Samples++
+
+		record.Capacity = sample.capacity
+		record.MaxCapacity = sample.maxCapacity
+		if sample.usedPercent > record.MaxUsedPercent {
+			record.MaxUsedPercent = sample.usedPercent
+		}
+		if sample.memory > record.MaxMemory {
+			record.MaxMemory = sample.memory
+		}
+		if sample.vcores > record.MaxVcores {
+			record.MaxVcores = sample.vcores
+		}
+		if sample.applications > record.MaxApplications {
+			record.MaxApplications = sample.applications
+		}
+	}
+	return records
+}
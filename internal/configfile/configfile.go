@@ -0,0 +1,279 @@
+// Package configfile 读取 yaml 配置文件，检查已废弃和不存在的配置项，并输出隐藏了密码等敏感信息的配置
+package configfile
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/morikuni/failure"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Redacted 隐藏后的敏感配置
+const Redacted = "******"
+
+// envReference 配置文件中 ${NAME} 形式的环境变量引用
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Deprecation 已废弃的配置项，Key 和 Replacement 为以 . 分隔的路径
+type Deprecation struct {
+	Key         string
+	Replacement string
+}
+
+// File 解析后的配置文件
+type File struct {
+	// Warnings 使用了已废弃的配置项或者不存在的配置项时的警告
+	Warnings []string
+
+	root *yaml.Node
+}
+
+// Has 判断配置文件中是否设置了 key，key 为以 . 分隔的路径，值为空时视为没有设置。
+// 用于只为没有设置的配置项填充默认值，使 retry.times: 0 等零值可以生效
+func (f *File) Has(key string) bool {
+	if f == nil || f.root == nil {
+		return false
+	}
+	node := lookup(f.root, key)
+	return node != nil && node.ShortTag() != "!!null"
+}
+
+// Load 读取 path 并解析到 v，文件中的 ${NAME} 替换为环境变量的值，便于通过 Kubernetes 的 Secret 注入密码
+//
+// 类型错误时返回 error，使用了 deprecations 中的配置项或者 v 中不存在的配置项时只记录在 Warnings 中。
+func Load(path string, v interface{}, deprecations []Deprecation) (*File, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+
+	var document yaml.Node
+	err = yaml.Unmarshal(file, &document)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	if len(document.Content) == 0 {
+		return &File{}, nil
+	}
+	root := document.Content[0]
+	expandEnv(root)
+	err = root.Decode(v)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+
+	f := &File{root: root}
+	for _, deprecation := range deprecations {
+		if lookup(root, deprecation.Key) != nil {
+			f.Warnings = append(f.Warnings, fmt.Sprintf("%s 已废弃，请改用 %s", deprecation.Key, deprecation.Replacement))
+		}
+	}
+	f.Warnings = append(f.Warnings, unknownKeys(root, reflect.TypeOf(v), "")...)
+	return f, nil
+}
+
+// expandEnv 将值中的 ${NAME} 替换为环境变量的值。在解析之后替换，值中的 #、引号、冒号等不会改变文件的结构
+func expandEnv(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return
+		}
+		node.Value = envReference.ReplaceAllStringFunc(node.Value, func(reference string) string {
+			return os.Getenv(envReference.FindStringSubmatch(reference)[1])
+		})
+		// 没有引号和显式标签的值按替换后的内容重新识别类型，例如 port: ${SMTP_PORT} 仍然解析为数字
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			expandEnv(node.Content[i+1])
+		}
+	default:
+		for _, child := range node.Content {
+			expandEnv(child)
+		}
+	}
+}
+
+// lookup 按以 . 分隔的路径查找 mapping 中的值
+func lookup(node *yaml.Node, key string) *yaml.Node {
+	for _, name := range strings.Split(key, ".") {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var value *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == name {
+				value = node.Content[i+1]
+				break
+			}
+		}
+		if value == nil {
+			return nil
+		}
+		node = value
+	}
+	return node
+}
+
+var (
+	unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+	nodeType        = reflect.TypeOf(yaml.Node{})
+)
+
+// unknownKeys 对照 t 的 yaml 标签找出配置文件中不存在的配置项，自定义解析的类型不检查
+func unknownKeys(node *yaml.Node, t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nodeType || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+
+	var warnings []string
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := structFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			field, ok := fields[key]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("未知的配置项 %s", join(prefix, key)))
+				continue
+			}
+			warnings = append(warnings, unknownKeys(node.Content[i+1], field, join(prefix, key))...)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			warnings = append(warnings, unknownKeys(node.Content[i+1], t.Elem(), join(prefix, node.Content[i].Value))...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			warnings = append(warnings, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", prefix, i))...)
+		}
+	}
+	return warnings
+}
+
+// structFields yaml 中的名称到字段类型，没有标签时与 yaml.v3 一致使用小写的字段名，标记为 inline 的结构体展开其中的字段
+func structFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		options := strings.Split(field.Tag.Get("yaml"), ",")
+		if inline(options[1:]) && field.Type.Kind() == reflect.Struct {
+			for name, inlineField := range structFields(field.Type) {
+				fields[name] = inlineField
+			}
+			continue
+		}
+		name := options[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func inline(options []string) bool {
+	for _, option := range options {
+		if option == "inline" {
+			return true
+		}
+	}
+	return false
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// Marshal 将 v 输出为 yaml，secrets 中的配置项及其下的所有值替换为 Redacted，urls 中的配置项只隐藏查询参数的值和密码，
+// 例如群机器人地址中的 access_token，空值保持不变
+func Marshal(v interface{}, secrets, urls []string) ([]byte, error) {
+	var root yaml.Node
+	err := root.Encode(v)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	names := make(map[string]bool, len(secrets))
+	for _, secret := range secrets {
+		names[secret] = true
+	}
+	urlNames := make(map[string]bool, len(urls))
+	for _, name := range urls {
+		urlNames[name] = true
+	}
+	redact(&root, names, urlNames, false, false)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&root)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	return out.Bytes(), failure.Wrap(encoder.Close())
+}
+
+func redact(node *yaml.Node, secrets, urls map[string]bool, secret, isURL bool) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Value == "" || node.Tag == "!!null" {
+			return
+		}
+		if secret {
+			node.SetString(Redacted)
+		} else if isURL {
+			node.SetString(redactURL(node.Value))
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			redact(node.Content[i+1], secrets, urls, secret || secrets[key], urls[key])
+		}
+	default:
+		for _, child := range node.Content {
+			redact(child, secrets, urls, secret, isURL)
+		}
+	}
+}
+
+// redactURL 隐藏地址中的密码和查询参数的值，保留参数名便于排查，无法解析时整体隐藏
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return Redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, param := range params {
+			if name := strings.SplitN(param, "=", 2)[0]; name != param {
+				params[i] = name + "=" + Redacted
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	return u.String()
+}
@@ -0,0 +1,38 @@
+package configfile
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadExpandsEnvInValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := ioutil.WriteFile(path, []byte("password: ${TEST_PASSWORD}\nquoted: \"${TEST_PASSWORD}\"\nport: ${TEST_PORT}\nempty: ${TEST_UNSET}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := `*p#a: "s'&`
+	t.Setenv("TEST_PASSWORD", password)
+	t.Setenv("TEST_PORT", "2525")
+
+	var v struct {
+		Password string `yaml:"password"`
+		Quoted   string `yaml:"quoted"`
+		Port     int    `yaml:"port"`
+		Empty    string `yaml:"empty"`
+	}
+	file, err := Load(path, &v, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Password != password || v.Quoted != password {
+		t.Errorf("password = %q, quoted = %q, want %q", v.Password, v.Quoted, password)
+	}
+	if v.Port != 2525 {
+		t.Errorf("port = %d, want 2525", v.Port)
+	}
+	if file.Has("empty") || !file.Has("port") {
+		t.Errorf("Has(empty) = %t, Has(port) = %t, want false and true", file.Has("empty"), file.Has("port"))
+	}
+}
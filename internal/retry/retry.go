@@ -0,0 +1,118 @@
+// Package retry 重试策略、退避和可重试错误的判断，供 Hive、hdfs、对象存储和 sink 共用，保证各处的重试行为一致
+package retry
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Policy 重试策略，Times 为失败后最多重试的次数，为 0 时不重试
+type Policy struct {
+	Times    int           `yaml:"times"`
+	Interval time.Duration `yaml:"interval"`
+	// Multiplier 每次重试后间隔乘以的倍数，小于等于 1 时为固定间隔
+	Multiplier float64 `yaml:"multiplier,omitempty"`
+	// MaxInterval 间隔的上限，为 0 时不限制
+	MaxInterval time.Duration `yaml:"max_interval,omitempty"`
+	// Jitter 间隔随机浮动的比例，例如 0.2 表示在 ±20% 内浮动，避免多个实例同时重试
+	Jitter float64 `yaml:"jitter,omitempty"`
+}
+
+// Backoff 第 attempt 次重试前等待的时间，attempt 从 1 开始
+func (p Policy) Backoff(attempt int) time.Duration {
+	wait := float64(p.Interval)
+	if p.Multiplier > 1 && attempt > 1 {
+		wait *= math.Pow(p.Multiplier, float64(attempt-1))
+	}
+	if p.MaxInterval > 0 && wait > float64(p.MaxInterval) {
+		wait = float64(p.MaxInterval)
+	}
+	if p.Jitter > 0 {
+		wait *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	if wait < 0 {
+		return 0
+	}
+	return time.Duration(wait)
+}
+
+// Retryable 判断错误是否可以重试
+type Retryable func(err error) bool
+
+// Retrier 按 Policy 重试，Retryable 为 nil 时所有错误都重试，OnRetry 在每次等待前调用，可以为 nil
+type Retrier struct {
+	Policy    Policy
+	Retryable Retryable
+	OnRetry   func(attempt int, wait time.Duration, err error)
+}
+
+// Do 执行 fn，失败且可以重试时等待后重新执行，返回最后一次的错误，ctx 结束时停止等待并返回 ctx 的错误
+func (r Retrier) Do(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > r.Policy.Times || (r.Retryable != nil && !r.Retryable(err)) {
+			return err
+		}
+		wait := r.Policy.Backoff(attempt)
+		if r.OnRetry != nil {
+			r.OnRetry(attempt, wait, err)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Do 按 policy 重试所有错误
+func Do(ctx context.Context, policy Policy, fn func() error) error {
+	return Retrier{Policy: policy}.Do(ctx, fn)
+}
+
+// Any 任意一个判断返回 true 时可以重试
+func Any(retryables ...Retryable) Retryable {
+	return func(err error) bool {
+		for _, retryable := range retryables {
+			if retryable(err) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// transientMessages 不同客户端返回的临时错误中包含的信息
+var transientMessages = []string{"connection reset", "broken pipe", "connection refused", "i/o timeout", "unexpected EOF"}
+
+// Transient 网络超时、连接断开等重试后可能成功的错误，ctx 取消或超时不重试
+func Transient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	message := err.Error()
+	for _, transient := range transientMessages {
+		if strings.Contains(message, transient) {
+			return true
+		}
+	}
+	return false
+}
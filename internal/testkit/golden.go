@@ -0,0 +1,100 @@
+package testkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// goldenRecord 黄金数据中的一条记录，不包含每次运行都会变化的 ID 和 Date，失败时只记录 Failed，不记录包含调用位置的错误信息
+type goldenRecord struct {
+	Db       string      `json:"db"`
+	Table    string      `json:"table"`
+	Location string      `json:"location"`
+	Size     int64       `json:"size"`
+	Failed   bool        `json:"failed,omitempty"`
+	Extra    model.Extra `json:"extra,omitempty"`
+}
+
+// Golden 将记录按库名、表名排序后编码为 JSON Lines，作为黄金数据比较
+func Golden(records []*model.Hive) ([]byte, error) {
+	sorted := make([]goldenRecord, len(records))
+	for i, record := range records {
+		sorted[i] = goldenRecord{
+			Db:       record.Db,
+			Table:    record.Table,
+			Location: record.Location,
+			Size:     record.Size,
+			Failed:   record.Size < 0,
+			Extra:    record.Extra,
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Db != sorted[j].Db {
+			return sorted[i].Db < sorted[j].Db
+		}
+		return sorted[i].Table < sorted[j].Table
+	})
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, record := range sorted {
+		err := encoder.Encode(record)
+		if err != nil {
+			return nil, failure.Wrap(err)
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// DefaultGolden testdata/golden.jsonl，为 DefaultCluster 完整抓取一次的结果
+func DefaultGolden() []byte {
+	data, err := testdata.ReadFile("testdata/golden.jsonl")
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// CompareGolden 将记录与 path 中的黄金数据比较，不一致时返回第一处不同的行，update 为 true 时用记录覆盖 path
+func CompareGolden(path string, records []*model.Hive, update bool) error {
+	actual, err := Golden(records)
+	if err != nil {
+		return err
+	}
+	if update {
+		return failure.Wrap(ioutil.WriteFile(path, actual, 0644))
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	return Diff(expected, actual)
+}
+
+// Diff 逐行比较黄金数据，不一致时返回第一处不同的行
+func Diff(expected, actual []byte) error {
+	if bytes.Equal(expected, actual) {
+		return nil
+	}
+	expectedLines := strings.Split(strings.TrimSuffix(string(expected), "\n"), "\n")
+	actualLines := strings.Split(strings.TrimSuffix(string(actual), "\n"), "\n")
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var e, a string
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if e != a {
+			return failure.Wrap(fmt.Errorf("golden mismatch at line %d:\nexpected: %s\nactual:   %s", i+1, e, a))
+		}
+	}
+	return nil
+}
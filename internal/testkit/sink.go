@@ -0,0 +1,86 @@
+package testkit
+
+import (
+	"context"
+	"errors"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"sync"
+)
+
+// Sink 将写入的记录保存在内存中，满足 sink.Sink，通过 Faults 的 open、write、close 让对应的调用失败
+type Sink struct {
+	Faults Faults
+
+	mu      sync.Mutex
+	opened  bool
+	closed  bool
+	batches [][]*model.Hive
+}
+
+func (s *Sink) Open(ctx context.Context) error {
+	if err := s.Faults.check(Key("open")); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opened = true
+	return nil
+}
+
+// WriteBatch 失败的批次不会保存，重试成功后只保存一次
+func (s *Sink) WriteBatch(ctx context.Context, records []*model.Hive) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.Faults.check(Key("write")); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.opened || s.closed {
+		return failure.Wrap(errors.New("testkit: write to a sink that is not open"))
+	}
+	batch := make([]*model.Hive, len(records))
+	for i, record := range records {
+		copied := *record
+		batch[i] = &copied
+	}
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func (s *Sink) Close() error {
+	if err := s.Faults.check(Key("close")); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Batches 成功写入的批次
+func (s *Sink) Batches() [][]*model.Hive {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]*model.Hive(nil), s.batches...)
+}
+
+// Records 成功写入的所有记录
+func (s *Sink) Records() []*model.Hive {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []*model.Hive
+	for _, batch := range s.batches {
+		records = append(records, batch...)
+	}
+	return records
+}
+
+// Closed Open 和 Close 是否都调用过
+func (s *Sink) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opened && s.closed
+}
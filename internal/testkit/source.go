@@ -0,0 +1,94 @@
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/model"
+)
+
+// Source 从 Cluster 中列出库、表和表目录，满足 counter.MetadataSource
+type Source struct {
+	Cluster *Cluster
+	Faults  Faults
+}
+
+func NewSource(cluster *Cluster) *Source {
+	return &Source{Cluster: cluster}
+}
+
+func (s *Source) Dbs(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.Faults.check(Key("dbs")); err != nil {
+		return nil, err
+	}
+	return s.Cluster.dbNames(), nil
+}
+
+func (s *Source) Tables(ctx context.Context, db string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.Faults.check(Key("tables", db)); err != nil {
+		return nil, failure.Wrap(err, failure.Context{"op": "show tables", "db": db})
+	}
+	if _, ok := s.Cluster.Dbs[db]; !ok {
+		return nil, failure.Wrap(fmt.Errorf("database %s does not exist", db), failure.Context{"op": "show tables", "db": db})
+	}
+	return s.Cluster.tableNames(db), nil
+}
+
+func (s *Source) Location(ctx context.Context, db, table string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := s.Faults.check(Key("location", db, table)); err != nil {
+		return "", failure.Wrap(err, failure.Context{"op": "show create table", "db": db, "table": table})
+	}
+	t, ok := s.Cluster.table(db, table)
+	if !ok || t.Location == "" {
+		return "", failure.Wrap(fmt.Errorf("have no location"), failure.Context{"op": "show create table", "db": db, "table": table})
+	}
+	return t.Location, nil
+}
+
+// Sizes 从 Cluster 中获取表目录的大小，满足 counter.SizeProvider，与 hdfssize.Sizer 一样只处理 hdfs 路径
+type Sizes struct {
+	Cluster *Cluster
+	Faults  Faults
+}
+
+func NewSizes(cluster *Cluster) *Sizes {
+	return &Sizes{Cluster: cluster}
+}
+
+// Measure 失败时 Size 为 -1，Desc 为错误信息
+func (s *Sizes) Measure(ctx context.Context, table *model.Hive) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !hdfssize.IsLocation(table.Location) {
+		return nil
+	}
+	err := s.Faults.check(Key("measure", table.Db, table.Table))
+	if err == nil {
+		if _, ok := s.Cluster.table(table.Db, table.Table); !ok {
+			err = fmt.Errorf("file does not exist: %s", table.Location)
+		}
+	}
+	if err != nil {
+		err = failure.Wrap(err, failure.Context{"op": "content summary", "db": table.Db, "table": table.Table, "location": table.Location})
+		table.Size = -1
+		table.Desc = err.Error()
+		return err
+	}
+	t, _ := s.Cluster.table(table.Db, table.Table)
+	table.Size = t.Size
+	table.SetExtra(model.ExtraRawSize, t.RawSize)
+	table.SetExtra(model.ExtraFileCount, t.Files)
+	table.SetExtra(model.ExtraDirCount, t.Dirs)
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package testkit 内存中的 Hive 元数据、hdfs 大小和 sink，以及黄金数据，用于在没有集群的情况下测试抓取流程、重试和 sink
+//
+// 集群由 Cluster 描述，可以直接构造，也可以通过 LoadCluster 从 yaml 文件读取，testdata/cluster.yaml 为默认的集群。
+// Source、Sizes 和 Sink 分别满足 counter.MetadataSource、counter.SizeProvider 和 sink.Sink，
+// 通过 Faults 让指定的调用失败若干次，用于测试重试和单表失败的处理。
+package testkit
+
+import (
+	"embed"
+	"fmt"
+	"github.com/morikuni/failure"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"sort"
+	"sync"
+)
+
+//go:embed testdata
+var testdata embed.FS
+
+// Table 一张表的元数据和表目录的大小，Location 为空时表示没有表目录
+type Table struct {
+	Location string `yaml:"location"`
+	Size     int64  `yaml:"size"`
+	RawSize  int64  `yaml:"raw_size"`
+	Files    int64  `yaml:"files"`
+	Dirs     int64  `yaml:"dirs"`
+}
+
+// Cluster 内存中的集群，key 为库名和表名
+type Cluster struct {
+	Dbs map[string]map[string]Table `yaml:"dbs"`
+}
+
+// DefaultCluster testdata/cluster.yaml 中的集群，每次调用返回新的副本
+func DefaultCluster() *Cluster {
+	data, err := testdata.ReadFile("testdata/cluster.yaml")
+	if err != nil {
+		panic(err)
+	}
+	cluster, err := parseCluster(data)
+	if err != nil {
+		panic(err)
+	}
+	return cluster
+}
+
+// LoadCluster 从 yaml 文件读取集群
+func LoadCluster(path string) (*Cluster, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	return parseCluster(data)
+}
+
+func parseCluster(data []byte) (*Cluster, error) {
+	cluster := &Cluster{}
+	err := yaml.Unmarshal(data, cluster)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	return cluster, nil
+}
+
+// dbNames 按名称排序的库
+func (c *Cluster) dbNames() []string {
+	dbs := make([]string, 0, len(c.Dbs))
+	for db := range c.Dbs {
+		dbs = append(dbs, db)
+	}
+	sort.Strings(dbs)
+	return dbs
+}
+
+// tableNames 按名称排序的表
+func (c *Cluster) tableNames(db string) []string {
+	tables := make([]string, 0, len(c.Dbs[db]))
+	for table := range c.Dbs[db] {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// table 查找表，不存在时返回 false
+func (c *Cluster) table(db, table string) (Table, bool) {
+	tables, ok := c.Dbs[db]
+	if !ok {
+		return Table{}, false
+	}
+	t, ok := tables[table]
+	return t, ok
+}
+
+// Fault 让一个调用失败，Times 为失败的次数，之后的调用成功，Times 为 0 时一直失败
+type Fault struct {
+	Err   error
+	Times int
+}
+
+// Faults 按调用注入的错误，key 由 Key 生成，并发安全
+type Faults struct {
+	mu     sync.Mutex
+	faults map[string]*Fault
+	calls  map[string]int
+}
+
+// Key 调用的 key，例如 Key("tables", "ods") 和 Key("measure", "ods", "orders")
+func Key(op string, args ...string) string {
+	key := op
+	for _, arg := range args {
+		key += ":" + arg
+	}
+	return key
+}
+
+// Set 设置 key 对应的调用失败 times 次，times 为 0 时一直失败
+func (f *Faults) Set(key string, err error, times int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.faults == nil {
+		f.faults = make(map[string]*Fault)
+	}
+	f.faults[key] = &Fault{Err: err, Times: times}
+}
+
+// Calls key 对应的调用次数，包括失败的调用
+func (f *Faults) Calls(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[key]
+}
+
+// check 记录一次调用，需要失败时返回注入的错误
+func (f *Faults) check(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.calls[key]++
+	fault, ok := f.faults[key]
+	if !ok {
+		return nil
+	}
+	if fault.Times > 0 && f.calls[key] > fault.Times {
+		return nil
+	}
+	err := fault.Err
+	if err == nil {
+		err = fmt.Errorf("testkit: injected fault for %s", key)
+	}
+	return failure.Wrap(err)
+}
@@ -0,0 +1,221 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: counter.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// CounterClient is the client API for Counter service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CounterClient interface {
+	// TriggerRun 触发一次抓取，dbs 和 tables 都为空时抓取全部
+	TriggerRun(ctx context.Context, in *TriggerRunRequest, opts ...grpc.CallOption) (*Run, error)
+	// GetRunStatus 查询一次抓取的进度
+	GetRunStatus(ctx context.Context, in *GetRunStatusRequest, opts ...grpc.CallOption) (*Run, error)
+	// QueryUsage 查询某一天每张表的大小
+	QueryUsage(ctx context.Context, in *QueryUsageRequest, opts ...grpc.CallOption) (*QueryUsageResponse, error)
+	// Diff 比较两天的结果
+	Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (*DiffResponse, error)
+}
+
+type counterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCounterClient(cc grpc.ClientConnInterface) CounterClient {
+	return &counterClient{cc}
+}
+
+func (c *counterClient) TriggerRun(ctx context.Context, in *TriggerRunRequest, opts ...grpc.CallOption) (*Run, error) {
+	out := new(Run)
+	err := c.cc.Invoke(ctx, "/counter.v1.Counter/TriggerRun", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterClient) GetRunStatus(ctx context.Context, in *GetRunStatusRequest, opts ...grpc.CallOption) (*Run, error) {
+	out := new(Run)
+	err := c.cc.Invoke(ctx, "/counter.v1.Counter/GetRunStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterClient) QueryUsage(ctx context.Context, in *QueryUsageRequest, opts ...grpc.CallOption) (*QueryUsageResponse, error) {
+	out := new(QueryUsageResponse)
+	err := c.cc.Invoke(ctx, "/counter.v1.Counter/QueryUsage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterClient) Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (*DiffResponse, error) {
+	out := new(DiffResponse)
+	err := c.cc.Invoke(ctx, "/counter.v1.Counter/Diff", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CounterServer is the server API for Counter service.
+// All implementations must embed UnimplementedCounterServer
+// for forward compatibility
+type CounterServer interface {
+	// TriggerRun 触发一次抓取，dbs 和 tables 都为空时抓取全部
+	TriggerRun(context.Context, *TriggerRunRequest) (*Run, error)
+	// GetRunStatus 查询一次抓取的进度
+	GetRunStatus(context.Context, *GetRunStatusRequest) (*Run, error)
+	// QueryUsage 查询某一天每张表的大小
+	QueryUsage(context.Context, *QueryUsageRequest) (*QueryUsageResponse, error)
+	// Diff 比较两天的结果
+	Diff(context.Context, *DiffRequest) (*DiffResponse, error)
+	mustEmbedUnimplementedCounterServer()
+}
+
+// UnimplementedCounterServer must be embedded to have forward compatible implementations.
+type UnimplementedCounterServer struct {
+}
+
+func (UnimplementedCounterServer) TriggerRun(context.Context, *TriggerRunRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TriggerRun not implemented")
+}
+func (UnimplementedCounterServer) GetRunStatus(context.Context, *GetRunStatusRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRunStatus not implemented")
+}
+func (UnimplementedCounterServer) QueryUsage(context.Context, *QueryUsageRequest) (*QueryUsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryUsage not implemented")
+}
+func (UnimplementedCounterServer) Diff(context.Context, *DiffRequest) (*DiffResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Diff not implemented")
+}
+func (UnimplementedCounterServer) mustEmbedUnimplementedCounterServer() {}
+
+// UnsafeCounterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CounterServer will
+// result in compilation errors.
+type UnsafeCounterServer interface {
+	mustEmbedUnimplementedCounterServer()
+}
+
+func RegisterCounterServer(s grpc.ServiceRegistrar, srv CounterServer) {
+	s.RegisterService(&Counter_ServiceDesc, srv)
+}
+
+func _Counter_TriggerRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TriggerRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServer).TriggerRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/counter.v1.Counter/TriggerRun",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServer).TriggerRun(ctx, req.(*TriggerRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Counter_GetRunStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRunStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServer).GetRunStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/counter.v1.Counter/GetRunStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServer).GetRunStatus(ctx, req.(*GetRunStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Counter_QueryUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServer).QueryUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/counter.v1.Counter/QueryUsage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServer).QueryUsage(ctx, req.(*QueryUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Counter_Diff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServer).Diff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/counter.v1.Counter/Diff",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServer).Diff(ctx, req.(*DiffRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Counter_ServiceDesc is the grpc.ServiceDesc for Counter service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Counter_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "counter.v1.Counter",
+	HandlerType: (*CounterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "TriggerRun",
+			Handler:    _Counter_TriggerRun_Handler,
+		},
+		{
+			MethodName: "GetRunStatus",
+			Handler:    _Counter_GetRunStatus_Handler,
+		},
+		{
+			MethodName: "QueryUsage",
+			Handler:    _Counter_QueryUsage_Handler,
+		},
+		{
+			MethodName: "Diff",
+			Handler:    _Counter_Diff_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "counter.proto",
+}
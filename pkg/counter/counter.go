@@ -0,0 +1,250 @@
+// Package counter 抓取 Hive 表在 hdfs 上的大小，供其他工具直接嵌入而不必调用 counter 命令
+package counter
+
+import (
+	"context"
+	"github.com/rea1shane/counter/pkg/hdfssize"
+	"github.com/rea1shane/counter/pkg/hivemeta"
+	"github.com/rea1shane/counter/pkg/model"
+	"github.com/rea1shane/counter/pkg/sink"
+	"time"
+)
+
+// Config 一次抓取的配置，除 Hive 和 Hdfs 以外均可以为空
+type Config struct {
+	Hive hivemeta.Config
+	Hdfs hdfssize.Config
+	// Source 不为空时代替 Hive 获取元数据
+	Source MetadataSource
+	// Sizes 不为空时代替 Hdfs 获取大小
+	Sizes SizeProvider
+	// SkipDb 返回 true 时跳过整个库
+	SkipDb func(db string) bool
+	// SkipTable 返回 true 时跳过该表
+	SkipTable func(db, table string) bool
+	// Progress 获取大小时报告进度
+	Progress func(done, total int)
+	// Hooks 各个阶段的回调，可以为 nil
+	Hooks *Hooks
+	// Sink 不为空时将结果写入 sink，Run 负责 Open 和 Close
+	Sink sink.Sink
+}
+
+// Report 一次抓取的结果
+type Report struct {
+	Date   time.Time
+	Start  time.Time
+	End    time.Time
+	Tables []*model.Hive
+	// Failed 获取路径或者大小失败的表的数量，失败的表 Size 为 -1，Desc 为错误信息
+	Failed int
+}
+
+// Run 抓取所有 Hive 表的大小，单张表失败不会中断抓取，只记录在 Report 中
+func Run(ctx context.Context, cfg *Config) (*Report, error) {
+	now := time.Now()
+	report := &Report{
+		Date:  time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()),
+		Start: now,
+	}
+
+	source := cfg.Source
+	if source == nil {
+		connection, err := hivemeta.Connect(cfg.Hive)
+		if err != nil {
+			return nil, err
+		}
+		defer connection.Close()
+		cursor := connection.Cursor()
+		defer cursor.Close()
+		source = hivemeta.NewSource(cursor)
+	}
+
+	sizes := cfg.Sizes
+	if sizes == nil {
+		client, err := cfg.Hdfs.NewClient()
+		if err != nil {
+			return nil, err
+		}
+		defer client.Close()
+		defer hdfssize.CloseOnDone(ctx, client)()
+		sizes = cfg.Hdfs.NewSizer(client)
+	}
+
+	var err error
+	report.Tables, err = Fetch(ctx, source, cfg.SkipDb, cfg.SkipTable, cfg.Hooks)
+	if err != nil {
+		return nil, err
+	}
+	err = Measure(ctx, sizes, report.Tables, cfg.Progress, cfg.Hooks)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range report.Tables {
+		if table.Size < 0 && !table.Skipped() {
+			report.Failed++
+		}
+		table.Date = report.Date
+	}
+	if cfg.Progress != nil {
+		cfg.Progress(len(report.Tables), len(report.Tables))
+	}
+
+	if cfg.Sink != nil {
+		err = cfg.Sink.Open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		err = cfg.Sink.WriteBatch(ctx, report.Tables)
+		closeErr := cfg.Sink.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report.End = time.Now()
+	return report, nil
+}
+
+// Hooks 抓取过程中的回调，用于记录耗时、trace，以及根据表属性补充或过滤结果，均可以为 nil
+type Hooks struct {
+	// Db 开始在 stage 中处理一个库时调用，返回的函数在该库处理完或者出错时调用
+	Db func(stage Stage, db string) func(err error)
+	// Query 每次查询元数据前调用，列出库时 db 和 table 为空，列出表时 table 为空，返回的函数在查询结束后调用
+	Query func(db, table string) func(err error)
+	// Wait 处理每张表之前调用，返回错误时中断抓取，例如等待业务低峰
+	Wait func(ctx context.Context) error
+	// Table 获取表目录和表属性后调用，可以补充 table，返回 false 时不写入结果，获取失败的表不调用
+	Table func(table *model.Hive, t *hivemeta.Table) bool
+	// Measure 获取一张表的大小前调用，返回 nil 时跳过该表，否则返回的函数在获取大小后调用，可以继续补充 table
+	Measure func(table *model.Hive) func(err error)
+}
+
+// Stage 抓取的阶段
+type Stage string
+
+const (
+	StageFetch   Stage = "fetch"
+	StageMeasure Stage = "measure"
+)
+
+func (h *Hooks) db(stage Stage, db string) func(error) {
+	if h == nil || h.Db == nil {
+		return func(error) {}
+	}
+	return h.Db(stage, db)
+}
+
+func (h *Hooks) query(db, table string) func(error) {
+	if h == nil || h.Query == nil {
+		return func(error) {}
+	}
+	return h.Query(db, table)
+}
+
+func (h *Hooks) wait(ctx context.Context) error {
+	if h == nil || h.Wait == nil {
+		return ctx.Err()
+	}
+	return h.Wait(ctx)
+}
+
+// Fetch 列出所有库和表并获取表目录和表属性，skipDb、skipTable 和 hooks 可以为 nil，获取目录失败的表 Size 为 -1，Desc 为错误信息
+func Fetch(ctx context.Context, source MetadataSource, skipDb func(db string) bool, skipTable func(db, table string) bool, hooks *Hooks) ([]*model.Hive, error) {
+	end := hooks.query("", "")
+	dbs, err := source.Dbs(ctx)
+	end(err)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []*model.Hive
+	for _, db := range dbs {
+		if skipDb != nil && skipDb(db) {
+			continue
+		}
+		endDb := hooks.db(StageFetch, db)
+		end = hooks.query(db, "")
+		names, err := source.Tables(ctx, db)
+		end(err)
+		if err != nil {
+			endDb(err)
+			return nil, err
+		}
+		for _, name := range names {
+			if skipTable != nil && skipTable(db, name) {
+				continue
+			}
+			if err = hooks.wait(ctx); err != nil {
+				endDb(err)
+				return nil, err
+			}
+			start := time.Now()
+			end = hooks.query(db, name)
+			t, err := Describe(ctx, source, db, name)
+			end(err)
+			table := &model.Hive{Db: db, Table: name, MetadataMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				table.Size = -1
+				table.Desc = err.Error()
+				tables = append(tables, table)
+				continue
+			}
+			table.Location = t.Location
+			if hooks != nil && hooks.Table != nil && !hooks.Table(table, t) {
+				continue
+			}
+			tables = append(tables, table)
+		}
+		endDb(nil)
+	}
+	return tables, nil
+}
+
+// Measure 按顺序获取 Size 为 0 的表的大小，Skipped 的表不获取，Size 设为 -1，progress 和 hooks 可以为 nil。
+// 单张表失败不会中断，只有 ctx 取消或者 Hooks.Wait 返回错误时返回错误
+func Measure(ctx context.Context, sizes SizeProvider, tables []*model.Hive, progress func(done, total int), hooks *Hooks) error {
+	var endDb func(error)
+	for i, table := range tables {
+		if progress != nil {
+			progress(i, len(tables))
+		}
+		if err := hooks.wait(ctx); err != nil {
+			if endDb != nil {
+				endDb(err)
+			}
+			return err
+		}
+		// tables 按库的顺序排列
+		if i == 0 || tables[i-1].Db != table.Db {
+			if endDb != nil {
+				endDb(nil)
+			}
+			endDb = hooks.db(StageMeasure, table.Db)
+		}
+		if table.Skipped() {
+			// 没有获取大小，不能写入 0，否则汇总和比较时会被当作空表
+			table.Size = -1
+			continue
+		}
+		if table.Size != 0 {
+			continue
+		}
+		end := func(error) {}
+		if hooks != nil && hooks.Measure != nil {
+			end = hooks.Measure(table)
+			if end == nil {
+				continue
+			}
+		}
+		// 单张表失败时 Measure 已经记录在 table 中
+		end(sizes.Measure(ctx, table))
+	}
+	if endDb != nil {
+		endDb(nil)
+	}
+	return nil
+}
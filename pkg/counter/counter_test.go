@@ -0,0 +1,184 @@
+package counter_test
+
+import (
+	"context"
+	"errors"
+	"github.com/rea1shane/counter/internal/testkit"
+	"github.com/rea1shane/counter/pkg/counter"
+	"github.com/rea1shane/counter/pkg/hivemeta"
+	"github.com/rea1shane/counter/pkg/model"
+	"testing"
+)
+
+func newConfig(cluster *testkit.Cluster) (*counter.Config, *testkit.Source, *testkit.Sizes, *testkit.Sink) {
+	source := testkit.NewSource(cluster)
+	sizes := testkit.NewSizes(cluster)
+	sink := &testkit.Sink{}
+	return &counter.Config{Source: source, Sizes: sizes, Sink: sink}, source, sizes, sink
+}
+
+func find(tables []*model.Hive, db, table string) *model.Hive {
+	for _, t := range tables {
+		if t.Db == db && t.Table == table {
+			return t
+		}
+	}
+	return nil
+}
+
+func TestRunGolden(t *testing.T) {
+	cfg, _, _, sink := newConfig(testkit.DefaultCluster())
+	report, err := counter.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := testkit.Golden(report.Tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = testkit.Diff(testkit.DefaultGolden(), actual); err != nil {
+		t.Fatal(err)
+	}
+	written, err := testkit.Golden(sink.Records())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = testkit.Diff(actual, written); err != nil {
+		t.Fatal(err)
+	}
+	if !sink.Closed() {
+		t.Error("sink is not closed")
+	}
+	if len(sink.Batches()) != 1 {
+		t.Errorf("batches = %d, want 1", len(sink.Batches()))
+	}
+	// ods.v_orders 没有表目录
+	if report.Failed != 1 {
+		t.Errorf("failed = %d, want 1", report.Failed)
+	}
+	for _, table := range report.Tables {
+		if !table.Date.Equal(report.Date) {
+			t.Errorf("%s.%s date = %s, want %s", table.Db, table.Table, table.Date, report.Date)
+		}
+	}
+}
+
+func TestRunTableFailure(t *testing.T) {
+	cfg, source, sizes, _ := newConfig(testkit.DefaultCluster())
+	source.Faults.Set(testkit.Key("location", "dw", "sales_daily"), nil, 0)
+	sizes.Faults.Set(testkit.Key("measure", "ods", "orders"), nil, 0)
+
+	report, err := counter.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Failed != 3 {
+		t.Errorf("failed = %d, want 3", report.Failed)
+	}
+	for _, name := range [][2]string{{"dw", "sales_daily"}, {"ods", "orders"}} {
+		table := find(report.Tables, name[0], name[1])
+		if table == nil || table.Size != -1 || table.Desc == "" {
+			t.Errorf("%s.%s = %+v, want failed with desc", name[0], name[1], table)
+		}
+	}
+	if users := find(report.Tables, "ods", "users"); users == nil || users.Size != 52428800 {
+		t.Errorf("ods.users = %+v, want measured", users)
+	}
+	// 获取目录失败的表不获取大小
+	if calls := sizes.Faults.Calls(testkit.Key("measure", "dw", "sales_daily")); calls != 0 {
+		t.Errorf("measure dw.sales_daily called %d times, want 0", calls)
+	}
+}
+
+func TestRunAbortsOnTablesError(t *testing.T) {
+	cfg, source, _, sink := newConfig(testkit.DefaultCluster())
+	injected := errors.New("metastore unavailable")
+	source.Faults.Set(testkit.Key("tables", "ods"), injected, 0)
+
+	_, err := counter.Run(context.Background(), cfg)
+	if !errors.Is(err, injected) {
+		t.Fatalf("err = %v, want %v", err, injected)
+	}
+	if len(sink.Records()) != 0 {
+		t.Errorf("records = %d, want 0", len(sink.Records()))
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	cfg, _, sizes, _ := newConfig(testkit.DefaultCluster())
+	var (
+		dbs     = map[counter.Stage][]string{}
+		queries int
+		ended   int
+	)
+	cfg.SkipDb = func(db string) bool {
+		return db == "tmp"
+	}
+	cfg.Hooks = &counter.Hooks{
+		Db: func(stage counter.Stage, db string) func(error) {
+			dbs[stage] = append(dbs[stage], db)
+			return func(error) { ended++ }
+		},
+		Query: func(db, table string) func(error) {
+			queries++
+			return func(error) {}
+		},
+		Table: func(table *model.Hive, _ *hivemeta.Table) bool {
+			switch table.Table {
+			case "users":
+				return false
+			case "sales_daily":
+				table.SetExtra(model.ExtraSkipped, true)
+			}
+			return true
+		},
+		Measure: func(table *model.Hive) func(error) {
+			if table.Table == "ext_logs" {
+				return nil
+			}
+			return func(error) {}
+		},
+	}
+
+	report, err := counter.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if find(report.Tables, "ods", "users") != nil {
+		t.Error("ods.users is not excluded by the Table hook")
+	}
+	if sales := find(report.Tables, "dw", "sales_daily"); sales == nil || sales.Size != -1 || !sales.Skipped() {
+		t.Errorf("dw.sales_daily = %+v, want skipped without size", sales)
+	}
+	if calls := sizes.Faults.Calls(testkit.Key("measure", "dw", "sales_daily")); calls != 0 {
+		t.Errorf("measure skipped table called %d times, want 0", calls)
+	}
+	if orders := find(report.Tables, "ods", "orders"); orders == nil || orders.Size != 1073741824 {
+		t.Errorf("ods.orders = %+v, want measured", orders)
+	}
+	// dbs、dw 和 ods 的 tables、5 张表的目录
+	if queries != 8 {
+		t.Errorf("queries = %d, want 8", queries)
+	}
+	if len(dbs[counter.StageFetch]) != 2 || len(dbs[counter.StageMeasure]) != 2 || ended != 4 {
+		t.Errorf("dbs = %v, ended = %d, want dw and ods in both stages", dbs, ended)
+	}
+}
+
+func TestMeasureWaitError(t *testing.T) {
+	cluster := testkit.DefaultCluster()
+	tables, err := counter.Fetch(context.Background(), testkit.NewSource(cluster), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := errors.New("stop")
+	err = counter.Measure(context.Background(), testkit.NewSizes(cluster), tables, nil, &counter.Hooks{
+		Wait: func(context.Context) error {
+			return stop
+		},
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("err = %v, want %v", err, stop)
+	}
+}
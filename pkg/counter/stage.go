@@ -0,0 +1,40 @@
+package counter
+
+import (
+	"context"
+	"github.com/rea1shane/counter/pkg/hivemeta"
+	"github.com/rea1shane/counter/pkg/model"
+)
+
+// MetadataSource 抓取的第一步，列出库、表和表目录，默认实现为 hivemeta.Source
+type MetadataSource interface {
+	Dbs(ctx context.Context) ([]string, error)
+	Tables(ctx context.Context, db string) ([]string, error)
+	Location(ctx context.Context, db, table string) (string, error)
+}
+
+// TableDescriber 除表目录外还可以获取表属性的 MetadataSource，hivemeta.Source 满足该接口
+type TableDescriber interface {
+	Table(ctx context.Context, db, table string) (*hivemeta.Table, error)
+}
+
+// Describe 获取表目录和表属性，source 不满足 TableDescriber 时只获取表目录，Properties 为空
+func Describe(ctx context.Context, source MetadataSource, db, table string) (*hivemeta.Table, error) {
+	if describer, ok := source.(TableDescriber); ok {
+		return describer.Table(ctx, db, table)
+	}
+	location, err := source.Location(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	return &hivemeta.Table{Location: location}, nil
+}
+
+// SizeProvider 抓取的第二步，获取表目录的大小，默认实现为 hdfssize.Sizer
+//
+// 失败时设置 Size 为 -1、Desc 为错误信息并返回错误，不支持的 location 应保持 Size 为 0 并返回 nil。
+type SizeProvider interface {
+	Measure(ctx context.Context, table *model.Hive) error
+}
+
+// 抓取的第三步为写入 sink.Sink，已有 MySQL、PostgreSQL、SQLite 的实现 sink.Gorm
@@ -0,0 +1,132 @@
+package hdfssize
+
+import (
+	"context"
+	"github.com/colinmarc/hdfs"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Flag hdfs 路径的 scheme
+const Flag = "hdfs://"
+
+// Config 连接 hdfs 的配置
+type Config struct {
+	// ConfDir hadoop 配置目录，为空时使用 HADOOP_CONF_DIR 等环境变量
+	ConfDir string
+	User    string
+	Router  Router
+}
+
+// Addresses 开启 Router-Based Federation 时返回 router 的地址，否则返回 hadoop 配置中的 NameNode
+func (c *Config) Addresses() ([]string, error) {
+	hadoopConf := hdfs.LoadHadoopConf(c.ConfDir)
+	if c.Router.Enabled {
+		namenodes, err := c.Router.addresses(hadoopConf)
+		return namenodes, failure.Wrap(err, failure.Context{"op": "resolve router"})
+	}
+	namenodes, err := hadoopConf.Namenodes()
+	return namenodes, failure.Wrap(err, failure.Context{"op": "resolve namenodes", "path": c.ConfDir})
+}
+
+// DefaultReplication hadoop 配置中的 dfs.replication，没有配置时为 HDFS 的默认值 3
+func (c *Config) DefaultReplication() int {
+	replication, err := strconv.Atoi(hdfs.LoadHadoopConf(c.ConfDir)["dfs.replication"])
+	if err != nil || replication <= 0 {
+		return 3
+	}
+	return replication
+}
+
+// NewClient 开启 Router-Based Federation 时连接 router，否则连接 hadoop 配置中的 NameNode
+func (c *Config) NewClient() (*hdfs.Client, error) {
+	namenodes, err := c.Addresses()
+	if err != nil {
+		return nil, err
+	}
+	client, err := hdfs.NewClient(hdfs.ClientOptions{
+		Addresses: namenodes,
+		User:      c.User,
+	})
+	return client, failure.Wrap(err, failure.Context{"op": "connect", "address": strings.Join(namenodes, ",")})
+}
+
+// Path 将 location 转换为 hdfs 客户端可以直接访问的路径
+func (c *Config) Path(location string) (string, error) {
+	nameservice, path := ParseLocation(location)
+	if c.Router.Enabled {
+		path, err := c.Router.resolveMountPoint(nameservice, path)
+		return path, failure.Wrap(err, failure.Context{"op": "resolve mount point", "location": location})
+	}
+	return path, nil
+}
+
+// ContentSummary 获取 hdfs 路径的 content summary，开启 router 时失败会重试
+func (c *Config) ContentSummary(client *hdfs.Client, path string) (summary *hdfs.ContentSummary, err error) {
+	if c.Router.Enabled {
+		summary, err = c.Router.contentSummary(client, path)
+	} else {
+		summary, err = client.GetContentSummary(path)
+	}
+	if err != nil {
+		err = failure.Wrap(err, failure.Context{"op": "content summary", "path": path})
+		return
+	}
+	return
+}
+
+// LocationSummary 获取 location 的逻辑大小、包含副本的实际占用空间和文件数量
+func (c *Config) LocationSummary(client *hdfs.Client, location string) (*hdfs.ContentSummary, error) {
+	path, err := c.Path(location)
+	if err != nil {
+		return nil, err
+	}
+	return c.ContentSummary(client, path)
+}
+
+// Measure 获取表目录的逻辑大小、包含副本的实际占用空间、文件和目录数量，失败时 Size 为 -1，Desc 为错误信息
+func (c *Config) Measure(client *hdfs.Client, table *model.Hive) error {
+	summary, err := c.LocationSummary(client, table.Location)
+	if err != nil {
+		err = failure.Wrap(err, failure.Context{"db": table.Db, "table": table.Table})
+		table.Size = -1
+		table.Desc = err.Error()
+		return err
+	}
+	table.Size = summary.Size()
+	table.SetExtra(model.ExtraRawSize, summary.SizeAfterReplication())
+	table.SetExtra(model.ExtraFileCount, summary.FileCount())
+	table.SetExtra(model.ExtraDirCount, summary.DirectoryCount())
+	return nil
+}
+
+// IsLocation location 是否为 hdfs 路径
+func IsLocation(location string) bool {
+	return strings.Contains(location, Flag)
+}
+
+// ParseLocation 将 location 解析为 hdfs 集群名称和路径
+func ParseLocation(location string) (nameservice, path string) {
+	parts := strings.SplitN(strings.Split(location, Flag)[1], "/", 2)
+	return parts[0], "/" + parts[1] + "/"
+}
+
+// CloseOnDone ctx 结束时关闭 client，hdfs 客户端不支持 context，关闭后正在进行的请求会立即返回错误。
+// 返回的 stop 用于在正常结束时停止等待，需要在 client 关闭前调用，通常为 defer CloseOnDone(ctx, client)()
+func CloseOnDone(ctx context.Context, client *hdfs.Client) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.Close()
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,136 @@
+package hdfssize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/colinmarc/hdfs"
+	"github.com/colinmarc/hdfs/rpc"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/internal/retry"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MountPoint RBF 挂载表中的一条记录，将下游集群的路径挂载到 Router 的全局命名空间中
+type MountPoint struct {
+	Mount       string `yaml:"mount"`
+	Nameservice string `yaml:"nameservice"`
+	Path        string `yaml:"path"`
+}
+
+// Router Router-Based Federation 的配置，未开启时直接连接 NameNode
+type Router struct {
+	Enabled     bool         `yaml:"enabled"`
+	Nameservice string       `yaml:"nameservice"`
+	Addresses   []string     `yaml:"addresses"`
+	Retry       retry.Policy `yaml:"retry"`
+	MountTable  []MountPoint `yaml:"mount_table"`
+	// OnRetry 每次重试前调用，attempt 从 1 开始
+	OnRetry func(path string, attempt int, err error) `yaml:"-"`
+}
+
+const (
+	defaultRouterRetryTimes    = 3
+	defaultRouterRetryInterval = 10 * time.Second
+)
+
+// routerRetryableExceptions Router 故障切换期间会返回的异常，出现时等待后重试
+var routerRetryableExceptions = []string{
+	"org.apache.hadoop.ipc.StandbyException",
+	"org.apache.hadoop.ipc.RetriableException",
+	"org.apache.hadoop.hdfs.server.federation.router.RouterSafeModeException",
+}
+
+// addresses 获取 Router 的 RPC 地址，未直接配置时从 hadoop 配置中按 nameservice 解析
+func (router *Router) addresses(hadoopConf hdfs.HadoopConf) ([]string, error) {
+	if len(router.Addresses) > 0 {
+		return router.Addresses, nil
+	}
+	if router.Nameservice == "" {
+		return nil, failure.Wrap(errors.New("router addresses and nameservice are both empty"))
+	}
+
+	var addresses []string
+	for _, id := range strings.Split(hadoopConf["dfs.ha.namenodes."+router.Nameservice], ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if address, ok := hadoopConf["dfs.namenode.rpc-address."+router.Nameservice+"."+id]; ok {
+			addresses = append(addresses, address)
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, failure.Wrap(fmt.Errorf("no router address for nameservice %s", router.Nameservice))
+	}
+	return addresses, nil
+}
+
+// resolveMountPoint 将下游集群上的路径转换为 Router 命名空间中的路径
+func (router *Router) resolveMountPoint(nameservice, path string) (string, error) {
+	if nameservice == "" || nameservice == router.Nameservice {
+		return path, nil
+	}
+
+	mountTable := make([]MountPoint, len(router.MountTable))
+	copy(mountTable, router.MountTable)
+	// 最长前缀优先匹配
+	sort.Slice(mountTable, func(i, j int) bool {
+		return len(mountTable[i].Path) > len(mountTable[j].Path)
+	})
+
+	trimmed := strings.TrimSuffix(path, "/")
+	for _, mp := range mountTable {
+		if mp.Nameservice != nameservice {
+			continue
+		}
+		prefix := strings.TrimSuffix(mp.Path, "/")
+		if trimmed != prefix && !strings.HasPrefix(trimmed, prefix+"/") {
+			continue
+		}
+		return strings.TrimSuffix(mp.Mount, "/") + strings.TrimPrefix(trimmed, prefix) + "/", nil
+	}
+
+	return "", failure.Wrap(fmt.Errorf("no mount point for hdfs://%s%s", nameservice, path))
+}
+
+// isRouterRetryable 判断错误是否由 Router 故障切换引起
+func isRouterRetryable(err error) bool {
+	var nnErr *rpc.NamenodeError
+	if errors.As(err, &nnErr) {
+		for _, exception := range routerRetryableExceptions {
+			if nnErr.Exception == exception {
+				return true
+			}
+		}
+		return false
+	}
+	// 所有 Router 都处于退避期时 hdfs 客户端返回的错误
+	return strings.Contains(err.Error(), "no available namenodes")
+}
+
+// contentSummary 通过 Router 获取路径的 ContentSummary，Router 切换期间会进行重试
+func (router *Router) contentSummary(client *hdfs.Client, path string) (summary *hdfs.ContentSummary, err error) {
+	policy := router.Retry
+	if policy.Times <= 0 {
+		policy.Times = defaultRouterRetryTimes
+	}
+	if policy.Interval <= 0 {
+		policy.Interval = defaultRouterRetryInterval
+	}
+
+	retrier := retry.Retrier{Policy: policy, Retryable: isRouterRetryable}
+	if router.OnRetry != nil {
+		retrier.OnRetry = func(attempt int, _ time.Duration, err error) {
+			router.OnRetry(path, attempt, err)
+		}
+	}
+	// hdfs 客户端不支持 context，ctx 结束时由 CloseOnDone 关闭客户端
+	err = retrier.Do(context.Background(), func() error {
+		summary, err = client.GetContentSummary(path)
+		return err
+	})
+	return
+}
@@ -0,0 +1,28 @@
+package hdfssize
+
+import (
+	"context"
+	"github.com/colinmarc/hdfs"
+	"github.com/rea1shane/counter/pkg/model"
+)
+
+// Sizer 通过 hdfs 客户端获取表目录的大小，client 由调用方关闭
+type Sizer struct {
+	config *Config
+	client *hdfs.Client
+}
+
+func (c *Config) NewSizer(client *hdfs.Client) *Sizer {
+	return &Sizer{config: c, client: client}
+}
+
+// Measure 只处理 hdfs 上的表目录，其他 location 保持不变
+func (s *Sizer) Measure(ctx context.Context, table *model.Hive) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !IsLocation(table.Location) {
+		return nil
+	}
+	return s.config.Measure(s.client, table)
+}
@@ -0,0 +1,172 @@
+package hivemeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/beltran/gohive"
+	"github.com/morikuni/failure"
+	"strconv"
+	"strings"
+)
+
+// Dialect HiveServer2 兼容服务的 SHOW 和 DESCRIBE 输出格式，不同版本和发行版的差异集中在这里：
+//
+//	Hive 1.x/2.x/3.x  SHOW TABLES 只有表名一列，DESCRIBE DATABASE 为一行多列，Hive 4 增加 managedlocation
+//	Spark 2.x/3.x     Spark Thrift Server 和 Kyuubi，SHOW TABLES 为 database、tableName、isTemporary 三列，
+//	                  DESCRIBE DATABASE 为多行键值对，SHOW CREATE TABLE 整条语句在一行中，3.x 需要 AS SERDE 才输出 Hive 建表语句
+type Dialect struct {
+	// Name 为 hive 或 spark
+	Name string
+	// Major 服务端的主版本号，没有 version 函数（Hive 1.x、Spark 2.x）时为 0
+	Major int
+}
+
+// 方言名称
+const (
+	DialectHive  = "hive"
+	DialectSpark = "spark"
+)
+
+// Hive 没有指定或者无法识别方言时的默认值
+var Hive = Dialect{Name: DialectHive}
+
+func (d Dialect) String() string {
+	if d.Major == 0 {
+		return d.Name
+	}
+	return d.Name + strconv.Itoa(d.Major)
+}
+
+// ParseDialect 解析 hive1、hive2、hive3、spark2、spark3 等形式的方言，不带版本号时主版本号为 0
+func ParseDialect(s string) (Dialect, error) {
+	for _, name := range []string{DialectHive, DialectSpark} {
+		if !strings.HasPrefix(s, name) {
+			continue
+		}
+		d := Dialect{Name: name}
+		if version := strings.TrimPrefix(s, name); version != "" {
+			major, err := strconv.Atoi(version)
+			if err != nil {
+				return Dialect{}, failure.Wrap(fmt.Errorf("invalid dialect version: %s", s))
+			}
+			d.Major = major
+		}
+		return d, nil
+	}
+	return Dialect{}, failure.Wrap(fmt.Errorf("unknown dialect: %s", s))
+}
+
+// DetectDialect 通过 SHOW DATABASES 的列名区分 Hive 和 Spark，再通过 SELECT version() 获取主版本号，
+// 只有 Hive 2.1 和 Spark 3.0 之后才有 version 函数，调用失败时主版本号为 0
+func DetectDialect(ctx context.Context, cursor *gohive.Cursor) (Dialect, error) {
+	cursor.Exec(ctx, "SHOW DATABASES")
+	if cursor.Err != nil {
+		return Dialect{}, failure.Wrap(cursor.Err, failure.Context{"op": "detect dialect"})
+	}
+	description := cursor.Description()
+	if cursor.Err != nil {
+		return Dialect{}, failure.Wrap(cursor.Err, failure.Context{"op": "detect dialect"})
+	}
+	d := Hive
+	// Hive 的列名为 database_name，Spark 2.x 为 databaseName，Spark 3.x 为 namespace
+	if len(description) > 0 && description[0][0] != "database_name" {
+		d.Name = DialectSpark
+	}
+
+	var version string
+	cursor.Exec(ctx, "SELECT version()")
+	if cursor.Err == nil && cursor.HasMore(ctx) {
+		cursor.FetchOne(ctx, &version)
+	}
+	if cursor.Err != nil {
+		// 没有 version 函数不影响使用
+		cursor.Err = nil
+		return d, nil
+	}
+	d.Major, _ = strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	return d, nil
+}
+
+// showCreateTable SHOW CREATE TABLE 语句，Spark 3.x 的 Hive 表需要 AS SERDE
+func (d Dialect) showCreateTable(db, table string) string {
+	if d.Name == DialectSpark && d.Major >= 3 {
+		return "SHOW CREATE TABLE " + db + "." + table + " AS SERDE"
+	}
+	return "SHOW CREATE TABLE " + db + "." + table
+}
+
+// NotPartitioned err 是否为对非分区表执行 SHOW PARTITIONS 的错误
+func (d Dialect) NotPartitioned(err error) bool {
+	if d.Name == DialectSpark {
+		return strings.Contains(err.Error(), "not allowed on a table that is not partitioned")
+	}
+	return strings.Contains(err.Error(), "is not a partitioned table")
+}
+
+// ListTables 列出库中的所有表，不切换 cursor 的当前库，所有语句都使用 db.table 的完整表名。
+// Spark 的结果中包括会话级的临时视图，跳过
+func (d Dialect) ListTables(ctx context.Context, cursor *gohive.Cursor, db string) (tables []string, err error) {
+	cursor.Exec(ctx, "SHOW TABLES IN "+db)
+	if cursor.Err != nil {
+		err = failure.Wrap(cursor.Err, failure.Context{"op": "show tables", "db": db})
+		return
+	}
+
+	var (
+		database, table string
+		temporary       bool
+	)
+	for cursor.HasMore(ctx) {
+		if d.Name == DialectSpark {
+			cursor.FetchOne(ctx, &database, &table, &temporary)
+		} else {
+			cursor.FetchOne(ctx, &table)
+		}
+		if cursor.Err != nil {
+			err = failure.Wrap(cursor.Err, failure.Context{"op": "show tables", "db": db, "dialect": d.String()})
+			return
+		}
+		if temporary {
+			continue
+		}
+		tables = append(tables, table)
+	}
+
+	return
+}
+
+// DbLocation 通过 DESCRIBE DATABASE 获取库目录，Hive 4 的 managedlocation 为托管表的目录，不是库目录
+func (d Dialect) DbLocation(ctx context.Context, cursor *gohive.Cursor, db string) (string, error) {
+	errContext := failure.Context{"op": "describe database", "db": db, "dialect": d.String()}
+	cursor.Exec(ctx, "DESCRIBE DATABASE "+db)
+	if cursor.Err != nil {
+		return "", failure.Wrap(cursor.Err, errContext)
+	}
+
+	if d.Name == DialectSpark {
+		var name, value string
+		for cursor.HasMore(ctx) {
+			cursor.FetchOne(ctx, &name, &value)
+			if cursor.Err != nil {
+				return "", failure.Wrap(cursor.Err, errContext)
+			}
+			if strings.EqualFold(strings.TrimSpace(name), "location") {
+				return strings.TrimSpace(value), nil
+			}
+		}
+		return "", failure.Wrap(errors.New("no location"), errContext)
+	}
+
+	row := cursor.RowMap(ctx)
+	if cursor.Err != nil {
+		return "", failure.Wrap(cursor.Err, errContext)
+	}
+	for column, value := range row {
+		if strings.HasSuffix(column, "location") && !strings.Contains(column, "managed") {
+			location, _ := value.(string)
+			return location, nil
+		}
+	}
+	return "", failure.Wrap(errors.New("no location"), errContext)
+}
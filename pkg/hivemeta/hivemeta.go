@@ -0,0 +1,235 @@
+package hivemeta
+
+import (
+	"context"
+	"errors"
+	"github.com/beltran/gohive"
+	"github.com/morikuni/failure"
+	"regexp"
+	"strings"
+)
+
+// Config 连接 HiveServer2 的配置
+type Config struct {
+	Username string
+	Password string
+	// Zookeeper HiveServer2 服务发现使用的 ZooKeeper 地址
+	Zookeeper string
+}
+
+// Connect 通过 ZooKeeper 服务发现连接 HiveServer2
+func Connect(cfg Config) (*gohive.Connection, error) {
+	configuration := gohive.NewConnectConfiguration()
+	configuration.Username = cfg.Username
+	configuration.Password = cfg.Password
+
+	connection, err := gohive.ConnectZookeeper(cfg.Zookeeper, "NONE", configuration)
+	return connection, failure.Wrap(err)
+}
+
+// ListDbs 列出所有库
+func ListDbs(ctx context.Context, cursor *gohive.Cursor) (dbs []string, err error) {
+	cursor.Exec(ctx, "SHOW DATABASES")
+	if cursor.Err != nil {
+		err = failure.Wrap(cursor.Err, failure.Context{"op": "show databases"})
+		return
+	}
+
+	var db string
+	for cursor.HasMore(ctx) {
+		cursor.FetchOne(ctx, &db)
+		if cursor.Err != nil {
+			err = failure.Wrap(cursor.Err, failure.Context{"op": "show databases"})
+			return
+		}
+		dbs = append(dbs, db)
+	}
+
+	return
+}
+
+// ListTables 按 Hive 的输出格式列出库中的所有表，见 Dialect.ListTables
+func ListTables(ctx context.Context, cursor *gohive.Cursor, db string) ([]string, error) {
+	return Hive.ListTables(ctx, cursor, db)
+}
+
+// DbLocation 按 Hive 的输出格式获取库目录，见 Dialect.DbLocation
+func DbLocation(ctx context.Context, cursor *gohive.Cursor, db string) (string, error) {
+	return Hive.DbLocation(ctx, cursor, db)
+}
+
+// Table SHOW CREATE TABLE 中解析出的表目录和表属性
+type Table struct {
+	Location   string
+	Properties map[string]string
+	// Temporary 通过 CREATE TEMPORARY TABLE 创建的临时表
+	Temporary bool
+	// MaterializedView 物化视图，数据由查询生成，可以重建
+	MaterializedView bool
+	// SkewedBy SkewedValues SKEWED BY 声明的倾斜列和倾斜值，例如 key 和 (('1'),('2'))，没有声明时为空
+	SkewedBy     []string
+	SkewedValues string
+	// Fallback FallbackReason SHOW CREATE TABLE 失败时实际使用的方式和失败的原因
+	Fallback       string
+	FallbackReason string
+}
+
+// ErrView 视图没有表目录，不需要回退到其他方式获取
+var ErrView = errors.New("view has no location")
+
+// tableProperty TBLPROPERTIES 中的一个属性，例如 'counter.skip'='true'
+var tableProperty = regexp.MustCompile(`'([^']*)'\s*=\s*'([^']*)'`)
+
+// DescribeTable 按 Hive 的输出格式获取表目录和表属性，见 Dialect.DescribeTable
+func DescribeTable(ctx context.Context, cursor *gohive.Cursor, db, table string) (*Table, error) {
+	return Hive.DescribeTable(ctx, cursor, db, table)
+}
+
+// DescribeTable 通过 SHOW CREATE TABLE 获取表目录和表属性，没有表目录时返回错误。
+// Hive 每行返回建表语句的一行，LOCATION 和路径分两行；Spark 整条语句在一行中，LOCATION 和路径在同一行，按行拆分后统一处理
+func (d Dialect) DescribeTable(ctx context.Context, cursor *gohive.Cursor, db, table string) (*Table, error) {
+	errContext := failure.Context{"op": "show create table", "db": db, "table": table, "dialect": d.String()}
+	cursor.Exec(ctx, d.showCreateTable(db, table))
+	if cursor.Err != nil {
+		return nil, failure.Wrap(cursor.Err, errContext)
+	}
+
+	var (
+		lines     []string
+		createSql string
+	)
+	for cursor.HasMore(ctx) {
+		cursor.FetchOne(ctx, &createSql)
+		if cursor.Err != nil {
+			return nil, failure.Wrap(cursor.Err, errContext)
+		}
+		lines = append(lines, strings.Split(createSql, "\n")...)
+	}
+
+	var (
+		result     = &Table{Properties: make(map[string]string)}
+		location   string
+		properties bool
+		skewed     bool
+		view       bool
+	)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case strings.HasPrefix(line, "CREATE "):
+			result.Temporary = strings.Contains(line, " TEMPORARY ")
+			result.MaterializedView = strings.Contains(line, " MATERIALIZED VIEW ")
+			view = !result.MaterializedView && strings.Contains(line, " VIEW ")
+		case strings.HasPrefix(line, "SKEWED BY"):
+			skewed = true
+			for _, column := range strings.Split(strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "SKEWED BY")), "()"), ",") {
+				result.SkewedBy = append(result.SkewedBy, strings.Trim(strings.TrimSpace(column), "`"))
+			}
+		case skewed && strings.HasPrefix(line, "ON"):
+			skewed = false
+			result.SkewedValues = strings.TrimSpace(strings.TrimPrefix(line, "ON"))
+		case line == "LOCATION":
+			if i+1 < len(lines) {
+				i++
+				location = lines[i]
+			}
+		case strings.HasPrefix(line, "LOCATION "):
+			location = strings.TrimPrefix(line, "LOCATION ")
+		case strings.HasPrefix(line, "TBLPROPERTIES"):
+			properties = true
+			fallthrough
+		case properties:
+			for _, match := range tableProperty.FindAllStringSubmatch(line, -1) {
+				result.Properties[match[1]] = match[2]
+			}
+		}
+	}
+
+	if view {
+		return nil, failure.Wrap(ErrView, errContext)
+	}
+	// 物化视图的建表语句中没有 LOCATION，通过 DESCRIBE FORMATTED 获取，不视为回退
+	if result.MaterializedView && location == "" {
+		return d.DescribeFormatted(ctx, cursor, db, table)
+	}
+	if !strings.Contains(location, "'") {
+		return nil, failure.Wrap(errors.New("have no location"), errContext)
+	}
+	result.Location = strings.Split(location, "'")[1]
+	return result, nil
+}
+
+// DescribeFormatted 按 Hive 的输出格式获取表目录和表属性，见 Dialect.DescribeFormatted
+func DescribeFormatted(ctx context.Context, cursor *gohive.Cursor, db, table string) (*Table, error) {
+	return Hive.DescribeFormatted(ctx, cursor, db, table)
+}
+
+// DescribeFormatted 通过 DESCRIBE FORMATTED 获取表目录和表属性，不需要反序列化建表语句，
+// serde 损坏或者缺少 jar 导致 SHOW CREATE TABLE 失败时通常仍然可用。
+// Hive 的名称带冒号，表属性在 Table Parameters: 之后逐行列出；Spark 的名称不带冒号，表属性在 Table Properties 一行中
+func (d Dialect) DescribeFormatted(ctx context.Context, cursor *gohive.Cursor, db, table string) (*Table, error) {
+	errContext := failure.Context{"op": "describe formatted", "db": db, "table": table, "dialect": d.String()}
+	cursor.Exec(ctx, "DESCRIBE FORMATTED "+db+"."+table)
+	if cursor.Err != nil {
+		return nil, failure.Wrap(cursor.Err, errContext)
+	}
+
+	var (
+		result               = &Table{Properties: make(map[string]string)}
+		name, value, comment string
+		properties           bool
+		view                 bool
+	)
+	for cursor.HasMore(ctx) {
+		cursor.FetchOne(ctx, &name, &value, &comment)
+		if cursor.Err != nil {
+			return nil, failure.Wrap(cursor.Err, errContext)
+		}
+		name, value = strings.TrimSuffix(strings.TrimSpace(name), ":"), strings.TrimSpace(value)
+		// Table Parameters: 之后的属性行第一列为空
+		if properties && name == "" && value != "" {
+			result.Properties[value] = strings.TrimSpace(comment)
+			continue
+		}
+		properties = false
+		switch name {
+		case "Location":
+			result.Location = value
+		case "Table Type", "Type":
+			result.Temporary = strings.Contains(value, "TEMPORARY")
+			result.MaterializedView = strings.Contains(value, "MATERIALIZED_VIEW")
+			view = value == "VIRTUAL_VIEW" || value == "VIEW"
+		case "Table Parameters":
+			properties = true
+		case "Table Properties":
+			for _, property := range strings.Split(strings.Trim(value, "[]"), ", ") {
+				if parts := strings.SplitN(property, "=", 2); len(parts) == 2 {
+					result.Properties[parts[0]] = parts[1]
+				}
+			}
+		case "Skewed Columns":
+			for _, column := range strings.Split(strings.Trim(value, "[]"), ",") {
+				result.SkewedBy = append(result.SkewedBy, strings.TrimSpace(column))
+			}
+		case "Skewed Values":
+			result.SkewedValues = value
+		}
+	}
+
+	if view {
+		return nil, failure.Wrap(ErrView, errContext)
+	}
+	if result.Location == "" {
+		return nil, failure.Wrap(errors.New("have no location"), errContext)
+	}
+	return result, nil
+}
+
+// TableLocation 通过 SHOW CREATE TABLE 获取表目录
+func TableLocation(ctx context.Context, cursor *gohive.Cursor, db, table string) (string, error) {
+	t, err := DescribeTable(ctx, cursor, db, table)
+	if err != nil {
+		return "", err
+	}
+	return t.Location, nil
+}
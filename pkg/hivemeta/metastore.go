@@ -0,0 +1,281 @@
+package hivemeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/morikuni/failure"
+	"strings"
+	"time"
+)
+
+// Metastore 通过 thrift 接口访问 Hive metastore，只实现了 get_table，使用 binary 协议和 buffered transport，不支持 SASL。
+// 与 HiveServer2 的 cursor 一样不能并发使用
+type Metastore struct {
+	transport thrift.TTransport
+	client    *thrift.TStandardClient
+}
+
+// OpenMetastore 按顺序连接 uris 中的 metastore，格式与 hive.metastore.uris 相同，例如 thrift://metastore-1:9083,thrift://metastore-2:9083，
+// 返回第一个连接成功的
+func OpenMetastore(uris string, timeout time.Duration) (*Metastore, error) {
+	conf := &thrift.TConfiguration{ConnectTimeout: timeout, SocketTimeout: timeout}
+	var errs []string
+	for _, uri := range strings.Split(uris, ",") {
+		address := strings.TrimPrefix(strings.TrimSpace(uri), "thrift://")
+		if address == "" {
+			continue
+		}
+		socket, err := thrift.NewTSocketConf(address, conf)
+		if err == nil {
+			err = socket.Open()
+		}
+		if err != nil {
+			errs = append(errs, address+": "+err.Error())
+			continue
+		}
+		transport := thrift.NewTBufferedTransport(socket, 4096)
+		protocol := thrift.NewTBinaryProtocolConf(transport, conf)
+		return &Metastore{transport: transport, client: thrift.NewTStandardClient(protocol, protocol)}, nil
+	}
+	return nil, failure.Wrap(fmt.Errorf("connect metastore %s: %s", uris, strings.Join(errs, "; ")))
+}
+
+func (m *Metastore) Close() error {
+	return m.transport.Close()
+}
+
+// Table 通过 get_table 获取表目录和表属性，不经过 HiveServer2，不需要反序列化建表语句。视图没有表目录，返回 ErrView
+func (m *Metastore) Table(ctx context.Context, db, table string) (*Table, error) {
+	errContext := failure.Context{"op": "metastore get_table", "db": db, "table": table}
+	args := &getTableArgs{db: db, table: table}
+	result := &getTableResult{}
+	_, err := m.client.Call(ctx, "get_table", args, result)
+	if err != nil {
+		return nil, failure.Wrap(err, errContext)
+	}
+	if result.err != nil {
+		return nil, failure.Wrap(result.err, errContext)
+	}
+	t := result.table
+	if t == nil {
+		return nil, failure.Wrap(errors.New("get_table returned no table"), errContext)
+	}
+	if t.view {
+		return nil, failure.Wrap(ErrView, errContext)
+	}
+	if t.Location == "" {
+		return nil, failure.Wrap(errors.New("have no location"), errContext)
+	}
+	return &t.Table, nil
+}
+
+// getTableArgs ThriftHiveMetastore.get_table 的参数，1: string dbname, 2: string tbl_name
+type getTableArgs struct {
+	db    string
+	table string
+}
+
+func (a *getTableArgs) Write(ctx context.Context, p thrift.TProtocol) error {
+	if err := p.WriteStructBegin(ctx, "get_table_args"); err != nil {
+		return err
+	}
+	if err := writeStringField(ctx, p, "dbname", 1, a.db); err != nil {
+		return err
+	}
+	if err := writeStringField(ctx, p, "tbl_name", 2, a.table); err != nil {
+		return err
+	}
+	if err := p.WriteFieldStop(ctx); err != nil {
+		return err
+	}
+	return p.WriteStructEnd(ctx)
+}
+
+func (a *getTableArgs) Read(context.Context, thrift.TProtocol) error {
+	return errors.New("get_table_args is write only")
+}
+
+func writeStringField(ctx context.Context, p thrift.TProtocol, name string, id int16, value string) error {
+	if err := p.WriteFieldBegin(ctx, name, thrift.STRING, id); err != nil {
+		return err
+	}
+	if err := p.WriteString(ctx, value); err != nil {
+		return err
+	}
+	return p.WriteFieldEnd(ctx)
+}
+
+// getTableResult get_table 的返回值，0: Table success, 1: MetaException o1, 2: NoSuchObjectException o2
+type getTableResult struct {
+	table *metastoreTable
+	err   error
+}
+
+// metastoreTable 只读取用到的字段，其他字段跳过
+type metastoreTable struct {
+	Table
+	view bool
+}
+
+func (r *getTableResult) Write(context.Context, thrift.TProtocol) error {
+	return errors.New("get_table_result is read only")
+}
+
+func (r *getTableResult) Read(ctx context.Context, p thrift.TProtocol) error {
+	return readStruct(ctx, p, func(id int16, typ thrift.TType) (bool, error) {
+		switch {
+		case id == 0 && typ == thrift.STRUCT:
+			r.table = &metastoreTable{Table: Table{Properties: make(map[string]string)}}
+			return true, r.table.read(ctx, p)
+		case (id == 1 || id == 2) && typ == thrift.STRUCT:
+			// MetaException 和 NoSuchObjectException 都只有 1: string message
+			var message string
+			err := readStruct(ctx, p, func(id int16, typ thrift.TType) (bool, error) {
+				if id != 1 || typ != thrift.STRING {
+					return false, nil
+				}
+				var err error
+				message, err = p.ReadString(ctx)
+				return true, err
+			})
+			if id == 1 {
+				r.err = fmt.Errorf("MetaException: %s", message)
+			} else {
+				r.err = fmt.Errorf("NoSuchObjectException: %s", message)
+			}
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+// read Table 的 7: StorageDescriptor sd, 9: map<string, string> parameters, 12: string tableType, 14: bool temporary
+func (t *metastoreTable) read(ctx context.Context, p thrift.TProtocol) error {
+	return readStruct(ctx, p, func(id int16, typ thrift.TType) (bool, error) {
+		switch {
+		case id == 7 && typ == thrift.STRUCT:
+			return true, t.readStorageDescriptor(ctx, p)
+		case id == 9 && typ == thrift.MAP:
+			_, _, size, err := p.ReadMapBegin(ctx)
+			if err != nil {
+				return true, err
+			}
+			for i := 0; i < size; i++ {
+				key, err := p.ReadString(ctx)
+				if err != nil {
+					return true, err
+				}
+				value, err := p.ReadString(ctx)
+				if err != nil {
+					return true, err
+				}
+				t.Properties[key] = value
+			}
+			return true, p.ReadMapEnd(ctx)
+		case id == 12 && typ == thrift.STRING:
+			tableType, err := p.ReadString(ctx)
+			t.MaterializedView = tableType == "MATERIALIZED_VIEW"
+			t.view = tableType == "VIRTUAL_VIEW"
+			return true, err
+		case id == 14 && typ == thrift.BOOL:
+			var err error
+			t.Temporary, err = p.ReadBool(ctx)
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+// readStorageDescriptor StorageDescriptor 的 2: string location, 10: SkewedInfo skewedInfo
+func (t *metastoreTable) readStorageDescriptor(ctx context.Context, p thrift.TProtocol) error {
+	return readStruct(ctx, p, func(id int16, typ thrift.TType) (bool, error) {
+		switch {
+		case id == 2 && typ == thrift.STRING:
+			var err error
+			t.Location, err = p.ReadString(ctx)
+			return true, err
+		case id == 10 && typ == thrift.STRUCT:
+			return true, t.readSkewedInfo(ctx, p)
+		}
+		return false, nil
+	})
+}
+
+// readSkewedInfo SkewedInfo 的 1: list<string> skewedColNames, 2: list<list<string>> skewedColValues，
+// 倾斜值按 SHOW CREATE TABLE 的格式拼接，例如 (('1'),('2'))
+func (t *metastoreTable) readSkewedInfo(ctx context.Context, p thrift.TProtocol) error {
+	return readStruct(ctx, p, func(id int16, typ thrift.TType) (bool, error) {
+		switch {
+		case id == 1 && typ == thrift.LIST:
+			names, err := readStringList(ctx, p)
+			t.SkewedBy = names
+			return true, err
+		case id == 2 && typ == thrift.LIST:
+			_, size, err := p.ReadListBegin(ctx)
+			if err != nil {
+				return true, err
+			}
+			values := make([]string, 0, size)
+			for i := 0; i < size; i++ {
+				value, err := readStringList(ctx, p)
+				if err != nil {
+					return true, err
+				}
+				values = append(values, "('"+strings.Join(value, "','")+"')")
+			}
+			if len(values) > 0 {
+				t.SkewedValues = "(" + strings.Join(values, ",") + ")"
+			}
+			return true, p.ReadListEnd(ctx)
+		}
+		return false, nil
+	})
+}
+
+func readStringList(ctx context.Context, p thrift.TProtocol) ([]string, error) {
+	_, size, err := p.ReadListBegin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, size)
+	for i := 0; i < size; i++ {
+		value, err := p.ReadString(ctx)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, p.ReadListEnd(ctx)
+}
+
+// readStruct 逐个读取字段，field 返回 false 时跳过该字段
+func readStruct(ctx context.Context, p thrift.TProtocol, field func(id int16, typ thrift.TType) (bool, error)) error {
+	if _, err := p.ReadStructBegin(ctx); err != nil {
+		return err
+	}
+	for {
+		_, typ, id, err := p.ReadFieldBegin(ctx)
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		read, err := field(id, typ)
+		if err != nil {
+			return err
+		}
+		if !read {
+			err = p.Skip(ctx, typ)
+			if err != nil {
+				return err
+			}
+		}
+		if err = p.ReadFieldEnd(ctx); err != nil {
+			return err
+		}
+	}
+	return p.ReadStructEnd(ctx)
+}
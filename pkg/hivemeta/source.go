@@ -0,0 +1,92 @@
+package hivemeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/beltran/gohive"
+	"github.com/morikuni/failure"
+)
+
+// 回退的方式，记录在 Table.Fallback 中
+const (
+	FallbackDescribeFormatted = "describe formatted"
+	FallbackMetastore         = "metastore"
+)
+
+// Source 通过 HiveServer2 获取库、表和表目录
+type Source struct {
+	cursor *gohive.Cursor
+	// Metastore 不为空时作为 SHOW CREATE TABLE 和 DESCRIBE FORMATTED 都失败后的最后一次尝试
+	Metastore *Metastore
+	// Dialect 服务端的输出格式，为空时在第一次查询前通过 DetectDialect 识别
+	Dialect *Dialect
+}
+
+func NewSource(cursor *gohive.Cursor) *Source {
+	return &Source{cursor: cursor}
+}
+
+// Detect 返回配置的方言，没有配置时识别一次，识别失败时按 Hive 处理
+func (s *Source) Detect(ctx context.Context) Dialect {
+	if s.Dialect == nil {
+		d, err := DetectDialect(ctx, s.cursor)
+		if err != nil {
+			d = Hive
+		}
+		s.Dialect = &d
+	}
+	return *s.Dialect
+}
+
+func (s *Source) Dbs(ctx context.Context) ([]string, error) {
+	return ListDbs(ctx, s.cursor)
+}
+
+func (s *Source) Tables(ctx context.Context, db string) ([]string, error) {
+	return s.Detect(ctx).ListTables(ctx, s.cursor, db)
+}
+
+// DbLocation 获取库目录
+func (s *Source) DbLocation(ctx context.Context, db string) (string, error) {
+	return s.Detect(ctx).DbLocation(ctx, s.cursor, db)
+}
+
+func (s *Source) Location(ctx context.Context, db, table string) (string, error) {
+	t, err := s.Table(ctx, db, table)
+	if err != nil {
+		return "", err
+	}
+	return t.Location, nil
+}
+
+// Table 获取表目录和表属性，SHOW CREATE TABLE 失败时依次回退到 DESCRIBE FORMATTED 和 metastore 的 get_table，
+// 回退成功时在 Fallback 和 FallbackReason 中记录使用的方式和之前失败的原因，全部失败时返回所有的错误。视图没有表目录，不回退
+func (s *Source) Table(ctx context.Context, db, table string) (*Table, error) {
+	d := s.Detect(ctx)
+	t, showErr := d.DescribeTable(ctx, s.cursor, db, table)
+	if showErr == nil {
+		return t, nil
+	}
+	if ctx.Err() != nil || errors.Is(showErr, ErrView) {
+		return nil, showErr
+	}
+	t, describeErr := d.DescribeFormatted(ctx, s.cursor, db, table)
+	if describeErr == nil {
+		t.Fallback = FallbackDescribeFormatted
+		t.FallbackReason = showErr.Error()
+		return t, nil
+	}
+	// 保留 SHOW CREATE TABLE 的错误链，临时错误仍然可以被调用方识别并重试
+	err := fmt.Errorf("%w; %v", showErr, describeErr)
+	if s.Metastore == nil || ctx.Err() != nil || errors.Is(describeErr, ErrView) {
+		return nil, failure.Wrap(err)
+	}
+	t, metastoreErr := s.Metastore.Table(ctx, db, table)
+	if metastoreErr != nil {
+		return nil, failure.Wrap(fmt.Errorf("%w; %v", err, metastoreErr))
+	}
+	t.Fallback = FallbackMetastore
+	t.FallbackReason = err.Error()
+	return t, nil
+}
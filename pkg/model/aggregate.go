@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// HiveDb 一个库在某一天的汇总，由每次运行根据 hive 表的结果生成
+type HiveDb struct {
+	ID       int64  `gorm:"primaryKey;autoIncrement"`
+	Db       string `gorm:"size:128;not null;uniqueIndex:db_record,priority:1;comment:库名"`
+	Size     int64  `gorm:"not null;comment:获取成功的表的大小之和，单位 bytes"`
+	Tables   int64  `gorm:"not null;comment:表的数量"`
+	Failures int64  `gorm:"not null;comment:获取大小失败的表的数量"`
+	// Delta 前一天没有数据时为空
+	Delta *int64    `gorm:"comment:与前一天相比的大小变化，单位 bytes"`
+	Date  time.Time `gorm:"type:date;uniqueIndex:db_record,priority:2;index:idx_hive_db_date;comment:抓取数据时间"`
+}
+
+func (HiveDb) TableName() string {
+	return "hive_db"
+}
+
+// HiveCluster 整个集群在某一天的汇总
+type HiveCluster struct {
+	ID      int64  `gorm:"primaryKey;autoIncrement"`
+	Cluster string `gorm:"size:128;not null;uniqueIndex:cluster_record,priority:1;comment:集群名称"`
+	Size    int64  `gorm:"not null;comment:获取成功的表的逻辑大小之和，单位 bytes"`
+	// RawSize 包含副本，反映实际消耗的磁盘空间
+	RawSize  int64 `gorm:"not null;comment:获取成功的表包含副本的实际占用空间之和，单位 bytes"`
+	Dbs      int64 `gorm:"not null;comment:库的数量"`
+	Tables   int64 `gorm:"not null;comment:表的数量"`
+	Failures int64 `gorm:"not null;comment:获取大小失败的表的数量"`
+	// Delta 前一天没有数据时为空
+	Delta *int64    `gorm:"comment:与前一天相比的逻辑大小变化，单位 bytes"`
+	Date  time.Time `gorm:"type:date;uniqueIndex:cluster_record,priority:2;comment:抓取数据时间"`
+}
+
+func (HiveCluster) TableName() string {
+	return "hive_cluster"
+}
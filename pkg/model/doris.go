@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// DorisTable 一张 Doris 或 StarRocks 表在某一天的数据量，来自 FE 的 SHOW DATA
+type DorisTable struct {
+	ID    int64  `gorm:"primaryKey;autoIncrement"`
+	Db    string `gorm:"size:128;not null;uniqueIndex:doris_table_record,priority:1;comment:库名"`
+	Table string `gorm:"size:256;not null;uniqueIndex:doris_table_record,priority:2;comment:表名"`
+	Size  int64  `gorm:"not null;comment:本地存储的数据量，单位 bytes"`
+	// RemoteSize 冷热分层时存储在远端的数据量，旧版本和 StarRocks 没有此列时为 0
+	RemoteSize int64  `gorm:"not null;comment:远端存储的数据量，单位 bytes"`
+	Replicas   int64  `gorm:"not null;comment:副本数量"`
+	Desc       string `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:doris_table_record,priority:3;index:idx_doris_table_date;comment:统计日期"`
+}
+
+func (DorisTable) TableName() string {
+	return "doris_table"
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// EsIndex 一个 Elasticsearch 索引在某一天的大小、文档数量和分片数量
+type EsIndex struct {
+	ID     int64  `gorm:"primaryKey;autoIncrement"`
+	Index  string `gorm:"size:256;not null;uniqueIndex:es_index_record,priority:1;comment:索引名称"`
+	Health string `gorm:"size:16;not null;comment:green, yellow 或 red"`
+	Status string `gorm:"size:16;not null;comment:open 或 close"`
+	// Shards 主分片数量，Replicas 每个主分片的副本数量
+	Shards    int   `gorm:"not null;comment:主分片数量"`
+	Replicas  int   `gorm:"not null;comment:副本数量"`
+	DocsCount int64 `gorm:"not null;comment:文档数量，不包含副本"`
+	// Size 只统计主分片，RawSize 统计所有分片
+	Size    int64  `gorm:"not null;comment:主分片的存储大小，单位 bytes"`
+	RawSize int64  `gorm:"not null;comment:所有分片的存储大小，单位 bytes"`
+	Desc    string `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:es_index_record,priority:2;index:idx_es_index_date;comment:统计日期"`
+}
+
+func (EsIndex) TableName() string {
+	return "es_index"
+}
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/morikuni/failure"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// 内置采集项写入 Extra 的属性
+const (
+	// ExtraRawSize 包含副本的实际占用空间，单位 bytes
+	ExtraRawSize = "raw_size"
+	// ExtraFileCount 路径下的文件数量
+	ExtraFileCount = "file_count"
+	// ExtraDirCount 路径下的目录数量，包括路径本身
+	ExtraDirCount = "dir_count"
+	// ExtraAccessTime 路径下文件最近的访问时间，unix 时间戳，单位秒
+	ExtraAccessTime = "access_time"
+	// ExtraPartitions ExtraMaxPartition ExtraMaxPartitionSize 一级分区的数量、最大的分区和它的大小
+	ExtraPartitions       = "partitions"
+	ExtraMaxPartition     = "max_partition"
+	ExtraMaxPartitionSize = "max_partition_size"
+	// ExtraTeam ExtraProject ExtraCostCenter 根据归属映射得到的团队、项目和成本中心
+	ExtraTeam       = "team"
+	ExtraProject    = "project"
+	ExtraCostCenter = "cost_center"
+	// ExtraSkipped 表属性 hive.skip_property 为 true 时表的所有者选择不统计，不获取大小
+	ExtraSkipped = "skipped"
+	// ExtraMetadataFallback ExtraMetadataFallbackReason SHOW CREATE TABLE 失败时实际获取表目录的方式和失败的原因
+	ExtraMetadataFallback       = "metadata_fallback"
+	ExtraMetadataFallbackReason = "metadata_fallback_reason"
+	// ExtraTemporary 临时表或者表名符合 hive.temporary.patterns 的中间表
+	ExtraTemporary = "temporary"
+	// ExtraMaterializedView 物化视图，与其他表一样获取大小，报表中单独统计派生数据的大小
+	ExtraMaterializedView = "materialized_view"
+	// ExtraSkewedBy ExtraSkewedValues SKEWED BY 声明的倾斜列（逗号分隔）和倾斜值
+	ExtraSkewedBy     = "skewed_by"
+	ExtraSkewedValues = "skewed_values"
+	// ExtraLargestFiles 表目录下最大的若干个文件，值为 []LargeFile
+	ExtraLargestFiles = "largest_files"
+	// ExtraReplication 按文件大小加权的有效副本数，ExtraReplicationSizes 每种副本数的文件大小，键为副本数，
+	// ExtraReplicationDeviates 是否存在副本数与集群默认值不同的文件
+	ExtraReplication         = "replication"
+	ExtraReplicationSizes    = "replication_sizes"
+	ExtraReplicationDeviates = "replication_deviates"
+	// ExtraTransactional 表属性 transactional 为 true 的 ACID 表
+	ExtraTransactional = "transactional"
+	// ExtraBaseSize ExtraDeltaSize ExtraDeleteDeltaSize 事务表 base、delta 和 delete_delta 目录的大小
+	ExtraBaseSize        = "base_size"
+	ExtraDeltaSize       = "delta_size"
+	ExtraDeleteDeltaSize = "delete_delta_size"
+	// ExtraDeltaDirs ExtraMaxDeltaDirs 等待 compaction 的 delta 和 delete_delta 目录总数，以及单个分区中最多的数量
+	ExtraDeltaDirs    = "delta_dirs"
+	ExtraMaxDeltaDirs = "max_delta_dirs"
+)
+
+// LargeFile 表目录下的一个大文件，Splittable 为 false 的文件只能由一个 task 读取
+type LargeFile struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Splittable bool   `json:"splittable"`
+}
+
+// Extra 可选采集项（统计信息、存储格式、策略、自定义标签等）附加的属性，以 JSON 存储，新增属性不需要修改表结构
+type Extra map[string]interface{}
+
+func (e Extra) Value() (driver.Value, error) {
+	if len(e) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	return string(data), nil
+}
+
+func (e *Extra) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*e = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return failure.Wrap(fmt.Errorf("unsupported extra type: %T", value))
+	}
+	if len(data) == 0 {
+		*e = nil
+		return nil
+	}
+	return failure.Wrap(json.Unmarshal(data, e))
+}
+
+func (Extra) GormDataType() string {
+	return "json"
+}
+
+// GormDBDataType MySQL 使用 JSON，PostgreSQL 使用 JSONB，SQLite 使用 TEXT
+func (Extra) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "mysql":
+		return "JSON"
+	case "postgres":
+		return "JSONB"
+	}
+	return "TEXT"
+}
+
+// Int64 读取整数属性，从数据库或 JSON 读出的数字为 float64
+func (e Extra) Int64(key string) (int64, bool) {
+	switch v := e[key].(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// Skipped 表的所有者通过表属性选择不统计，没有获取大小，Size 为 -1 但不是获取失败，Desc 为空
+func (h *Hive) Skipped() bool {
+	skipped, _ := h.Extra[ExtraSkipped].(bool)
+	return skipped
+}
+
+// SetExtra 设置一个扩展属性
+func (h *Hive) SetExtra(key string, value interface{}) {
+	if h.Extra == nil {
+		h.Extra = make(Extra)
+	}
+	h.Extra[key] = value
+}
+
+// LargestFiles 读取 ExtraLargestFiles，从数据库或 JSON 读出的值需要重新解析
+func (e Extra) LargestFiles() []LargeFile {
+	switch v := e[ExtraLargestFiles].(type) {
+	case nil:
+		return nil
+	case []LargeFile:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var files []LargeFile
+		if json.Unmarshal(data, &files) != nil {
+			return nil
+		}
+		return files
+	}
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// 名单类型
+const (
+	FilterBlacklist = "blacklist"
+	FilterWhitelist = "whitelist"
+)
+
+// HiveFilter 通过 API 添加的黑名单、白名单，与配置文件中的名单合并使用
+type HiveFilter struct {
+	ID      int64     `gorm:"primaryKey;autoIncrement"`
+	List    string    `gorm:"size:16;not null;uniqueIndex:filter_record,priority:1;comment:名单类型，blacklist 或 whitelist"`
+	Db      string    `gorm:"size:128;not null;uniqueIndex:filter_record,priority:2;comment:库名"`
+	Comment string    `gorm:"size:1024;comment:备注，例如添加的原因"`
+	Created time.Time `gorm:"not null;comment:添加时间"`
+}
+
+func (HiveFilter) TableName() string {
+	return "hive_filter"
+}
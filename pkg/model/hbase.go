@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Hbase 一张 HBase 表在某一天的大小，通过 HBase 根目录下的 data 目录统计
+type Hbase struct {
+	ID        int64  `gorm:"primaryKey;autoIncrement"`
+	Namespace string `gorm:"size:128;not null;uniqueIndex:hbase_record,priority:1;comment:命名空间"`
+	Table     string `gorm:"size:128;not null;uniqueIndex:hbase_record,priority:2;comment:表名"`
+	Path      string `gorm:"size:1024;not null;comment:表目录"`
+	Regions   int    `gorm:"not null;comment:region 数量"`
+	Size      int64  `gorm:"not null;comment:占用存储空间大小，单位 bytes，-1 表示获取失败"`
+	RawSize   int64  `gorm:"not null;comment:包含副本的占用存储空间大小，单位 bytes"`
+	Files     int64  `gorm:"not null;comment:文件数量"`
+	Desc      string `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:hbase_record,priority:3;index:idx_hbase_date;comment:统计日期"`
+}
+
+func (Hbase) TableName() string {
+	return "hbase"
+}
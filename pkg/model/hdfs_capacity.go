@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// HdfsCapacity HDFS 集群在某一天的容量，每天一行，可以与其他表按 date 关联计算使用率
+type HdfsCapacity struct {
+	ID       int64  `gorm:"primaryKey;autoIncrement"`
+	Namenode string `gorm:"size:256;not null;comment:提供数据的 active NameNode"`
+	// Capacity、Used、Remaining 都是包含副本的磁盘空间
+	Capacity   int64 `gorm:"not null;comment:配置的总容量，单位 bytes"`
+	Used       int64 `gorm:"not null;comment:DFS 已使用，单位 bytes"`
+	Remaining  int64 `gorm:"not null;comment:DFS 剩余，单位 bytes"`
+	NonDfsUsed int64 `gorm:"not null;comment:非 DFS 占用，单位 bytes"`
+	Blocks     int64 `gorm:"not null;comment:块数量"`
+	// Files NameNode 中的文件和目录总数
+	Files                 int64 `gorm:"not null;comment:文件和目录数量"`
+	MissingBlocks         int64 `gorm:"not null;comment:丢失的块数量"`
+	UnderReplicatedBlocks int64 `gorm:"not null;comment:副本不足的块数量"`
+	LiveDatanodes         int   `gorm:"not null;comment:存活的 DataNode 数量"`
+	DeadDatanodes         int   `gorm:"not null;comment:死亡的 DataNode 数量"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:idx_hdfs_capacity_date;comment:统计日期"`
+}
+
+func (HdfsCapacity) TableName() string {
+	return "hdfs_capacity"
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// 日志目录的类型
+const (
+	LogDirSpark = "spark"
+	LogDirYarn  = "yarn"
+)
+
+// HdfsLogDir Spark event log 或 YARN 聚合日志目录在某一天的大小，按文件的修改时间统计分布和可以清理的部分
+type HdfsLogDir struct {
+	ID      int64  `gorm:"primaryKey;autoIncrement"`
+	Kind    string `gorm:"size:16;not null;comment:spark 或 yarn"`
+	Path    string `gorm:"size:512;not null;uniqueIndex:hdfs_log_dir_record,priority:1;comment:日志目录"`
+	Size    int64  `gorm:"not null;comment:占用存储空间大小，单位 bytes，-1 表示获取失败"`
+	RawSize int64  `gorm:"not null;comment:包含副本的占用存储空间大小，单位 bytes"`
+	Files   int64  `gorm:"not null;comment:文件数量"`
+	// Day Week Month Older 按文件的修改时间统计，分别为 1 天内、1 到 7 天、7 到 30 天、30 天以上
+	Day   int64 `gorm:"not null;comment:1 天内修改的文件大小，单位 bytes"`
+	Week  int64 `gorm:"not null;comment:1 到 7 天的文件大小，单位 bytes"`
+	Month int64 `gorm:"not null;comment:7 到 30 天的文件大小，单位 bytes"`
+	Older int64 `gorm:"not null;comment:30 天以上的文件大小，单位 bytes"`
+	// Candidates 超过 logs.cleanup_days 没有修改的文件，可以清理
+	Candidates    int64      `gorm:"not null;comment:可以清理的文件数量"`
+	CandidateSize int64      `gorm:"not null;comment:可以清理的文件大小，单位 bytes"`
+	Oldest        *time.Time `gorm:"comment:最早的文件修改时间"`
+	Desc          string     `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:hdfs_log_dir_record,priority:2;index:idx_hdfs_log_dir_date;comment:统计日期"`
+}
+
+func (HdfsLogDir) TableName() string {
+	return "hdfs_log_dir"
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// HdfsPath 配置的 HDFS 路径在某一天的大小，与 Hive 无关，例如 /tmp、Spark checkpoint 目录
+type HdfsPath struct {
+	ID      int64  `gorm:"primaryKey;autoIncrement"`
+	Pattern string `gorm:"size:1024;not null;comment:配置的路径，可以包含通配符"`
+	Path    string `gorm:"size:512;not null;uniqueIndex:hdfs_path_record,priority:1;comment:通配符展开后的路径"`
+	Size    int64  `gorm:"not null;comment:占用存储空间大小，单位 bytes，-1 表示获取失败"`
+	RawSize int64  `gorm:"not null;comment:包含副本的占用存储空间大小，单位 bytes"`
+	Files   int64  `gorm:"not null;comment:文件数量"`
+	Dirs    int64  `gorm:"not null;comment:目录数量"`
+	Desc    string `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:hdfs_path_record,priority:2;index:idx_hdfs_path_date;comment:统计日期"`
+}
+
+func (HdfsPath) TableName() string {
+	return "hdfs_path"
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// HdfsQuota HDFS 目录的名称配额、空间配额和使用量
+type HdfsQuota struct {
+	ID   int64  `gorm:"primaryKey;autoIncrement"`
+	Path string `gorm:"size:512;not null;uniqueIndex:hdfs_quota_record,priority:1;comment:目录"`
+	// Db 统计库目录时为库名
+	Db string `gorm:"size:128;not null;comment:库名，配置的路径为空"`
+	// NameQuota SpaceQuota 没有设置配额时为 -1
+	NameQuota    int64   `gorm:"not null;comment:名称配额，-1 表示没有设置"`
+	NameUsed     int64   `gorm:"not null;comment:文件和目录的数量"`
+	NamePercent  float64 `gorm:"not null;comment:名称配额使用率，百分比"`
+	SpaceQuota   int64   `gorm:"not null;comment:空间配额，单位 bytes，-1 表示没有设置"`
+	SpaceUsed    int64   `gorm:"not null;comment:包含副本的占用存储空间大小，单位 bytes"`
+	SpacePercent float64 `gorm:"not null;comment:空间配额使用率，百分比"`
+	Desc         string  `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:hdfs_quota_record,priority:2;index:idx_hdfs_quota_date;comment:统计日期"`
+}
+
+func (HdfsQuota) TableName() string {
+	return "hdfs_quota"
+}
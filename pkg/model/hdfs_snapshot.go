@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// HdfsSnapshot 一个 snapshottable 目录在某一天只被快照引用的数据大小，即删除所有快照后可以释放的空间
+type HdfsSnapshot struct {
+	ID            int64  `gorm:"primaryKey;autoIncrement"`
+	Path          string `gorm:"size:512;not null;uniqueIndex:hdfs_snapshot_record,priority:1;comment:snapshottable 目录"`
+	Snapshots     int    `gorm:"not null;comment:快照数量"`
+	SnapshotQuota int    `gorm:"not null;comment:快照数量上限"`
+	// Oldest 最早的快照名称，OldestTime 为其创建时间
+	Oldest     string     `gorm:"size:256;not null;comment:最早的快照"`
+	OldestTime *time.Time `gorm:"comment:最早的快照创建时间"`
+	// Deleted 当前目录中已经删除、只存在于快照中的路径数量，删除的目录只计一次
+	Deleted int    `gorm:"not null;comment:只存在于快照中的路径数量"`
+	Size    int64  `gorm:"not null;comment:只被快照引用的数据大小，单位 bytes，-1 表示获取失败"`
+	RawSize int64  `gorm:"not null;comment:包含副本的只被快照引用的数据大小，单位 bytes"`
+	Desc    string `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:hdfs_snapshot_record,priority:2;index:idx_hdfs_snapshot_date;comment:统计日期"`
+}
+
+func (HdfsSnapshot) TableName() string {
+	return "hdfs_snapshot"
+}
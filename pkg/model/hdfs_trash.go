@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// HdfsTrash 用户回收站在某一天的大小，按 checkpoint 的时间统计等待删除的数据分布
+type HdfsTrash struct {
+	ID      int64  `gorm:"primaryKey;autoIncrement"`
+	User    string `gorm:"size:128;not null;uniqueIndex:hdfs_trash_record,priority:1;comment:用户名"`
+	Path    string `gorm:"size:1024;not null;comment:回收站目录"`
+	Size    int64  `gorm:"not null;comment:占用存储空间大小，单位 bytes，-1 表示获取失败"`
+	RawSize int64  `gorm:"not null;comment:包含副本的占用存储空间大小，单位 bytes"`
+	Files   int64  `gorm:"not null;comment:文件数量"`
+	// Current 还没有生成 checkpoint 的部分
+	Current int64 `gorm:"not null;comment:Current 目录的大小，单位 bytes"`
+	// Day Week Older 按 checkpoint 的时间统计，分别为 1 天内、1 到 7 天、7 天以上
+	Day              int64      `gorm:"not null;comment:1 天内的 checkpoint 大小，单位 bytes"`
+	Week             int64      `gorm:"not null;comment:1 到 7 天的 checkpoint 大小，单位 bytes"`
+	Older            int64      `gorm:"not null;comment:7 天以上的 checkpoint 大小，单位 bytes"`
+	Checkpoints      int        `gorm:"not null;comment:checkpoint 数量"`
+	OldestCheckpoint *time.Time `gorm:"comment:最早的 checkpoint 时间"`
+	Desc             string     `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:hdfs_trash_record,priority:2;index:idx_hdfs_trash_date;comment:统计日期"`
+}
+
+func (HdfsTrash) TableName() string {
+	return "hdfs_trash"
+}
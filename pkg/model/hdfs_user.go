@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// HdfsUser 用户 home 目录在某一天的大小
+type HdfsUser struct {
+	ID      int64  `gorm:"primaryKey;autoIncrement"`
+	User    string `gorm:"size:128;not null;uniqueIndex:hdfs_user_record,priority:1;comment:用户名"`
+	Path    string `gorm:"size:1024;not null;comment:home 目录"`
+	Owner   string `gorm:"size:128;not null;comment:目录的所有者"`
+	Size    int64  `gorm:"not null;comment:占用存储空间大小，单位 bytes，-1 表示获取失败"`
+	RawSize int64  `gorm:"not null;comment:包含副本的占用存储空间大小，单位 bytes"`
+	Files   int64  `gorm:"not null;comment:文件数量"`
+	Dirs    int64  `gorm:"not null;comment:目录数量"`
+	Desc    string `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:hdfs_user_record,priority:2;index:idx_hdfs_user_date;comment:统计日期"`
+}
+
+func (HdfsUser) TableName() string {
+	return "hdfs_user"
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Hive 一张 Hive 表在某一天的抓取结果
+//
+// gorm 标签与 internal/app 目录下的建表语句保持一致，AutoMigrate 时据此建表、补充字段和索引。
+type Hive struct {
+	ID       int64     `gorm:"primaryKey;autoIncrement"`
+	Db       string    `gorm:"size:128;not null;uniqueIndex:uniq_record,priority:1;comment:库名"`
+	Table    string    `gorm:"size:128;not null;uniqueIndex:uniq_record,priority:2;comment:表名"`
+	Location string    `gorm:"size:4000;not null;comment:路径，为空代表没有路径"`
+	Size     int64     `gorm:"not null;comment:占用存储空间大小，单位 bytes，-1 表示没有路径或者获取路径错误"`
+	Desc     string    `gorm:"size:4096;not null;comment:备注"`
+	Date     time.Time `gorm:"type:date;uniqueIndex:uniq_record,priority:3;index:idx_hive_date;comment:抓取数据时间"`
+	Extra    Extra     `gorm:"comment:扩展属性"`
+	// RunID CounterVersion 写入该行的运行和 counter 版本，用于追溯数据的来源
+	RunID          string `gorm:"size:36;comment:运行 ID"`
+	CounterVersion string `gorm:"size:64;comment:counter 版本"`
+	// MetadataMs SizeMs 获取表目录和获取大小的耗时，单位毫秒，耗时异常通常说明目录树异常或者 metastore 有问题
+	MetadataMs int64 `gorm:"comment:获取元数据耗时，单位毫秒"`
+	SizeMs     int64 `gorm:"comment:获取大小耗时，单位毫秒"`
+	// DeltaBytes DeltaPct 与上一次抓取相比的变化，写入时计算，上一次没有该表或者任一次获取失败时为空
+	DeltaBytes *int64   `gorm:"comment:与上一次抓取相比的变化，单位 bytes"`
+	DeltaPct   *float64 `gorm:"comment:与上一次抓取相比的变化，单位 %"`
+}
+
+func (Hive) TableName() string {
+	return "hive"
+}
+
+func (h *Hive) String() string {
+	return fmt.Sprintf("Database: %s\nTable: %s\nLocation: %s\nSize: %d bytes\nDescription: %s",
+		h.Db, h.Table, h.Location, h.Size, h.Desc)
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// HiveDatabase 库目录本身的大小，只需要每个库一次 GetContentSummary，不包括目录在库目录之外的外部表
+type HiveDatabase struct {
+	ID       int64  `gorm:"primaryKey;autoIncrement"`
+	Db       string `gorm:"size:128;not null;uniqueIndex:hive_database_record,priority:1;comment:库名"`
+	Location string `gorm:"size:4000;not null;comment:库目录"`
+	Size     int64  `gorm:"not null;comment:库目录的大小，单位 bytes，-1 表示获取失败"`
+	RawSize  int64  `gorm:"not null;comment:包含副本的实际占用空间，单位 bytes"`
+	Files    int64  `gorm:"not null;comment:文件数量"`
+	Dirs     int64  `gorm:"not null;comment:目录数量"`
+	Desc     string `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:hive_database_record,priority:2;index:idx_hive_database_date;comment:统计日期"`
+}
+
+func (HiveDatabase) TableName() string {
+	return "hive_database"
+}
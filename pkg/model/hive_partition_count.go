@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// HivePartitionCount 一张 Hive 表在某一天的分区数量，用于跟踪 metastore 的对象压力，与表的大小无关
+type HivePartitionCount struct {
+	ID    int64  `gorm:"primaryKey;autoIncrement"`
+	Db    string `gorm:"size:128;not null;uniqueIndex:hive_partition_count_record,priority:1;comment:库名"`
+	Table string `gorm:"size:256;not null;uniqueIndex:hive_partition_count_record,priority:2;comment:表名"`
+	// Partitions 非分区表为 0
+	Partitions int64  `gorm:"not null;comment:分区数量，-1 表示获取失败"`
+	Desc       string `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:hive_partition_count_record,priority:3;index:idx_hive_partition_count_date;comment:统计日期"`
+}
+
+func (HivePartitionCount) TableName() string {
+	return "hive_partition_count"
+}
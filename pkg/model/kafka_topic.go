@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// KafkaTopic 一个 Kafka topic 在某一天的磁盘占用和保留设置
+type KafkaTopic struct {
+	ID                int64  `gorm:"primaryKey;autoIncrement"`
+	Topic             string `gorm:"size:256;not null;uniqueIndex:kafka_topic_record,priority:1;comment:topic 名称"`
+	Partitions        int    `gorm:"not null;comment:分区数量"`
+	ReplicationFactor int    `gorm:"not null;comment:副本数量"`
+	// Size 只统计 leader 副本，RawSize 统计所有副本
+	Size    int64 `gorm:"not null;comment:leader 副本的磁盘占用，单位 bytes"`
+	RawSize int64 `gorm:"not null;comment:所有副本的磁盘占用，单位 bytes"`
+	// RetentionMs 和 RetentionBytes 为 -1 时表示不限制
+	RetentionMs    int64  `gorm:"not null;comment:retention.ms"`
+	RetentionBytes int64  `gorm:"not null;comment:retention.bytes"`
+	CleanupPolicy  string `gorm:"size:64;not null;comment:cleanup.policy"`
+	Desc           string `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:kafka_topic_record,priority:2;index:idx_kafka_topic_date;comment:统计日期"`
+}
+
+func (KafkaTopic) TableName() string {
+	return "kafka_topic"
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// Kudu 一张 Kudu 表在某一天的磁盘占用，由各个 tablet server 的 tablet 指标汇总
+type Kudu struct {
+	ID    int64  `gorm:"primaryKey;autoIncrement"`
+	Table string `gorm:"size:256;not null;uniqueIndex:kudu_record,priority:1;comment:表名，通过 Impala 创建的表为 impala::db.table"`
+	// Tablets 不同的 tablet 数量，Replicas 所有 tablet server 上的副本数量
+	Tablets  int    `gorm:"not null;comment:tablet 数量"`
+	Replicas int    `gorm:"not null;comment:tablet 副本数量"`
+	Size     int64  `gorm:"not null;comment:所有副本的磁盘占用，单位 bytes"`
+	Desc     string `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:kudu_record,priority:2;index:idx_kudu_date;comment:统计日期"`
+}
+
+func (Kudu) TableName() string {
+	return "kudu"
+}
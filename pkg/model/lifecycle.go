@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// 表的生命周期事件
+const (
+	LifecycleCreated = "created"
+	LifecycleDropped = "dropped"
+)
+
+// HiveLifecycle 与前一次抓取相比新建或删除的表
+type HiveLifecycle struct {
+	ID    int64  `gorm:"primaryKey;autoIncrement"`
+	Db    string `gorm:"size:128;not null;uniqueIndex:lifecycle_record,priority:1;comment:库名"`
+	Table string `gorm:"size:128;not null;uniqueIndex:lifecycle_record,priority:2;comment:表名"`
+	Event string `gorm:"size:16;not null;comment:事件，created 或 dropped"`
+	// Location Size 新建时为本次的结果，删除时为最后一次的结果
+	Location string `gorm:"size:4000;comment:路径"`
+	Size     int64  `gorm:"not null;comment:大小，单位 bytes，获取失败时为 -1"`
+	// PreviousDate 比较的前一次抓取的日期
+	PreviousDate time.Time `gorm:"type:date;comment:比较的前一次抓取日期"`
+	Date         time.Time `gorm:"type:date;uniqueIndex:lifecycle_record,priority:3;index:idx_hive_lifecycle_date;comment:统计日期"`
+}
+
+func (HiveLifecycle) TableName() string {
+	return "hive_lifecycle"
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// MysqlSchema MySQL 实例中一个库或一张表在某一天的大小，来自 information_schema.tables
+type MysqlSchema struct {
+	ID       int64  `gorm:"primaryKey;autoIncrement"`
+	Instance string `gorm:"size:128;not null;uniqueIndex:mysql_schema_record,priority:1;comment:实例名称"`
+	Level    string `gorm:"size:16;not null;uniqueIndex:mysql_schema_record,priority:2;comment:统计层级，db 或 table"`
+	Schema   string `gorm:"size:64;not null;uniqueIndex:mysql_schema_record,priority:3;comment:库名"`
+	Table    string `gorm:"size:64;not null;uniqueIndex:mysql_schema_record,priority:4;comment:表名，库级别时为空"`
+	// Tables 库级别时为表的数量，表级别时为 1
+	Tables int `gorm:"not null;comment:表数量"`
+	// DataSize、IndexSize、FreeSize 和 Rows 对于 InnoDB 是估算值
+	DataSize  int64 `gorm:"not null;comment:数据大小，单位 bytes"`
+	IndexSize int64 `gorm:"not null;comment:索引大小，单位 bytes"`
+	FreeSize  int64 `gorm:"not null;comment:已分配未使用的空间，单位 bytes"`
+	Rows      int64 `gorm:"not null;comment:估算的行数"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:mysql_schema_record,priority:5;index:idx_mysql_schema_date;comment:统计日期"`
+}
+
+func (MysqlSchema) TableName() string {
+	return "mysql_schema"
+}
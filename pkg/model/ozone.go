@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Ozone 的统计层级
+const (
+	LevelVolume = "volume"
+	LevelBucket = "bucket"
+)
+
+// OzoneBucket Ozone 中一个 volume 或 bucket 在某一天的使用量，volume 级别为其下所有 bucket 的汇总
+type OzoneBucket struct {
+	ID     int64  `gorm:"primaryKey;autoIncrement"`
+	Level  string `gorm:"size:16;not null;uniqueIndex:ozone_bucket_record,priority:1;comment:统计层级，volume 或 bucket"`
+	Volume string `gorm:"size:128;not null;uniqueIndex:ozone_bucket_record,priority:2;comment:volume 名称"`
+	Bucket string `gorm:"size:128;not null;uniqueIndex:ozone_bucket_record,priority:3;comment:bucket 名称，volume 级别时为空"`
+	Layout string `gorm:"size:32;not null;comment:bucket 布局，volume 级别时为空"`
+	// Buckets volume 级别时为 bucket 数量，bucket 级别时为 1
+	Buckets int   `gorm:"not null;comment:bucket 数量"`
+	Size    int64 `gorm:"not null;comment:已使用的空间，单位 bytes，包含副本"`
+	Keys    int64 `gorm:"not null;comment:key 数量"`
+	// QuotaBytes 和 QuotaKeys 为 -1 时表示不限制
+	QuotaBytes int64 `gorm:"not null;comment:空间配额，单位 bytes"`
+	QuotaKeys  int64 `gorm:"not null;comment:key 数量配额"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:ozone_bucket_record,priority:4;index:idx_ozone_bucket_date;comment:统计日期"`
+}
+
+func (OzoneBucket) TableName() string {
+	return "ozone_bucket"
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// HiveRetention 分区表的保留策略建议
+type HiveRetention struct {
+	ID    int64  `gorm:"primaryKey;autoIncrement"`
+	Db    string `gorm:"size:128;not null;uniqueIndex:retention_record,priority:1;comment:库名"`
+	Table string `gorm:"size:128;not null;uniqueIndex:retention_record,priority:2;comment:表名"`
+	// Partitions 能解析出日期的一级分区数量
+	Partitions int `gorm:"not null;comment:按日期分区的数量"`
+	OldestDays int `gorm:"not null;comment:最早的分区距今的天数"`
+	// NeededDays 覆盖最近被读取的最早分区需要保留的天数，没有访问时间时为配置的最小保留天数
+	NeededDays  int       `gorm:"not null;comment:需要保留的天数"`
+	KeepDays    int       `gorm:"not null;comment:建议保留的天数"`
+	Size        int64     `gorm:"not null;comment:分区的总大小，单位 bytes"`
+	Reclaimable int64     `gorm:"not null;comment:按建议保留后可以释放的大小，单位 bytes"`
+	Rate        float64   `gorm:"not null;comment:平均每天增长的大小，单位 bytes"`
+	Date        time.Time `gorm:"type:date;uniqueIndex:retention_record,priority:3;index:idx_hive_retention_date;comment:统计日期"`
+}
+
+func (HiveRetention) TableName() string {
+	return "hive_retention"
+}
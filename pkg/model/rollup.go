@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// 汇总粒度
+const (
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// HiveRollup 一张表在一周或一个月内的大小，由 rollup 子命令将过期的每日记录汇总生成
+type HiveRollup struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement"`
+	Granularity string `gorm:"size:16;not null;uniqueIndex:rollup_record,priority:1;comment:汇总粒度，week 或 month"`
+	Db          string `gorm:"size:128;not null;uniqueIndex:rollup_record,priority:2;comment:库名"`
+	Table       string `gorm:"size:128;not null;uniqueIndex:rollup_record,priority:3;comment:表名"`
+	Location    string `gorm:"size:4000;comment:周期内最后一次记录的路径"`
+	// Days 周期内获取大小成功的天数，为 0 时大小均为 -1
+	Days     int   `gorm:"not null;comment:周期内获取大小成功的天数"`
+	AvgSize  int64 `gorm:"not null;comment:平均大小，单位 bytes"`
+	MaxSize  int64 `gorm:"not null;comment:最大大小，单位 bytes"`
+	LastSize int64 `gorm:"not null;comment:周期内最后一次获取成功的大小，单位 bytes"`
+	// Period 周期的第一天，按周汇总时为周一
+	Period time.Time `gorm:"type:date;uniqueIndex:rollup_record,priority:4;index:idx_hive_rollup_period;comment:周期的第一天"`
+}
+
+func (HiveRollup) TableName() string {
+	return "hive_rollup"
+}
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// HiveRun 一次运行的审计记录，包括各阶段的耗时和按类型统计的失败数量
+type HiveRun struct {
+	ID     int64     `gorm:"primaryKey;autoIncrement"`
+	RunID  string    `gorm:"size:36;not null;uniqueIndex:run_id;comment:运行 ID"`
+	Start  time.Time `gorm:"not null;comment:开始时间"`
+	End    time.Time `gorm:"not null;comment:结束时间"`
+	Status string    `gorm:"size:16;not null;comment:运行结果，succeeded 或 failed"`
+	Error  string    `gorm:"size:4096;comment:运行失败的原因"`
+	// Filter Shard 只抓取部分库、表或者一个分片时不为空
+	Filter          string  `gorm:"size:4096;comment:抓取的库或表"`
+	Shard           string  `gorm:"size:32;comment:抓取的分片，格式为 序号/总数"`
+	Tables          int     `gorm:"not null;comment:抓取的表的数量"`
+	Failures        int     `gorm:"not null;comment:获取大小失败的表的数量"`
+	Size            int64   `gorm:"not null;comment:抓取的表的总大小，单位 bytes"`
+	DurationSeconds float64 `gorm:"not null;comment:运行耗时，单位秒"`
+	TablesPerSecond float64 `gorm:"not null;comment:平均每秒抓取的表的数量"`
+	// HiveQueries HiveQuerySeconds HiveQueryMaxSeconds Hive 查询的次数、总耗时和最大耗时
+	HiveQueries         int     `gorm:"not null;comment:Hive 查询次数"`
+	HiveQuerySeconds    float64 `gorm:"not null;comment:Hive 查询总耗时，单位秒"`
+	HiveQueryMaxSeconds float64 `gorm:"not null;comment:Hive 查询最大耗时，单位秒"`
+	// HdfsCalls HdfsSeconds HdfsMaxSeconds 获取路径大小的 HDFS 请求次数、总耗时和最大耗时
+	HdfsCalls        int     `gorm:"not null;comment:HDFS 请求次数"`
+	HdfsSeconds      float64 `gorm:"not null;comment:HDFS 请求总耗时，单位秒"`
+	HdfsMaxSeconds   float64 `gorm:"not null;comment:HDFS 请求最大耗时，单位秒"`
+	SinkWriteSeconds float64 `gorm:"not null;comment:写入 sink 的耗时，单位秒"`
+	// FailureClasses 按类型统计的失败数量，JSON 对象，例如 {"hdfs_not_found": 3}
+	FailureClasses string    `gorm:"size:1024;comment:按类型统计的失败数量"`
+	Date           time.Time `gorm:"type:date;index:idx_hive_run_date;comment:统计日期"`
+	// CounterVersion BuildDate GoVersion 运行的 counter 版本、编译时间和 Go 版本，见 counter version
+	CounterVersion string `gorm:"size:64;comment:counter 版本"`
+	BuildDate      string `gorm:"size:32;comment:编译时间"`
+	GoVersion      string `gorm:"size:32;comment:Go 版本"`
+}
+
+func (HiveRun) TableName() string {
+	return "hive_run"
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// S3Usage 对象存储中一个桶或前缀在某一天的大小和对象数量
+type S3Usage struct {
+	ID     int64  `gorm:"primaryKey;autoIncrement"`
+	Bucket string `gorm:"size:128;not null;uniqueIndex:s3_usage_record,priority:1;comment:桶名"`
+	// Prefix 为空表示整个桶
+	Prefix  string `gorm:"size:512;not null;uniqueIndex:s3_usage_record,priority:2;comment:前缀"`
+	Size    int64  `gorm:"not null;comment:对象的总大小，单位 bytes，-1 表示获取失败"`
+	Objects int64  `gorm:"not null;comment:对象数量"`
+	Desc    string `gorm:"size:4096;not null;comment:备注"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:s3_usage_record,priority:3;index:idx_s3_usage_date;comment:统计日期"`
+}
+
+func (S3Usage) TableName() string {
+	return "s3_usage"
+}
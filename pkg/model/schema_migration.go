@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// SchemaMigration 结果数据库中已执行的迁移，见 sink.Migrations
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false;comment:迁移版本"`
+	Name      string    `gorm:"size:128;not null;comment:迁移名称"`
+	AppliedAt time.Time `gorm:"not null;comment:执行时间"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// 统计层级
+const (
+	LevelDb    = "db"
+	LevelTable = "table"
+)
+
+// HiveTrend 库或表在某一天之前一段时间内的增长速度
+type HiveTrend struct {
+	ID    int64  `gorm:"primaryKey;autoIncrement"`
+	Level string `gorm:"size:16;not null;uniqueIndex:trend_record,priority:1;comment:统计层级，db 或 table"`
+	Db    string `gorm:"size:128;not null;uniqueIndex:trend_record,priority:2;comment:库名"`
+	Table string `gorm:"size:128;not null;uniqueIndex:trend_record,priority:3;comment:表名，库级别时为空"`
+	// Window 统计窗口，单位天
+	Window int `gorm:"not null;uniqueIndex:trend_record,priority:4;comment:统计窗口，单位天"`
+	// Days 窗口内实际有数据的天数，历史数据不足时小于 Window
+	Days     int       `gorm:"not null;comment:窗口内实际覆盖的天数"`
+	BaseSize int64     `gorm:"not null;comment:窗口起始时的大小，单位 bytes"`
+	Size     int64     `gorm:"not null;comment:当天的大小，单位 bytes"`
+	Rate     float64   `gorm:"not null;comment:平均每天增长的大小，单位 bytes"`
+	Date     time.Time `gorm:"type:date;uniqueIndex:trend_record,priority:5;index:idx_hive_trend_date;comment:统计日期"`
+}
+
+func (HiveTrend) TableName() string {
+	return "hive_trend"
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// YarnQueue YARN 队列在某一天的资源使用，每次采样后更新平均值和最大值
+type YarnQueue struct {
+	ID    int64  `gorm:"primaryKey;autoIncrement"`
+	Queue string `gorm:"size:256;not null;uniqueIndex:yarn_queue_record,priority:1;comment:队列的完整路径，例如 root.default"`
+	// Samples 当天的采样次数
+	Samples int `gorm:"not null;comment:采样次数"`
+	// Capacity MaxCapacity UsedPercent 均为占整个集群的百分比
+	Capacity        float64 `gorm:"not null;comment:队列容量，占集群的百分比"`
+	MaxCapacity     float64 `gorm:"not null;comment:队列最大容量，占集群的百分比"`
+	AvgUsedPercent  float64 `gorm:"not null;comment:平均使用，占集群的百分比"`
+	MaxUsedPercent  float64 `gorm:"not null;comment:最大使用，占集群的百分比"`
+	AvgMemory       float64 `gorm:"not null;comment:平均使用的内存，单位 MB"`
+	MaxMemory       int64   `gorm:"not null;comment:最大使用的内存，单位 MB"`
+	AvgVcores       float64 `gorm:"not null;comment:平均使用的 vcore"`
+	MaxVcores       int64   `gorm:"not null;comment:最大使用的 vcore"`
+	MaxApplications int     `gorm:"not null;comment:最多同时运行的应用数量"`
+	// Date 统计日期
+	Date time.Time `gorm:"type:date;uniqueIndex:yarn_queue_record,priority:2;index:idx_yarn_queue_date;comment:统计日期"`
+}
+
+func (YarnQueue) TableName() string {
+	return "yarn_queue"
+}
@@ -0,0 +1,190 @@
+// Package plugin 通过外部程序实现 counter 的元数据来源、大小获取和 sink，不需要修改 counter 的代码
+//
+// 外部程序从 stdin 逐行读取请求，向 stdout 逐行写入响应，均为 JSON，stderr 默认直接输出到 counter 的 stderr。
+// 请求为 {"method": "...", "params": ...}，响应为 {"result": ...} 或者 {"error": "..."}，一个请求对应一个响应。
+//
+// 元数据来源的方法：
+//
+//	dbs       params 为空，result 为库名数组
+//	tables    params 为 {"db": "..."}，result 为表名数组
+//	location  params 为 {"db": "...", "table": "..."}，result 为表目录
+//
+// 大小获取的方法：
+//
+//	measure   params 为一条记录，result 为补充了 size、desc 和 extra 的记录
+//
+// sink 的方法：
+//
+//	open      params 为空，result 为空
+//	write     params 为 {"records": [...]}，result 为空
+//	close     params 为空，result 为空，响应后外部程序应当退出
+//
+// 记录的格式为 {"db", "table", "location", "size", "desc", "date", "extra", "run_id", "counter_version", "metadata_ms", "size_ms", "delta_bytes", "delta_pct"}，date 的格式为 2006-01-02。
+// stdin 关闭时外部程序应当退出。
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Command 外部程序的命令和额外的环境变量
+type Command struct {
+	Command []string          `yaml:"command"`
+	Env     map[string]string `yaml:"env"`
+	// Stderr 外部程序的 stderr，为空时输出到 counter 的 stderr
+	Stderr io.Writer `yaml:"-"`
+}
+
+func (c Command) Enabled() bool {
+	return len(c.Command) > 0
+}
+
+// Record 记录在外部程序中的 JSON 表示
+type Record struct {
+	Db       string      `json:"db"`
+	Table    string      `json:"table"`
+	Location string      `json:"location"`
+	Size     int64       `json:"size"`
+	Desc     string      `json:"desc"`
+	Date     string      `json:"date,omitempty"`
+	Extra    model.Extra `json:"extra,omitempty"`
+	// RunID CounterVersion MetadataMs SizeMs DeltaBytes DeltaPct 只在写入 sink 时存在
+	RunID          string   `json:"run_id,omitempty"`
+	CounterVersion string   `json:"counter_version,omitempty"`
+	MetadataMs     int64    `json:"metadata_ms,omitempty"`
+	SizeMs         int64    `json:"size_ms,omitempty"`
+	DeltaBytes     *int64   `json:"delta_bytes,omitempty"`
+	DeltaPct       *float64 `json:"delta_pct,omitempty"`
+}
+
+func newRecord(entity *model.Hive) Record {
+	record := Record{
+		Db:       entity.Db,
+		Table:    entity.Table,
+		Location: entity.Location,
+		Size:     entity.Size,
+		Desc:     entity.Desc,
+		Extra:    entity.Extra,
+
+		RunID:          entity.RunID,
+		CounterVersion: entity.CounterVersion,
+		MetadataMs:     entity.MetadataMs,
+		SizeMs:         entity.SizeMs,
+		DeltaBytes:     entity.DeltaBytes,
+		DeltaPct:       entity.DeltaPct,
+	}
+	if !entity.Date.IsZero() {
+		record.Date = entity.Date.Format("2006-01-02")
+	}
+	return record
+}
+
+type request struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+// Process 运行中的外部程序，请求串行发送
+type Process struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	mu     sync.Mutex
+}
+
+// Start 启动外部程序，ctx 取消时外部程序会被结束
+func Start(ctx context.Context, command Command) (*Process, error) {
+	if !command.Enabled() {
+		return nil, failure.Wrap(errors.New("plugin command is empty"))
+	}
+	cmd := exec.CommandContext(ctx, command.Command[0], command.Command[1:]...)
+	cmd.Stderr = command.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	cmd.Env = os.Environ()
+	for name, value := range command.Env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	return &Process{
+		name:   command.Command[0],
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}, nil
+}
+
+// Call 发送一个请求并将响应的 result 解析到 result 中，result 为 nil 时忽略响应的 result
+func (p *Process) Call(method string, params, result interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	line, err := json.Marshal(request{Method: method, Params: params})
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	errContext := failure.Context{"plugin": p.name, "method": method}
+	_, err = p.stdin.Write(append(line, '\n'))
+	if err != nil {
+		return failure.Wrap(err, errContext)
+	}
+	line, err = p.stdout.ReadBytes('\n')
+	if err != nil {
+		return failure.Wrap(err, errContext)
+	}
+	var resp response
+	err = json.Unmarshal(line, &resp)
+	if err != nil {
+		return failure.Wrap(fmt.Errorf("invalid response: %w", err), errContext)
+	}
+	if resp.Error != "" {
+		return failure.Wrap(errors.New(resp.Error), errContext)
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	return failure.Wrap(json.Unmarshal(resp.Result, result), errContext)
+}
+
+// Close 关闭 stdin 并等待外部程序退出，超过 10 秒未退出时结束外部程序
+func (p *Process) Close() error {
+	p.stdin.Close()
+	done := make(chan error, 1)
+	go func() { done <- p.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return failure.Wrap(err)
+	case <-time.After(10 * time.Second):
+		p.cmd.Process.Kill()
+		return failure.Wrap(fmt.Errorf("plugin %s did not exit: %w", p.name, <-done))
+	}
+}
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"context"
+	"github.com/rea1shane/counter/pkg/model"
+)
+
+// Sink 通过外部程序写入抓取结果，Open 时启动外部程序，Close 时等待外部程序退出
+type Sink struct {
+	command Command
+	process *Process
+}
+
+func NewSink(command Command) *Sink {
+	return &Sink{command: command}
+}
+
+func (s *Sink) Open(ctx context.Context) error {
+	p, err := Start(ctx, s.command)
+	if err != nil {
+		return err
+	}
+	s.process = p
+	err = p.Call("open", nil, nil)
+	if err != nil {
+		p.Close()
+		s.process = nil
+	}
+	return err
+}
+
+func (s *Sink) WriteBatch(ctx context.Context, entities []*model.Hive) error {
+	records := make([]Record, len(entities))
+	for i, entity := range entities {
+		records[i] = newRecord(entity)
+	}
+	return s.process.Call("write", map[string]interface{}{"records": records}, nil)
+}
+
+func (s *Sink) Close() error {
+	if s.process == nil {
+		return nil
+	}
+	err := s.process.Call("close", nil, nil)
+	closeErr := s.process.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
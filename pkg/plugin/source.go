@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"context"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+)
+
+// Source 通过外部程序获取库、表和表目录
+type Source struct {
+	*Process
+}
+
+func StartSource(ctx context.Context, command Command) (*Source, error) {
+	p, err := Start(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+	return &Source{Process: p}, nil
+}
+
+func (s *Source) Dbs(ctx context.Context) (dbs []string, err error) {
+	err = s.Call("dbs", nil, &dbs)
+	return
+}
+
+func (s *Source) Tables(ctx context.Context, db string) (tables []string, err error) {
+	err = s.Call("tables", map[string]string{"db": db}, &tables)
+	if err != nil {
+		err = failure.Wrap(err, failure.Context{"db": db})
+	}
+	return
+}
+
+func (s *Source) Location(ctx context.Context, db, table string) (location string, err error) {
+	err = s.Call("location", map[string]string{"db": db, "table": table}, &location)
+	if err != nil {
+		err = failure.Wrap(err, failure.Context{"db": db, "table": table})
+	}
+	return
+}
+
+// Sizer 通过外部程序获取表目录的大小
+type Sizer struct {
+	*Process
+}
+
+func StartSizer(ctx context.Context, command Command) (*Sizer, error) {
+	p, err := Start(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+	return &Sizer{Process: p}, nil
+}
+
+// Measure 外部程序返回的 size、desc 和 extra 覆盖 table 中的值，调用失败时 Size 为 -1，Desc 为错误信息
+func (s *Sizer) Measure(ctx context.Context, table *model.Hive) error {
+	var record Record
+	err := s.Call("measure", newRecord(table), &record)
+	if err != nil {
+		err = failure.Wrap(err, failure.Context{"db": table.Db, "table": table.Table, "location": table.Location})
+		table.Size = -1
+		table.Desc = err.Error()
+		return err
+	}
+	table.Size = record.Size
+	table.Desc = record.Desc
+	for key, value := range record.Extra {
+		table.SetExtra(key, value)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/internal/retry"
+	"github.com/rea1shane/counter/pkg/model"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+	"strings"
+	"time"
+)
+
+// upsert 同一天重复抓取时覆盖已有记录
+var upsert = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "db"}, {Name: "table"}, {Name: "date"}},
+	DoUpdates: clause.AssignmentColumns([]string{"location", "size", "desc", "extra", "run_id", "counter_version", "metadata_ms", "size_ms", "delta_bytes", "delta_pct"}),
+}
+
+// Pool 连接池的配置，为零值时使用 database/sql 的默认值
+type Pool struct {
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+// Gorm 通过 gorm 写入关系型数据库，打开后执行 Migrations 中未执行的迁移
+type Gorm struct {
+	// Logger gorm 的日志，为空时使用 gorm 默认的日志
+	Logger logger.Interface
+	// SkipMigrations 为 true 时 Open 不执行迁移，由 MigrateUp 单独执行
+	SkipMigrations bool
+	Pool           Pool
+	// Retry 写入遇到死锁、锁等待超时、连接断开等临时错误时的重试
+	Retry retry.Policy
+
+	dialector gorm.Dialector
+	db        *gorm.DB
+}
+
+// NewMysql 写入 MySQL
+func NewMysql(dsn string) *Gorm {
+	return &Gorm{dialector: mysql.Open(dsn)}
+}
+
+// NewPostgres 写入 PostgreSQL
+func NewPostgres(dsn string) *Gorm {
+	return &Gorm{dialector: postgres.Open(dsn)}
+}
+
+// NewSqlite 写入本地的 SQLite 文件，本地开发或小集群使用
+func NewSqlite(path string) *Gorm {
+	return &Gorm{dialector: sqlite.Open(path)}
+}
+
+func (s *Gorm) Open(ctx context.Context) error {
+	db, err := gorm.Open(s.dialector, &gorm.Config{Logger: s.Logger})
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	s.db = db
+	sqlDB, err := db.DB()
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	if s.Pool.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(s.Pool.MaxOpenConns)
+	}
+	if s.Pool.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(s.Pool.MaxIdleConns)
+	}
+	if s.Pool.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(s.Pool.ConnMaxLifetime)
+	}
+	if s.SkipMigrations {
+		return nil
+	}
+	_, err = s.MigrateUp(ctx)
+	return err
+}
+
+// Migrate 表不存在时建表，否则只补充缺少的字段和索引，不修改已有字段，避免大表上执行 ALTER
+func (s *Gorm) Migrate(ctx context.Context, models ...interface{}) error {
+	return migrate(s.db.WithContext(ctx), models...)
+}
+
+func migrate(db *gorm.DB, models ...interface{}) error {
+	migrator := db.Migrator()
+	for _, value := range models {
+		if !migrator.HasTable(value) {
+			err := migrator.CreateTable(value)
+			if err != nil {
+				return failure.Wrap(err)
+			}
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		err := stmt.Parse(value)
+		if err != nil {
+			return failure.Wrap(err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || migrator.HasColumn(value, field.DBName) {
+				continue
+			}
+			err = migrator.AddColumn(value, field.DBName)
+			if err != nil {
+				return failure.Wrap(err)
+			}
+		}
+		for name, index := range stmt.Schema.ParseIndexes() {
+			if migrator.HasIndex(value, name) {
+				continue
+			}
+			if index.Class == "UNIQUE" {
+				err = dedupe(db, stmt, index)
+				if err != nil {
+					return err
+				}
+			}
+			err = migrator.CreateIndex(value, name)
+			if err != nil {
+				return failure.Wrap(err)
+			}
+		}
+	}
+	return nil
+}
+
+// dedupe 在已有的表上补充唯一索引之前删除索引字段重复的行，每组只保留 ID 最大即最后写入的一行，
+// 否则建索引会失败。没有主键的表不处理。
+func dedupe(db *gorm.DB, stmt *gorm.Statement, index schema.Index) error {
+	primary := stmt.Schema.PrioritizedPrimaryField
+	if primary == nil {
+		return nil
+	}
+	columns := make([]string, len(index.Fields))
+	for i, field := range index.Fields {
+		columns[i] = stmt.Quote(field.DBName)
+	}
+	// MySQL 不允许在 DELETE 的子查询中直接读取同一张表，需要多包一层派生表
+	result := db.Exec(fmt.Sprintf(
+		"DELETE FROM %s WHERE %s NOT IN (SELECT id FROM (SELECT MAX(%s) AS id FROM %s GROUP BY %s) AS keep)",
+		stmt.Quote(stmt.Table), stmt.Quote(primary.DBName), stmt.Quote(primary.DBName), stmt.Quote(stmt.Table), strings.Join(columns, ", "),
+	))
+	if result.Error != nil {
+		return failure.Wrap(result.Error, failure.Context{"op": "dedupe", "table": stmt.Table, "index": index.Name})
+	}
+	if result.RowsAffected > 0 {
+		db.Logger.Warn(db.Statement.Context, "%s 创建唯一索引 %s 前删除了 %d 行重复数据", stmt.Table, index.Name, result.RowsAffected)
+	}
+	return nil
+}
+
+// WriteBatch 逐条 upsert，临时错误按 Retry 重试，其他错误立即返回
+func (s *Gorm) WriteBatch(ctx context.Context, records []*model.Hive) error {
+	for _, record := range records {
+		err := s.create(ctx, record)
+		if err != nil {
+			return failure.Wrap(err, failure.Context{"op": "write", "db": record.Db, "table": record.Table})
+		}
+	}
+	return nil
+}
+
+func (s *Gorm) create(ctx context.Context, record *model.Hive) error {
+	retrier := retry.Retrier{Policy: s.Retry, Retryable: isTransient}
+	return retrier.Do(ctx, func() error {
+		return s.db.WithContext(ctx).Clauses(upsert).Create(record).Error
+	})
+}
+
+// 可以重试的 MySQL 错误码
+const (
+	mysqlLockWaitTimeout = 1205
+	mysqlDeadlock        = 1213
+)
+
+// isTransient 判断写入错误是否为重试后可能成功的临时错误
+func isTransient(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDeadlock || mysqlErr.Number == mysqlLockWaitTimeout
+	}
+	return errors.Is(err, gomysql.ErrInvalidConn) || retry.Transient(err) || strings.Contains(err.Error(), "deadlock")
+}
+
+func (s *Gorm) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	db, err := s.db.DB()
+	if err != nil {
+		return failure.Wrap(err)
+	}
+	return failure.Wrap(db.Close())
+}
+
+// DB 返回底层连接，供 export 等子命令读取历史数据，需要先调用 Open
+func (s *Gorm) DB() *gorm.DB {
+	return s.db
+}
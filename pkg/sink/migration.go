@@ -0,0 +1,198 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Migration 结果数据库的一次结构变更，按 Version 从小到大执行，执行过的版本记录在 schema_migrations 表中
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(db *gorm.DB) error
+}
+
+// Migrations 内置的迁移，新增字段、索引时在末尾追加新的版本，已发布的迁移不再修改
+//
+// 每个版本只执行它引入的变更，使用的是当时的表结构，见 hiveV1 等，不依赖之后修改过的 model.Hive。
+// 版本 1 兼容引入迁移之前由 Open 自动建好的表，只补充缺少的字段和索引。
+var Migrations = []Migration{
+	{Version: 1, Name: "create hive", Up: func(db *gorm.DB) error {
+		return migrate(db, &hiveV1{})
+	}},
+	{Version: 2, Name: "add run_id and counter_version", Up: func(db *gorm.DB) error {
+		err := addColumns(db, &hiveV2{}, "RunID", "CounterVersion")
+		if err != nil {
+			return err
+		}
+		// 运行 ID 改为 UUID，加长 hive_run.run_id
+		migrator := db.Migrator()
+		if migrator.HasTable(&hiveRunV2{}) {
+			return failure.Wrap(migrator.AlterColumn(&hiveRunV2{}, "RunID"))
+		}
+		return nil
+	}},
+	{Version: 3, Name: "add metadata_ms and size_ms", Up: func(db *gorm.DB) error {
+		return addColumns(db, &hiveV3{}, "MetadataMs", "SizeMs")
+	}},
+	{Version: 4, Name: "add delta_bytes and delta_pct", Up: func(db *gorm.DB) error {
+		return addColumns(db, &hiveV4{}, "DeltaBytes", "DeltaPct")
+	}},
+	// 早期的建表语句中 record 是普通索引，HasIndex 认为已存在而不会改成唯一索引，upsert 无法生效。
+	// 唯一索引改名为 uniq_record，创建前先删除重复的行，见 dedupe；之后删除旧的 record 索引。
+	{Version: 5, Name: "add unique index uniq_record", Up: func(db *gorm.DB) error {
+		migrator := db.Migrator()
+		if !migrator.HasIndex(&hiveV5{}, "uniq_record") {
+			stmt := &gorm.Statement{DB: db}
+			err := stmt.Parse(&hiveV5{})
+			if err != nil {
+				return failure.Wrap(err)
+			}
+			err = dedupe(db, stmt, *stmt.Schema.LookIndex("uniq_record"))
+			if err != nil {
+				return err
+			}
+			err = migrator.CreateIndex(&hiveV5{}, "uniq_record")
+			if err != nil {
+				return failure.Wrap(err)
+			}
+		}
+		if migrator.HasIndex(&hiveV5{}, "record") {
+			return failure.Wrap(migrator.DropIndex(&hiveV5{}, "record"))
+		}
+		return nil
+	}},
+}
+
+// hiveV1 版本 1 的 hive 表
+type hiveV1 struct {
+	ID       int64       `gorm:"primaryKey;autoIncrement"`
+	Db       string      `gorm:"size:128;not null;uniqueIndex:record,priority:1;comment:库名"`
+	Table    string      `gorm:"size:128;not null;uniqueIndex:record,priority:2;comment:表名"`
+	Location string      `gorm:"size:4000;not null;comment:路径，为空代表没有路径"`
+	Size     int64       `gorm:"not null;comment:占用存储空间大小，单位 bytes，-1 表示没有路径或者获取路径错误"`
+	Desc     string      `gorm:"size:4096;not null;comment:备注"`
+	Date     time.Time   `gorm:"type:date;uniqueIndex:record,priority:3;index:idx_hive_date;comment:抓取数据时间"`
+	Extra    model.Extra `gorm:"comment:扩展属性"`
+}
+
+// hiveV2 版本 2 在 hive 表上新增的字段
+type hiveV2 struct {
+	RunID          string `gorm:"size:36;comment:运行 ID"`
+	CounterVersion string `gorm:"size:64;comment:counter 版本"`
+}
+
+// hiveRunV2 版本 2 加长的 hive_run.run_id
+type hiveRunV2 struct {
+	RunID string `gorm:"size:36;not null;comment:运行 ID"`
+}
+
+// hiveV3 版本 3 在 hive 表上新增的字段
+type hiveV3 struct {
+	MetadataMs int64 `gorm:"comment:获取元数据耗时，单位毫秒"`
+	SizeMs     int64 `gorm:"comment:获取大小耗时，单位毫秒"`
+}
+
+// hiveV4 版本 4 在 hive 表上新增的字段
+type hiveV4 struct {
+	DeltaBytes *int64   `gorm:"comment:与上一次抓取相比的变化，单位 bytes"`
+	DeltaPct   *float64 `gorm:"comment:与上一次抓取相比的变化，单位 %"`
+}
+
+// hiveV5 版本 5 的唯一索引，dedupe 需要主键
+type hiveV5 struct {
+	ID    int64     `gorm:"primaryKey;autoIncrement"`
+	Db    string    `gorm:"size:128;not null;uniqueIndex:uniq_record,priority:1"`
+	Table string    `gorm:"size:128;not null;uniqueIndex:uniq_record,priority:2"`
+	Date  time.Time `gorm:"type:date;uniqueIndex:uniq_record,priority:3"`
+}
+
+func (hiveV1) TableName() string    { return "hive" }
+func (hiveV2) TableName() string    { return "hive" }
+func (hiveRunV2) TableName() string { return "hive_run" }
+func (hiveV3) TableName() string    { return "hive" }
+func (hiveV4) TableName() string    { return "hive" }
+func (hiveV5) TableName() string    { return "hive" }
+
+// addColumns 在已有的表上补充字段，引入迁移之前由 Open 自动建表时可能已经存在
+func addColumns(db *gorm.DB, value interface{}, fields ...string) error {
+	migrator := db.Migrator()
+	for _, field := range fields {
+		if migrator.HasColumn(value, field) {
+			continue
+		}
+		err := migrator.AddColumn(value, field)
+		if err != nil {
+			return failure.Wrap(err, failure.Context{"op": "add column", "field": field})
+		}
+	}
+	return nil
+}
+
+// MigrationState 一个迁移的执行情况，未执行时 AppliedAt 为零值
+type MigrationState struct {
+	Migration
+	AppliedAt time.Time
+}
+
+func (s MigrationState) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// MigrationStatus 返回所有内置迁移的执行情况
+func (s *Gorm) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	db := s.db.WithContext(ctx)
+	err := migrate(db, &model.SchemaMigration{})
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []model.SchemaMigration
+	err = db.Find(&applied).Error
+	if err != nil {
+		return nil, failure.Wrap(err)
+	}
+	appliedAt := make(map[int]time.Time, len(applied))
+	for _, m := range applied {
+		appliedAt[m.Version] = m.AppliedAt
+	}
+
+	states := make([]MigrationState, len(Migrations))
+	for i, m := range Migrations {
+		states[i] = MigrationState{Migration: m, AppliedAt: appliedAt[m.Version]}
+	}
+	return states, nil
+}
+
+// MigrateUp 按版本执行所有未执行的迁移，返回本次执行的迁移，每个迁移和它的记录在同一个事务中写入，
+// 失败时停止，之前执行成功的迁移保留
+func (s *Gorm) MigrateUp(ctx context.Context) ([]Migration, error) {
+	states, err := s.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, state := range states {
+		if state.Applied() {
+			continue
+		}
+		m := state.Migration
+		err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			err := m.Up(tx)
+			if err != nil {
+				return err
+			}
+			return failure.Wrap(tx.Create(&model.SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error)
+		})
+		if err != nil {
+			return applied, failure.Wrap(err, failure.Context{"op": "migrate", "version": fmt.Sprint(m.Version), "name": m.Name})
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
@@ -0,0 +1,89 @@
+package sink
+
+import (
+	"context"
+	"github.com/rea1shane/counter/pkg/model"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openSqlite(t *testing.T, skipMigrations bool) *Gorm {
+	s := NewSqlite(filepath.Join(t.TempDir(), "counter.db"))
+	s.SkipMigrations = skipMigrations
+	err := s.Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestMigrationOnlyAppliesItsOwnChange(t *testing.T) {
+	s := openSqlite(t, true)
+	err := s.DB().Transaction(Migrations[0].Up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator := s.DB().Migrator()
+	if !migrator.HasColumn(&model.Hive{}, "extra") || !migrator.HasIndex(&model.Hive{}, "record") {
+		t.Error("version 1 did not create the version 1 hive table")
+	}
+	for _, column := range []string{"run_id", "metadata_ms", "delta_bytes"} {
+		if migrator.HasColumn(&model.Hive{}, column) {
+			t.Errorf("version 1 added %s", column)
+		}
+	}
+}
+
+func TestMigrateUpLegacyTable(t *testing.T) {
+	s := openSqlite(t, true)
+	db := s.DB()
+	// 引入迁移之前由 Open 自动建好的表，record 索引还没有建
+	err := db.Migrator().CreateTable(&hiveV1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Migrator().DropIndex(&hiveV1{}, "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	for _, size := range []int64{1, 2} {
+		err = db.Create(&hiveV1{Db: "ods", Table: "orders", Size: size, Date: date}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	applied, err := s.MigrateUp(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(Migrations) {
+		t.Errorf("applied %d migrations, want %d", len(applied), len(Migrations))
+	}
+	migrator := db.Migrator()
+	stmt := &gorm.Statement{DB: db}
+	err = stmt.Parse(&model.Hive{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range stmt.Schema.Fields {
+		if !migrator.HasColumn(&model.Hive{}, field.DBName) {
+			t.Errorf("missing column %s", field.DBName)
+		}
+	}
+	if !migrator.HasIndex(&model.Hive{}, "uniq_record") || migrator.HasIndex(&model.Hive{}, "record") {
+		t.Error("want uniq_record instead of record")
+	}
+	var rows []model.Hive
+	err = db.Find(&rows).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Size != 2 {
+		t.Errorf("rows = %+v, want the last written row only", rows)
+	}
+}
@@ -0,0 +1,68 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/morikuni/failure"
+	"github.com/rea1shane/counter/pkg/model"
+	"sort"
+	"sync"
+)
+
+// Sink 抓取结果的写入目标
+//
+// 一次运行中 Open 和 Close 各调用一次，WriteBatch 可能被调用多次，写入失败时可能以同一批数据重试。
+type Sink interface {
+	Open(ctx context.Context) error
+	WriteBatch(ctx context.Context, records []*model.Hive) error
+	Close() error
+}
+
+// Options sink 在配置文件中的自定义配置，*yaml.Node 满足该接口
+type Options interface {
+	Decode(v interface{}) error
+}
+
+// Factory 创建 Sink，没有自定义配置时 options 为 nil
+type Factory func(options Options) (Sink, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register 注册一种 sink，通常在 init 中调用，名称重复时 panic
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("sink: Register factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("sink: Register called twice for sink " + name)
+	}
+	factories[name] = factory
+}
+
+// New 根据名称创建已注册的 sink
+func New(name string, options Options) (Sink, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, failure.Wrap(fmt.Errorf("unknown sink type: %s", name))
+	}
+	return factory(options)
+}
+
+// Names 返回所有已注册的 sink 名称
+func Names() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}